@@ -1,6 +1,11 @@
 package arc
 
 import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
 	"github.com/jiaxwu/gommon/cache"
 	"github.com/jiaxwu/gommon/math"
 
@@ -8,6 +13,22 @@ import (
 	"github.com/jiaxwu/gommon/cache/lru"
 )
 
+const (
+	// 快照中的缓存策略名称
+	snapshotPolicy = "arc"
+	// 内部使用的lru和lfu的快照策略名称
+	lruSnapshotPolicy = "lru"
+	lfuSnapshotPolicy = "lfu"
+)
+
+// 快照中元素所在的部分
+const (
+	segmentLRUEvict uint8 = iota // 从LRUCache淘汰的记录
+	segmentLFUEvict              // 从LFUCache淘汰的记录
+	segmentLRUCache              // 只访问过一次的元素
+	segmentLFUCache              // 访问过多次的元素
+)
+
 // 结合LRU和LFU，根据负载动态调整LRU和LFU容量
 // 优点：集合LRU和LFU优点
 // 缺点：比较耗空间
@@ -18,9 +39,17 @@ type Cache[K comparable, V any] struct {
 	lfuCache *lfu.Cache[K, V]
 	lfuEvict *lfu.Cache[K, V]
 	// 表示有多偏向LRU
-	preferLRU int
-	capacity  int
-	onEvict   cache.OnEvict[K, V]
+	preferLRU  int
+	capacity   int
+	weigher    cache.Weigher[K, V] // 计算元素权重，为空则每个元素权重为1
+	ttl        time.Duration       // 默认过期时间
+	onEvict    cache.OnEvictWithReason[K, V]
+	pins       map[K]int            // 被固定的元素的固定次数，在LRU和LFU之间移动时保持不变
+	canEvict   cache.CanEvict[K, V] // 判断元素能否被淘汰，为空表示都可以
+	janitor    *cache.Janitor[K]
+	stats      *cache.StatsCounter // 统计，为空表示不统计
+	keyCodec   cache.Codec[K]      // 保存快照时Key的编解码器
+	valueCodec cache.Codec[V]      // 保存快照时Value的编解码器
 }
 
 func New[K comparable, V any](capacity int) *Cache[K, V] {
@@ -33,89 +62,231 @@ func New[K comparable, V any](capacity int) *Cache[K, V] {
 		lfuCache: lfu.New[K, V](capacity),
 		lfuEvict: lfu.New[K, V](capacity),
 		capacity: capacity,
+		pins:     make(map[K]int),
 	}
+	c.lruCache.SetCanEvict(c.evictable)
+	c.lruCache.SetOnEvictWithReason(c.doOnEvict)
+	c.lfuCache.SetOnEvictWithReason(c.doOnEvict)
 	return c
 }
 
 // 设置 OnEvict
 func (c *Cache[K, V]) SetOnEvict(onEvict cache.OnEvict[K, V]) {
+	c.onEvict = onEvict.WithReason()
+}
+
+// 设置 OnEvict，带上淘汰原因
+func (c *Cache[K, V]) SetOnEvictWithReason(onEvict cache.OnEvictWithReason[K, V]) {
 	c.onEvict = onEvict
 }
 
+// 设置 CanEvict，返回false的元素淘汰时会被跳过
+func (c *Cache[K, V]) SetCanEvict(canEvict cache.CanEvict[K, V]) {
+	c.canEvict = canEvict
+}
+
+// 固定元素，固定的元素不会被淘汰，可以固定多次，需要相同次数的 Unpin()
+// 不存在返回false
+func (c *Cache[K, V]) Pin(key K) bool {
+	if !c.Contains(key) {
+		return false
+	}
+	c.pins[key]++
+	return true
+}
+
+// 解除一次固定，完全解除后如果超过容量则淘汰元素
+// 没有固定返回false
+func (c *Cache[K, V]) Unpin(key K) bool {
+	n, ok := c.pins[key]
+	if !ok {
+		return false
+	}
+	if n > 1 {
+		c.pins[key] = n - 1
+		return true
+	}
+	delete(c.pins, key)
+	c.evictToFit(0, false)
+	return true
+}
+
+// 元素是否被固定
+func (c *Cache[K, V]) Pinned(key K) bool {
+	return c.pins[key] > 0
+}
+
+// 设置权重计算函数，设置后容量表示总权重，而不是元素个数
+func (c *Cache[K, V]) SetWeigher(weigher cache.Weigher[K, V]) {
+	c.weigher = weigher
+	c.lruCache.SetWeigher(weigher)
+	c.lfuCache.SetWeigher(weigher)
+	c.evictToFit(0, false)
+}
+
+// 设置统计计数器，为空表示不统计
+func (c *Cache[K, V]) SetStatsCounter(stats *cache.StatsCounter) {
+	c.stats = stats
+}
+
+// 获取统计信息
+func (c *Cache[K, V]) Stats() cache.Stats {
+	return c.stats.Snapshot()
+}
+
+// 设置默认过期时间，Put()会使用该过期时间，小于等于0表示永不过期
+func (c *Cache[K, V]) SetDefaultTTL(ttl time.Duration) {
+	c.ttl = ttl
+}
+
+// 启动过期清理器，到期主动删除元素，直到ctx被关闭
+// locker是业务访问缓存时使用的锁
+// 只会清理启动之后添加的元素，之前添加的元素依然在访问时删除
+func (c *Cache[K, V]) StartJanitor(ctx context.Context, locker sync.Locker) {
+	c.janitor = cache.NewJanitor(locker, c.RemoveExpired)
+	go c.janitor.Run(ctx)
+}
+
 // 添加或更新元素
 // 返回被淘汰的元素
 func (c *Cache[K, V]) Put(key K, value V) *cache.Entry[K, V] {
+	return c.PutWithTTL(key, value, c.ttl)
+}
+
+// 添加或更新元素，并设置过期时间，小于等于0表示永不过期
+// 返回被淘汰的元素
+func (c *Cache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) *cache.Entry[K, V] {
+	return c.PutWithExpiration(key, value, cache.Expiration(ttl))
+}
+
+// 添加或更新元素，并设置过期时刻，零值表示永不过期
+// 返回被淘汰的元素，如果淘汰了多个元素，返回最后一个
+// 如果元素权重超过容量，或者剩余的元素都被固定放不下，则不会添加，直接返回该元素，可以通过 TryPut() 区分
+// 更新已经存在的元素时，如果剩余的元素都被固定，允许暂时超过容量
+func (c *Cache[K, V]) PutWithExpiration(key K, value V, expiration time.Time) *cache.Entry[K, V] {
+	evicted, err := c.tryPut(key, value, expiration)
+	if err != nil {
+		return &cache.Entry[K, V]{
+			Key:        key,
+			Value:      value,
+			Expiration: expiration,
+		}
+	}
+	return evicted
+}
+
+// 添加或更新元素，拒绝添加时返回错误
+// 返回被淘汰的元素，如果淘汰了多个元素，返回最后一个
+// 元素权重超过容量返回 cache.ErrTooLarge，剩余的元素都被固定放不下返回 cache.ErrAllPinned
+// 拒绝添加时不会淘汰任何元素，已经存在的旧值保持不变
+func (c *Cache[K, V]) TryPut(key K, value V) (*cache.Entry[K, V], error) {
+	return c.tryPut(key, value, cache.Expiration(c.ttl))
+}
+
+// 添加或更新元素，拒绝添加时返回错误
+func (c *Cache[K, V]) tryPut(key K, value V, expiration time.Time) (*cache.Entry[K, V], error) {
+	weight := c.weigh(key, value)
+	if weight > int64(c.capacity) {
+		return nil, cache.ErrTooLarge
+	}
+
+	// 先删除过期的旧元素，避免同一个Key同时出现在LRUCache和LFUCache
+	c.RemoveExpired(key)
+	if c.janitor != nil {
+		c.janitor.Push(key, expiration)
+	}
+
 	// 如果存在LRUCache，则移动到LFUCache
 	if c.lruCache.Contains(key) {
 		c.lruCache.Remove(key)
-		return c.lfuCache.Put(key, value)
+		evicted := c.evictToFit(weight, false)
+		c.lfuCache.PutWithExpiration(key, value, expiration)
+		c.stats.RecordUpdate()
+		return evicted, nil
 	}
 
 	// 如果存在LFUCache，则更新
 	if c.lfuCache.Contains(key) {
-		return c.lfuCache.Put(key, value)
+		c.lfuCache.PutWithExpiration(key, value, expiration)
+		c.stats.RecordUpdate()
+		// 权重变大可能需要淘汰元素
+		return c.evictToFit(0, false), nil
+	}
+
+	// 剩余的元素都被固定放不下，直接拒绝，避免白白淘汰元素
+	if !c.canFit(weight) {
+		return nil, cache.ErrAllPinned
 	}
 
 	// 如果存在LRUEvict，则增加LRUCache的权重
 	if c.lruEvict.Contains(key) {
 		// 不超过容量，每次最少增加1
 		c.preferLRU = math.Min(c.Cap(), c.preferLRU+math.Max(c.lfuEvict.Len()/c.lruEvict.Len(), 1))
-		if c.Full() {
-			c.evict(false)
-		}
+		evicted := c.evictToFit(weight, false)
 
 		// 移动到LFUCache
 		c.lruEvict.Remove(key)
-		return c.lfuCache.Put(key, value)
+		c.lfuCache.PutWithExpiration(key, value, expiration)
+		c.stats.RecordPut()
+		return evicted, nil
 	}
 
 	// 如果存在LFUEvict，则减少LRUCache的权重
 	if c.lfuEvict.Contains(key) {
 		// 不超过容量，每次最少增加1
 		c.preferLRU = math.Min(c.Cap(), c.preferLRU-math.Max(c.lruEvict.Len()/c.lfuEvict.Len(), 1))
-		if c.Full() {
-			c.evict(true)
-		}
+		evicted := c.evictToFit(weight, true)
 
 		// 移动到LFUCache
 		c.lfuEvict.Remove(key)
-		return c.lfuCache.Put(key, value)
+		c.lfuCache.PutWithExpiration(key, value, expiration)
+		c.stats.RecordPut()
+		return evicted, nil
 	}
 
-	// 如果已经到达最大尺寸，先剔除一个元素
-	if c.Full() {
-		c.evict(false)
-	}
+	// 如果放不下，先剔除元素
+	evicted := c.evictToFit(weight, false)
 
 	if c.lruEvict.Len() > c.Cap()-c.preferLRU {
 		entry := c.lruEvict.Evict()
 		c.lruEvict.Put(entry.Key, entry.Value)
-		c.doOnEvict(entry.Key, entry.Value)
+		c.doOnEvict(entry, cache.EvictReasonCapacity)
 	}
 	if c.lfuEvict.Len() > c.preferLRU {
 		entry := c.lfuEvict.Evict()
 		c.lfuEvict.Put(entry.Key, entry.Value)
-		c.doOnEvict(entry.Key, entry.Value)
+		c.doOnEvict(entry, cache.EvictReasonCapacity)
 	}
 
 	// 添加到LRUCache
-	return c.lruCache.Put(key, value)
+	c.lruCache.PutWithExpiration(key, value, expiration)
+	c.stats.RecordPut()
+	return evicted, nil
 }
 
 // 获取元素
 func (c *Cache[K, V]) Get(key K) (V, bool) {
 	// 如果存在LRUCache，则移动到LFUCache
-	if value, ok := c.lruCache.Peek(key); ok {
+	if entry, ok := c.lruCache.PeekEntry(key); ok {
 		c.lruCache.Remove(key)
-		c.lfuCache.Put(key, value)
-		return value, true
+		c.lfuCache.PutWithExpiration(key, entry.Value, entry.Expiration)
+		c.stats.RecordHit()
+		return entry.Value, true
+	}
+	// 过期了直接删除
+	if c.lruCache.RemoveExpired(key) {
+		c.stats.RecordMiss()
+		var value V
+		return value, false
 	}
 	// 如果存在LFUCache
 	if value, ok := c.lfuCache.Get(key); ok {
+		c.stats.RecordHit()
 		return value, true
 	}
 
 	// 不存在返回空值和false
+	c.stats.RecordMiss()
 	var value V
 	return value, false
 }
@@ -141,6 +312,7 @@ func (c *Cache[K, V]) Contains(key K) bool {
 }
 
 // 获取缓存的Keys
+// 可能包含已经过期但还没被删除的元素
 func (c *Cache[K, V]) Keys() []K {
 	return append(c.lruCache.Keys(), c.lfuCache.Keys()...)
 }
@@ -155,8 +327,23 @@ func (c *Cache[K, V]) Entries() []*cache.Entry[K, V] {
 	return append(c.lruCache.Entries(), c.lfuCache.Entries()...)
 }
 
+// 按淘汰顺序，先LRU部分再LFU部分遍历元素，f返回false时停止，不更新状态，不分配内存
+// 可能包含已经过期但还没被删除的元素
+// 遍历期间可以删除当前元素，其他修改的结果是未定义的
+func (c *Cache[K, V]) Range(f func(key K, value V) bool) {
+	stop := false
+	c.lruCache.Range(func(key K, value V) bool {
+		stop = !f(key, value)
+		return !stop
+	})
+	if !stop {
+		c.lfuCache.Range(f)
+	}
+}
+
 // 移除元素
 func (c *Cache[K, V]) Remove(key K) bool {
+	delete(c.pins, key)
 	if c.lruCache.Remove(key) {
 		return true
 	}
@@ -172,12 +359,18 @@ func (c *Cache[K, V]) Remove(key K) bool {
 	return false
 }
 
+// 移除已经过期的元素
+func (c *Cache[K, V]) RemoveExpired(key K) bool {
+	return c.lruCache.RemoveExpired(key) || c.lfuCache.RemoveExpired(key)
+}
+
 // 清空缓存
 func (c *Cache[K, V]) Clear(needOnEvict bool) {
 	c.lruCache.Clear(needOnEvict)
 	c.lruEvict.Clear(needOnEvict)
 	c.lfuCache.Clear(needOnEvict)
 	c.lfuEvict.Clear(needOnEvict)
+	c.pins = make(map[K]int)
 }
 
 // 元素个数
@@ -190,27 +383,189 @@ func (c *Cache[K, V]) Cap() int {
 	return c.capacity
 }
 
+// 当前总权重，没有设置权重计算函数时等于元素个数
+func (c *Cache[K, V]) Cost() int64 {
+	return c.lruCache.Cost() + c.lfuCache.Cost()
+}
+
 // 缓存满了
 func (c *Cache[K, V]) Full() bool {
-	return c.Len() >= c.Cap()
+	return c.Cost() >= int64(c.Cap())
+}
+
+// 设置保存快照时使用的编解码器，为空使用GobCodec
+func (c *Cache[K, V]) SetCodec(keyCodec cache.Codec[K], valueCodec cache.Codec[V]) {
+	c.keyCodec = keyCodec
+	c.valueCodec = valueCodec
+}
+
+// 保存快照到w，包括元素所在的LRU和LFU部分、淘汰记录和偏向LRU的程度
+func (c *Cache[K, V]) Save(w io.Writer) error {
+	return c.Snapshot().Encode(w, c.keyCodec, c.valueCodec)
+}
+
+// 从r加载快照，会先清空缓存，不触发回调
+// 快照格式不正确时缓存保持不变
+func (c *Cache[K, V]) Load(r io.Reader) error {
+	snapshot, err := cache.DecodeSnapshot(r, snapshotPolicy, c.keyCodec, c.valueCodec)
+	if err != nil {
+		return err
+	}
+	return c.Restore(snapshot)
+}
+
+// 获取快照，依次是LRUEvict、LFUEvict、LRUCache、LFUCache，每部分按淘汰顺序排列
+func (c *Cache[K, V]) Snapshot() *cache.Snapshot[K, V] {
+	snapshot := &cache.Snapshot[K, V]{
+		Policy: snapshotPolicy,
+		Meta:   []int64{int64(c.preferLRU)},
+	}
+	parts := []*cache.Snapshot[K, V]{
+		segmentLRUEvict: c.lruEvict.Snapshot(),
+		segmentLFUEvict: c.lfuEvict.Snapshot(),
+		segmentLRUCache: c.lruCache.Snapshot(),
+		segmentLFUCache: c.lfuCache.Snapshot(),
+	}
+	for segment, part := range parts {
+		for _, record := range part.Records {
+			record.Segment = uint8(segment)
+			snapshot.Records = append(snapshot.Records, record)
+		}
+	}
+	return snapshot
+}
+
+// 从快照恢复，会先清空缓存，不触发回调
+// 跳过已经过期的元素，放不下的元素按淘汰顺序淘汰
+func (c *Cache[K, V]) Restore(snapshot *cache.Snapshot[K, V]) error {
+	if snapshot.Policy != snapshotPolicy {
+		return cache.ErrSnapshotPolicyMismatch
+	}
+	parts := []*cache.Snapshot[K, V]{
+		segmentLRUEvict: {Policy: lruSnapshotPolicy},
+		segmentLFUEvict: {Policy: lfuSnapshotPolicy},
+		segmentLRUCache: {Policy: lruSnapshotPolicy},
+		segmentLFUCache: {Policy: lfuSnapshotPolicy},
+	}
+	for _, record := range snapshot.Records {
+		if int(record.Segment) >= len(parts) {
+			return cache.ErrInvalidSnapshot
+		}
+		parts[record.Segment].Records = append(parts[record.Segment].Records, record)
+	}
+
+	c.preferLRU = 0
+	if len(snapshot.Meta) > 0 {
+		c.preferLRU = math.Min(c.Cap(), int(snapshot.Meta[0]))
+	}
+	c.lruEvict.Restore(parts[segmentLRUEvict])
+	c.lfuEvict.Restore(parts[segmentLFUEvict])
+	c.lruCache.Restore(parts[segmentLRUCache])
+	c.lfuCache.Restore(parts[segmentLFUCache])
+	c.evictToFit(0, false)
+	return nil
 }
 
 // 淘汰元素
 // lfuEvictContainsKey: 如果lfuEvict包含key，则先从lruCache淘汰
 func (c *Cache[K, V]) evict(lfuEvictContainsKey bool) *cache.Entry[K, V] {
-	lruCacheLen := c.lruCache.Len()
-	if lruCacheLen > 0 && (lruCacheLen > c.preferLRU || (lruCacheLen == c.preferLRU && lfuEvictContainsKey)) {
-		return c.lruCache.Evict()
-	} else {
-		return c.lfuCache.Evict()
+	lruCacheCost, preferLRU := c.lruCache.Cost(), int64(c.preferLRU)
+	// 优先的一边都不能淘汰时淘汰另一边
+	if lruCacheCost > 0 && (lruCacheCost > preferLRU || (lruCacheCost == preferLRU && lfuEvictContainsKey)) {
+		if entry := c.lruCache.Evict(); entry != nil {
+			return entry
+		}
+		return c.evictLFU()
+	}
+	if entry := c.evictLFU(); entry != nil {
+		return entry
+	}
+	return c.lruCache.Evict()
+}
+
+// 淘汰可以淘汰的元素后能否放下给定权重的元素
+func (c *Cache[K, V]) canFit(weight int64) bool {
+	need := c.Cost() + weight - int64(c.capacity)
+	if need <= 0 {
+		return true
+	}
+	c.lruCache.Range(func(key K, value V) bool {
+		// 过期的元素也可以淘汰
+		if entry, ok := c.lruCache.PeekEntry(key); !ok || c.evictable(entry) {
+			need -= c.weigh(key, value)
+		}
+		return need > 0
+	})
+	c.lfuCache.Range(func(key K, value V) bool {
+		if need <= 0 {
+			return false
+		}
+		if entry, ok := c.lfuCache.PeekEntry(key); !ok || c.evictable(entry) {
+			need -= c.weigh(key, value)
+		}
+		return true
+	})
+	return need <= 0
+}
+
+// 从LFUCache淘汰一个可以淘汰的元素，都不能淘汰返回nil
+// lfu淘汰时不能跳过元素，所以按淘汰顺序找到第一个可以淘汰的元素再删除
+func (c *Cache[K, V]) evictLFU() *cache.Entry[K, V] {
+	var evicted *cache.Entry[K, V]
+	c.lfuCache.Range(func(key K, value V) bool {
+		entry, ok := c.lfuCache.PeekEntry(key)
+		// 过期的元素直接删除，会触发回调
+		if !ok {
+			c.lfuCache.RemoveExpired(key)
+			evicted = &cache.Entry[K, V]{Key: key, Value: value}
+			return false
+		}
+		if !c.evictable(entry) {
+			return true
+		}
+		c.lfuCache.Remove(key)
+		c.doOnEvict(entry, cache.EvictReasonCapacity)
+		evicted = entry
+		return false
+	})
+	return evicted
+}
+
+// 淘汰元素直到能放下给定权重的元素
+// 返回最后一个被淘汰的元素
+func (c *Cache[K, V]) evictToFit(weight int64, lfuEvictContainsKey bool) *cache.Entry[K, V] {
+	var evicted *cache.Entry[K, V]
+	for c.Len() > 0 && c.Cost()+weight > int64(c.capacity) {
+		entry := c.evict(lfuEvictContainsKey)
+		// 剩下的元素都不能淘汰
+		if entry == nil {
+			break
+		}
+		evicted = entry
 	}
+	return evicted
 }
 
-func (c *Cache[K, V]) doOnEvict(key K, value V) {
+// 计算元素权重
+func (c *Cache[K, V]) weigh(key K, value V) int64 {
+	if c.weigher == nil {
+		return 1
+	}
+	return c.weigher(key, value)
+}
+
+// 元素能否被淘汰
+func (c *Cache[K, V]) evictable(entry *cache.Entry[K, V]) bool {
+	return c.pins[entry.Key] == 0 && (c.canEvict == nil || c.canEvict(entry))
+}
+
+// 触发淘汰回调
+func (c *Cache[K, V]) doOnEvict(entry *cache.Entry[K, V], reason cache.EvictReason) {
+	if !c.Contains(entry.Key) {
+		delete(c.pins, entry.Key)
+	}
+	c.stats.RecordEviction(reason)
 	if c.onEvict != nil {
-		c.onEvict(&cache.Entry[K, V]{
-			Key:   key,
-			Value: value,
-		})
+		c.onEvict(entry, reason)
 	}
 }
@@ -0,0 +1,176 @@
+package bytecache
+
+import (
+	"hash/maphash"
+	"math"
+	"time"
+
+	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/hash"
+	mathx "github.com/jiaxwu/gommon/math"
+	"github.com/jiaxwu/gommon/pool"
+)
+
+// 编码记录使用的缓冲区初始容量
+const bufferSize = 1024
+
+// 字节缓存
+// 适合保存几千万个序列化后的元素，Value保存在预先分配的大块[]byte里，索引不包含指针，几乎没有GC开销
+// 每个分片是一个环形缓冲区，写满后按写入顺序覆盖最早的元素（FIFO）
+// 删除和更新不会立即释放空间，旧记录等待被覆盖
+// 线程安全
+type Cache struct {
+	shards  []*shard
+	mask    uint64
+	seed    maphash.Seed
+	hashes  *pool.Pool[*hash.Hash] // 哈希函数非线程安全，每次使用从池里取一个
+	buffers *pool.BytePool         // 编码记录使用的缓冲区
+	ttl     time.Duration          // 默认过期时间
+	stats   *cache.StatsCounter
+}
+
+// capacity：总字节数，会平均分到每个分片，每个分片最多4GB
+// shards：分片数量，会向上取整到2的幂
+func New(capacity, shards int) *Cache {
+	if shards < 1 {
+		panic("too small shards")
+	}
+	shardCnt := mathx.RoundUpPowOf2(uint(shards))
+	shardCap := capacity / int(shardCnt)
+	if shardCap < headerSize {
+		panic("too small capacity")
+	}
+	if uint64(shardCap) > math.MaxUint32 {
+		panic("too large capacity")
+	}
+	c := &Cache{
+		shards:  make([]*shard, shardCnt),
+		mask:    uint64(shardCnt - 1),
+		seed:    maphash.MakeSeed(),
+		buffers: pool.NewBytePool(0, bufferSize),
+	}
+	c.hashes = pool.New(func() *hash.Hash {
+		return hash.NewWithSeed(c.seed)
+	}, nil)
+	for i := range c.shards {
+		c.shards[i] = newShard(shardCap)
+	}
+	return c
+}
+
+// 设置默认过期时间，小于等于0表示永不过期
+func (c *Cache) SetDefaultTTL(ttl time.Duration) {
+	c.ttl = ttl
+}
+
+// 设置统计计数器，为空表示不统计
+// 需要在使用前设置
+func (c *Cache) SetStatsCounter(stats *cache.StatsCounter) {
+	for _, s := range c.shards {
+		s.mutex.Lock()
+		s.stats = stats
+		s.mutex.Unlock()
+	}
+	c.stats = stats
+}
+
+// 获取统计信息
+func (c *Cache) Stats() cache.Stats {
+	return c.stats.Snapshot()
+}
+
+// 添加或更新元素
+// 空间不够时覆盖最早的元素，被覆盖的元素不会返回
+// 如果元素超过分片容量或者Key太长，则不会添加，直接返回该元素
+func (c *Cache) Put(key string, value []byte) *cache.Entry[string, []byte] {
+	return c.PutWithTTL(key, value, c.ttl)
+}
+
+// 添加或更新元素，并设置过期时间，小于等于0表示永不过期
+func (c *Cache) PutWithTTL(key string, value []byte, ttl time.Duration) *cache.Entry[string, []byte] {
+	expiration := cache.Expiration(ttl)
+	s, h := c.shard(key)
+	if len(key) > maxKeyLen || headerSize+len(key)+len(value) > len(s.buf) {
+		c.Remove(key)
+		return &cache.Entry[string, []byte]{
+			Key:        key,
+			Value:      value,
+			Expiration: expiration,
+		}
+	}
+
+	// 在锁外编码记录，锁内只需要复制
+	var nanos int64
+	if !expiration.IsZero() {
+		nanos = expiration.UnixNano()
+	}
+	record := appendRecord(c.buffers.Get(), h, nanos, key, value)
+	s.put(h, record)
+	c.buffers.Put(record)
+	return nil
+}
+
+// 获取元素，返回Value的副本
+func (c *Cache) Get(key string) ([]byte, bool) {
+	return c.GetAppend(nil, key)
+}
+
+// 获取元素，Value追加到dst后返回，可以复用dst避免分配内存
+func (c *Cache) GetAppend(dst []byte, key string) ([]byte, bool) {
+	s, h := c.shard(key)
+	s.mutex.RLock()
+	record := s.get(h, key)
+	if record != nil {
+		dst = append(dst, recordValue(record)...)
+	}
+	s.mutex.RUnlock()
+	if record == nil {
+		c.stats.RecordMiss()
+		return dst, false
+	}
+	c.stats.RecordHit()
+	return dst, true
+}
+
+// 是否包含元素
+func (c *Cache) Contains(key string) bool {
+	s, h := c.shard(key)
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.get(h, key) != nil
+}
+
+// 移除元素
+func (c *Cache) Remove(key string) bool {
+	s, h := c.shard(key)
+	return s.remove(h, key)
+}
+
+// 元素个数，包括过期但还没被覆盖的元素
+func (c *Cache) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		n += s.len()
+	}
+	return n
+}
+
+// 容量，所有分片的字节数之和
+func (c *Cache) Cap() int {
+	return len(c.shards) * len(c.shards[0].buf)
+}
+
+// 清空缓存
+func (c *Cache) Clear() {
+	for _, s := range c.shards {
+		s.clear()
+	}
+}
+
+// 计算哈希值并获取所在的分片
+func (c *Cache) shard(key string) (*shard, uint64) {
+	hash := c.hashes.Get()
+	h := hash.Sum64String(key)
+	c.hashes.Put(hash)
+	return c.shards[h&c.mask], h
+}
@@ -0,0 +1,194 @@
+package bytecache
+
+import (
+	"bytes"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/cache/cachetest"
+)
+
+func TestCache_Put(t *testing.T) {
+	c := New(1024, 4)
+	c.Put("11", []byte("5"))
+	c.Put("22", []byte("6"))
+	value, ok := c.Get("11")
+	if !ok || string(value) != "5" {
+		t.Errorf("Get() = %s, want %s", value, "5")
+	}
+	c.Put("11", []byte("55"))
+	value, ok = c.Get("11")
+	if !ok || string(value) != "55" {
+		t.Errorf("Get() = %s, want %s", value, "55")
+	}
+	if _, ok := c.Get("33"); ok {
+		t.Errorf("Get() = %v, want %v", ok, false)
+	}
+	if c.Len() != 2 || c.Cap() != 1024 {
+		t.Errorf("Len() = %v, Cap() = %v, want %v, %v", c.Len(), c.Cap(), 2, 1024)
+	}
+
+	// 超过分片容量直接拒绝
+	rejected := c.Put("11", make([]byte, 256))
+	if rejected == nil || rejected.Key != "11" || c.Contains("11") {
+		t.Errorf("Put() = %v, want %v", rejected, "11")
+	}
+}
+
+func TestCache_FIFO(t *testing.T) {
+	// 每条记录22+2+8=32字节，一个分片放4条
+	c := New(128, 1)
+	for i := 10; i < 14; i++ {
+		c.Put(strconv.Itoa(i), make([]byte, 8))
+	}
+	c.Get("10")
+	c.Put("14", make([]byte, 8))
+
+	// 访问不影响淘汰顺序，覆盖最早写入的10
+	if c.Contains("10") || !c.Contains("11") || !c.Contains("14") || c.Len() != 4 {
+		t.Errorf("Contains() = %v, Len() = %v, want %v, %v", c.Contains("10"), c.Len(), false, 4)
+	}
+
+	// 放不下时回绕到开头，覆盖11和12
+	c.Put("15", make([]byte, 40))
+	if c.Contains("11") || c.Contains("12") || !c.Contains("13") || !c.Contains("15") {
+		t.Errorf("Contains() = %v, want %v", c.Contains("12"), false)
+	}
+}
+
+func TestCache_Remove(t *testing.T) {
+	c := New(128, 1)
+	c.Put("11", []byte("5"))
+	if !c.Remove("11") || c.Contains("11") || c.Remove("11") {
+		t.Errorf("Remove() = %v, want %v", false, true)
+	}
+	c.Put("22", []byte("6"))
+	c.Clear()
+	if c.Len() != 0 || c.Contains("22") {
+		t.Errorf("Len() = %v, want %v", c.Len(), 0)
+	}
+}
+
+func TestCache_PutWithTTL(t *testing.T) {
+	clock := cachetest.UseFakeClock(t)
+	c := New(1024, 1)
+	c.SetStatsCounter(cache.NewStatsCounter())
+	c.PutWithTTL("11", []byte("5"), time.Millisecond*10)
+	c.Put("22", []byte("6"))
+	clock.Advance(time.Millisecond * 20)
+
+	if _, ok := c.Get("11"); ok {
+		t.Errorf("Get() = %v, want %v", ok, false)
+	}
+	if _, ok := c.Get("22"); !ok {
+		t.Errorf("Get() = %v, want %v", ok, true)
+	}
+
+	// 占满整个分片，覆盖时过期的元素统计为过期淘汰
+	c.Put("33", make([]byte, 1024-headerSize-2))
+	stats := c.Stats()
+	if stats.EvictionCountOf(cache.EvictReasonExpired) != 1 || stats.EvictionCountOf(cache.EvictReasonCapacity) != 1 {
+		t.Errorf("Evictions = %v, want %v", stats.Evictions, []uint64{1, 1, 0})
+	}
+	if stats.Hits != 1 || stats.Misses != 1 || c.Len() != 1 {
+		t.Errorf("Hits = %v, Misses = %v, Len() = %v, want %v, %v, %v", stats.Hits, stats.Misses, c.Len(), 1, 1, 1)
+	}
+}
+
+func TestCache_GetAppend(t *testing.T) {
+	c := New(1024, 1)
+	c.Put("11", []byte("5"))
+	dst, ok := c.GetAppend([]byte("value="), "11")
+	if !ok || string(dst) != "value=5" {
+		t.Errorf("GetAppend() = %s, want %s", dst, "value=5")
+	}
+}
+
+// 随机写入不同大小的元素，读到的一定是最后写入的值
+func TestCache_Random(t *testing.T) {
+	c := New(4096, 2)
+	r := rand.New(rand.NewSource(1))
+	latest := map[string][]byte{}
+	for i := 0; i < 100000; i++ {
+		key := strconv.Itoa(r.Intn(200))
+		switch r.Intn(10) {
+		case 0:
+			c.Remove(key)
+			delete(latest, key)
+		case 1, 2, 3:
+			value := make([]byte, r.Intn(100))
+			r.Read(value)
+			c.Put(key, value)
+			latest[key] = value
+		default:
+			value, ok := c.Get(key)
+			if ok && !bytes.Equal(value, latest[key]) {
+				t.Fatalf("Get(%v) = %v, want %v", key, value, latest[key])
+			}
+		}
+	}
+	// 刚写入的元素一定存在
+	c.Put("new", []byte("value"))
+	if !c.Contains("new") {
+		t.Errorf("Contains() = %v, want %v", false, true)
+	}
+}
+
+func TestCache_Concurrent(t *testing.T) {
+	c := New(1<<16, 4)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10000; j++ {
+				key := strconv.Itoa(j % 1000)
+				if value, ok := c.Get(key); ok && string(value) != key {
+					t.Errorf("Get() = %s, want %s", value, key)
+				}
+				c.Put(key, []byte(key))
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func BenchmarkCache_Put(b *testing.B) {
+	c := New(1<<26, 16)
+	value := make([]byte, 128)
+	keys := make([]string, 1<<16)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.Put(keys[i&(len(keys)-1)], value)
+			i++
+		}
+	})
+}
+
+func BenchmarkCache_Get(b *testing.B) {
+	c := New(1<<26, 16)
+	value := make([]byte, 128)
+	keys := make([]string, 1<<16)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		c.Put(keys[i], value)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var dst []byte
+		i := 0
+		for pb.Next() {
+			dst, _ = c.GetAppend(dst[:0], keys[i&(len(keys)-1)])
+			i++
+		}
+	})
+}
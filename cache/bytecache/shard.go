@@ -0,0 +1,177 @@
+package bytecache
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/jiaxwu/gommon/cache"
+)
+
+// 记录头：哈希值(8) + 过期时间(8) + Key长度(2) + Value长度(4)
+const (
+	hashOffset       = 0
+	expirationOffset = 8
+	keyLenOffset     = 16
+	valueLenOffset   = 18
+	headerSize       = 22
+)
+
+// 最长的Key
+const maxKeyLen = 1<<16 - 1
+
+// 分片
+// 所有记录按写入顺序保存在一个环形缓冲区里，写满后从最早的记录开始覆盖
+// 索引只保存哈希值到记录偏移量的映射，不包含指针，GC不需要扫描
+type shard struct {
+	mutex sync.RWMutex
+	buf   []byte            // 环形缓冲区
+	index map[uint64]uint32 // 哈希值到记录偏移量
+	head  int               // 下一条记录的写入位置
+	tail  int               // 最早的记录的位置
+	end   int               // 回绕前最后一条记录的结束位置，只有回绕后才有意义
+	used  int               // 所有记录占用的字节数，包括已经删除但还没被覆盖的
+	stats *cache.StatsCounter
+}
+
+func newShard(capacity int) *shard {
+	return &shard{
+		buf:   make([]byte, capacity),
+		index: make(map[uint64]uint32),
+	}
+}
+
+// 写入编码好的记录
+// 空间不够时按写入顺序覆盖最早的记录
+func (s *shard) put(h uint64, record []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n := len(record)
+	for {
+		if s.used == 0 {
+			s.head, s.tail, s.end = 0, 0, 0
+		}
+		// 数据在[tail, head)，剩余空间在head之后
+		if s.used == 0 || s.head > s.tail {
+			if len(s.buf)-s.head >= n {
+				break
+			}
+			// 放不下则回绕到开头，head之后的空间不再使用
+			s.end = s.head
+			s.head = 0
+			continue
+		}
+		// 回绕后数据在[tail, end)和[0, head)，剩余空间在[head, tail)
+		if s.tail-s.head >= n {
+			break
+		}
+		s.evict()
+	}
+
+	copy(s.buf[s.head:], record)
+	if _, ok := s.index[h]; ok {
+		s.stats.RecordUpdate()
+	} else {
+		s.stats.RecordPut()
+	}
+	s.index[h] = uint32(s.head)
+	s.head += n
+	s.used += n
+}
+
+// 覆盖最早的记录
+func (s *shard) evict() {
+	offset := s.tail
+	header := s.buf[offset : offset+headerSize]
+	h := binary.LittleEndian.Uint64(header[hashOffset:])
+	n := recordSize(header)
+	// 记录还在索引中才算淘汰，已经被删除或者更新的记录直接覆盖
+	if index, ok := s.index[h]; ok && int(index) == offset {
+		delete(s.index, h)
+		if expired(header) {
+			s.stats.RecordEviction(cache.EvictReasonExpired)
+		} else {
+			s.stats.RecordEviction(cache.EvictReasonCapacity)
+		}
+	}
+	s.tail += n
+	s.used -= n
+	if s.tail == s.end {
+		s.tail = 0
+		s.end = 0
+	}
+}
+
+// 获取记录，不存在、过期或者哈希冲突返回nil
+// 返回的记录引用了缓冲区，需要在读锁内使用
+func (s *shard) get(h uint64, key string) []byte {
+	offset, ok := s.index[h]
+	if !ok {
+		return nil
+	}
+	record := s.buf[offset:]
+	if expired(record) {
+		return nil
+	}
+	keyLen := int(binary.LittleEndian.Uint16(record[keyLenOffset:]))
+	if string(record[headerSize:headerSize+keyLen]) != key {
+		return nil
+	}
+	return record[:recordSize(record)]
+}
+
+// 删除记录，只删除索引，记录等待被覆盖
+func (s *shard) remove(h uint64, key string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.get(h, key) == nil {
+		return false
+	}
+	delete(s.index, h)
+	return true
+}
+
+// 元素个数，包括过期但还没被覆盖的元素
+func (s *shard) len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.index)
+}
+
+// 清空
+func (s *shard) clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.index = make(map[uint64]uint32)
+	s.head, s.tail, s.end, s.used = 0, 0, 0, 0
+}
+
+// 编码记录
+func appendRecord(dst []byte, h uint64, expiration int64, key string, value []byte) []byte {
+	var header [headerSize]byte
+	binary.LittleEndian.PutUint64(header[hashOffset:], h)
+	binary.LittleEndian.PutUint64(header[expirationOffset:], uint64(expiration))
+	binary.LittleEndian.PutUint16(header[keyLenOffset:], uint16(len(key)))
+	binary.LittleEndian.PutUint32(header[valueLenOffset:], uint32(len(value)))
+	dst = append(dst, header[:]...)
+	dst = append(dst, key...)
+	return append(dst, value...)
+}
+
+// 记录的Value
+func recordValue(record []byte) []byte {
+	keyLen := int(binary.LittleEndian.Uint16(record[keyLenOffset:]))
+	return record[headerSize+keyLen:]
+}
+
+// 记录占用的字节数
+func recordSize(header []byte) int {
+	keyLen := int(binary.LittleEndian.Uint16(header[keyLenOffset:]))
+	valueLen := int(binary.LittleEndian.Uint32(header[valueLenOffset:]))
+	return headerSize + keyLen + valueLen
+}
+
+// 记录是否过期
+func expired(header []byte) bool {
+	expiration := int64(binary.LittleEndian.Uint64(header[expirationOffset:]))
+	return expiration != 0 && cache.Now().UnixNano() >= expiration
+}
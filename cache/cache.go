@@ -1,20 +1,124 @@
 package cache
 
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
 // 缓存接口
 type Cache[K comparable, V any] interface {
-	// 添加或更新元素
-	Put(key K, value V)
+	// 添加或更新元素，返回被淘汰的元素
+	Put(key K, value V) *Entry[K, V]
 	// 获取元素
 	Get(key K) (V, bool)
 }
 
+// 缓存策略接口，lru、lfu、fifo、arc、slru、tinylfu、s3fifo、sieve、clock、clockpro、lirs、twoq、nearlylru、random都实现了该接口
+// 可以通过配置切换缓存策略
+type Policy[K comparable, V any] interface {
+	Cache[K, V]
+	// 获取元素，不更新状态
+	Peek(key K) (V, bool)
+	// 是否包含元素，不更新状态
+	Contains(key K) bool
+	// 移除元素，不触发 OnEvict
+	Remove(key K) bool
+	// 获取缓存的Keys，按淘汰顺序排列
+	Keys() []K
+	// 元素个数
+	Len() int
+	// 容量
+	Cap() int
+	// 清空缓存
+	Clear(needOnEvict bool)
+	// 设置 OnEvict
+	SetOnEvict(onEvict OnEvict[K, V])
+}
+
+// 淘汰原因
+type EvictReason int
+
+const (
+	EvictReasonCapacity EvictReason = iota // 容量不足
+	EvictReasonExpired                     // 过期
+	EvictReasonClear                       // 清空缓存
+	evictReasonCount                       // 淘汰原因数量
+)
+
 // 淘汰时触发
 type OnEvict[K comparable, V any] func(entry *Entry[K, V])
 
+// 淘汰时触发，带上淘汰原因
+type OnEvictWithReason[K comparable, V any] func(entry *Entry[K, V], reason EvictReason)
+
+// 转换成 OnEvictWithReason，忽略淘汰原因
+func (f OnEvict[K, V]) WithReason() OnEvictWithReason[K, V] {
+	if f == nil {
+		return nil
+	}
+	return func(entry *Entry[K, V], _ EvictReason) {
+		f(entry)
+	}
+}
+
+// 计算元素的权重，比如占用的字节数，必须是确定的，同样的元素每次计算结果相同
+type Weigher[K comparable, V any] func(key K, value V) int64
+
+var (
+	// 元素权重超过容量，不能添加
+	ErrTooLarge = errors.New("entry too large")
+	// 剩余的元素都被固定或者不能淘汰，放不下新元素
+	ErrAllPinned = errors.New("all entries pinned")
+)
+
+// 判断元素能否被淘汰，返回false则淘汰时跳过该元素
+// 只影响容量淘汰，过期、Remove()和Clear()依然会删除元素
+type CanEvict[K comparable, V any] func(entry *Entry[K, V]) bool
+
 // 缓存项
 type Entry[K comparable, V any] struct {
-	Key   K
-	Value V
+	Key        K
+	Value      V
+	Expiration time.Time // 过期时间，零值表示永不过期
+}
+
+// 是否已经过期
+func (e *Entry[K, V]) Expired() bool {
+	return !e.Expiration.IsZero() && !Now().Before(e.Expiration)
+}
+
+// 时钟，返回当前时间
+type Clock func() time.Time
+
+// 判断过期使用的时钟
+var clock atomic.Value
+
+func init() {
+	clock.Store(Clock(time.Now))
+}
+
+// 当前时间，计算和判断过期都使用该时间
+func Now() time.Time {
+	return clock.Load().(Clock)()
+}
+
+// 替换全局时钟，返回恢复原来时钟的函数，用于测试
+// 只影响过期判断，Janitor 依然按真实时间清理
+func SetClock(c Clock) (restore func()) {
+	old := clock.Swap(c)
+	return func() {
+		clock.Store(old)
+	}
+}
+
+// 根据存活时间计算过期时间
+// ttl小于等于0表示永不过期，返回零值
+func Expiration(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return Now().Add(ttl)
 }
 
 // 基础缓存结构
@@ -0,0 +1,243 @@
+// 缓存策略的一致性测试，检查 cache.Policy 的实现是否满足接口约定
+package cachetest
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/jiaxwu/gommon/cache"
+)
+
+// 测试使用的容量
+const capacity = 10
+
+// 创建被测试的缓存策略
+type NewFunc func(capacity int) cache.Policy[string, int]
+
+// 测试选项，不同缓存策略的Keys()顺序约定不同
+type Options struct {
+	// 只添加不访问时，Keys()的第一个元素最先被淘汰
+	EvictionOrder bool
+	// Keys()没有顺序，比如random、nearlylru
+	Unordered bool
+}
+
+// 运行一致性测试
+func Run(t *testing.T, newFunc NewFunc, opts Options) {
+	t.Run("Capacity", func(t *testing.T) {
+		testCapacity(t, newFunc)
+	})
+	t.Run("OnEvict", func(t *testing.T) {
+		testOnEvict(t, newFunc)
+	})
+	t.Run("Clear", func(t *testing.T) {
+		testClear(t, newFunc)
+	})
+	t.Run("Remove", func(t *testing.T) {
+		testRemove(t, newFunc)
+	})
+	t.Run("Keys", func(t *testing.T) {
+		testKeys(t, newFunc, opts)
+	})
+	t.Run("Peek", func(t *testing.T) {
+		testPeek(t, newFunc, opts)
+	})
+}
+
+// 元素个数不超过容量
+func testCapacity(t *testing.T, newFunc NewFunc) {
+	c := newFunc(capacity)
+	if c.Cap() != capacity {
+		t.Errorf("Cap() = %v, want %v", c.Cap(), capacity)
+	}
+	for i := 0; i < capacity*10; i++ {
+		c.Put(strconv.Itoa(i), i)
+		if i%3 == 0 {
+			c.Get(strconv.Itoa(i / 2))
+		}
+		if c.Len() > c.Cap() {
+			t.Fatalf("Len() = %v, want <= %v", c.Len(), c.Cap())
+		}
+	}
+	if c.Len() == 0 {
+		t.Errorf("Len() = %v, want > %v", c.Len(), 0)
+	}
+}
+
+// 每个被淘汰的元素只触发一次 OnEvict，并且和 Put() 的返回值一致
+func testOnEvict(t *testing.T, newFunc NewFunc) {
+	c := newFunc(capacity)
+	evicted := map[string]int{}
+	var lastEvicted *cache.Entry[string, int]
+	c.SetOnEvict(func(entry *cache.Entry[string, int]) {
+		evicted[entry.Key]++
+		lastEvicted = entry
+	})
+	n := capacity * 10
+	for i := 0; i < n; i++ {
+		key := strconv.Itoa(i)
+		lastEvicted = nil
+		if entry := c.Put(key, i); entry != nil && (lastEvicted == nil || entry.Key != lastEvicted.Key) {
+			t.Fatalf("Put() = %v, want %v", entry, lastEvicted)
+		}
+		// 更新和访问不会触发 OnEvict
+		c.Put(key, i)
+		c.Get(strconv.Itoa(i / 2))
+	}
+
+	for key, count := range evicted {
+		if count != 1 {
+			t.Errorf("OnEvict(%v) count = %v, want %v", key, count, 1)
+		}
+		if c.Contains(key) {
+			t.Errorf("Contains(%v) = %v, want %v", key, true, false)
+		}
+	}
+	if len(evicted)+c.Len() != n {
+		t.Errorf("evicted + Len() = %v, want %v", len(evicted)+c.Len(), n)
+	}
+}
+
+// 清空缓存时按需触发 OnEvict
+func testClear(t *testing.T, newFunc NewFunc) {
+	c := newFunc(capacity)
+	evicted := map[string]int{}
+	c.SetOnEvict(func(entry *cache.Entry[string, int]) {
+		evicted[entry.Key]++
+	})
+	for i := 0; i < capacity; i++ {
+		c.Put(strconv.Itoa(i), i)
+	}
+	keys := c.Keys()
+	evicted = map[string]int{}
+	c.Clear(true)
+	if c.Len() != 0 || len(c.Keys()) != 0 {
+		t.Errorf("Len() = %v, want %v", c.Len(), 0)
+	}
+	if len(evicted) != len(keys) {
+		t.Errorf("OnEvict count = %v, want %v", len(evicted), len(keys))
+	}
+	for _, key := range keys {
+		if evicted[key] != 1 {
+			t.Errorf("OnEvict(%v) count = %v, want %v", key, evicted[key], 1)
+		}
+	}
+
+	// 清空后还能正常使用
+	for i := 0; i < capacity; i++ {
+		c.Put(strconv.Itoa(i), i)
+	}
+	evicted = map[string]int{}
+	c.Clear(false)
+	if c.Len() != 0 || len(evicted) != 0 {
+		t.Errorf("Len() = %v, OnEvict count = %v, want %v, %v", c.Len(), len(evicted), 0, 0)
+	}
+	c.Put("0", 0)
+	if value, ok := c.Get("0"); !ok || value != 0 {
+		t.Errorf("Get() = %v, %v, want %v, %v", value, ok, 0, true)
+	}
+}
+
+// 移除元素不触发 OnEvict
+func testRemove(t *testing.T, newFunc NewFunc) {
+	c := newFunc(capacity)
+	c.SetOnEvict(func(entry *cache.Entry[string, int]) {
+		t.Errorf("OnEvict(%v) called", entry.Key)
+	})
+	for i := 0; i < capacity/2; i++ {
+		c.Put(strconv.Itoa(i), i)
+	}
+	if !c.Remove("0") || c.Contains("0") || c.Remove("0") {
+		t.Errorf("Remove() = %v, want %v", false, true)
+	}
+	if c.Len() != capacity/2-1 {
+		t.Errorf("Len() = %v, want %v", c.Len(), capacity/2-1)
+	}
+	if _, ok := c.Get("0"); ok {
+		t.Errorf("Get() = %v, want %v", ok, false)
+	}
+}
+
+// Keys()包含所有元素，没有重复
+func testKeys(t *testing.T, newFunc NewFunc, opts Options) {
+	c := newFunc(capacity)
+	for i := 0; i < capacity*3; i++ {
+		c.Put(strconv.Itoa(i), i)
+		if i%2 == 0 {
+			c.Get(strconv.Itoa(i / 2))
+		}
+		keys := c.Keys()
+		if len(keys) != c.Len() {
+			t.Fatalf("len(Keys()) = %v, want %v", len(keys), c.Len())
+		}
+		seen := map[string]bool{}
+		for _, key := range keys {
+			if seen[key] || !c.Contains(key) {
+				t.Fatalf("Keys() = %v, duplicate or missing %v", keys, key)
+			}
+			seen[key] = true
+		}
+	}
+
+	if !opts.EvictionOrder {
+		return
+	}
+	// 没有访问时按Keys()的顺序淘汰
+	c = newFunc(capacity)
+	for i := 0; i < capacity; i++ {
+		c.Put(strconv.Itoa(i), i)
+	}
+	for i := capacity; i < capacity*3; i++ {
+		want := c.Keys()[0]
+		if evicted := c.Put(strconv.Itoa(i), i); evicted == nil || evicted.Key != want {
+			t.Fatalf("Put() = %v, want %v", evicted, want)
+		}
+	}
+}
+
+// Peek()和Contains()不改变状态
+func testPeek(t *testing.T, newFunc NewFunc, opts Options) {
+	c := newFunc(capacity)
+	for i := 0; i < capacity*2; i++ {
+		c.Put(strconv.Itoa(i), i)
+		if i%3 == 0 {
+			c.Get(strconv.Itoa(i / 2))
+		}
+	}
+	keys := c.Keys()
+	for _, key := range keys {
+		value, ok := c.Peek(key)
+		if want, _ := strconv.Atoi(key); !ok || value != want {
+			t.Errorf("Peek(%v) = %v, %v, want %v, %v", key, value, ok, want, true)
+		}
+		c.Contains(key)
+	}
+	if _, ok := c.Peek("missing"); ok || c.Contains("missing") {
+		t.Errorf("Peek() = %v, want %v", ok, false)
+	}
+	got := c.Keys()
+	if opts.Unordered {
+		sort.Strings(keys)
+		sort.Strings(got)
+	}
+	if !reflect.DeepEqual(got, keys) {
+		t.Errorf("Keys() = %v, want %v", got, keys)
+	}
+
+	if !opts.EvictionOrder {
+		return
+	}
+	// 查看最先被淘汰的元素后，它仍然最先被淘汰
+	c = newFunc(capacity)
+	for i := 0; i < capacity; i++ {
+		c.Put(strconv.Itoa(i), i)
+	}
+	want := c.Keys()[0]
+	c.Peek(want)
+	c.Contains(want)
+	if evicted := c.Put(strconv.Itoa(capacity), capacity); evicted == nil || evicted.Key != want {
+		t.Errorf("Put() = %v, want %v", evicted, want)
+	}
+}
@@ -0,0 +1,111 @@
+package cachetest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jiaxwu/gommon/cache"
+)
+
+// 假的时钟，只有调用 Advance() 时间才会前进
+type FakeClock struct {
+	now   time.Time
+	mutex sync.Mutex
+}
+
+func NewFakeClock() *FakeClock {
+	return &FakeClock{now: time.Now()}
+}
+
+// 当前时间
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// 时间前进d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// 把全局时钟替换成假的时钟，测试结束时恢复
+func UseFakeClock(t *testing.T) *FakeClock {
+	clock := NewFakeClock()
+	t.Cleanup(cache.SetClock(clock.Now))
+	return clock
+}
+
+// 支持过期时间的缓存策略
+type TTLPolicy interface {
+	cache.Policy[string, int]
+	PutWithTTL(key string, value int, ttl time.Duration) *cache.Entry[string, int]
+	SetDefaultTTL(ttl time.Duration)
+	SetOnEvictWithReason(onEvict cache.OnEvictWithReason[string, int])
+}
+
+// 创建被测试的支持过期时间的缓存策略
+type NewTTLFunc func(capacity int) TTLPolicy
+
+// 运行过期时间的一致性测试，会替换全局时钟，不能并行运行
+func RunTTL(t *testing.T, newFunc NewTTLFunc) {
+	t.Run("PutWithTTL", func(t *testing.T) {
+		testPutWithTTL(t, newFunc)
+	})
+	t.Run("SetDefaultTTL", func(t *testing.T) {
+		testSetDefaultTTL(t, newFunc)
+	})
+}
+
+// 过期的元素访问不到，并以过期原因触发 OnEvict
+func testPutWithTTL(t *testing.T, newFunc NewTTLFunc) {
+	clock := UseFakeClock(t)
+	c := newFunc(capacity)
+	evicted := map[string]cache.EvictReason{}
+	c.SetOnEvictWithReason(func(entry *cache.Entry[string, int], reason cache.EvictReason) {
+		evicted[entry.Key] = reason
+	})
+	c.PutWithTTL("1", 1, time.Second)
+	c.Put("2", 2)
+	clock.Advance(time.Second - 1)
+	if !c.Contains("1") {
+		t.Errorf("Contains() = %v, want %v", false, true)
+	}
+
+	clock.Advance(1)
+	if _, ok := c.Peek("1"); ok {
+		t.Errorf("Peek() = %v, want %v", ok, false)
+	}
+	if c.Contains("1") {
+		t.Errorf("Contains() = %v, want %v", true, false)
+	}
+	if value, ok := c.Get("1"); value != 0 || ok {
+		t.Errorf("Get() = %v, %v, want %v, %v", value, ok, 0, false)
+	}
+	if reason, ok := evicted["1"]; !ok || reason != cache.EvictReasonExpired {
+		t.Errorf("OnEvict() = %v, want %v", reason, cache.EvictReasonExpired)
+	}
+	if value, ok := c.Get("2"); value != 2 || !ok {
+		t.Errorf("Get() = %v, %v, want %v, %v", value, ok, 2, true)
+	}
+}
+
+// 默认过期时间只对没有指定过期时间的元素生效
+func testSetDefaultTTL(t *testing.T, newFunc NewTTLFunc) {
+	clock := UseFakeClock(t)
+	c := newFunc(capacity)
+	c.SetDefaultTTL(time.Second)
+	c.Put("1", 1)
+	c.PutWithTTL("2", 2, time.Hour)
+	clock.Advance(time.Second)
+
+	if value, ok := c.Get("1"); value != 0 || ok {
+		t.Errorf("Get() = %v, %v, want %v, %v", value, ok, 0, false)
+	}
+	if value, ok := c.Get("2"); value != 2 || !ok {
+		t.Errorf("Get() = %v, %v, want %v, %v", value, ok, 2, true)
+	}
+}
@@ -0,0 +1,473 @@
+package clock
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/container/list"
+)
+
+// 快照中的缓存策略名称
+const snapshotPolicy = "clock"
+
+type referencedEntry[K comparable, V any] struct {
+	entry      *cache.Entry[K, V]
+	referenced uint32 // 引用位，上次指针经过之后被访问过为1
+}
+
+// CLOCK，也叫第二次机会算法
+// 元素组成一个环，访问时只设置引用位，不移动元素
+// 淘汰时指针沿着环移动，跳过并清除引用位为1的元素，淘汰第一个引用位为0的元素
+// 新元素放到指针后面，也就是指针转一圈最后才会经过的位置
+// 优点：命中时只设置原子的引用位，Get()、Peek()、Contains()、Keys()等只读方法可以在读锁内并发调用
+// 非线程安全，请根据业务加锁，写操作需要加写锁
+type Cache[K comparable, V any] struct {
+	entries    map[K]*list.Element[*referencedEntry[K, V]]
+	evictList  *list.List[*referencedEntry[K, V]]
+	hand       *list.Element[*referencedEntry[K, V]] // 淘汰指针，从尾部往头部移动，为空表示从尾部开始
+	capacity   int
+	cost       int64               // 当前总权重
+	weigher    cache.Weigher[K, V] // 计算元素权重，为空则每个元素权重为1
+	ttl        time.Duration       // 默认过期时间
+	onEvict    cache.OnEvictWithReason[K, V]
+	janitor    *cache.Janitor[K]
+	stats      *cache.StatsCounter // 统计，为空表示不统计
+	keyCodec   cache.Codec[K]      // 保存快照时Key的编解码器
+	valueCodec cache.Codec[V]      // 保存快照时Value的编解码器
+}
+
+func New[K comparable, V any](capacity int) *Cache[K, V] {
+	if capacity < 1 {
+		panic("too small capacity")
+	}
+	return &Cache[K, V]{
+		entries:   make(map[K]*list.Element[*referencedEntry[K, V]]),
+		evictList: list.New[*referencedEntry[K, V]](),
+		capacity:  capacity,
+	}
+}
+
+// 设置 OnEvict
+func (c *Cache[K, V]) SetOnEvict(onEvict cache.OnEvict[K, V]) {
+	c.onEvict = onEvict.WithReason()
+}
+
+// 设置 OnEvict，带上淘汰原因
+func (c *Cache[K, V]) SetOnEvictWithReason(onEvict cache.OnEvictWithReason[K, V]) {
+	c.onEvict = onEvict
+}
+
+// 设置权重计算函数，设置后容量表示总权重，而不是元素个数
+func (c *Cache[K, V]) SetWeigher(weigher cache.Weigher[K, V]) {
+	c.weigher = weigher
+	// 重新计算总权重
+	c.cost = 0
+	for elem := c.evictList.Back(); elem != nil; elem = elem.Prev() {
+		c.cost += c.weigh(elem.Value.entry.Key, elem.Value.entry.Value)
+	}
+	c.evictToFit(0)
+}
+
+// 设置统计计数器，为空表示不统计
+func (c *Cache[K, V]) SetStatsCounter(stats *cache.StatsCounter) {
+	c.stats = stats
+}
+
+// 获取统计信息
+func (c *Cache[K, V]) Stats() cache.Stats {
+	return c.stats.Snapshot()
+}
+
+// 设置默认过期时间，Put()会使用该过期时间，小于等于0表示永不过期
+func (c *Cache[K, V]) SetDefaultTTL(ttl time.Duration) {
+	c.ttl = ttl
+}
+
+// 启动过期清理器，到期主动删除元素，直到ctx被关闭
+// locker是业务访问缓存时使用的锁
+// 只会清理启动之后添加的元素，之前添加的元素依然在访问时删除
+func (c *Cache[K, V]) StartJanitor(ctx context.Context, locker sync.Locker) {
+	c.janitor = cache.NewJanitor(locker, c.RemoveExpired)
+	go c.janitor.Run(ctx)
+}
+
+// 添加或更新元素
+// 返回被淘汰的元素
+func (c *Cache[K, V]) Put(key K, value V) *cache.Entry[K, V] {
+	return c.PutWithTTL(key, value, c.ttl)
+}
+
+// 添加或更新元素，并设置过期时间，小于等于0表示永不过期
+// 返回被淘汰的元素
+func (c *Cache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) *cache.Entry[K, V] {
+	return c.PutWithExpiration(key, value, cache.Expiration(ttl))
+}
+
+// 添加或更新元素，并设置过期时刻，零值表示永不过期
+// 返回被淘汰的元素，如果淘汰了多个元素，返回最后一个
+// 如果元素权重超过容量，则不会添加，直接返回该元素
+func (c *Cache[K, V]) PutWithExpiration(key K, value V, expiration time.Time) *cache.Entry[K, V] {
+	weight := c.weigh(key, value)
+	if weight > int64(c.capacity) {
+		c.Remove(key)
+		return &cache.Entry[K, V]{
+			Key:        key,
+			Value:      value,
+			Expiration: expiration,
+		}
+	}
+
+	// 如果 key 已经存在，设置引用位，然后设置新值
+	if elem, ok := c.entries[key]; ok {
+		atomic.StoreUint32(&elem.Value.referenced, 1)
+		c.cost += weight - c.weigh(key, elem.Value.entry.Value)
+		elem.Value.entry.Value = value
+		elem.Value.entry.Expiration = expiration
+		c.schedule(key, expiration)
+		c.stats.RecordUpdate()
+		// 权重变大可能需要淘汰元素，它自己设置了引用位，不会第一个被淘汰
+		return c.evictToFit(0)
+	}
+
+	// 如果放不下，先剔除元素
+	evicted := c.evictToFit(weight)
+
+	// 添加元素到指针后面
+	entry := &referencedEntry[K, V]{
+		entry: &cache.Entry[K, V]{
+			Key:        key,
+			Value:      value,
+			Expiration: expiration,
+		},
+	}
+	var elem *list.Element[*referencedEntry[K, V]]
+	if c.hand == nil {
+		elem = c.evictList.PushFront(entry)
+	} else {
+		elem = c.evictList.InsertAfter(entry, c.hand)
+	}
+	c.entries[key] = elem
+	c.cost += weight
+	c.schedule(key, expiration)
+	c.stats.RecordPut()
+	return evicted
+}
+
+// 获取元素
+// 只设置原子的引用位，可以在读锁内并发调用
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	// 如果存在并且没有过期，设置引用位，然后返回
+	// 过期的元素不在这里删除，等淘汰或者过期清理器删除
+	if elem, ok := c.entries[key]; ok && !elem.Value.entry.Expired() {
+		atomic.StoreUint32(&elem.Value.referenced, 1)
+		c.stats.RecordHit()
+		return elem.Value.entry.Value, true
+	}
+
+	// 不存在返回空值和false
+	c.stats.RecordMiss()
+	var value V
+	return value, false
+}
+
+// 获取元素，不更新状态
+func (c *Cache[K, V]) Peek(key K) (V, bool) {
+	// 如果存在
+	if entry, ok := c.PeekEntry(key); ok {
+		return entry.Value, true
+	}
+
+	// 不存在返回空值和false
+	var value V
+	return value, false
+}
+
+// 获取缓存项，不更新状态
+func (c *Cache[K, V]) PeekEntry(key K) (*cache.Entry[K, V], bool) {
+	// 如果存在并且没有过期
+	if elem, ok := c.entries[key]; ok && !elem.Value.entry.Expired() {
+		return elem.Value.entry, true
+	}
+	return nil, false
+}
+
+// 是否包含元素，不更新状态
+func (c *Cache[K, V]) Contains(key K) bool {
+	_, ok := c.PeekEntry(key)
+	return ok
+}
+
+// 获取缓存的Keys，从指针开始按指针移动的顺序排列
+// 可能包含已经过期但还没被删除的元素
+func (c *Cache[K, V]) Keys() []K {
+	keys := make([]K, 0, c.Len())
+	c.each(func(entry *referencedEntry[K, V]) {
+		keys = append(keys, entry.entry.Key)
+	})
+	return keys
+}
+
+// 获取缓存的Values
+func (c *Cache[K, V]) Values() []V {
+	values := make([]V, 0, c.Len())
+	c.each(func(entry *referencedEntry[K, V]) {
+		values = append(values, entry.entry.Value)
+	})
+	return values
+}
+
+// 获取缓存的Entries
+func (c *Cache[K, V]) Entries() []*cache.Entry[K, V] {
+	entries := make([]*cache.Entry[K, V], 0, c.Len())
+	c.each(func(entry *referencedEntry[K, V]) {
+		entries = append(entries, entry.entry)
+	})
+	return entries
+}
+
+// 移除元素
+func (c *Cache[K, V]) Remove(key K) bool {
+	if elem, ok := c.entries[key]; ok {
+		c.removeElement(elem)
+		return true
+	}
+	return false
+}
+
+// 移除已经过期的元素
+func (c *Cache[K, V]) RemoveExpired(key K) bool {
+	if elem, ok := c.entries[key]; ok && elem.Value.entry.Expired() {
+		c.expireElement(elem)
+		return true
+	}
+	return false
+}
+
+// 淘汰元素
+// 指针跳过引用位为1的元素并清除引用位，淘汰第一个引用位为0的元素
+func (c *Cache[K, V]) Evict() *cache.Entry[K, V] {
+	if c.Len() == 0 {
+		return nil
+	}
+	elem := c.hand
+	if elem == nil {
+		elem = c.evictList.Back()
+	}
+	for atomic.LoadUint32(&elem.Value.referenced) == 1 && !elem.Value.entry.Expired() {
+		atomic.StoreUint32(&elem.Value.referenced, 0)
+		elem = c.prev(elem)
+	}
+	c.hand = elem
+	c.removeElement(elem)
+	entry := elem.Value.entry
+	// 回调
+	reason := cache.EvictReasonCapacity
+	if entry.Expired() {
+		reason = cache.EvictReasonExpired
+	}
+	c.doOnEvict(entry, reason)
+	return entry
+}
+
+// 获取可能被淘汰的元素
+func (c *Cache[K, V]) Victim() *cache.Entry[K, V] {
+	if c.Len() == 0 {
+		return nil
+	}
+	start := c.hand
+	if start == nil {
+		start = c.evictList.Back()
+	}
+	// 如果所有元素引用位都为1，指针转一圈之后会回到起点
+	elem := start
+	for atomic.LoadUint32(&elem.Value.referenced) == 1 && !elem.Value.entry.Expired() {
+		if elem = c.prev(elem); elem == start {
+			break
+		}
+	}
+	return elem.Value.entry
+}
+
+// 清空缓存
+func (c *Cache[K, V]) Clear(needOnEvict bool) {
+	// 触发回调
+	if needOnEvict {
+		c.each(func(entry *referencedEntry[K, V]) {
+			c.doOnEvict(entry.entry, cache.EvictReasonClear)
+		})
+	}
+
+	// 清空
+	c.entries = make(map[K]*list.Element[*referencedEntry[K, V]])
+	c.evictList.Clear()
+	c.hand = nil
+	c.cost = 0
+}
+
+// 改变容量
+func (c *Cache[K, V]) Resize(capacity int, needOnEvict bool) {
+	c.capacity = capacity
+	c.evictToFit(0)
+}
+
+// 元素个数
+func (c *Cache[K, V]) Len() int {
+	return len(c.entries)
+}
+
+// 当前总权重，没有设置权重计算函数时等于元素个数
+func (c *Cache[K, V]) Cost() int64 {
+	return c.cost
+}
+
+// 容量，设置了权重计算函数时表示总权重
+func (c *Cache[K, V]) Cap() int {
+	return c.capacity
+}
+
+// 缓存满了
+func (c *Cache[K, V]) Full() bool {
+	return c.Cost() >= int64(c.Cap())
+}
+
+// 设置保存快照时使用的编解码器，为空使用GobCodec
+func (c *Cache[K, V]) SetCodec(keyCodec cache.Codec[K], valueCodec cache.Codec[V]) {
+	c.keyCodec = keyCodec
+	c.valueCodec = valueCodec
+}
+
+// 保存快照到w，包括元素在环中的位置和引用位
+func (c *Cache[K, V]) Save(w io.Writer) error {
+	return c.Snapshot().Encode(w, c.keyCodec, c.valueCodec)
+}
+
+// 从r加载快照，会先清空缓存，不触发回调
+// 快照格式不正确时缓存保持不变
+func (c *Cache[K, V]) Load(r io.Reader) error {
+	snapshot, err := cache.DecodeSnapshot(r, snapshotPolicy, c.keyCodec, c.valueCodec)
+	if err != nil {
+		return err
+	}
+	return c.Restore(snapshot)
+}
+
+// 获取快照，从指针开始按指针移动的顺序排列，Frequency是引用位
+func (c *Cache[K, V]) Snapshot() *cache.Snapshot[K, V] {
+	snapshot := &cache.Snapshot[K, V]{
+		Policy:  snapshotPolicy,
+		Records: make([]*cache.SnapshotRecord[K, V], 0, c.Len()),
+	}
+	c.each(func(entry *referencedEntry[K, V]) {
+		e := *entry.entry
+		snapshot.Records = append(snapshot.Records, &cache.SnapshotRecord[K, V]{
+			Entry:     &e,
+			Frequency: uint64(atomic.LoadUint32(&entry.referenced)),
+		})
+	})
+	return snapshot
+}
+
+// 从快照恢复，会先清空缓存，不触发回调
+// 跳过已经过期的元素，放不下的元素按淘汰顺序淘汰
+func (c *Cache[K, V]) Restore(snapshot *cache.Snapshot[K, V]) error {
+	if snapshot.Policy != snapshotPolicy {
+		return cache.ErrSnapshotPolicyMismatch
+	}
+	c.Clear(false)
+	for _, record := range snapshot.Records {
+		entry := *record.Entry
+		if entry.Expired() {
+			continue
+		}
+		if elem, ok := c.entries[entry.Key]; ok {
+			c.removeElement(elem)
+		}
+		// 快照指针最先经过的在前面，所以依次放到最前面，指针从尾部开始
+		referenced := uint32(0)
+		if record.Frequency > 0 {
+			referenced = 1
+		}
+		c.entries[entry.Key] = c.evictList.PushFront(&referencedEntry[K, V]{
+			entry:      &entry,
+			referenced: referenced,
+		})
+		c.cost += c.weigh(entry.Key, entry.Value)
+		c.schedule(entry.Key, entry.Expiration)
+	}
+	c.evictToFit(0)
+	return nil
+}
+
+// 从指针开始按指针移动的顺序遍历元素
+func (c *Cache[K, V]) each(f func(entry *referencedEntry[K, V])) {
+	start := c.hand
+	if start == nil {
+		start = c.evictList.Back()
+	}
+	for elem, i := start, 0; i < c.Len(); elem, i = c.prev(elem), i+1 {
+		f(elem.Value)
+	}
+}
+
+// 指针的下一个位置，到头部之后回到尾部
+func (c *Cache[K, V]) prev(elem *list.Element[*referencedEntry[K, V]]) *list.Element[*referencedEntry[K, V]] {
+	if prev := elem.Prev(); prev != nil {
+		return prev
+	}
+	return c.evictList.Back()
+}
+
+// 移除给定节点
+func (c *Cache[K, V]) removeElement(elem *list.Element[*referencedEntry[K, V]]) {
+	// 指针指向被移除的元素，则移动到下一个位置
+	if c.hand == elem {
+		c.hand = elem.Prev()
+	}
+	c.evictList.Remove(elem)
+	entry := elem.Value.entry
+	delete(c.entries, entry.Key)
+	c.cost -= c.weigh(entry.Key, entry.Value)
+}
+
+// 淘汰元素直到能放下给定权重的元素
+// 返回最后一个被淘汰的元素
+func (c *Cache[K, V]) evictToFit(weight int64) *cache.Entry[K, V] {
+	var evicted *cache.Entry[K, V]
+	for c.Len() > 0 && c.cost+weight > int64(c.capacity) {
+		evicted = c.Evict()
+	}
+	return evicted
+}
+
+// 计算元素权重
+func (c *Cache[K, V]) weigh(key K, value V) int64 {
+	if c.weigher == nil {
+		return 1
+	}
+	return c.weigher(key, value)
+}
+
+// 删除过期节点
+func (c *Cache[K, V]) expireElement(elem *list.Element[*referencedEntry[K, V]]) {
+	c.removeElement(elem)
+	c.doOnEvict(elem.Value.entry, cache.EvictReasonExpired)
+}
+
+// 添加到过期清理器
+func (c *Cache[K, V]) schedule(key K, expiration time.Time) {
+	if c.janitor != nil {
+		c.janitor.Push(key, expiration)
+	}
+}
+
+// 触发淘汰回调
+func (c *Cache[K, V]) doOnEvict(entry *cache.Entry[K, V], reason cache.EvictReason) {
+	c.stats.RecordEviction(reason)
+	if c.onEvict != nil {
+		c.onEvict(entry, reason)
+	}
+}
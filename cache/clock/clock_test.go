@@ -0,0 +1,298 @@
+package clock
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/cache/cachetest"
+)
+
+func TestCache_Put(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Get("11")
+	c.Put("44", 8)
+
+	// 11引用位为1被跳过，淘汰22
+	value, ok := c.Get("22")
+	if value != 0 || ok {
+		t.Errorf("Get() = %v, want %v", ok, false)
+	}
+	value, ok = c.Get("11")
+	if value != 5 || !ok {
+		t.Errorf("Get() = %v, want %v", value, 5)
+	}
+}
+
+func TestCache_OnEvict(t *testing.T) {
+	c := New[string, int](3)
+	c.SetOnEvict(func(entry *cache.Entry[string, int]) {
+		if entry.Key != "22" || entry.Value != 6 {
+			t.Errorf("OnEvict() = %v, want %v", entry.Key, "22")
+		}
+	})
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Get("11")
+	evicted := c.Put("44", 8)
+	if evicted == nil || evicted.Key != "22" {
+		t.Errorf("Put() = %v, want %v", evicted, "22")
+	}
+}
+
+func TestCache_PutBehindHand(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Get("11")
+	c.Put("44", 8)
+	c.Get("33")
+	c.Get("11")
+	// 指针清除33、11的引用位，淘汰44，新元素放到指针后面，最后才会被指针经过
+	c.Put("55", 9)
+
+	if !reflect.DeepEqual(c.Keys(), []string{"33", "11", "55"}) {
+		t.Errorf("Keys() = %v, want %v", c.Keys(), []string{"33", "11", "55"})
+	}
+}
+
+func TestCache_ConcurrentGet(t *testing.T) {
+	c := New[int, int](100)
+	for i := 0; i < 100; i++ {
+		c.Put(i, i)
+	}
+
+	// Get()只设置引用位，可以在读锁内并发调用
+	var mu sync.RWMutex
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				mu.RLock()
+				value, ok := c.Get((i + j) % 100)
+				mu.RUnlock()
+				if !ok || value != (i+j)%100 {
+					t.Errorf("Get() = %v, want %v", value, (i+j)%100)
+				}
+			}
+		}(i)
+	}
+	for i := 100; i < 200; i++ {
+		mu.Lock()
+		c.Put(i%100, i%100)
+		mu.Unlock()
+	}
+	wg.Wait()
+}
+
+func TestCache_Evict(t *testing.T) {
+	c := New[string, int](4)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Put("44", 8)
+	c.Get("11")
+	c.Get("22")
+	c.Get("44")
+
+	// 指针从尾部开始，清除11、22的引用位，淘汰33
+	if victim := c.Victim(); victim.Key != "33" {
+		t.Errorf("Victim() = %v, want %v", victim.Key, "33")
+	}
+	if evicted := c.Evict(); evicted.Key != "33" {
+		t.Errorf("Evict() = %v, want %v", evicted.Key, "33")
+	}
+	// 指针停在44，清除44的引用位，回到尾部淘汰11
+	if evicted := c.Evict(); evicted.Key != "11" {
+		t.Errorf("Evict() = %v, want %v", evicted.Key, "11")
+	}
+
+	// 引用位全部为1时转一圈后淘汰指针指向的元素
+	c.Get("22")
+	c.Get("44")
+	if victim := c.Victim(); victim.Key != "22" {
+		t.Errorf("Victim() = %v, want %v", victim.Key, "22")
+	}
+	if evicted := c.Evict(); evicted.Key != "22" {
+		t.Errorf("Evict() = %v, want %v", evicted.Key, "22")
+	}
+}
+
+func TestCache_Peek(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Peek("11")
+	c.Put("44", 8)
+
+	value, ok := c.Get("11")
+	if value != 0 || ok {
+		t.Errorf("Get() = %v, want %v", ok, false)
+	}
+}
+
+func TestCache_Remove(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Get("11")
+	c.Evict()
+	// 移除指针指向的元素
+	c.Remove("33")
+	c.Put("44", 8)
+	c.Put("55", 9)
+
+	if !reflect.DeepEqual(c.Keys(), []string{"11", "44", "55"}) {
+		t.Errorf("Keys() = %v, want %v", c.Keys(), []string{"11", "44", "55"})
+	}
+}
+
+func TestCache_Resize(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Get("33")
+	c.Resize(1, true)
+
+	if !reflect.DeepEqual(c.Keys(), []string{"33"}) {
+		t.Errorf("Keys() = %v, want %v", c.Keys(), []string{"33"})
+	}
+}
+
+func TestCache_PutWithTTL(t *testing.T) {
+	c := New[string, int](3)
+	evicted := map[string]cache.EvictReason{}
+	c.SetOnEvictWithReason(func(entry *cache.Entry[string, int], reason cache.EvictReason) {
+		evicted[entry.Key] = reason
+	})
+	c.PutWithTTL("11", 5, time.Millisecond*10)
+	c.Put("22", 6)
+	c.Get("11")
+	time.Sleep(time.Millisecond * 20)
+
+	// 过期的元素Get()返回不存在，但不会删除
+	if _, ok := c.Get("11"); ok || c.Len() != 2 {
+		t.Errorf("Get() = %v, Len() = %v, want %v, %v", ok, c.Len(), false, 2)
+	}
+	// 过期的元素即使引用位为1也会被淘汰
+	c.Put("33", 7)
+	c.Put("44", 8)
+	if reason, ok := evicted["11"]; !ok || reason != cache.EvictReasonExpired {
+		t.Errorf("OnEvict() = %v, want %v", reason, cache.EvictReasonExpired)
+	}
+	value, ok := c.Get("22")
+	if value != 6 || !ok {
+		t.Errorf("Get() = %v, want %v", ok, true)
+	}
+}
+
+func TestCache_SetWeigher(t *testing.T) {
+	c := New[string, []byte](10)
+	c.SetWeigher(func(key string, value []byte) int64 {
+		return int64(len(value))
+	})
+	c.Put("11", make([]byte, 4))
+	c.Put("22", make([]byte, 4))
+	c.Get("11")
+	c.Put("33", make([]byte, 6))
+	if c.Contains("22") || c.Cost() != 10 {
+		t.Errorf("Keys() = %v, Cost() = %v, want %v, %v", c.Keys(), c.Cost(), []string{"11", "33"}, 10)
+	}
+
+	// 超过容量直接拒绝
+	rejected := c.Put("44", make([]byte, 11))
+	if rejected == nil || rejected.Key != "44" || c.Contains("44") {
+		t.Errorf("Put() = %v, want %v", rejected, "44")
+	}
+}
+
+func TestCache_Save(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Get("11")
+	c.Get("33")
+	c.Evict()
+	c.Put("44", 8)
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// 恢复后引用位和指针位置不变
+	c2 := New[string, int](3)
+	if err := c2.Load(&buf); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(c2.Keys(), c.Keys()) {
+		t.Errorf("Keys() = %v, want %v", c2.Keys(), c.Keys())
+	}
+	if c2.Victim().Key != c.Victim().Key {
+		t.Errorf("Victim() = %v, want %v", c2.Victim().Key, c.Victim().Key)
+	}
+}
+
+func TestCache_Conformance(t *testing.T) {
+	cachetest.Run(t, func(capacity int) cache.Policy[string, int] {
+		return New[string, int](capacity)
+	}, cachetest.Options{EvictionOrder: true})
+}
+
+// clock_test.go:290: cachePercentage=0.1%, count=206048, hitCount=26811, hitRate=13.01%
+// clock_test.go:290: cachePercentage=0.3%, count=206048, hitCount=59121, hitRate=28.69%
+// clock_test.go:290: cachePercentage=0.5%, count=206048, hitCount=89739, hitRate=43.55%
+// clock_test.go:290: cachePercentage=0.7%, count=206048, hitCount=118003, hitRate=57.27%
+// clock_test.go:290: cachePercentage=1.0%, count=206048, hitCount=153448, hitRate=74.47%
+// clock_test.go:290: cachePercentage=2.0%, count=206048, hitCount=187988, hitRate=91.24%
+// clock_test.go:290: cachePercentage=3.0%, count=206048, hitCount=190900, hitRate=92.65%
+// clock_test.go:290: cachePercentage=5.0%, count=206048, hitCount=192620, hitRate=93.48%
+// clock_test.go:290: cachePercentage=10.0%, count=206048, hitCount=192842, hitRate=93.59%
+func TestHitRate(t *testing.T) {
+	dataset, err := os.ReadFile("../dataset")
+	if err != nil {
+		t.Errorf("read dataset error %v", err)
+	}
+	reqs := strings.Split(string(dataset), ",")
+	testHitRate(t, reqs, 0.001)
+	testHitRate(t, reqs, 0.003)
+	testHitRate(t, reqs, 0.005)
+	testHitRate(t, reqs, 0.007)
+	testHitRate(t, reqs, 0.01)
+	testHitRate(t, reqs, 0.02)
+	testHitRate(t, reqs, 0.03)
+	testHitRate(t, reqs, 0.05)
+	testHitRate(t, reqs, 0.1)
+}
+
+func testHitRate(t *testing.T, reqs []string, cachePercentage float64) {
+	count := len(reqs)
+	n := int(float64(count) * cachePercentage)
+	c := New[string, int](n)
+	hitCount := 0
+	for _, req := range reqs {
+		_, exists := c.Get(req)
+		if exists {
+			hitCount++
+		} else {
+			c.Put(req, 0)
+		}
+	}
+	hitRate := float64(hitCount) / float64(count)
+	t.Logf("cachePercentage=%.1f%%, count=%v, hitCount=%v, hitRate=%.2f%%", cachePercentage*100, count, hitCount, hitRate*100)
+}
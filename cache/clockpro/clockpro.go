@@ -0,0 +1,649 @@
+package clockpro
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/container/list"
+)
+
+// 快照中的缓存策略名称
+const snapshotPolicy = "clockpro"
+
+// 页面类型，也是快照中的段
+const (
+	pageCold uint8 = iota // 冷页面，有值
+	pageHot               // 热页面，有值
+	pageTest              // 测试页面，只有Key，没有值
+)
+
+type page[K comparable, V any] struct {
+	entry      *cache.Entry[K, V]
+	typ        uint8
+	referenced uint32 // 引用位，上次指针经过之后被访问过为1
+}
+
+// 是否在缓存中，测试页面只是历史记录
+func (p *page[K, V]) resident() bool {
+	return p.typ != pageTest
+}
+
+// CLOCK-Pro
+// 所有页面组成一个环，分为热页面、冷页面和测试页面，访问时只设置引用位，不移动元素
+// 冷指针：冷页面引用位为1则变成热页面，否则淘汰它的值，变成测试页面
+// 热指针：热页面引用位为1则清除，否则变成冷页面；遇到测试页面则删除
+// 测试指针：测试页面超过容量时删除
+// 测试页面再次被添加说明它的重用距离比较短，直接成为热页面，同时增大冷页面的目标数量，反之减小
+// 优点：和CLOCK一样命中时只设置原子的引用位，同时像LIRS一样能抵抗扫描
+// 容量表示缓存的页面数，不支持权重
+// 非线程安全，请根据业务加锁，写操作需要加写锁
+// https://www.usenix.org/legacy/event/usenix05/tech/general/full_papers/jiang/jiang.pdf
+type Cache[K comparable, V any] struct {
+	pages        map[K]*list.Element[*page[K, V]]
+	clock        *list.List[*page[K, V]]
+	handHot      *list.Element[*page[K, V]] // 热指针，新页面放到它前面
+	handCold     *list.Element[*page[K, V]] // 冷指针
+	handTest     *list.Element[*page[K, V]] // 测试指针
+	countHot     int
+	countCold    int
+	countTest    int
+	capacity     int
+	coldCapacity int                // 冷页面的目标数量，根据测试页面的命中情况调整
+	evicted      *cache.Entry[K, V] // 冷指针最近淘汰的元素
+	ttl          time.Duration      // 默认过期时间
+	onEvict      cache.OnEvictWithReason[K, V]
+	janitor      *cache.Janitor[K]
+	stats        *cache.StatsCounter // 统计，为空表示不统计
+	keyCodec     cache.Codec[K]      // 保存快照时Key的编解码器
+	valueCodec   cache.Codec[V]      // 保存快照时Value的编解码器
+}
+
+func New[K comparable, V any](capacity int) *Cache[K, V] {
+	if capacity < 1 {
+		panic("too small capacity")
+	}
+	return &Cache[K, V]{
+		pages:        make(map[K]*list.Element[*page[K, V]]),
+		clock:        list.New[*page[K, V]](),
+		capacity:     capacity,
+		coldCapacity: initialColdCapacity(capacity),
+	}
+}
+
+// 冷页面的初始目标数量，容量的1%，之后根据测试页面的命中情况调整
+func initialColdCapacity(capacity int) int {
+	return capacity/100 + 1
+}
+
+// 设置 OnEvict
+func (c *Cache[K, V]) SetOnEvict(onEvict cache.OnEvict[K, V]) {
+	c.onEvict = onEvict.WithReason()
+}
+
+// 设置 OnEvict，带上淘汰原因
+func (c *Cache[K, V]) SetOnEvictWithReason(onEvict cache.OnEvictWithReason[K, V]) {
+	c.onEvict = onEvict
+}
+
+// 设置统计计数器，为空表示不统计
+func (c *Cache[K, V]) SetStatsCounter(stats *cache.StatsCounter) {
+	c.stats = stats
+}
+
+// 获取统计信息
+func (c *Cache[K, V]) Stats() cache.Stats {
+	return c.stats.Snapshot()
+}
+
+// 设置默认过期时间，Put()会使用该过期时间，小于等于0表示永不过期
+func (c *Cache[K, V]) SetDefaultTTL(ttl time.Duration) {
+	c.ttl = ttl
+}
+
+// 启动过期清理器，到期主动删除元素，直到ctx被关闭
+// locker是业务访问缓存时使用的锁
+// 只会清理启动之后添加的元素，之前添加的元素依然在淘汰时删除
+func (c *Cache[K, V]) StartJanitor(ctx context.Context, locker sync.Locker) {
+	c.janitor = cache.NewJanitor(locker, c.RemoveExpired)
+	go c.janitor.Run(ctx)
+}
+
+// 添加或更新元素
+// 返回被淘汰的元素
+func (c *Cache[K, V]) Put(key K, value V) *cache.Entry[K, V] {
+	return c.PutWithTTL(key, value, c.ttl)
+}
+
+// 添加或更新元素，并设置过期时间，小于等于0表示永不过期
+// 返回被淘汰的元素
+func (c *Cache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) *cache.Entry[K, V] {
+	return c.PutWithExpiration(key, value, cache.Expiration(ttl))
+}
+
+// 添加或更新元素，并设置过期时刻，零值表示永不过期
+// 返回被淘汰的元素
+func (c *Cache[K, V]) PutWithExpiration(key K, value V, expiration time.Time) *cache.Entry[K, V] {
+	typ := pageCold
+	if elem, ok := c.pages[key]; ok {
+		// 如果 key 已经在缓存中，设置引用位，然后设置新值
+		if elem.Value.resident() {
+			atomic.StoreUint32(&elem.Value.referenced, 1)
+			elem.Value.entry.Value = value
+			elem.Value.entry.Expiration = expiration
+			c.schedule(key, expiration)
+			c.stats.RecordUpdate()
+			return nil
+		}
+		// 测试页面命中，说明冷页面太少，增大冷页面的目标数量，然后作为热页面添加
+		if c.coldCapacity < c.capacity {
+			c.coldCapacity++
+		}
+		c.removeElement(elem)
+		typ = pageHot
+	}
+
+	// 如果放不下，先剔除元素
+	var evicted *cache.Entry[K, V]
+	if c.Len() >= c.capacity {
+		evicted = c.Evict()
+	}
+
+	// 添加页面到热指针前面，也就是指针转一圈最后才会经过的位置
+	c.insert(&page[K, V]{
+		entry: &cache.Entry[K, V]{
+			Key:        key,
+			Value:      value,
+			Expiration: expiration,
+		},
+		typ: typ,
+	})
+	c.schedule(key, expiration)
+	c.stats.RecordPut()
+	return evicted
+}
+
+// 获取元素
+// 只设置原子的引用位，可以在读锁内并发调用
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	// 如果在缓存中并且没有过期，设置引用位，然后返回
+	// 过期的元素不在这里删除，等淘汰或者过期清理器删除
+	if elem, ok := c.pages[key]; ok && elem.Value.resident() && !elem.Value.entry.Expired() {
+		atomic.StoreUint32(&elem.Value.referenced, 1)
+		c.stats.RecordHit()
+		return elem.Value.entry.Value, true
+	}
+
+	// 不存在返回空值和false
+	c.stats.RecordMiss()
+	var value V
+	return value, false
+}
+
+// 获取元素，不更新状态
+func (c *Cache[K, V]) Peek(key K) (V, bool) {
+	// 如果存在
+	if entry, ok := c.PeekEntry(key); ok {
+		return entry.Value, true
+	}
+
+	// 不存在返回空值和false
+	var value V
+	return value, false
+}
+
+// 获取缓存项，不更新状态
+func (c *Cache[K, V]) PeekEntry(key K) (*cache.Entry[K, V], bool) {
+	// 如果在缓存中并且没有过期
+	if elem, ok := c.pages[key]; ok && elem.Value.resident() && !elem.Value.entry.Expired() {
+		return elem.Value.entry, true
+	}
+	return nil, false
+}
+
+// 是否包含元素，不更新状态
+func (c *Cache[K, V]) Contains(key K) bool {
+	_, ok := c.PeekEntry(key)
+	return ok
+}
+
+// 获取缓存的Keys，从冷指针开始按指针移动的顺序排列，不包括测试页面
+// 可能包含已经过期但还没被删除的元素
+func (c *Cache[K, V]) Keys() []K {
+	keys := make([]K, 0, c.Len())
+	c.each(c.handCold, func(p *page[K, V]) {
+		if p.resident() {
+			keys = append(keys, p.entry.Key)
+		}
+	})
+	return keys
+}
+
+// 获取缓存的Values
+func (c *Cache[K, V]) Values() []V {
+	values := make([]V, 0, c.Len())
+	c.each(c.handCold, func(p *page[K, V]) {
+		if p.resident() {
+			values = append(values, p.entry.Value)
+		}
+	})
+	return values
+}
+
+// 获取缓存的Entries
+func (c *Cache[K, V]) Entries() []*cache.Entry[K, V] {
+	entries := make([]*cache.Entry[K, V], 0, c.Len())
+	c.each(c.handCold, func(p *page[K, V]) {
+		if p.resident() {
+			entries = append(entries, p.entry)
+		}
+	})
+	return entries
+}
+
+// 移除元素，保留测试页面
+func (c *Cache[K, V]) Remove(key K) bool {
+	if elem, ok := c.pages[key]; ok && elem.Value.resident() {
+		c.removeElement(elem)
+		return true
+	}
+	return false
+}
+
+// 移除已经过期的元素
+func (c *Cache[K, V]) RemoveExpired(key K) bool {
+	if elem, ok := c.pages[key]; ok && elem.Value.resident() && elem.Value.entry.Expired() {
+		c.removeElement(elem)
+		c.doOnEvict(elem.Value.entry, cache.EvictReasonExpired)
+		return true
+	}
+	return false
+}
+
+// 淘汰元素
+// 冷指针转动直到淘汰一个冷页面或者过期的页面
+func (c *Cache[K, V]) Evict() *cache.Entry[K, V] {
+	if c.Len() == 0 {
+		return nil
+	}
+	for c.evicted == nil {
+		// 没有冷页面时由热指针把热页面变成冷页面
+		if c.countCold == 0 {
+			c.runHandHot()
+			continue
+		}
+		c.runHandCold()
+	}
+	entry := c.evicted
+	c.evicted = nil
+	// 回调
+	reason := cache.EvictReasonCapacity
+	if entry.Expired() {
+		reason = cache.EvictReasonExpired
+	}
+	c.doOnEvict(entry, reason)
+	return entry
+}
+
+// 获取可能被淘汰的元素
+// 冷指针之后第一个过期或者引用位为0的冷页面，没有则是第一个在缓存中的页面
+func (c *Cache[K, V]) Victim() *cache.Entry[K, V] {
+	if c.Len() == 0 {
+		return nil
+	}
+	var first, victim *cache.Entry[K, V]
+	c.each(c.handCold, func(p *page[K, V]) {
+		if victim != nil || !p.resident() {
+			return
+		}
+		if first == nil {
+			first = p.entry
+		}
+		if p.entry.Expired() || p.typ == pageCold && atomic.LoadUint32(&p.referenced) == 0 {
+			victim = p.entry
+		}
+	})
+	if victim == nil {
+		victim = first
+	}
+	return victim
+}
+
+// 清空缓存
+func (c *Cache[K, V]) Clear(needOnEvict bool) {
+	// 触发回调
+	if needOnEvict {
+		c.each(c.handCold, func(p *page[K, V]) {
+			if p.resident() {
+				c.doOnEvict(p.entry, cache.EvictReasonClear)
+			}
+		})
+	}
+
+	// 清空
+	c.pages = make(map[K]*list.Element[*page[K, V]])
+	c.clock.Clear()
+	c.handHot, c.handCold, c.handTest = nil, nil, nil
+	c.countHot, c.countCold, c.countTest = 0, 0, 0
+	c.coldCapacity = initialColdCapacity(c.capacity)
+}
+
+// 改变容量
+func (c *Cache[K, V]) Resize(capacity int, needOnEvict bool) {
+	c.capacity = capacity
+	if c.coldCapacity > capacity {
+		c.coldCapacity = capacity
+	}
+	for c.Len() > capacity {
+		c.Evict()
+	}
+	for c.countTest > capacity {
+		c.runHandTest()
+	}
+}
+
+// 元素个数，不包括测试页面
+func (c *Cache[K, V]) Len() int {
+	return c.countHot + c.countCold
+}
+
+// 当前总权重，等于元素个数
+func (c *Cache[K, V]) Cost() int64 {
+	return int64(c.Len())
+}
+
+// 容量
+func (c *Cache[K, V]) Cap() int {
+	return c.capacity
+}
+
+// 缓存满了
+func (c *Cache[K, V]) Full() bool {
+	return c.Len() >= c.capacity
+}
+
+// 设置保存快照时使用的编解码器，为空使用GobCodec
+func (c *Cache[K, V]) SetCodec(keyCodec cache.Codec[K], valueCodec cache.Codec[V]) {
+	c.keyCodec = keyCodec
+	c.valueCodec = valueCodec
+}
+
+// 保存快照到w，包括页面类型、引用位、指针位置和冷页面的目标数量
+func (c *Cache[K, V]) Save(w io.Writer) error {
+	return c.Snapshot().Encode(w, c.keyCodec, c.valueCodec)
+}
+
+// 从r加载快照，会先清空缓存，不触发回调
+// 快照格式不正确时缓存保持不变
+func (c *Cache[K, V]) Load(r io.Reader) error {
+	snapshot, err := cache.DecodeSnapshot(r, snapshotPolicy, c.keyCodec, c.valueCodec)
+	if err != nil {
+		return err
+	}
+	return c.Restore(snapshot)
+}
+
+// 获取快照，从热指针开始按指针移动的顺序排列，包括测试页面
+// Segment是页面类型，Frequency是引用位
+// Meta[0]是冷页面的目标数量，Meta[1]、Meta[2]分别是冷指针和测试指针在Records中的下标
+func (c *Cache[K, V]) Snapshot() *cache.Snapshot[K, V] {
+	snapshot := &cache.Snapshot[K, V]{
+		Policy:  snapshotPolicy,
+		Meta:    []int64{int64(c.coldCapacity), 0, 0},
+		Records: make([]*cache.SnapshotRecord[K, V], 0, c.clock.Len()),
+	}
+	for elem, i := c.handHot, 0; i < c.clock.Len(); elem, i = c.next(elem), i+1 {
+		if elem == c.handCold {
+			snapshot.Meta[1] = int64(i)
+		}
+		if elem == c.handTest {
+			snapshot.Meta[2] = int64(i)
+		}
+		entry := *elem.Value.entry
+		snapshot.Records = append(snapshot.Records, &cache.SnapshotRecord[K, V]{
+			Entry:     &entry,
+			Segment:   elem.Value.typ,
+			Frequency: uint64(atomic.LoadUint32(&elem.Value.referenced)),
+		})
+	}
+	return snapshot
+}
+
+// 从快照恢复，会先清空缓存，不触发回调
+// 跳过已经过期的元素，放不下的元素按淘汰顺序淘汰
+func (c *Cache[K, V]) Restore(snapshot *cache.Snapshot[K, V]) error {
+	if snapshot.Policy != snapshotPolicy {
+		return cache.ErrSnapshotPolicyMismatch
+	}
+	for _, record := range snapshot.Records {
+		if record.Segment > pageTest {
+			return cache.ErrInvalidSnapshot
+		}
+	}
+	c.Clear(false)
+	elems := make([]*list.Element[*page[K, V]], len(snapshot.Records))
+	for i, record := range snapshot.Records {
+		entry := *record.Entry
+		if record.Segment != pageTest && entry.Expired() {
+			continue
+		}
+		if elem, ok := c.pages[entry.Key]; ok {
+			c.removeElement(elem)
+		}
+		// 快照从热指针开始，所以依次放到尾部
+		p := &page[K, V]{
+			entry: &entry,
+			typ:   record.Segment,
+		}
+		if record.Frequency > 0 {
+			p.referenced = 1
+		}
+		elems[i] = c.clock.PushBack(p)
+		c.pages[entry.Key] = elems[i]
+		c.count(p.typ, 1)
+		if p.resident() {
+			c.schedule(entry.Key, entry.Expiration)
+		}
+	}
+	c.handHot = c.clock.Front()
+	c.handCold, c.handTest = c.handHot, c.handHot
+	if len(snapshot.Meta) >= 3 {
+		if coldCapacity := int(snapshot.Meta[0]); coldCapacity >= 1 && coldCapacity <= c.capacity {
+			c.coldCapacity = coldCapacity
+		}
+		c.handCold = c.restoreHand(elems, snapshot.Meta[1])
+		c.handTest = c.restoreHand(elems, snapshot.Meta[2])
+	}
+	for c.Len() > c.capacity {
+		c.Evict()
+	}
+	for c.countTest > c.capacity {
+		c.runHandTest()
+	}
+	return nil
+}
+
+// 恢复指针，指向的页面被跳过时指向它后面的页面
+func (c *Cache[K, V]) restoreHand(elems []*list.Element[*page[K, V]], index int64) *list.Element[*page[K, V]] {
+	if index < 0 || index >= int64(len(elems)) {
+		return c.handHot
+	}
+	for _, elem := range elems[index:] {
+		if elem != nil && elem.Value.entry == c.pages[elem.Value.entry.Key].Value.entry {
+			return elem
+		}
+	}
+	return c.handHot
+}
+
+// 冷指针转动一格
+func (c *Cache[K, V]) runHandCold() {
+	elem := c.handCold
+	p := elem.Value
+	switch {
+	case p.resident() && p.entry.Expired():
+		// 过期的页面直接删除
+		c.removeElement(elem)
+		c.evicted = p.entry
+	case p.typ == pageCold && atomic.LoadUint32(&p.referenced) == 1:
+		// 冷页面在测试期间被访问，变成热页面，移动到热指针前面
+		atomic.StoreUint32(&p.referenced, 0)
+		p.typ = pageHot
+		c.count(pageCold, -1)
+		c.count(pageHot, 1)
+		c.moveToHead(elem)
+	case p.typ == pageCold:
+		// 淘汰冷页面的值，变成测试页面
+		c.evicted = p.entry
+		p.entry = &cache.Entry[K, V]{Key: p.entry.Key}
+		p.typ = pageTest
+		c.count(pageCold, -1)
+		c.count(pageTest, 1)
+		c.handCold = c.next(elem)
+		for c.countTest > c.capacity {
+			c.runHandTest()
+		}
+	default:
+		c.handCold = c.next(elem)
+	}
+	// 热页面超过目标数量
+	for c.countHot > 0 && c.countHot > c.capacity-c.coldCapacity {
+		c.runHandHot()
+	}
+}
+
+// 热指针转动一格
+func (c *Cache[K, V]) runHandHot() {
+	elem := c.handHot
+	p := elem.Value
+	switch {
+	case p.typ == pageHot && atomic.LoadUint32(&p.referenced) == 1:
+		atomic.StoreUint32(&p.referenced, 0)
+		c.handHot = c.next(elem)
+	case p.typ == pageHot:
+		// 热页面没有被访问，变成冷页面
+		p.typ = pageCold
+		c.count(pageHot, -1)
+		c.count(pageCold, 1)
+		c.handHot = c.next(elem)
+	case p.typ == pageTest:
+		// 测试期间没有被访问，减小冷页面的目标数量
+		c.removeElement(elem)
+		c.shrinkColdCapacity()
+	default:
+		c.handHot = c.next(elem)
+	}
+}
+
+// 测试指针转动直到删除一个测试页面
+func (c *Cache[K, V]) runHandTest() {
+	for c.countTest > 0 {
+		elem := c.handTest
+		if elem.Value.typ == pageTest {
+			c.removeElement(elem)
+			c.shrinkColdCapacity()
+			return
+		}
+		c.handTest = c.next(elem)
+	}
+}
+
+// 减小冷页面的目标数量
+func (c *Cache[K, V]) shrinkColdCapacity() {
+	if c.coldCapacity > 1 {
+		c.coldCapacity--
+	}
+}
+
+// 添加页面到热指针前面
+func (c *Cache[K, V]) insert(p *page[K, V]) {
+	var elem *list.Element[*page[K, V]]
+	if c.handHot == nil {
+		elem = c.clock.PushBack(p)
+		c.handHot, c.handCold, c.handTest = elem, elem, elem
+	} else {
+		elem = c.clock.InsertBefore(p, c.handHot)
+	}
+	c.pages[p.entry.Key] = elem
+	c.count(p.typ, 1)
+}
+
+// 移动页面到热指针前面
+func (c *Cache[K, V]) moveToHead(elem *list.Element[*page[K, V]]) {
+	if c.handHot == elem {
+		c.handHot = c.next(elem)
+	}
+	if c.handCold == elem {
+		c.handCold = c.next(elem)
+	}
+	if c.handTest == elem {
+		c.handTest = c.next(elem)
+	}
+	c.clock.MoveBefore(elem, c.handHot)
+}
+
+// 调整页面计数
+func (c *Cache[K, V]) count(typ uint8, delta int) {
+	switch typ {
+	case pageHot:
+		c.countHot += delta
+	case pageCold:
+		c.countCold += delta
+	default:
+		c.countTest += delta
+	}
+}
+
+// 从start开始按指针移动的顺序遍历页面
+func (c *Cache[K, V]) each(start *list.Element[*page[K, V]], f func(p *page[K, V])) {
+	for elem, i := start, 0; i < c.clock.Len(); elem, i = c.next(elem), i+1 {
+		f(elem.Value)
+	}
+}
+
+// 指针的下一个位置，到尾部之后回到头部
+func (c *Cache[K, V]) next(elem *list.Element[*page[K, V]]) *list.Element[*page[K, V]] {
+	if next := elem.Next(); next != nil {
+		return next
+	}
+	return c.clock.Front()
+}
+
+// 移除给定页面
+func (c *Cache[K, V]) removeElement(elem *list.Element[*page[K, V]]) {
+	// 指针指向被移除的页面，则移动到下一个位置
+	next := c.next(elem)
+	if next == elem {
+		next = nil
+	}
+	if c.handHot == elem {
+		c.handHot = next
+	}
+	if c.handCold == elem {
+		c.handCold = next
+	}
+	if c.handTest == elem {
+		c.handTest = next
+	}
+	c.clock.Remove(elem)
+	c.count(elem.Value.typ, -1)
+	delete(c.pages, elem.Value.entry.Key)
+}
+
+// 添加到过期清理器
+func (c *Cache[K, V]) schedule(key K, expiration time.Time) {
+	if c.janitor != nil {
+		c.janitor.Push(key, expiration)
+	}
+}
+
+// 触发淘汰回调
+func (c *Cache[K, V]) doOnEvict(entry *cache.Entry[K, V], reason cache.EvictReason) {
+	c.stats.RecordEviction(reason)
+	if c.onEvict != nil {
+		c.onEvict(entry, reason)
+	}
+}
@@ -0,0 +1,302 @@
+package clockpro
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/cache/cachetest"
+)
+
+func TestCache_Put(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Get("11")
+	c.Put("44", 8)
+
+	// 11引用位为1变成热页面，淘汰22
+	value, ok := c.Get("22")
+	if value != 0 || ok {
+		t.Errorf("Get() = %v, want %v", ok, false)
+	}
+	value, ok = c.Get("11")
+	if value != 5 || !ok {
+		t.Errorf("Get() = %v, want %v", value, 5)
+	}
+	if c.pages["11"].Value.typ != pageHot {
+		t.Errorf("typ = %v, want %v", c.pages["11"].Value.typ, pageHot)
+	}
+}
+
+func TestCache_TestPage(t *testing.T) {
+	c := New[string, int](2)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+
+	// 11被淘汰后变成测试页面
+	if c.Contains("11") || c.Len() != 2 || c.countTest != 1 {
+		t.Errorf("Contains() = %v, Len() = %v, want %v, %v", c.Contains("11"), c.Len(), false, 2)
+	}
+
+	// 测试期间再次添加，直接成为热页面
+	evicted := c.Put("11", 5)
+	if evicted == nil || evicted.Key != "22" {
+		t.Errorf("Put() = %v, want %v", evicted, "22")
+	}
+	if c.pages["11"].Value.typ != pageHot || c.coldCapacity != 2 {
+		t.Errorf("typ = %v, coldCapacity = %v, want %v, %v", c.pages["11"].Value.typ, c.coldCapacity, pageHot, 2)
+	}
+}
+
+func TestCache_ScanResistance(t *testing.T) {
+	c := New[int, int](100)
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 50; j++ {
+			if _, ok := c.Get(j); !ok {
+				c.Put(j, j)
+			}
+		}
+	}
+
+	// 只访问一次的扫描不会淘汰热页面
+	for i := 1000; i < 3000; i++ {
+		if _, ok := c.Get(i); !ok {
+			c.Put(i, i)
+		}
+	}
+	for i := 0; i < 50; i++ {
+		if !c.Contains(i) {
+			t.Errorf("Contains(%v) = %v, want %v", i, false, true)
+		}
+	}
+}
+
+func TestCache_OnEvict(t *testing.T) {
+	c := New[string, int](3)
+	c.SetOnEvict(func(entry *cache.Entry[string, int]) {
+		if entry.Key != "22" || entry.Value != 6 {
+			t.Errorf("OnEvict() = %v, want %v", entry.Key, "22")
+		}
+	})
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Get("11")
+	evicted := c.Put("44", 8)
+	if evicted == nil || evicted.Key != "22" {
+		t.Errorf("Put() = %v, want %v", evicted, "22")
+	}
+}
+
+func TestCache_Evict(t *testing.T) {
+	c := New[string, int](4)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Put("44", 8)
+	c.Get("11")
+	c.Get("22")
+
+	// 11、22变成热页面，淘汰33
+	if victim := c.Victim(); victim.Key != "33" {
+		t.Errorf("Victim() = %v, want %v", victim.Key, "33")
+	}
+	if evicted := c.Evict(); evicted.Key != "33" {
+		t.Errorf("Evict() = %v, want %v", evicted.Key, "33")
+	}
+	if evicted := c.Evict(); evicted.Key != "44" {
+		t.Errorf("Evict() = %v, want %v", evicted.Key, "44")
+	}
+
+	// 没有冷页面时热页面先变成冷页面再淘汰
+	c.Evict()
+	c.Evict()
+	if c.Len() != 0 || c.Evict() != nil {
+		t.Errorf("Len() = %v, want %v", c.Len(), 0)
+	}
+}
+
+func TestCache_Remove(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Evict()
+	// 测试页面不能被移除
+	if c.Remove("11") {
+		t.Errorf("Remove() = %v, want %v", true, false)
+	}
+	// 移除指针指向的元素
+	if !c.Remove("22") {
+		t.Errorf("Remove() = %v, want %v", false, true)
+	}
+	c.Put("44", 8)
+	c.Put("55", 9)
+
+	if !reflect.DeepEqual(c.Keys(), []string{"33", "44", "55"}) {
+		t.Errorf("Keys() = %v, want %v", c.Keys(), []string{"33", "44", "55"})
+	}
+}
+
+func TestCache_Resize(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Get("33")
+	c.Resize(1, true)
+
+	if !reflect.DeepEqual(c.Keys(), []string{"33"}) {
+		t.Errorf("Keys() = %v, want %v", c.Keys(), []string{"33"})
+	}
+	if c.countTest > 1 {
+		t.Errorf("countTest = %v, want %v", c.countTest, 1)
+	}
+}
+
+func TestCache_PutWithTTL(t *testing.T) {
+	c := New[string, int](3)
+	evicted := map[string]cache.EvictReason{}
+	c.SetOnEvictWithReason(func(entry *cache.Entry[string, int], reason cache.EvictReason) {
+		evicted[entry.Key] = reason
+	})
+	c.PutWithTTL("11", 5, time.Millisecond*10)
+	c.Put("22", 6)
+	c.Get("11")
+	time.Sleep(time.Millisecond * 20)
+
+	// 过期的元素Get()返回不存在，但不会删除
+	if _, ok := c.Get("11"); ok || c.Len() != 2 {
+		t.Errorf("Get() = %v, Len() = %v, want %v, %v", ok, c.Len(), false, 2)
+	}
+	// 过期的元素即使引用位为1也会被淘汰，并且不会变成测试页面
+	c.Put("33", 7)
+	c.Put("44", 8)
+	if reason, ok := evicted["11"]; !ok || reason != cache.EvictReasonExpired {
+		t.Errorf("OnEvict() = %v, want %v", reason, cache.EvictReasonExpired)
+	}
+	if _, ok := c.pages["11"]; ok {
+		t.Errorf("pages[11] = %v, want %v", ok, false)
+	}
+	value, ok := c.Get("22")
+	if value != 6 || !ok {
+		t.Errorf("Get() = %v, want %v", ok, true)
+	}
+}
+
+func TestCache_ConcurrentGet(t *testing.T) {
+	c := New[int, int](100)
+	for i := 0; i < 100; i++ {
+		c.Put(i, i)
+	}
+
+	// Get()只设置引用位，可以在读锁内并发调用
+	var mu sync.RWMutex
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				mu.RLock()
+				value, ok := c.Get((i + j) % 100)
+				mu.RUnlock()
+				if !ok || value != (i+j)%100 {
+					t.Errorf("Get() = %v, want %v", value, (i+j)%100)
+				}
+			}
+		}(i)
+	}
+	for i := 100; i < 200; i++ {
+		mu.Lock()
+		c.Put(i%100, i%100)
+		mu.Unlock()
+	}
+	wg.Wait()
+}
+
+func TestCache_Save(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Get("11")
+	c.Put("44", 8)
+	c.Get("33")
+	c.Put("22", 6)
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// 恢复后页面类型、引用位和指针位置不变
+	c2 := New[string, int](3)
+	if err := c2.Load(&buf); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(c2.Snapshot(), c.Snapshot()) {
+		t.Errorf("Snapshot() = %v, want %v", c2.Snapshot(), c.Snapshot())
+	}
+	for c.Len() > 0 {
+		if evicted, evicted2 := c.Evict(), c2.Evict(); evicted.Key != evicted2.Key {
+			t.Errorf("Evict() = %v, want %v", evicted2.Key, evicted.Key)
+		}
+	}
+}
+
+func TestCache_Conformance(t *testing.T) {
+	cachetest.Run(t, func(capacity int) cache.Policy[string, int] {
+		return New[string, int](capacity)
+	}, cachetest.Options{EvictionOrder: true})
+}
+
+// clockpro_test.go:294: cachePercentage=0.1%, count=206048, hitCount=30636, hitRate=14.87%
+// clockpro_test.go:294: cachePercentage=0.3%, count=206048, hitCount=68850, hitRate=33.41%
+// clockpro_test.go:294: cachePercentage=0.5%, count=206048, hitCount=103793, hitRate=50.37%
+// clockpro_test.go:294: cachePercentage=0.7%, count=206048, hitCount=135210, hitRate=65.62%
+// clockpro_test.go:294: cachePercentage=1.0%, count=206048, hitCount=170295, hitRate=82.65%
+// clockpro_test.go:294: cachePercentage=2.0%, count=206048, hitCount=189674, hitRate=92.05%
+// clockpro_test.go:294: cachePercentage=3.0%, count=206048, hitCount=191260, hitRate=92.82%
+// clockpro_test.go:294: cachePercentage=5.0%, count=206048, hitCount=192620, hitRate=93.48%
+// clockpro_test.go:294: cachePercentage=10.0%, count=206048, hitCount=192842, hitRate=93.59%
+func TestHitRate(t *testing.T) {
+	dataset, err := os.ReadFile("../dataset")
+	if err != nil {
+		t.Errorf("read dataset error %v", err)
+	}
+	reqs := strings.Split(string(dataset), ",")
+	testHitRate(t, reqs, 0.001)
+	testHitRate(t, reqs, 0.003)
+	testHitRate(t, reqs, 0.005)
+	testHitRate(t, reqs, 0.007)
+	testHitRate(t, reqs, 0.01)
+	testHitRate(t, reqs, 0.02)
+	testHitRate(t, reqs, 0.03)
+	testHitRate(t, reqs, 0.05)
+	testHitRate(t, reqs, 0.1)
+}
+
+func testHitRate(t *testing.T, reqs []string, cachePercentage float64) {
+	count := len(reqs)
+	n := int(float64(count) * cachePercentage)
+	c := New[string, int](n)
+	hitCount := 0
+	for _, req := range reqs {
+		_, exists := c.Get(req)
+		if exists {
+			hitCount++
+		} else {
+			c.Put(req, 0)
+		}
+	}
+	hitRate := float64(hitCount) / float64(count)
+	t.Logf("cachePercentage=%.1f%%, count=%v, hitCount=%v, hitRate=%.2f%%", cachePercentage*100, count, hitCount, hitRate*100)
+}
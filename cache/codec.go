@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// 编解码器，用于保存快照等需要序列化Key和Value的场景
+type Codec[T any] interface {
+	// 编码
+	Marshal(v T) ([]byte, error)
+	// 解码
+	Unmarshal(data []byte) (T, error)
+}
+
+// 基于 encoding/gob 的编解码器
+// 接口类型需要先调用 gob.Register() 注册具体类型
+type GobCodec[T any] struct{}
+
+func (GobCodec[T]) Marshal(v T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec[T]) Unmarshal(data []byte) (T, error) {
+	var v T
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+	return v, err
+}
+
+// 基于 encoding/json 的编解码器
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Marshal(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec[T]) Unmarshal(data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
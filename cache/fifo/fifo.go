@@ -1,18 +1,33 @@
 package fifo
 
 import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
 	"github.com/jiaxwu/gommon/cache"
 	"github.com/jiaxwu/gommon/container/list"
 )
 
+// 快照中的缓存策略名称
+const snapshotPolicy = "fifo"
+
 // 先进先出
 // 优点：公平
 // 非线程安全，请根据业务加锁
 type Cache[K comparable, V any] struct {
-	entries   map[K]*list.Element[*cache.Entry[K, V]]
-	evictList *list.List[*cache.Entry[K, V]]
-	capacity  int
-	onEvict   cache.OnEvict[K, V]
+	entries    map[K]*list.Element[*cache.Entry[K, V]]
+	evictList  *list.List[*cache.Entry[K, V]]
+	capacity   int
+	ttl        time.Duration // 默认过期时间
+	onEvict    cache.OnEvictWithReason[K, V]
+	pins       map[K]int            // 被固定的元素的固定次数
+	canEvict   cache.CanEvict[K, V] // 判断元素能否被淘汰，为空表示都可以
+	janitor    *cache.Janitor[K]
+	stats      *cache.StatsCounter // 统计，为空表示不统计
+	keyCodec   cache.Codec[K]      // 保存快照时Key的编解码器
+	valueCodec cache.Codec[V]      // 保存快照时Value的编解码器
 }
 
 func New[K comparable, V any](capacity int) *Cache[K, V] {
@@ -23,49 +38,167 @@ func New[K comparable, V any](capacity int) *Cache[K, V] {
 		entries:   make(map[K]*list.Element[*cache.Entry[K, V]]),
 		evictList: list.New[*cache.Entry[K, V]](),
 		capacity:  capacity,
+		pins:      make(map[K]int),
 	}
 }
 
 // 设置 OnEvict
 func (c *Cache[K, V]) SetOnEvict(onEvict cache.OnEvict[K, V]) {
+	c.onEvict = onEvict.WithReason()
+}
+
+// 设置 OnEvict，带上淘汰原因
+func (c *Cache[K, V]) SetOnEvictWithReason(onEvict cache.OnEvictWithReason[K, V]) {
 	c.onEvict = onEvict
 }
 
+// 设置 CanEvict，返回false的元素淘汰时会被跳过
+func (c *Cache[K, V]) SetCanEvict(canEvict cache.CanEvict[K, V]) {
+	c.canEvict = canEvict
+}
+
+// 固定元素，固定的元素不会被淘汰，可以固定多次，需要相同次数的 Unpin()
+// 不存在返回false
+func (c *Cache[K, V]) Pin(key K) bool {
+	if _, ok := c.entries[key]; !ok {
+		return false
+	}
+	c.pins[key]++
+	return true
+}
+
+// 解除一次固定，完全解除后如果超过容量则淘汰元素
+// 没有固定返回false
+func (c *Cache[K, V]) Unpin(key K) bool {
+	n, ok := c.pins[key]
+	if !ok {
+		return false
+	}
+	if n > 1 {
+		c.pins[key] = n - 1
+		return true
+	}
+	delete(c.pins, key)
+	c.evictToFit()
+	return true
+}
+
+// 元素是否被固定
+func (c *Cache[K, V]) Pinned(key K) bool {
+	return c.pins[key] > 0
+}
+
+// 设置统计计数器，为空表示不统计
+func (c *Cache[K, V]) SetStatsCounter(stats *cache.StatsCounter) {
+	c.stats = stats
+}
+
+// 获取统计信息
+func (c *Cache[K, V]) Stats() cache.Stats {
+	return c.stats.Snapshot()
+}
+
+// 设置默认过期时间，Put()会使用该过期时间，小于等于0表示永不过期
+func (c *Cache[K, V]) SetDefaultTTL(ttl time.Duration) {
+	c.ttl = ttl
+}
+
+// 启动过期清理器，到期主动删除元素，直到ctx被关闭
+// locker是业务访问缓存时使用的锁
+// 只会清理启动之后添加的元素，之前添加的元素依然在访问时删除
+func (c *Cache[K, V]) StartJanitor(ctx context.Context, locker sync.Locker) {
+	c.janitor = cache.NewJanitor(locker, c.RemoveExpired)
+	go c.janitor.Run(ctx)
+}
+
 // 添加或更新元素
 // 返回被淘汰的元素
 func (c *Cache[K, V]) Put(key K, value V) *cache.Entry[K, V] {
+	return c.PutWithTTL(key, value, c.ttl)
+}
+
+// 添加或更新元素，并设置过期时间，小于等于0表示永不过期
+// 返回被淘汰的元素
+func (c *Cache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) *cache.Entry[K, V] {
+	return c.PutWithExpiration(key, value, cache.Expiration(ttl))
+}
+
+// 添加或更新元素，并设置过期时刻，零值表示永不过期
+// 返回被淘汰的元素
+// 如果满了并且所有元素都被固定，则不会添加，直接返回该元素，可以通过 TryPut() 区分
+func (c *Cache[K, V]) PutWithExpiration(key K, value V, expiration time.Time) *cache.Entry[K, V] {
+	evicted, err := c.tryPut(key, value, expiration)
+	if err != nil {
+		return &cache.Entry[K, V]{
+			Key:        key,
+			Value:      value,
+			Expiration: expiration,
+		}
+	}
+	return evicted
+}
+
+// 添加或更新元素，拒绝添加时返回错误
+// 返回被淘汰的元素
+// 满了并且所有元素都被固定返回 cache.ErrAllPinned
+func (c *Cache[K, V]) TryPut(key K, value V) (*cache.Entry[K, V], error) {
+	return c.tryPut(key, value, cache.Expiration(c.ttl))
+}
+
+// 添加或更新元素，拒绝添加时返回错误
+func (c *Cache[K, V]) tryPut(key K, value V, expiration time.Time) (*cache.Entry[K, V], error) {
 	// 如果 key 已经存在，直接把它移到最前面，然后设置新值
 	if elem, ok := c.entries[key]; ok {
 		c.evictList.MoveToFront(elem)
 		elem.Value.Value = value
-		return nil
+		elem.Value.Expiration = expiration
+		c.schedule(key, expiration)
+		c.stats.RecordUpdate()
+		return nil, nil
 	}
 
 	// 如果已经到达最大尺寸，先剔除一个元素
 	var evicted *cache.Entry[K, V]
 	if c.Full() {
 		evicted = c.Evict()
+		if evicted == nil {
+			return nil, cache.ErrAllPinned
+		}
 	}
 
 	// 添加元素
 	elem := c.evictList.PushFront(&cache.Entry[K, V]{
-		Key:   key,
-		Value: value,
+		Key:        key,
+		Value:      value,
+		Expiration: expiration,
 	})
 	c.entries[key] = elem
-	return evicted
+	c.schedule(key, expiration)
+	c.stats.RecordPut()
+	return evicted, nil
 }
 
 // 获取元素
 func (c *Cache[K, V]) Get(key K) (V, bool) {
-	return c.Peek(key)
+	// 过期了直接删除
+	if !c.RemoveExpired(key) {
+		if value, ok := c.Peek(key); ok {
+			c.stats.RecordHit()
+			return value, true
+		}
+	}
+
+	// 不存在返回空值和false
+	c.stats.RecordMiss()
+	var value V
+	return value, false
 }
 
 // 获取元素
 func (c *Cache[K, V]) Peek(key K) (V, bool) {
 	// 如果存在直接返回
-	if elem, ok := c.entries[key]; ok {
-		return elem.Value.Value, true
+	if entry, ok := c.PeekEntry(key); ok {
+		return entry.Value, true
 	}
 
 	// 不存在返回空值和false
@@ -73,13 +206,23 @@ func (c *Cache[K, V]) Peek(key K) (V, bool) {
 	return value, false
 }
 
+// 获取缓存项，不更新状态
+func (c *Cache[K, V]) PeekEntry(key K) (*cache.Entry[K, V], bool) {
+	// 如果存在并且没有过期
+	if elem, ok := c.entries[key]; ok && !elem.Value.Expired() {
+		return elem.Value, true
+	}
+	return nil, false
+}
+
 // 是否包含元素，不更新状态
 func (c *Cache[K, V]) Contains(key K) bool {
-	_, ok := c.entries[key]
+	_, ok := c.PeekEntry(key)
 	return ok
 }
 
 // 获取缓存的Keys
+// 可能包含已经过期但还没被删除的元素
 func (c *Cache[K, V]) Keys() []K {
 	keys := make([]K, c.Len())
 	for elem, i := c.evictList.Back(), 0; elem != nil; elem, i = elem.Prev(), i+1 {
@@ -115,44 +258,53 @@ func (c *Cache[K, V]) Remove(key K) bool {
 	return false
 }
 
-// 淘汰元素
+// 移除已经过期的元素
+func (c *Cache[K, V]) RemoveExpired(key K) bool {
+	if elem, ok := c.entries[key]; ok && elem.Value.Expired() {
+		c.removeElement(elem)
+		c.doOnEvict(elem.Value, cache.EvictReasonExpired)
+		return true
+	}
+	return false
+}
+
+// 淘汰元素，跳过被固定和不能淘汰的元素
+// 没有可以淘汰的元素返回nil
 func (c *Cache[K, V]) Evict() *cache.Entry[K, V] {
-	elem := c.evictList.Back()
+	elem := c.victim()
 	if elem == nil {
 		return nil
 	}
 	c.removeElement(elem)
 	// 回调
-	if c.onEvict != nil {
-		c.onEvict(elem.Value)
+	reason := cache.EvictReasonCapacity
+	if elem.Value.Expired() {
+		reason = cache.EvictReasonExpired
 	}
+	c.doOnEvict(elem.Value, reason)
 	return elem.Value
 }
 
 // 清空缓存
 func (c *Cache[K, V]) Clear(needOnEvict bool) {
 	// 触发回调
-	if needOnEvict && c.onEvict != nil {
+	if needOnEvict {
 		for elem, i := c.evictList.Back(), 0; elem != nil; elem, i = elem.Prev(), i+1 {
-			c.onEvict(elem.Value)
+			c.doOnEvict(elem.Value, cache.EvictReasonClear)
 		}
 	}
 
 	// 清空
 	c.entries = make(map[K]*list.Element[*cache.Entry[K, V]])
 	c.evictList.Clear()
+	c.pins = make(map[K]int)
 }
 
 // 改变容量
+// 被固定的元素放不下时允许暂时超过容量
 func (c *Cache[K, V]) Resize(capacity int, needOnEvict bool) {
-	diff := c.Len() - capacity
-	if diff < 0 {
-		diff = 0
-	}
-	for i := 0; i < diff; i++ {
-		c.Evict()
-	}
 	c.capacity = capacity
+	c.evictToFit()
 }
 
 // 元素个数
@@ -167,7 +319,66 @@ func (c *Cache[K, V]) Cap() int {
 
 // 缓存满了
 func (c *Cache[K, V]) Full() bool {
-	return c.Len() == c.Cap()
+	return c.Len() >= c.Cap()
+}
+
+// 设置保存快照时使用的编解码器，为空使用GobCodec
+func (c *Cache[K, V]) SetCodec(keyCodec cache.Codec[K], valueCodec cache.Codec[V]) {
+	c.keyCodec = keyCodec
+	c.valueCodec = valueCodec
+}
+
+// 保存快照到w，包括元素的进入顺序
+func (c *Cache[K, V]) Save(w io.Writer) error {
+	return c.Snapshot().Encode(w, c.keyCodec, c.valueCodec)
+}
+
+// 从r加载快照，会先清空缓存，不触发回调
+// 快照格式不正确时缓存保持不变
+func (c *Cache[K, V]) Load(r io.Reader) error {
+	snapshot, err := cache.DecodeSnapshot(r, snapshotPolicy, c.keyCodec, c.valueCodec)
+	if err != nil {
+		return err
+	}
+	return c.Restore(snapshot)
+}
+
+// 获取快照，按淘汰顺序排列
+func (c *Cache[K, V]) Snapshot() *cache.Snapshot[K, V] {
+	snapshot := &cache.Snapshot[K, V]{
+		Policy:  snapshotPolicy,
+		Records: make([]*cache.SnapshotRecord[K, V], 0, c.Len()),
+	}
+	for elem := c.evictList.Back(); elem != nil; elem = elem.Prev() {
+		entry := *elem.Value
+		snapshot.Records = append(snapshot.Records, &cache.SnapshotRecord[K, V]{
+			Entry: &entry,
+		})
+	}
+	return snapshot
+}
+
+// 从快照恢复，会先清空缓存，不触发回调
+// 跳过已经过期的元素，放不下的元素按淘汰顺序淘汰
+func (c *Cache[K, V]) Restore(snapshot *cache.Snapshot[K, V]) error {
+	if snapshot.Policy != snapshotPolicy {
+		return cache.ErrSnapshotPolicyMismatch
+	}
+	c.Clear(false)
+	for _, record := range snapshot.Records {
+		entry := *record.Entry
+		if entry.Expired() {
+			continue
+		}
+		if elem, ok := c.entries[entry.Key]; ok {
+			c.removeElement(elem)
+		}
+		// 快照最先被淘汰的在前面，所以依次放到最前面
+		c.entries[entry.Key] = c.evictList.PushFront(&entry)
+		c.schedule(entry.Key, entry.Expiration)
+	}
+	c.evictToFit()
+	return nil
 }
 
 // 移除给定节点
@@ -175,4 +386,40 @@ func (c *Cache[K, V]) removeElement(elem *list.Element[*cache.Entry[K, V]]) {
 	c.evictList.Remove(elem)
 	entry := elem.Value
 	delete(c.entries, entry.Key)
+	delete(c.pins, entry.Key)
+}
+
+// 获取最先被淘汰的可以淘汰的节点，都不能淘汰返回nil
+// 需要跳过被固定的元素，被固定的元素很多时会变慢
+func (c *Cache[K, V]) victim() *list.Element[*cache.Entry[K, V]] {
+	for elem := c.evictList.Back(); elem != nil; elem = elem.Prev() {
+		if c.pins[elem.Value.Key] == 0 && (c.canEvict == nil || c.canEvict(elem.Value)) {
+			return elem
+		}
+	}
+	return nil
+}
+
+// 淘汰元素直到不超过容量，剩下的元素都不能淘汰时停止
+func (c *Cache[K, V]) evictToFit() {
+	for c.Len() > c.capacity {
+		if c.Evict() == nil {
+			return
+		}
+	}
+}
+
+// 添加到过期清理器
+func (c *Cache[K, V]) schedule(key K, expiration time.Time) {
+	if c.janitor != nil {
+		c.janitor.Push(key, expiration)
+	}
+}
+
+// 触发淘汰回调
+func (c *Cache[K, V]) doOnEvict(entry *cache.Entry[K, V], reason cache.EvictReason) {
+	c.stats.RecordEviction(reason)
+	if c.onEvict != nil {
+		c.onEvict(entry, reason)
+	}
 }
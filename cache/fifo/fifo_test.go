@@ -1,11 +1,15 @@
 package fifo
 
 import (
+	"bytes"
+	"errors"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/cache/cachetest"
 )
 
 func TestCache_Put(t *testing.T) {
@@ -125,6 +129,86 @@ func TestCache_Entries(t *testing.T) {
 	}
 }
 
+func TestCache_TTL(t *testing.T) {
+	cachetest.RunTTL(t, func(capacity int) cachetest.TTLPolicy {
+		return New[string, int](capacity)
+	})
+}
+
+func TestCache_Save(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Get("11")
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	c2 := New[string, int](3)
+	if err := c2.Load(&buf); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(c2.Keys(), c.Keys()) {
+		t.Errorf("Keys() = %v, want %v", c2.Keys(), c.Keys())
+	}
+	c2.Put("44", 8)
+	if c2.Contains("11") {
+		t.Errorf("Contains() = %v, want %v", true, false)
+	}
+}
+
+func TestCache_Pin(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	if !c.Pin("11") || !c.Pin("11") || c.Pin("44") {
+		t.Errorf("Pin() = %v, want %v", false, true)
+	}
+
+	// 跳过被固定的元素
+	if evicted := c.Put("44", 8); evicted == nil || evicted.Key != "22" {
+		t.Errorf("Put() = %v, want %v", evicted, "22")
+	}
+
+	// 所有元素都被固定时不会添加，直接返回该元素
+	c.Pin("33")
+	c.Pin("44")
+	if evicted := c.Put("55", 9); evicted == nil || evicted.Key != "55" || c.Contains("55") {
+		t.Errorf("Put() = %v, want %v", evicted, "55")
+	}
+	if _, err := c.TryPut("55", 9); !errors.Is(err, cache.ErrAllPinned) {
+		t.Errorf("TryPut() error = %v, want %v", err, cache.ErrAllPinned)
+	}
+
+	// 解除相同次数的固定后才能淘汰
+	c.Unpin("11")
+	if !c.Pinned("11") {
+		t.Errorf("Pinned() = %v, want %v", false, true)
+	}
+	c.Unpin("11")
+	if evicted := c.Put("55", 9); evicted == nil || evicted.Key != "11" {
+		t.Errorf("Put() = %v, want %v", evicted, "11")
+	}
+	if c.Unpin("11") {
+		t.Errorf("Unpin() = %v, want %v", true, false)
+	}
+
+	// 删除元素会清除固定
+	c.Remove("33")
+	if c.Pinned("33") {
+		t.Errorf("Pinned() = %v, want %v", true, false)
+	}
+}
+
+func TestCache_Conformance(t *testing.T) {
+	cachetest.Run(t, func(capacity int) cache.Policy[string, int] {
+		return New[string, int](capacity)
+	}, cachetest.Options{EvictionOrder: true})
+}
+
 // fifo_test.go:168: cachePercentage=0.1%, count=206048, hitCount=26556, hitRate=12.89%
 // fifo_test.go:168: cachePercentage=0.3%, count=206048, hitCount=56624, hitRate=27.48%
 // fifo_test.go:168: cachePercentage=0.5%, count=206048, hitCount=83375, hitRate=40.46%
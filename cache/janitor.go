@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jiaxwu/gommon/timer/delayqueue"
+)
+
+// 过期清理器
+// 基于延迟队列，元素到期后主动删除，而不是等到下一次访问才删除
+type Janitor[K comparable] struct {
+	queue   *delayqueue.DelayQueue[K]
+	locker  sync.Locker      // 业务访问缓存时使用的锁
+	expire  func(key K) bool // 删除过期元素
+	stopped int32            // 1表示已经停止，不再接收新的Key
+}
+
+// locker：业务访问缓存时使用的锁，删除过期元素时会先加锁
+// expire：删除过期元素，如果元素没有过期（比如被更新了）需要忽略
+func NewJanitor[K comparable](locker sync.Locker, expire func(key K) bool) *Janitor[K] {
+	return &Janitor[K]{
+		queue:  delayqueue.New[K](),
+		locker: locker,
+		expire: expire,
+	}
+}
+
+// 添加到期需要清理的Key
+func (j *Janitor[K]) Push(key K, expiration time.Time) {
+	if expiration.IsZero() || atomic.LoadInt32(&j.stopped) == 1 {
+		return
+	}
+	j.queue.Push(key, time.Until(expiration))
+}
+
+// 运行清理器，直到ctx被关闭
+func (j *Janitor[K]) Run(ctx context.Context) {
+	defer atomic.StoreInt32(&j.stopped, 1)
+	for {
+		key, ok := j.queue.Take(ctx)
+		if !ok {
+			return
+		}
+		j.locker.Lock()
+		j.expire(key)
+		j.locker.Unlock()
+	}
+}
@@ -1,10 +1,19 @@
 package lfu
 
 import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
 	"github.com/jiaxwu/gommon/cache"
 	"github.com/jiaxwu/gommon/container/list"
+	"github.com/jiaxwu/gommon/math"
 )
 
+// 快照中的缓存策略名称
+const snapshotPolicy = "lfu"
+
 type frequencyEntry[K comparable, V any] struct {
 	entry     *cache.Entry[K, V]
 	frequency uint64 // 频率
@@ -15,10 +24,17 @@ type frequencyEntry[K comparable, V any] struct {
 // 缺点：如果访问模式改变，可能会导致某些很少访问的数据难以被置换出去
 // 非线程安全，请根据业务加锁
 type Cache[K comparable, V any] struct {
-	entries   map[K]*list.Element[*frequencyEntry[K, V]]
-	evictList *list.List[*frequencyEntry[K, V]]
-	capacity  int
-	onEvict   cache.OnEvict[K, V]
+	entries    map[K]*list.Element[*frequencyEntry[K, V]]
+	evictList  *list.List[*frequencyEntry[K, V]]
+	capacity   int
+	cost       int64               // 当前总权重
+	weigher    cache.Weigher[K, V] // 计算元素权重，为空则每个元素权重为1
+	ttl        time.Duration       // 默认过期时间
+	onEvict    cache.OnEvictWithReason[K, V]
+	janitor    *cache.Janitor[K]
+	stats      *cache.StatsCounter // 统计，为空表示不统计
+	keyCodec   cache.Codec[K]      // 保存快照时Key的编解码器
+	valueCodec cache.Codec[V]      // 保存快照时Value的编解码器
 }
 
 func New[K comparable, V any](capacity int) *Cache[K, V] {
@@ -34,34 +50,102 @@ func New[K comparable, V any](capacity int) *Cache[K, V] {
 
 // 设置 OnEvict
 func (c *Cache[K, V]) SetOnEvict(onEvict cache.OnEvict[K, V]) {
+	c.onEvict = onEvict.WithReason()
+}
+
+// 设置 OnEvict，带上淘汰原因
+func (c *Cache[K, V]) SetOnEvictWithReason(onEvict cache.OnEvictWithReason[K, V]) {
 	c.onEvict = onEvict
 }
 
+// 设置权重计算函数，设置后容量表示总权重，而不是元素个数
+func (c *Cache[K, V]) SetWeigher(weigher cache.Weigher[K, V]) {
+	c.weigher = weigher
+	// 重新计算总权重
+	c.cost = 0
+	for elem := c.evictList.Back(); elem != nil; elem = elem.Prev() {
+		c.cost += c.weigh(elem.Value.entry.Key, elem.Value.entry.Value)
+	}
+	c.evictToFit(0)
+}
+
+// 设置统计计数器，为空表示不统计
+func (c *Cache[K, V]) SetStatsCounter(stats *cache.StatsCounter) {
+	c.stats = stats
+}
+
+// 获取统计信息
+func (c *Cache[K, V]) Stats() cache.Stats {
+	return c.stats.Snapshot()
+}
+
+// 设置默认过期时间，Put()会使用该过期时间，小于等于0表示永不过期
+func (c *Cache[K, V]) SetDefaultTTL(ttl time.Duration) {
+	c.ttl = ttl
+}
+
+// 启动过期清理器，到期主动删除元素，直到ctx被关闭
+// locker是业务访问缓存时使用的锁
+// 只会清理启动之后添加的元素，之前添加的元素依然在访问时删除
+func (c *Cache[K, V]) StartJanitor(ctx context.Context, locker sync.Locker) {
+	c.janitor = cache.NewJanitor(locker, c.RemoveExpired)
+	go c.janitor.Run(ctx)
+}
+
 // 添加或更新元素
 // 返回被淘汰的元素
 func (c *Cache[K, V]) Put(key K, value V) *cache.Entry[K, V] {
+	return c.PutWithTTL(key, value, c.ttl)
+}
+
+// 添加或更新元素，并设置过期时间，小于等于0表示永不过期
+// 返回被淘汰的元素
+func (c *Cache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) *cache.Entry[K, V] {
+	return c.PutWithExpiration(key, value, cache.Expiration(ttl))
+}
+
+// 添加或更新元素，并设置过期时刻，零值表示永不过期
+// 返回被淘汰的元素，如果淘汰了多个元素，返回最后一个
+// 如果元素权重超过容量，则不会添加，直接返回该元素
+func (c *Cache[K, V]) PutWithExpiration(key K, value V, expiration time.Time) *cache.Entry[K, V] {
+	weight := c.weigh(key, value)
+	if weight > int64(c.capacity) {
+		c.Remove(key)
+		return &cache.Entry[K, V]{
+			Key:        key,
+			Value:      value,
+			Expiration: expiration,
+		}
+	}
+
 	// 如果 key 已经存在，直接更新淘汰顺序，然后设置新值
 	if elem, ok := c.entries[key]; ok {
 		c.updateEvictList(elem)
+		c.cost += weight - c.weigh(key, elem.Value.entry.Value)
 		elem.Value.entry.Value = value
-		return nil
+		elem.Value.entry.Expiration = expiration
+		c.schedule(key, expiration)
+		c.stats.RecordUpdate()
+		// 权重变大可能需要淘汰元素
+		return c.evictToFit(0)
 	}
 
-	// 如果已经到达最大尺寸，先剔除一个元素
-	var evicted *cache.Entry[K, V]
-	if c.Full() {
-		evicted = c.Evict()
-	}
+	// 如果放不下，先剔除元素
+	evicted := c.evictToFit(weight)
 
 	// 添加元素
 	elem := c.evictList.PushBack(&frequencyEntry[K, V]{
 		entry: &cache.Entry[K, V]{
-			Key:   key,
-			Value: value,
+			Key:        key,
+			Value:      value,
+			Expiration: expiration,
 		},
 		frequency: 1,
 	})
 	c.entries[key] = elem
+	c.cost += weight
+	c.schedule(key, expiration)
+	c.stats.RecordPut()
 	return evicted
 }
 
@@ -70,12 +154,21 @@ func (c *Cache[K, V]) Get(key K) (V, bool) {
 	// 如果存在频率+1，然后返回
 	if elem, ok := c.entries[key]; ok {
 		entry := elem.Value
+		// 过期了直接删除
+		if entry.entry.Expired() {
+			c.expireElement(elem)
+			c.stats.RecordMiss()
+			var value V
+			return value, false
+		}
 		entry.frequency++
 		c.updateEvictList(elem)
+		c.stats.RecordHit()
 		return entry.entry.Value, true
 	}
 
 	// 不存在返回空值和false
+	c.stats.RecordMiss()
 	var value V
 	return value, false
 }
@@ -83,8 +176,8 @@ func (c *Cache[K, V]) Get(key K) (V, bool) {
 // 获取元素，不更新状态
 func (c *Cache[K, V]) Peek(key K) (V, bool) {
 	// 如果存在
-	if elem, ok := c.entries[key]; ok {
-		return elem.Value.entry.Value, true
+	if entry, ok := c.PeekEntry(key); ok {
+		return entry.Value, true
 	}
 
 	// 不存在返回空值和false
@@ -92,13 +185,23 @@ func (c *Cache[K, V]) Peek(key K) (V, bool) {
 	return value, false
 }
 
+// 获取缓存项，不更新状态
+func (c *Cache[K, V]) PeekEntry(key K) (*cache.Entry[K, V], bool) {
+	// 如果存在并且没有过期
+	if elem, ok := c.entries[key]; ok && !elem.Value.entry.Expired() {
+		return elem.Value.entry, true
+	}
+	return nil, false
+}
+
 // 是否包含元素，不更新状态
 func (c *Cache[K, V]) Contains(key K) bool {
-	_, ok := c.entries[key]
+	_, ok := c.PeekEntry(key)
 	return ok
 }
 
 // 获取缓存的Keys
+// 可能包含已经过期但还没被删除的元素
 func (c *Cache[K, V]) Keys() []K {
 	keys := make([]K, c.Len())
 	for elem, i := c.evictList.Back(), 0; elem != nil; elem, i = elem.Prev(), i+1 {
@@ -125,6 +228,19 @@ func (c *Cache[K, V]) Entries() []*cache.Entry[K, V] {
 	return entries
 }
 
+// 按淘汰顺序，也就是从低频到高频遍历元素，f返回false时停止，不更新状态，不分配内存
+// 可能包含已经过期但还没被删除的元素
+// 遍历期间可以删除当前元素，其他修改的结果是未定义的
+func (c *Cache[K, V]) Range(f func(key K, value V) bool) {
+	for elem := c.evictList.Back(); elem != nil; {
+		prev := elem.Prev()
+		if !f(elem.Value.entry.Key, elem.Value.entry.Value) {
+			return
+		}
+		elem = prev
+	}
+}
+
 // 移除元素
 func (c *Cache[K, V]) Remove(key K) bool {
 	if elem, ok := c.entries[key]; ok {
@@ -134,6 +250,15 @@ func (c *Cache[K, V]) Remove(key K) bool {
 	return false
 }
 
+// 移除已经过期的元素
+func (c *Cache[K, V]) RemoveExpired(key K) bool {
+	if elem, ok := c.entries[key]; ok && elem.Value.entry.Expired() {
+		c.expireElement(elem)
+		return true
+	}
+	return false
+}
+
 // 淘汰元素
 func (c *Cache[K, V]) Evict() *cache.Entry[K, V] {
 	elem := c.evictList.Back()
@@ -143,36 +268,33 @@ func (c *Cache[K, V]) Evict() *cache.Entry[K, V] {
 	c.removeElement(elem)
 	entry := elem.Value.entry
 	// 回调
-	if c.onEvict != nil {
-		c.onEvict(entry)
+	reason := cache.EvictReasonCapacity
+	if entry.Expired() {
+		reason = cache.EvictReasonExpired
 	}
+	c.doOnEvict(entry, reason)
 	return entry
 }
 
 // 清空缓存
 func (c *Cache[K, V]) Clear(needOnEvict bool) {
 	// 触发回调
-	if needOnEvict && c.onEvict != nil {
+	if needOnEvict {
 		for elem, i := c.evictList.Back(), 0; elem != nil; elem, i = elem.Prev(), i+1 {
-			c.onEvict(elem.Value.entry)
+			c.doOnEvict(elem.Value.entry, cache.EvictReasonClear)
 		}
 	}
 
 	// 清空
 	c.entries = make(map[K]*list.Element[*frequencyEntry[K, V]])
 	c.evictList.Clear()
+	c.cost = 0
 }
 
 // 改变容量
 func (c *Cache[K, V]) Resize(capacity int, needOnEvict bool) {
-	diff := c.Len() - capacity
-	if diff < 0 {
-		diff = 0
-	}
-	for i := 0; i < diff; i++ {
-		c.Evict()
-	}
 	c.capacity = capacity
+	c.evictToFit(0)
 }
 
 // 元素个数
@@ -180,14 +302,83 @@ func (c *Cache[K, V]) Len() int {
 	return len(c.entries)
 }
 
-// 容量
+// 当前总权重，没有设置权重计算函数时等于元素个数
+func (c *Cache[K, V]) Cost() int64 {
+	return c.cost
+}
+
+// 容量，设置了权重计算函数时表示总权重
 func (c *Cache[K, V]) Cap() int {
 	return c.capacity
 }
 
 // 缓存满了
 func (c *Cache[K, V]) Full() bool {
-	return c.Len() == c.Cap()
+	return c.Cost() >= int64(c.Cap())
+}
+
+// 设置保存快照时使用的编解码器，为空使用GobCodec
+func (c *Cache[K, V]) SetCodec(keyCodec cache.Codec[K], valueCodec cache.Codec[V]) {
+	c.keyCodec = keyCodec
+	c.valueCodec = valueCodec
+}
+
+// 保存快照到w，包括元素的访问频率和淘汰顺序
+func (c *Cache[K, V]) Save(w io.Writer) error {
+	return c.Snapshot().Encode(w, c.keyCodec, c.valueCodec)
+}
+
+// 从r加载快照，会先清空缓存，不触发回调
+// 快照格式不正确时缓存保持不变
+func (c *Cache[K, V]) Load(r io.Reader) error {
+	snapshot, err := cache.DecodeSnapshot(r, snapshotPolicy, c.keyCodec, c.valueCodec)
+	if err != nil {
+		return err
+	}
+	return c.Restore(snapshot)
+}
+
+// 获取快照，按淘汰顺序排列
+func (c *Cache[K, V]) Snapshot() *cache.Snapshot[K, V] {
+	snapshot := &cache.Snapshot[K, V]{
+		Policy:  snapshotPolicy,
+		Records: make([]*cache.SnapshotRecord[K, V], 0, c.Len()),
+	}
+	for elem := c.evictList.Back(); elem != nil; elem = elem.Prev() {
+		entry := *elem.Value.entry
+		snapshot.Records = append(snapshot.Records, &cache.SnapshotRecord[K, V]{
+			Entry:     &entry,
+			Frequency: elem.Value.frequency,
+		})
+	}
+	return snapshot
+}
+
+// 从快照恢复，会先清空缓存，不触发回调
+// 跳过已经过期的元素，放不下的元素按淘汰顺序淘汰
+func (c *Cache[K, V]) Restore(snapshot *cache.Snapshot[K, V]) error {
+	if snapshot.Policy != snapshotPolicy {
+		return cache.ErrSnapshotPolicyMismatch
+	}
+	c.Clear(false)
+	for _, record := range snapshot.Records {
+		entry := *record.Entry
+		if entry.Expired() {
+			continue
+		}
+		if elem, ok := c.entries[entry.Key]; ok {
+			c.removeElement(elem)
+		}
+		// 快照最先被淘汰的在前面，所以依次放到最前面
+		c.entries[entry.Key] = c.evictList.PushFront(&frequencyEntry[K, V]{
+			entry:     &entry,
+			frequency: math.Max(record.Frequency, 1),
+		})
+		c.cost += c.weigh(entry.Key, entry.Value)
+		c.schedule(entry.Key, entry.Expiration)
+	}
+	c.evictToFit(0)
+	return nil
 }
 
 // 移除给定节点
@@ -195,6 +386,25 @@ func (c *Cache[K, V]) removeElement(elem *list.Element[*frequencyEntry[K, V]]) {
 	c.evictList.Remove(elem)
 	entry := elem.Value
 	delete(c.entries, entry.entry.Key)
+	c.cost -= c.weigh(entry.entry.Key, entry.entry.Value)
+}
+
+// 淘汰元素直到能放下给定权重的元素
+// 返回最后一个被淘汰的元素
+func (c *Cache[K, V]) evictToFit(weight int64) *cache.Entry[K, V] {
+	var evicted *cache.Entry[K, V]
+	for c.Len() > 0 && c.cost+weight > int64(c.capacity) {
+		evicted = c.Evict()
+	}
+	return evicted
+}
+
+// 计算元素权重
+func (c *Cache[K, V]) weigh(key K, value V) int64 {
+	if c.weigher == nil {
+		return 1
+	}
+	return c.weigher(key, value)
 }
 
 // 更新淘汰顺序
@@ -204,3 +414,24 @@ func (c *Cache[K, V]) updateEvictList(elem *list.Element[*frequencyEntry[K, V]])
 		c.evictList.MoveBefore(elem, elem.Prev())
 	}
 }
+
+// 删除过期节点
+func (c *Cache[K, V]) expireElement(elem *list.Element[*frequencyEntry[K, V]]) {
+	c.removeElement(elem)
+	c.doOnEvict(elem.Value.entry, cache.EvictReasonExpired)
+}
+
+// 添加到过期清理器
+func (c *Cache[K, V]) schedule(key K, expiration time.Time) {
+	if c.janitor != nil {
+		c.janitor.Push(key, expiration)
+	}
+}
+
+// 触发淘汰回调
+func (c *Cache[K, V]) doOnEvict(entry *cache.Entry[K, V], reason cache.EvictReason) {
+	c.stats.RecordEviction(reason)
+	if c.onEvict != nil {
+		c.onEvict(entry, reason)
+	}
+}
@@ -1,11 +1,14 @@
 package lfu
 
 import (
+	"bytes"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/cache/cachetest"
 )
 
 func TestCache_Put(t *testing.T) {
@@ -129,6 +132,85 @@ func TestCache_Entries(t *testing.T) {
 	}
 }
 
+func TestCache_Range(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Get("11")
+	c.Put("44", 8)
+
+	// 和 Keys() 顺序一致，不更新状态
+	keys := c.Keys()
+	var ranged []string
+	c.Range(func(key string, value int) bool {
+		ranged = append(ranged, key)
+		return true
+	})
+	if !reflect.DeepEqual(ranged, keys) || !reflect.DeepEqual(c.Keys(), keys) {
+		t.Errorf("Range() = %v, want %v", ranged, keys)
+	}
+
+	// 提前停止
+	n := 0
+	c.Range(func(key string, value int) bool {
+		n++
+		return n < 2
+	})
+	if n != 2 {
+		t.Errorf("n = %v, want %v", n, 2)
+	}
+
+	// 遍历期间删除当前元素
+	c.Range(func(key string, value int) bool {
+		c.Remove(key)
+		return true
+	})
+	if c.Len() != 0 {
+		t.Errorf("Len() = %v, want %v", c.Len(), 0)
+	}
+}
+
+func TestCache_TTL(t *testing.T) {
+	cachetest.RunTTL(t, func(capacity int) cachetest.TTLPolicy {
+		return New[string, int](capacity)
+	})
+}
+
+func TestCache_Save(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Get("11")
+	c.Get("11")
+	c.Get("33")
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// 恢复后访问频率不变
+	c2 := New[string, int](3)
+	if err := c2.Load(&buf); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(c2.Keys(), c.Keys()) {
+		t.Errorf("Keys() = %v, want %v", c2.Keys(), c.Keys())
+	}
+	c2.Get("22")
+	c2.Put("44", 8)
+	if c2.Contains("22") || !c2.Contains("33") {
+		t.Errorf("Keys() = %v, want %v", c2.Keys(), []string{"44", "33", "11"})
+	}
+}
+
+func TestCache_Conformance(t *testing.T) {
+	cachetest.Run(t, func(capacity int) cache.Policy[string, int] {
+		return New[string, int](capacity)
+	}, cachetest.Options{EvictionOrder: true})
+}
+
 // lfu_test.go:172: cachePercentage=0.1%, count=206048, hitCount=28322, hitRate=13.75%
 // lfu_test.go:172: cachePercentage=0.3%, count=206048, hitCount=59827, hitRate=29.04%
 // lfu_test.go:172: cachePercentage=0.5%, count=206048, hitCount=88984, hitRate=43.19%
@@ -0,0 +1,623 @@
+package lirs
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/container/list"
+	"github.com/jiaxwu/gommon/math"
+	"github.com/jiaxwu/gommon/slices"
+)
+
+const (
+	// HIR元素比例
+	hirPercentage = 0.01
+	// 快照中的缓存策略名称
+	snapshotPolicy = "lirs"
+)
+
+// 快照中元素的状态
+const (
+	segmentLIR         uint8 = iota // LIR元素
+	segmentHIR                      // 在缓存中的HIR元素
+	segmentNonResident              // 不在缓存中的HIR元素，只有Key
+)
+
+type lirsEntry[K comparable, V any] struct {
+	entry     *cache.Entry[K, V]
+	lir       bool
+	resident  bool
+	stackElem *list.Element[*lirsEntry[K, V]] // 在栈中的节点，为空表示不在栈中
+	queueElem *list.Element[*lirsEntry[K, V]] // 在HIR队列中的节点
+	ghostElem *list.Element[*lirsEntry[K, V]] // 在非驻留队列中的节点
+}
+
+// LIRS
+// 根据重用距离（两次访问之间访问过的不同元素个数）区分LIR和HIR元素
+// 栈S按访问顺序保存LIR元素和最近访问过的HIR元素，栈底一定是LIR元素
+// 队列Q保存在缓存中的HIR元素，淘汰时淘汰Q中最早的元素，它如果还在栈中就变成非驻留元素
+// HIR元素在栈中被再次访问说明重用距离比最老的LIR元素短，变成LIR元素，栈底的LIR元素变成HIR元素
+// 优点：只访问一次的元素一直是HIR元素，顺序扫描和循环访问不会冲掉LIR元素
+// 容量表示缓存的元素个数，不支持权重
+// 非线程安全，请根据业务加锁
+// https://dl.acm.org/doi/10.1145/511399.511340
+type Cache[K comparable, V any] struct {
+	entries    map[K]*lirsEntry[K, V]       // 包括非驻留元素
+	stack      *list.List[*lirsEntry[K, V]] // 栈S，头部是栈顶
+	queue      *list.List[*lirsEntry[K, V]] // 队列Q，尾部最先被淘汰
+	ghosts     *list.List[*lirsEntry[K, V]] // 非驻留元素，个数不超过容量
+	capacity   int
+	lirCap     int           // LIR元素个数上限
+	lirCount   int           // LIR元素个数
+	ttl        time.Duration // 默认过期时间
+	onEvict    cache.OnEvictWithReason[K, V]
+	janitor    *cache.Janitor[K]
+	stats      *cache.StatsCounter // 统计，为空表示不统计
+	keyCodec   cache.Codec[K]      // 保存快照时Key的编解码器
+	valueCodec cache.Codec[V]      // 保存快照时Value的编解码器
+}
+
+func New[K comparable, V any](capacity int) *Cache[K, V] {
+	if capacity < 1 {
+		panic("too small capacity")
+	}
+	return &Cache[K, V]{
+		entries:  make(map[K]*lirsEntry[K, V]),
+		stack:    list.New[*lirsEntry[K, V]](),
+		queue:    list.New[*lirsEntry[K, V]](),
+		ghosts:   list.New[*lirsEntry[K, V]](),
+		capacity: capacity,
+		lirCap:   lirCap(capacity),
+	}
+}
+
+// 设置 OnEvict
+func (c *Cache[K, V]) SetOnEvict(onEvict cache.OnEvict[K, V]) {
+	c.onEvict = onEvict.WithReason()
+}
+
+// 设置 OnEvict，带上淘汰原因
+func (c *Cache[K, V]) SetOnEvictWithReason(onEvict cache.OnEvictWithReason[K, V]) {
+	c.onEvict = onEvict
+}
+
+// 设置统计计数器，为空表示不统计
+func (c *Cache[K, V]) SetStatsCounter(stats *cache.StatsCounter) {
+	c.stats = stats
+}
+
+// 获取统计信息
+func (c *Cache[K, V]) Stats() cache.Stats {
+	return c.stats.Snapshot()
+}
+
+// 设置默认过期时间，Put()会使用该过期时间，小于等于0表示永不过期
+func (c *Cache[K, V]) SetDefaultTTL(ttl time.Duration) {
+	c.ttl = ttl
+}
+
+// 启动过期清理器，到期主动删除元素，直到ctx被关闭
+// locker是业务访问缓存时使用的锁
+// 只会清理启动之后添加的元素，之前添加的元素依然在访问时删除
+func (c *Cache[K, V]) StartJanitor(ctx context.Context, locker sync.Locker) {
+	c.janitor = cache.NewJanitor(locker, c.RemoveExpired)
+	go c.janitor.Run(ctx)
+}
+
+// 添加或更新元素
+// 返回被淘汰的元素
+func (c *Cache[K, V]) Put(key K, value V) *cache.Entry[K, V] {
+	return c.PutWithTTL(key, value, c.ttl)
+}
+
+// 添加或更新元素，并设置过期时间，小于等于0表示永不过期
+// 返回被淘汰的元素
+func (c *Cache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) *cache.Entry[K, V] {
+	return c.PutWithExpiration(key, value, cache.Expiration(ttl))
+}
+
+// 添加或更新元素，并设置过期时刻，零值表示永不过期
+// 返回被淘汰的元素
+func (c *Cache[K, V]) PutWithExpiration(key K, value V, expiration time.Time) *cache.Entry[K, V] {
+	// 如果 key 已经在缓存中，当作一次访问，然后设置新值
+	le, ok := c.entries[key]
+	if ok && le.resident {
+		le.entry.Value = value
+		le.entry.Expiration = expiration
+		c.access(le)
+		c.schedule(key, expiration)
+		c.stats.RecordUpdate()
+		return nil
+	}
+
+	// 如果放不下，先剔除元素
+	var evicted *cache.Entry[K, V]
+	if c.Len() >= c.capacity {
+		evicted = c.Evict()
+		// 淘汰时可能因为栈剪枝被删除
+		le, ok = c.entries[key]
+	}
+
+	entry := &cache.Entry[K, V]{
+		Key:        key,
+		Value:      value,
+		Expiration: expiration,
+	}
+	if ok {
+		// 非驻留元素在栈中被再次访问，变成LIR元素
+		c.ghosts.Remove(le.ghostElem)
+		le.ghostElem = nil
+		le.entry = entry
+		le.resident = true
+		c.stack.MoveToFront(le.stackElem)
+		c.promote(le)
+	} else {
+		le = &lirsEntry[K, V]{
+			entry:    entry,
+			resident: true,
+		}
+		c.entries[key] = le
+		le.stackElem = c.stack.PushFront(le)
+		if c.lirCount < c.lirCap {
+			// LIR元素没满时直接作为LIR元素
+			le.lir = true
+			c.lirCount++
+		} else {
+			le.queueElem = c.queue.PushFront(le)
+		}
+	}
+	c.schedule(key, expiration)
+	c.stats.RecordPut()
+	return evicted
+}
+
+// 获取元素
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	// 如果在缓存中调整位置，然后返回
+	if le, ok := c.entries[key]; ok && le.resident {
+		// 过期了直接删除
+		if le.entry.Expired() {
+			c.expireEntry(le)
+			c.stats.RecordMiss()
+			var value V
+			return value, false
+		}
+		c.access(le)
+		c.stats.RecordHit()
+		return le.entry.Value, true
+	}
+
+	// 不存在返回空值和false
+	c.stats.RecordMiss()
+	var value V
+	return value, false
+}
+
+// 获取元素，不更新状态
+func (c *Cache[K, V]) Peek(key K) (V, bool) {
+	// 如果存在
+	if entry, ok := c.PeekEntry(key); ok {
+		return entry.Value, true
+	}
+
+	// 不存在返回空值和false
+	var value V
+	return value, false
+}
+
+// 获取缓存项，不更新状态
+func (c *Cache[K, V]) PeekEntry(key K) (*cache.Entry[K, V], bool) {
+	// 如果在缓存中并且没有过期
+	if le, ok := c.entries[key]; ok && le.resident && !le.entry.Expired() {
+		return le.entry, true
+	}
+	return nil, false
+}
+
+// 是否包含元素，不更新状态
+func (c *Cache[K, V]) Contains(key K) bool {
+	_, ok := c.PeekEntry(key)
+	return ok
+}
+
+// 获取缓存的Keys，先是HIR元素，然后是LIR元素，按淘汰顺序排列
+// 可能包含已经过期但还没被删除的元素
+func (c *Cache[K, V]) Keys() []K {
+	keys := make([]K, 0, c.Len())
+	c.each(func(le *lirsEntry[K, V]) {
+		keys = append(keys, le.entry.Key)
+	})
+	return keys
+}
+
+// 获取缓存的Values
+func (c *Cache[K, V]) Values() []V {
+	values := make([]V, 0, c.Len())
+	c.each(func(le *lirsEntry[K, V]) {
+		values = append(values, le.entry.Value)
+	})
+	return values
+}
+
+// 获取缓存的Entries
+func (c *Cache[K, V]) Entries() []*cache.Entry[K, V] {
+	entries := make([]*cache.Entry[K, V], 0, c.Len())
+	c.each(func(le *lirsEntry[K, V]) {
+		entries = append(entries, le.entry)
+	})
+	return entries
+}
+
+// 移除元素
+func (c *Cache[K, V]) Remove(key K) bool {
+	if le, ok := c.entries[key]; ok && le.resident {
+		c.removeEntry(le)
+		return true
+	}
+	return false
+}
+
+// 移除已经过期的元素
+func (c *Cache[K, V]) RemoveExpired(key K) bool {
+	if le, ok := c.entries[key]; ok && le.resident && le.entry.Expired() {
+		c.expireEntry(le)
+		return true
+	}
+	return false
+}
+
+// 淘汰元素
+// 淘汰队列Q尾部的HIR元素，它如果还在栈中就变成非驻留元素
+// 没有HIR元素时淘汰栈底的LIR元素
+func (c *Cache[K, V]) Evict() *cache.Entry[K, V] {
+	if c.Len() == 0 {
+		return nil
+	}
+	var entry *cache.Entry[K, V]
+	if elem := c.queue.Back(); elem != nil {
+		le := elem.Value
+		entry = le.entry
+		c.queue.Remove(elem)
+		le.queueElem = nil
+		if le.stackElem != nil {
+			le.resident = false
+			le.entry = &cache.Entry[K, V]{Key: entry.Key}
+			c.addGhost(le)
+		} else {
+			delete(c.entries, entry.Key)
+		}
+	} else {
+		le := c.stack.Back().Value
+		entry = le.entry
+		c.removeEntry(le)
+	}
+	// 回调
+	reason := cache.EvictReasonCapacity
+	if entry.Expired() {
+		reason = cache.EvictReasonExpired
+	}
+	c.doOnEvict(entry, reason)
+	return entry
+}
+
+// 获取可能被淘汰的元素
+func (c *Cache[K, V]) Victim() *cache.Entry[K, V] {
+	if c.Len() == 0 {
+		return nil
+	}
+	if elem := c.queue.Back(); elem != nil {
+		return elem.Value.entry
+	}
+	return c.stack.Back().Value.entry
+}
+
+// 清空缓存
+func (c *Cache[K, V]) Clear(needOnEvict bool) {
+	// 触发回调
+	if needOnEvict {
+		c.each(func(le *lirsEntry[K, V]) {
+			c.doOnEvict(le.entry, cache.EvictReasonClear)
+		})
+	}
+
+	// 清空
+	c.entries = make(map[K]*lirsEntry[K, V])
+	c.stack.Clear()
+	c.queue.Clear()
+	c.ghosts.Clear()
+	c.lirCount = 0
+}
+
+// 改变容量
+func (c *Cache[K, V]) Resize(capacity int, needOnEvict bool) {
+	c.capacity = capacity
+	c.lirCap = lirCap(capacity)
+	for c.Len() > capacity {
+		c.Evict()
+	}
+	// LIR元素超过上限时把栈底的LIR元素变成HIR元素
+	for c.lirCount > c.lirCap {
+		c.demote()
+	}
+	for c.ghosts.Len() > capacity {
+		c.removeGhost(c.ghosts.Back().Value)
+	}
+}
+
+// 元素个数，不包括非驻留元素
+func (c *Cache[K, V]) Len() int {
+	return c.lirCount + c.queue.Len()
+}
+
+// 当前总权重，等于元素个数
+func (c *Cache[K, V]) Cost() int64 {
+	return int64(c.Len())
+}
+
+// 容量
+func (c *Cache[K, V]) Cap() int {
+	return c.capacity
+}
+
+// 缓存满了
+func (c *Cache[K, V]) Full() bool {
+	return c.Len() >= c.capacity
+}
+
+// 设置保存快照时使用的编解码器，为空使用GobCodec
+func (c *Cache[K, V]) SetCodec(keyCodec cache.Codec[K], valueCodec cache.Codec[V]) {
+	c.keyCodec = keyCodec
+	c.valueCodec = valueCodec
+}
+
+// 保存快照到w，包括元素的状态、栈和队列中的顺序
+func (c *Cache[K, V]) Save(w io.Writer) error {
+	return c.Snapshot().Encode(w, c.keyCodec, c.valueCodec)
+}
+
+// 从r加载快照，会先清空缓存，不触发回调
+// 快照格式不正确时缓存保持不变
+func (c *Cache[K, V]) Load(r io.Reader) error {
+	snapshot, err := cache.DecodeSnapshot(r, snapshotPolicy, c.keyCodec, c.valueCodec)
+	if err != nil {
+		return err
+	}
+	return c.Restore(snapshot)
+}
+
+// 获取快照，依次是HIR元素、LIR元素、非驻留元素，HIR元素按队列Q中的顺序，其他按栈中的顺序
+// Frequency是在栈中的位置，从栈底开始为1，0表示不在栈中
+// 非驻留元素只有Key，Value是零值
+func (c *Cache[K, V]) Snapshot() *cache.Snapshot[K, V] {
+	snapshot := &cache.Snapshot[K, V]{
+		Policy:  snapshotPolicy,
+		Records: make([]*cache.SnapshotRecord[K, V], 0, len(c.entries)),
+	}
+	positions := make(map[*lirsEntry[K, V]]uint64, c.stack.Len())
+	for elem, i := c.stack.Back(), uint64(1); elem != nil; elem, i = elem.Prev(), i+1 {
+		positions[elem.Value] = i
+	}
+	record := func(le *lirsEntry[K, V], segment uint8) {
+		entry := *le.entry
+		snapshot.Records = append(snapshot.Records, &cache.SnapshotRecord[K, V]{
+			Entry:     &entry,
+			Segment:   segment,
+			Frequency: positions[le],
+		})
+	}
+	for elem := c.queue.Back(); elem != nil; elem = elem.Prev() {
+		record(elem.Value, segmentHIR)
+	}
+	for elem := c.stack.Back(); elem != nil; elem = elem.Prev() {
+		if elem.Value.lir {
+			record(elem.Value, segmentLIR)
+		}
+	}
+	for elem := c.stack.Back(); elem != nil; elem = elem.Prev() {
+		if !elem.Value.resident {
+			record(elem.Value, segmentNonResident)
+		}
+	}
+	return snapshot
+}
+
+// 从快照恢复，会先清空缓存，不触发回调
+// 跳过已经过期的元素，放不下的元素按淘汰顺序淘汰
+func (c *Cache[K, V]) Restore(snapshot *cache.Snapshot[K, V]) error {
+	if snapshot.Policy != snapshotPolicy {
+		return cache.ErrSnapshotPolicyMismatch
+	}
+	for _, record := range snapshot.Records {
+		if record.Segment > segmentNonResident {
+			return cache.ErrInvalidSnapshot
+		}
+	}
+	c.Clear(false)
+	type stackEntry struct {
+		le       *lirsEntry[K, V]
+		position uint64
+	}
+	var stackEntries []stackEntry
+	for _, record := range snapshot.Records {
+		entry := *record.Entry
+		if _, ok := c.entries[entry.Key]; ok {
+			continue
+		}
+		if record.Segment != segmentNonResident && entry.Expired() {
+			continue
+		}
+		le := &lirsEntry[K, V]{
+			entry:    &entry,
+			lir:      record.Segment == segmentLIR,
+			resident: record.Segment != segmentNonResident,
+		}
+		// LIR元素和非驻留元素一定在栈中
+		if record.Frequency == 0 && record.Segment != segmentHIR {
+			continue
+		}
+		c.entries[entry.Key] = le
+		if record.Frequency > 0 {
+			stackEntries = append(stackEntries, stackEntry{le: le, position: record.Frequency})
+		}
+		switch record.Segment {
+		case segmentLIR:
+			c.lirCount++
+		case segmentHIR:
+			// 快照最先淘汰的在前面，所以依次放到最前面
+			le.queueElem = c.queue.PushFront(le)
+		}
+		if le.resident {
+			c.schedule(entry.Key, entry.Expiration)
+		}
+	}
+	// 按栈中的位置恢复栈，栈底在前面
+	slices.Sort(stackEntries, func(entry1, entry2 stackEntry) bool {
+		return entry1.position < entry2.position
+	})
+	for _, se := range stackEntries {
+		se.le.stackElem = c.stack.PushFront(se.le)
+		if !se.le.resident {
+			c.addGhost(se.le)
+		}
+	}
+	c.prune()
+	for c.Len() > c.capacity {
+		c.Evict()
+	}
+	for c.lirCount > c.lirCap {
+		c.demote()
+	}
+	return nil
+}
+
+// 访问元素
+func (c *Cache[K, V]) access(le *lirsEntry[K, V]) {
+	if le.lir {
+		// LIR元素移动到栈顶，如果原来在栈底需要剪枝
+		c.stack.MoveToFront(le.stackElem)
+		c.prune()
+		return
+	}
+	if le.stackElem == nil {
+		// 不在栈中的HIR元素放到栈顶，移动到队列Q头部，依然是HIR元素
+		le.stackElem = c.stack.PushFront(le)
+		c.queue.MoveToFront(le.queueElem)
+		return
+	}
+	// 在栈中的HIR元素重用距离比栈底的LIR元素短，变成LIR元素
+	c.stack.MoveToFront(le.stackElem)
+	c.queue.Remove(le.queueElem)
+	le.queueElem = nil
+	c.promote(le)
+}
+
+// 把HIR元素变成LIR元素，LIR元素超过上限时把栈底的LIR元素变成HIR元素
+func (c *Cache[K, V]) promote(le *lirsEntry[K, V]) {
+	le.lir = true
+	c.lirCount++
+	for c.lirCount > c.lirCap {
+		c.demote()
+	}
+}
+
+// 把栈底的LIR元素变成HIR元素，放到队列Q头部，然后剪枝
+func (c *Cache[K, V]) demote() {
+	le := c.stack.Back().Value
+	le.lir = false
+	c.lirCount--
+	c.stack.Remove(le.stackElem)
+	le.stackElem = nil
+	le.queueElem = c.queue.PushFront(le)
+	c.prune()
+}
+
+// 栈剪枝，删除栈底的HIR元素，保证栈底是LIR元素
+// 非驻留元素离开栈之后不再需要记录
+func (c *Cache[K, V]) prune() {
+	for elem := c.stack.Back(); elem != nil && !elem.Value.lir; elem = c.stack.Back() {
+		le := elem.Value
+		c.stack.Remove(elem)
+		le.stackElem = nil
+		if !le.resident {
+			c.ghosts.Remove(le.ghostElem)
+			le.ghostElem = nil
+			delete(c.entries, le.entry.Key)
+		}
+	}
+}
+
+// 添加非驻留元素，超过容量时删除最早的非驻留元素
+func (c *Cache[K, V]) addGhost(le *lirsEntry[K, V]) {
+	le.ghostElem = c.ghosts.PushFront(le)
+	for c.ghosts.Len() > c.capacity {
+		c.removeGhost(c.ghosts.Back().Value)
+	}
+}
+
+// 删除非驻留元素
+func (c *Cache[K, V]) removeGhost(le *lirsEntry[K, V]) {
+	c.ghosts.Remove(le.ghostElem)
+	le.ghostElem = nil
+	c.stack.Remove(le.stackElem)
+	le.stackElem = nil
+	delete(c.entries, le.entry.Key)
+}
+
+// 按淘汰顺序遍历在缓存中的元素，先是队列Q中的HIR元素，然后从栈底到栈顶遍历LIR元素
+func (c *Cache[K, V]) each(f func(le *lirsEntry[K, V])) {
+	for elem := c.queue.Back(); elem != nil; elem = elem.Prev() {
+		f(elem.Value)
+	}
+	for elem := c.stack.Back(); elem != nil; elem = elem.Prev() {
+		if elem.Value.lir {
+			f(elem.Value)
+		}
+	}
+}
+
+// 删除在缓存中的元素
+func (c *Cache[K, V]) removeEntry(le *lirsEntry[K, V]) {
+	if le.queueElem != nil {
+		c.queue.Remove(le.queueElem)
+		le.queueElem = nil
+	}
+	if le.stackElem != nil {
+		c.stack.Remove(le.stackElem)
+		le.stackElem = nil
+	}
+	if le.lir {
+		le.lir = false
+		c.lirCount--
+	}
+	delete(c.entries, le.entry.Key)
+	c.prune()
+}
+
+// 删除过期元素
+func (c *Cache[K, V]) expireEntry(le *lirsEntry[K, V]) {
+	c.removeEntry(le)
+	c.doOnEvict(le.entry, cache.EvictReasonExpired)
+}
+
+// 添加到过期清理器
+func (c *Cache[K, V]) schedule(key K, expiration time.Time) {
+	if c.janitor != nil {
+		c.janitor.Push(key, expiration)
+	}
+}
+
+// 触发淘汰回调
+func (c *Cache[K, V]) doOnEvict(entry *cache.Entry[K, V], reason cache.EvictReason) {
+	c.stats.RecordEviction(reason)
+	if c.onEvict != nil {
+		c.onEvict(entry, reason)
+	}
+}
+
+// 计算LIR元素个数上限，至少留一个位置给HIR元素
+func lirCap(capacity int) int {
+	return math.Max(capacity-math.Max(int(hirPercentage*float64(capacity)), 1), 1)
+}
@@ -0,0 +1,267 @@
+package lirs
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/cache/cachetest"
+	"github.com/jiaxwu/gommon/cache/lru"
+)
+
+func TestCache_Put(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	// LIR元素满了，33作为HIR元素
+	c.Put("33", 7)
+	// 淘汰HIR元素33，它还在栈中，变成非驻留元素
+	evicted := c.Put("44", 8)
+	if evicted == nil || evicted.Key != "33" {
+		t.Errorf("Put() = %v, want %v", evicted, "33")
+	}
+
+	// 非驻留元素再次被添加，变成LIR元素，栈底的LIR元素11变成HIR元素
+	evicted = c.Put("33", 7)
+	if evicted == nil || evicted.Key != "44" {
+		t.Errorf("Put() = %v, want %v", evicted, "44")
+	}
+	if !reflect.DeepEqual(c.Keys(), []string{"11", "22", "33"}) {
+		t.Errorf("Keys() = %v, want %v", c.Keys(), []string{"11", "22", "33"})
+	}
+}
+
+func TestCache_Get(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+
+	// 在栈中的HIR元素被访问，变成LIR元素，栈底的LIR元素11变成HIR元素
+	c.Get("33")
+	if !reflect.DeepEqual(c.Keys(), []string{"11", "22", "33"}) {
+		t.Errorf("Keys() = %v, want %v", c.Keys(), []string{"11", "22", "33"})
+	}
+	// 不在栈中的HIR元素被访问，依然是HIR元素
+	c.Get("11")
+	c.Put("44", 8)
+	if !reflect.DeepEqual(c.Keys(), []string{"44", "22", "33"}) {
+		t.Errorf("Keys() = %v, want %v", c.Keys(), []string{"44", "22", "33"})
+	}
+}
+
+func TestCache_OnEvict(t *testing.T) {
+	c := New[string, int](3)
+	c.SetOnEvict(func(entry *cache.Entry[string, int]) {
+		if entry.Key != "33" || entry.Value != 7 {
+			t.Errorf("OnEvict() = %v, want %v", entry.Key, "33")
+		}
+	})
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Get("11")
+	evicted := c.Put("44", 8)
+	if evicted == nil || evicted.Key != "33" {
+		t.Errorf("Put() = %v, want %v", evicted, "33")
+	}
+}
+
+func TestCache_Evict(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+
+	// 先淘汰HIR元素
+	if victim := c.Victim(); victim.Key != "33" {
+		t.Errorf("Victim() = %v, want %v", victim.Key, "33")
+	}
+	if evicted := c.Evict(); evicted.Key != "33" {
+		t.Errorf("Evict() = %v, want %v", evicted.Key, "33")
+	}
+	// 没有HIR元素时淘汰栈底的LIR元素
+	if evicted := c.Evict(); evicted.Key != "11" {
+		t.Errorf("Evict() = %v, want %v", evicted.Key, "11")
+	}
+	// 栈底的LIR元素被淘汰后，非驻留元素33到了栈底，被剪枝删除
+	if evicted := c.Evict(); evicted.Key != "22" {
+		t.Errorf("Evict() = %v, want %v", evicted.Key, "22")
+	}
+	if _, ok := c.entries["33"]; ok || c.Len() != 0 {
+		t.Errorf("entries[33] = %v, Len() = %v, want %v, %v", ok, c.Len(), false, 0)
+	}
+}
+
+func TestCache_Peek(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Peek("33")
+	c.Put("44", 8)
+
+	value, ok := c.Get("33")
+	if value != 0 || ok {
+		t.Errorf("Get() = %v, want %v", ok, false)
+	}
+}
+
+func TestCache_Remove(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Put("44", 8)
+	// 非驻留元素不能被移除
+	if c.Remove("33") {
+		t.Errorf("Remove() = %v, want %v", true, false)
+	}
+	if !c.Remove("11") || !c.Remove("44") {
+		t.Errorf("Remove() = %v, want %v", false, true)
+	}
+	c.Put("55", 9)
+
+	if !reflect.DeepEqual(c.Keys(), []string{"22", "55"}) {
+		t.Errorf("Keys() = %v, want %v", c.Keys(), []string{"22", "55"})
+	}
+}
+
+func TestCache_Resize(t *testing.T) {
+	c := New[string, int](4)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Get("11")
+	c.Resize(2, true)
+
+	if !reflect.DeepEqual(c.Keys(), []string{"33", "11"}) {
+		t.Errorf("Keys() = %v, want %v", c.Keys(), []string{"33", "11"})
+	}
+}
+
+func TestCache_PutWithTTL(t *testing.T) {
+	c := New[string, int](3)
+	c.PutWithTTL("11", 5, time.Millisecond*10)
+	c.Put("22", 6)
+	time.Sleep(time.Millisecond * 20)
+
+	if _, ok := c.Get("11"); ok || c.Len() != 1 {
+		t.Errorf("Get() = %v, Len() = %v, want %v, %v", ok, c.Len(), false, 1)
+	}
+	value, ok := c.Get("22")
+	if value != 6 || !ok {
+		t.Errorf("Get() = %v, want %v", ok, true)
+	}
+}
+
+func TestCache_Save(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Put("44", 8)
+	c.Get("22")
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// 恢复后栈、队列和非驻留元素不变
+	c2 := New[string, int](3)
+	if err := c2.Load(&buf); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(c2.Snapshot(), c.Snapshot()) {
+		t.Errorf("Snapshot() = %v, want %v", c2.Snapshot(), c.Snapshot())
+	}
+	c.Put("33", 7)
+	c2.Put("33", 7)
+	if !reflect.DeepEqual(c2.Keys(), c.Keys()) {
+		t.Errorf("Keys() = %v, want %v", c2.Keys(), c.Keys())
+	}
+}
+
+func TestCache_Scan(t *testing.T) {
+	c := New[int, int](100)
+	l := lru.New[int, int](100)
+	access := func(key int) {
+		if _, ok := c.Get(key); !ok {
+			c.Put(key, key)
+		}
+		if _, ok := l.Get(key); !ok {
+			l.Put(key, key)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 50; j++ {
+			access(j)
+		}
+	}
+
+	// 一次性的顺序扫描会冲掉LRU中的热点元素，但不会冲掉LIR元素
+	for i := 1000; i < 2000; i++ {
+		access(i)
+	}
+	for i := 0; i < 50; i++ {
+		if !c.Contains(i) {
+			t.Errorf("Contains(%v) = %v, want %v", i, false, true)
+		}
+		if l.Contains(i) {
+			t.Errorf("lru.Contains(%v) = %v, want %v", i, true, false)
+		}
+	}
+}
+
+func TestCache_Conformance(t *testing.T) {
+	cachetest.Run(t, func(capacity int) cache.Policy[string, int] {
+		return New[string, int](capacity)
+	}, cachetest.Options{EvictionOrder: true})
+}
+
+// lirs_test.go:259: cachePercentage=0.1%, count=206048, hitCount=30359, hitRate=14.73%
+// lirs_test.go:259: cachePercentage=0.3%, count=206048, hitCount=67141, hitRate=32.59%
+// lirs_test.go:259: cachePercentage=0.5%, count=206048, hitCount=101192, hitRate=49.11%
+// lirs_test.go:259: cachePercentage=0.7%, count=206048, hitCount=132154, hitRate=64.14%
+// lirs_test.go:259: cachePercentage=1.0%, count=206048, hitCount=168161, hitRate=81.61%
+// lirs_test.go:259: cachePercentage=2.0%, count=206048, hitCount=189595, hitRate=92.01%
+// lirs_test.go:259: cachePercentage=3.0%, count=206048, hitCount=191238, hitRate=92.81%
+// lirs_test.go:259: cachePercentage=5.0%, count=206048, hitCount=192609, hitRate=93.48%
+// lirs_test.go:259: cachePercentage=10.0%, count=206048, hitCount=192842, hitRate=93.59%
+func TestHitRate(t *testing.T) {
+	dataset, err := os.ReadFile("../dataset")
+	if err != nil {
+		t.Errorf("read dataset error %v", err)
+	}
+	reqs := strings.Split(string(dataset), ",")
+	testHitRate(t, reqs, 0.001)
+	testHitRate(t, reqs, 0.003)
+	testHitRate(t, reqs, 0.005)
+	testHitRate(t, reqs, 0.007)
+	testHitRate(t, reqs, 0.01)
+	testHitRate(t, reqs, 0.02)
+	testHitRate(t, reqs, 0.03)
+	testHitRate(t, reqs, 0.05)
+	testHitRate(t, reqs, 0.1)
+}
+
+func testHitRate(t *testing.T, reqs []string, cachePercentage float64) {
+	count := len(reqs)
+	n := int(float64(count) * cachePercentage)
+	c := New[string, int](n)
+	hitCount := 0
+	for _, req := range reqs {
+		_, exists := c.Get(req)
+		if exists {
+			hitCount++
+		} else {
+			c.Put(req, 0)
+		}
+	}
+	hitRate := float64(hitCount) / float64(count)
+	t.Logf("cachePercentage=%.1f%%, count=%v, hitCount=%v, hitRate=%.2f%%", cachePercentage*100, count, hitCount, hitRate*100)
+}
@@ -0,0 +1,217 @@
+package loader
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/jiaxwu/gommon/cache"
+)
+
+// 加载函数，缓存不存在时调用
+type LoadFunc[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+// 带加载功能的缓存
+// 缓存不存在时调用LoadFunc加载，同一个Key的并发加载只会调用一次LoadFunc，避免缓存击穿
+// 线程安全，底层缓存只能通过该结构访问
+type Cache[K comparable, V any] struct {
+	cache        cache.Cache[K, V]
+	mutex        sync.Mutex // 保护cache和loadTimes
+	group        *group[K, V]
+	refreshAfter time.Duration       // 加载超过这个时间的值需要后台刷新，0表示不刷新
+	loadTimes    map[K]time.Time     // 每个Key的加载时间，只有开启后台刷新才记录
+	beta         float64             // 提前刷新的系数，0表示不提前刷新
+	loadDelays   map[K]time.Duration // 每个Key的加载耗时，只有开启提前刷新才记录
+	random       func() float64      // 返回(0, 1]的随机数
+	stats        *cache.StatsCounter
+	onEvict      cache.OnEvict[K, V]
+}
+
+// c：如果实现了 SetOnEvict()，会覆盖它的 OnEvict，请通过 SetOnEvict() 设置
+func New[K comparable, V any](c cache.Cache[K, V]) *Cache[K, V] {
+	l := &Cache[K, V]{
+		cache:      c,
+		group:      newGroup[K, V](),
+		loadTimes:  make(map[K]time.Time),
+		loadDelays: make(map[K]time.Duration),
+		random: func() float64 {
+			return 1 - rand.Float64()
+		},
+	}
+	// 元素被淘汰（包括过期）时清理加载记录，底层缓存只能通过该结构访问，所以已经持有锁
+	if setter, ok := c.(interface {
+		SetOnEvict(onEvict cache.OnEvict[K, V])
+	}); ok {
+		setter.SetOnEvict(func(entry *cache.Entry[K, V]) {
+			l.forget(entry.Key)
+			if l.onEvict != nil {
+				l.onEvict(entry)
+			}
+		})
+	}
+	return l
+}
+
+// 设置底层缓存的 OnEvict
+func (c *Cache[K, V]) SetOnEvict(onEvict cache.OnEvict[K, V]) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onEvict = onEvict
+}
+
+// 设置后台刷新时间
+// 值加载超过refreshAfter后，GetOrLoad()会继续返回旧值，同时在后台重新加载
+// 后台加载失败会保留旧值，下一次访问会再次尝试刷新
+// 小于等于0表示不刷新
+func (c *Cache[K, V]) SetRefreshAfter(refreshAfter time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.refreshAfter = refreshAfter
+}
+
+// 设置提前刷新，使用XFetch算法避免大量请求在过期时同时加载
+// 元素过期前每次 GetOrLoad() 都有一定概率在后台重新加载，越接近过期、加载耗时越长，概率越大
+// 当 now - loadDelay * beta * ln(random) >= expiration 时刷新，random是(0, 1]的随机数
+// beta越大越早刷新，一般为1，小于等于0表示不提前刷新
+// 底层缓存需要实现 PeekEntry() 并且元素有过期时间，比如设置了 SetDefaultTTL()，没有实现 PeekEntry() 会panic
+func (c *Cache[K, V]) SetEarlyRefresh(beta float64) {
+	if _, ok := c.cache.(interface {
+		PeekEntry(key K) (*cache.Entry[K, V], bool)
+	}); beta > 0 && !ok {
+		panic("cache not support PeekEntry")
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.beta = beta
+}
+
+// 设置统计计数器，记录加载成功、失败次数和耗时，为空表示不统计
+// 可以和底层缓存共享同一个计数器，一起统计命中率
+func (c *Cache[K, V]) SetStatsCounter(stats *cache.StatsCounter) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats = stats
+}
+
+// 获取统计信息
+func (c *Cache[K, V]) Stats() cache.Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stats.Snapshot()
+}
+
+// 获取元素，不存在返回空值和false，不会加载
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.cache.Get(key)
+}
+
+// 添加或更新元素
+func (c *Cache[K, V]) Put(key K, value V) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.put(key, value)
+}
+
+// 获取元素，不存在则调用loadFunc加载并放入缓存
+// 同一个Key的并发加载只有一个会调用loadFunc，其他等待它的结果，包括错误
+// 加载失败不会放入缓存
+func (c *Cache[K, V]) GetOrLoad(ctx context.Context, key K, loadFunc LoadFunc[K, V]) (V, error) {
+	c.mutex.Lock()
+	value, ok := c.cache.Get(key)
+	needRefresh := ok && (c.needRefresh(key) || c.needEarlyRefresh(key))
+	if !ok {
+		c.forget(key)
+	}
+	c.mutex.Unlock()
+
+	// 存在直接返回，如果需要刷新，则在后台刷新
+	if ok {
+		if needRefresh {
+			c.group.doAsync(key, func() (V, error) {
+				return c.load(context.Background(), key, loadFunc)
+			})
+		}
+		return value, nil
+	}
+
+	// 不存在则加载
+	return c.group.do(ctx, key, func() (V, error) {
+		return c.load(ctx, key, loadFunc)
+	})
+}
+
+// 加载元素并放入缓存
+func (c *Cache[K, V]) load(ctx context.Context, key K, loadFunc LoadFunc[K, V]) (V, error) {
+	start := cache.Now()
+	value, err := loadFunc(ctx, key)
+	loadTime := cache.Now().Sub(start)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err != nil {
+		c.stats.RecordLoadFailure(loadTime)
+		return value, err
+	}
+	c.stats.RecordLoadSuccess(loadTime)
+	c.put(key, value)
+	if c.beta > 0 {
+		c.loadDelays[key] = loadTime
+	}
+	return value, nil
+}
+
+// 放入缓存并记录加载时间
+func (c *Cache[K, V]) put(key K, value V) {
+	// 底层缓存不支持 OnEvict 时，只能清理返回的元素，其他被淘汰的元素在访问时清理
+	if evicted := c.cache.Put(key, value); evicted != nil {
+		c.forget(evicted.Key)
+		// 被拒绝时底层缓存可能还保留着旧值
+		if evicted.Key == key {
+			if remover, ok := c.cache.(interface{ Remove(key K) bool }); ok {
+				remover.Remove(key)
+			}
+			return
+		}
+	}
+	if c.refreshAfter > 0 {
+		c.loadTimes[key] = cache.Now()
+	}
+}
+
+// 删除加载记录
+func (c *Cache[K, V]) forget(key K) {
+	delete(c.loadTimes, key)
+	delete(c.loadDelays, key)
+}
+
+// 是否需要刷新
+func (c *Cache[K, V]) needRefresh(key K) bool {
+	if c.refreshAfter <= 0 {
+		return false
+	}
+	loadTime, ok := c.loadTimes[key]
+	return ok && cache.Now().Sub(loadTime) >= c.refreshAfter
+}
+
+// 是否需要提前刷新
+func (c *Cache[K, V]) needEarlyRefresh(key K) bool {
+	if c.beta <= 0 {
+		return false
+	}
+	loadDelay, ok := c.loadDelays[key]
+	if !ok {
+		return false
+	}
+	// SetEarlyRefresh() 已经检查过底层缓存实现了 PeekEntry()
+	entry, ok := c.cache.(interface {
+		PeekEntry(key K) (*cache.Entry[K, V], bool)
+	}).PeekEntry(key)
+	if !ok || entry.Expiration.IsZero() {
+		return false
+	}
+	gap := time.Duration(-float64(loadDelay) * c.beta * math.Log(c.random()))
+	return !cache.Now().Add(gap).Before(entry.Expiration)
+}
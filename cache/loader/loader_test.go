@@ -0,0 +1,305 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/cache/cachetest"
+	"github.com/jiaxwu/gommon/cache/lru"
+	"github.com/jiaxwu/gommon/cache/slru"
+)
+
+func TestCache_GetOrLoad(t *testing.T) {
+	c := New[string, int](lru.New[string, int](3))
+	var loads int32
+	loadFunc := func(ctx context.Context, key string) (int, error) {
+		atomic.AddInt32(&loads, 1)
+		time.Sleep(time.Millisecond * 50)
+		return len(key), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := c.GetOrLoad(context.Background(), "11", loadFunc)
+			if value != 2 || err != nil {
+				t.Errorf("GetOrLoad() = %v, %v, want %v", value, err, 2)
+			}
+		}()
+	}
+	wg.Wait()
+	if loads != 1 {
+		t.Errorf("loads = %v, want %v", loads, 1)
+	}
+
+	value, ok := c.Get("11")
+	if value != 2 || !ok {
+		t.Errorf("Get() = %v, want %v", value, 2)
+	}
+}
+
+func TestCache_GetOrLoadError(t *testing.T) {
+	c := New[string, int](lru.New[string, int](3))
+	loadErr := errors.New("load error")
+	start := make(chan struct{})
+	loadFunc := func(ctx context.Context, key string) (int, error) {
+		<-start
+		return 0, loadErr
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.GetOrLoad(context.Background(), "11", loadFunc); err != loadErr {
+				t.Errorf("GetOrLoad() error = %v, want %v", err, loadErr)
+			}
+		}()
+	}
+	time.Sleep(time.Millisecond * 50)
+	close(start)
+	wg.Wait()
+
+	if _, ok := c.Get("11"); ok {
+		t.Errorf("Get() = %v, want %v", ok, false)
+	}
+}
+
+func TestCache_GetOrLoadCanceled(t *testing.T) {
+	c := New[string, int](lru.New[string, int](3))
+	start := make(chan struct{})
+	go c.GetOrLoad(context.Background(), "11", func(ctx context.Context, key string) (int, error) {
+		<-start
+		return 1, nil
+	})
+	time.Sleep(time.Millisecond * 10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	if _, err := c.GetOrLoad(ctx, "11", nil); err != context.DeadlineExceeded {
+		t.Errorf("GetOrLoad() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	close(start)
+}
+
+func TestCache_SetRefreshAfter(t *testing.T) {
+	c := New[string, int](lru.New[string, int](3))
+	c.SetRefreshAfter(time.Millisecond * 10)
+	var loads int32
+	refreshed := make(chan struct{}, 1)
+	loadFunc := func(ctx context.Context, key string) (int, error) {
+		n := atomic.AddInt32(&loads, 1)
+		if n > 1 {
+			refreshed <- struct{}{}
+		}
+		return int(n), nil
+	}
+
+	value, _ := c.GetOrLoad(context.Background(), "11", loadFunc)
+	if value != 1 {
+		t.Errorf("GetOrLoad() = %v, want %v", value, 1)
+	}
+	time.Sleep(time.Millisecond * 20)
+
+	// 返回旧值，后台刷新
+	value, _ = c.GetOrLoad(context.Background(), "11", loadFunc)
+	if value != 1 {
+		t.Errorf("GetOrLoad() = %v, want %v", value, 1)
+	}
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatalf("refresh not called")
+	}
+	time.Sleep(time.Millisecond * 10)
+	value, _ = c.Get("11")
+	if value != 2 {
+		t.Errorf("Get() = %v, want %v", value, 2)
+	}
+}
+
+func TestCache_OnEvict(t *testing.T) {
+	lruCache := lru.New[string, int](3)
+	lruCache.SetWeigher(func(key string, value int) int64 {
+		return int64(value)
+	})
+	c := New[string, int](lruCache)
+	c.SetRefreshAfter(time.Hour)
+	var evicted []string
+	c.SetOnEvict(func(entry *cache.Entry[string, int]) {
+		evicted = append(evicted, entry.Key)
+	})
+	loadFunc := func(ctx context.Context, key string) (int, error) {
+		return len(key), nil
+	}
+	c.GetOrLoad(context.Background(), "1", loadFunc)
+	c.GetOrLoad(context.Background(), "2", loadFunc)
+
+	// 一次淘汰多个元素，所有被淘汰元素的加载记录都被清理
+	c.GetOrLoad(context.Background(), "333", loadFunc)
+	if len(evicted) != 2 || len(c.loadTimes) != 1 {
+		t.Errorf("evicted = %v, len(loadTimes) = %v, want %v, %v", evicted, len(c.loadTimes), 2, 1)
+	}
+	c.Put("1", 1)
+	if len(c.loadTimes) != 1 {
+		t.Errorf("len(loadTimes) = %v, want %v", len(c.loadTimes), 1)
+	}
+}
+
+func TestCache_GetOrLoadPanic(t *testing.T) {
+	c := New[string, int](lru.New[string, int](3))
+	c.SetRefreshAfter(time.Millisecond * 10)
+	var loads int32
+	loadFunc := func(ctx context.Context, key string) (int, error) {
+		if atomic.AddInt32(&loads, 1) > 1 {
+			panic("load panic")
+		}
+		return len(key), nil
+	}
+	c.GetOrLoad(context.Background(), "11", loadFunc)
+	time.Sleep(time.Millisecond * 20)
+
+	// 后台刷新panic不会导致进程崩溃，保留旧值，下一次访问会再次刷新
+	for i := 0; i < 2; i++ {
+		if value, err := c.GetOrLoad(context.Background(), "11", loadFunc); value != 2 || err != nil {
+			t.Errorf("GetOrLoad() = %v, %v, want %v, %v", value, err, 2, nil)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if atomic.LoadInt32(&loads) != 3 {
+		t.Errorf("loads = %v, want %v", loads, 3)
+	}
+
+	// 等待者收到ErrLoadPanicked
+	g := newGroup[string, int]()
+	start := make(chan struct{})
+	g.doAsync("11", func() (int, error) {
+		<-start
+		panic("load panic")
+	})
+	done := make(chan error)
+	go func() {
+		_, err := g.do(context.Background(), "11", func() (int, error) {
+			return 0, nil
+		})
+		done <- err
+	}()
+	time.Sleep(time.Millisecond * 10)
+	close(start)
+	if err := <-done; !errors.Is(err, ErrLoadPanicked) {
+		t.Errorf("do() error = %v, want %v", err, ErrLoadPanicked)
+	}
+	if _, err := g.do(context.Background(), "11", func() (int, error) {
+		return 1, nil
+	}); err != nil {
+		t.Errorf("do() error = %v, want %v", err, nil)
+	}
+}
+
+func TestCache_SetStatsCounter(t *testing.T) {
+	stats := cache.NewStatsCounter()
+	lruCache := lru.New[string, int](3)
+	lruCache.SetStatsCounter(stats)
+	c := New[string, int](lruCache)
+	c.SetStatsCounter(stats)
+
+	loadErr := errors.New("load error")
+	loadFunc := func(ctx context.Context, key string) (int, error) {
+		time.Sleep(time.Millisecond * 10)
+		if key == "" {
+			return 0, loadErr
+		}
+		return len(key), nil
+	}
+	c.GetOrLoad(context.Background(), "11", loadFunc)
+	c.GetOrLoad(context.Background(), "11", loadFunc)
+	c.GetOrLoad(context.Background(), "", loadFunc)
+
+	s := c.Stats()
+	if s.LoadSuccesses != 1 || s.LoadFailures != 1 {
+		t.Errorf("LoadSuccesses = %v, LoadFailures = %v, want %v, %v", s.LoadSuccesses, s.LoadFailures, 1, 1)
+	}
+	if s.AverageLoadTime() < time.Millisecond*10 {
+		t.Errorf("AverageLoadTime() = %v, want >= %v", s.AverageLoadTime(), time.Millisecond*10)
+	}
+	if s.Hits != 1 || s.Misses != 2 {
+		t.Errorf("Hits = %v, Misses = %v, want %v, %v", s.Hits, s.Misses, 1, 2)
+	}
+}
+
+func TestCache_SetEarlyRefresh(t *testing.T) {
+	clock := cachetest.UseFakeClock(t)
+	lruCache := lru.New[string, int](3)
+	lruCache.SetDefaultTTL(time.Millisecond * 100)
+	c := New[string, int](lruCache)
+	c.SetEarlyRefresh(1)
+	var loads int32
+	loadFunc := func(ctx context.Context, key string) (int, error) {
+		// 每次加载耗时10ms
+		clock.Advance(time.Millisecond * 10)
+		return int(atomic.AddInt32(&loads, 1)), nil
+	}
+	c.GetOrLoad(context.Background(), "11", loadFunc)
+
+	// -ln(1) = 0，过期前不会刷新
+	c.random = func() float64 { return 1 }
+	clock.Advance(time.Millisecond * 50)
+	if value, _ := c.GetOrLoad(context.Background(), "11", loadFunc); value != 1 {
+		t.Errorf("GetOrLoad() = %v, want %v", value, 1)
+	}
+	if loads := atomic.LoadInt32(&loads); loads != 1 {
+		t.Errorf("loads = %v, want %v", loads, 1)
+	}
+
+	// 10ms * -ln(1e-9) ≈ 207ms，超过剩余的50ms，返回旧值并在后台刷新
+	c.random = func() float64 { return 1e-9 }
+	if value, _ := c.GetOrLoad(context.Background(), "11", loadFunc); value != 1 {
+		t.Errorf("GetOrLoad() = %v, want %v", value, 1)
+	}
+	deadline := time.After(time.Second)
+	for value, _ := c.Get("11"); value != 2; value, _ = c.Get("11") {
+		select {
+		case <-deadline:
+			t.Fatalf("Get() = %v, want %v", value, 2)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestCache_SetEarlyRefreshWithoutTTL(t *testing.T) {
+	c := New[string, int](lru.New[string, int](3))
+	c.SetEarlyRefresh(1)
+	c.random = func() float64 { return 1e-9 }
+	var loads int32
+	loadFunc := func(ctx context.Context, key string) (int, error) {
+		return int(atomic.AddInt32(&loads, 1)), nil
+	}
+	// 没有过期时间不会提前刷新
+	for i := 0; i < 3; i++ {
+		c.GetOrLoad(context.Background(), "11", loadFunc)
+	}
+	time.Sleep(time.Millisecond * 10)
+	if atomic.LoadInt32(&loads) != 1 {
+		t.Errorf("loads = %v, want %v", loads, 1)
+	}
+}
+
+func TestCache_SetEarlyRefreshWithoutPeekEntry(t *testing.T) {
+	c := New[string, int](slru.New[string, int](3))
+	c.SetEarlyRefresh(0)
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("SetEarlyRefresh() = %v, want panic", r)
+		}
+	}()
+	// 底层缓存没有实现 PeekEntry() 时在设置时就panic，而不是静默不刷新
+	c.SetEarlyRefresh(1)
+}
@@ -0,0 +1,91 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// 加载函数panic时，等待者收到的错误
+var ErrLoadPanicked = errors.New("load func panicked")
+
+// 一次正在进行的加载
+type call[V any] struct {
+	done  chan struct{} // 加载完成后关闭
+	value V
+	err   error
+}
+
+// 合并同一个Key的并发加载，只有第一个调用者会执行加载，其他调用者等待结果
+// 参考 golang.org/x/sync/singleflight
+type group[K comparable, V any] struct {
+	calls map[K]*call[V]
+	mutex sync.Mutex
+}
+
+func newGroup[K comparable, V any]() *group[K, V] {
+	return &group[K, V]{
+		calls: make(map[K]*call[V]),
+	}
+}
+
+// 执行加载，如果已经有同一个Key的加载在进行，则等待它的结果
+// 等待时ctx被关闭会直接返回ctx.Err()，不影响正在进行的加载
+func (g *group[K, V]) do(ctx context.Context, key K, fn func() (V, error)) (V, error) {
+	g.mutex.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mutex.Unlock()
+		return c.wait(ctx)
+	}
+	c := &call[V]{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mutex.Unlock()
+
+	g.run(key, c, fn, false)
+	return c.value, c.err
+}
+
+// 在后台执行加载，如果已经有同一个Key的加载在进行，则直接返回
+func (g *group[K, V]) doAsync(key K, fn func() (V, error)) {
+	g.mutex.Lock()
+	if _, ok := g.calls[key]; ok {
+		g.mutex.Unlock()
+		return
+	}
+	c := &call[V]{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mutex.Unlock()
+
+	go g.run(key, c, fn, true)
+}
+
+// 执行加载并唤醒等待者
+// async：后台加载没有调用者可以处理panic，需要恢复，避免进程崩溃
+func (g *group[K, V]) run(key K, c *call[V], fn func() (V, error), async bool) {
+	defer func() {
+		if async {
+			if r := recover(); r != nil {
+				c.err = fmt.Errorf("%w: %v", ErrLoadPanicked, r)
+			}
+		}
+		g.mutex.Lock()
+		delete(g.calls, key)
+		g.mutex.Unlock()
+		close(c.done)
+	}()
+	// 如果fn()发生panic，等待者会收到ErrLoadPanicked
+	c.err = ErrLoadPanicked
+	c.value, c.err = fn()
+}
+
+// 等待加载完成
+func (c *call[V]) wait(ctx context.Context) (V, error) {
+	select {
+	case <-c.done:
+		return c.value, c.err
+	case <-ctx.Done():
+		var value V
+		return value, ctx.Err()
+	}
+}
@@ -0,0 +1,10 @@
+//go:build go1.23
+
+package lru
+
+import "iter"
+
+// 按淘汰顺序遍历元素的迭代器，和 Range() 一样不更新状态
+func (c *Cache[K, V]) All() iter.Seq2[K, V] {
+	return c.Range
+}
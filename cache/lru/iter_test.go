@@ -0,0 +1,25 @@
+//go:build go1.23
+
+package lru
+
+import "testing"
+
+func TestCache_All(t *testing.T) {
+	c := New[int, int](3)
+	c.Put(1, 1)
+	c.Put(2, 2)
+	c.Put(3, 3)
+	var keys []int
+	for key, value := range c.All() {
+		if key != value {
+			t.Errorf("value = %v, want %v", value, key)
+		}
+		if key == 2 {
+			break
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) != 1 || keys[0] != 1 {
+		t.Errorf("keys = %v, want %v", keys, []int{1})
+	}
+}
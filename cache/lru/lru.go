@@ -1,18 +1,35 @@
 package lru
 
 import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
 	"github.com/jiaxwu/gommon/cache"
 	"github.com/jiaxwu/gommon/container/list"
 )
 
+// 快照中的缓存策略名称
+const snapshotPolicy = "lru"
+
 // 最近最少使用
 // 优点：稳定淘汰
 // 非线程安全，请根据业务加锁
 type Cache[K comparable, V any] struct {
-	entries   map[K]*list.Element[*cache.Entry[K, V]]
-	evictList *list.List[*cache.Entry[K, V]]
-	capacity  int
-	onEvict   cache.OnEvict[K, V]
+	entries    map[K]*list.Element[*cache.Entry[K, V]]
+	evictList  *list.List[*cache.Entry[K, V]]
+	capacity   int
+	cost       int64               // 当前总权重
+	weigher    cache.Weigher[K, V] // 计算元素权重，为空则每个元素权重为1
+	ttl        time.Duration       // 默认过期时间
+	onEvict    cache.OnEvictWithReason[K, V]
+	pins       map[K]int            // 被固定的元素的固定次数
+	canEvict   cache.CanEvict[K, V] // 判断元素能否被淘汰，为空表示都可以
+	janitor    *cache.Janitor[K]
+	stats      *cache.StatsCounter // 统计，为空表示不统计
+	keyCodec   cache.Codec[K]      // 保存快照时Key的编解码器
+	valueCodec cache.Codec[V]      // 保存快照时Value的编解码器
 }
 
 func New[K comparable, V any](capacity int) *Cache[K, V] {
@@ -23,48 +40,189 @@ func New[K comparable, V any](capacity int) *Cache[K, V] {
 		entries:   make(map[K]*list.Element[*cache.Entry[K, V]]),
 		evictList: list.New[*cache.Entry[K, V]](),
 		capacity:  capacity,
+		pins:      make(map[K]int),
 	}
 }
 
 // 设置 OnEvict
 func (c *Cache[K, V]) SetOnEvict(onEvict cache.OnEvict[K, V]) {
+	c.onEvict = onEvict.WithReason()
+}
+
+// 设置 OnEvict，带上淘汰原因
+func (c *Cache[K, V]) SetOnEvictWithReason(onEvict cache.OnEvictWithReason[K, V]) {
 	c.onEvict = onEvict
 }
 
+// 设置 CanEvict，返回false的元素淘汰时会被跳过
+func (c *Cache[K, V]) SetCanEvict(canEvict cache.CanEvict[K, V]) {
+	c.canEvict = canEvict
+}
+
+// 固定元素，固定的元素不会被淘汰，可以固定多次，需要相同次数的 Unpin()
+// 不存在返回false
+func (c *Cache[K, V]) Pin(key K) bool {
+	if _, ok := c.entries[key]; !ok {
+		return false
+	}
+	c.pins[key]++
+	return true
+}
+
+// 解除一次固定，完全解除后如果超过容量则淘汰元素
+// 没有固定返回false
+func (c *Cache[K, V]) Unpin(key K) bool {
+	n, ok := c.pins[key]
+	if !ok {
+		return false
+	}
+	if n > 1 {
+		c.pins[key] = n - 1
+		return true
+	}
+	delete(c.pins, key)
+	c.evictToFit(0)
+	return true
+}
+
+// 元素是否被固定
+func (c *Cache[K, V]) Pinned(key K) bool {
+	return c.pins[key] > 0
+}
+
+// 设置权重计算函数，设置后容量表示总权重，而不是元素个数
+func (c *Cache[K, V]) SetWeigher(weigher cache.Weigher[K, V]) {
+	c.weigher = weigher
+	// 重新计算总权重
+	c.cost = 0
+	for elem := c.evictList.Back(); elem != nil; elem = elem.Prev() {
+		c.cost += c.weigh(elem.Value.Key, elem.Value.Value)
+	}
+	c.evictToFit(0)
+}
+
+// 设置统计计数器，为空表示不统计
+func (c *Cache[K, V]) SetStatsCounter(stats *cache.StatsCounter) {
+	c.stats = stats
+}
+
+// 获取统计信息
+func (c *Cache[K, V]) Stats() cache.Stats {
+	return c.stats.Snapshot()
+}
+
+// 设置默认过期时间，Put()会使用该过期时间，小于等于0表示永不过期
+func (c *Cache[K, V]) SetDefaultTTL(ttl time.Duration) {
+	c.ttl = ttl
+}
+
+// 启动过期清理器，到期主动删除元素，直到ctx被关闭
+// locker是业务访问缓存时使用的锁
+// 只会清理启动之后添加的元素，之前添加的元素依然在访问时删除
+func (c *Cache[K, V]) StartJanitor(ctx context.Context, locker sync.Locker) {
+	c.janitor = cache.NewJanitor(locker, c.RemoveExpired)
+	go c.janitor.Run(ctx)
+}
+
 // 添加或更新元素
 // 返回被淘汰的元素
 func (c *Cache[K, V]) Put(key K, value V) *cache.Entry[K, V] {
+	return c.PutWithTTL(key, value, c.ttl)
+}
+
+// 添加或更新元素，并设置过期时间，小于等于0表示永不过期
+// 返回被淘汰的元素
+func (c *Cache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) *cache.Entry[K, V] {
+	return c.PutWithExpiration(key, value, cache.Expiration(ttl))
+}
+
+// 添加或更新元素，并设置过期时刻，零值表示永不过期
+// 返回被淘汰的元素，如果淘汰了多个元素，返回最后一个
+// 如果元素权重超过容量，或者剩余的元素都被固定放不下，则不会添加，直接返回该元素，可以通过 TryPut() 区分
+// 更新已经存在的元素时，如果剩余的元素都被固定，允许暂时超过容量
+func (c *Cache[K, V]) PutWithExpiration(key K, value V, expiration time.Time) *cache.Entry[K, V] {
+	evicted, err := c.tryPut(key, value, expiration)
+	if err != nil {
+		return &cache.Entry[K, V]{
+			Key:        key,
+			Value:      value,
+			Expiration: expiration,
+		}
+	}
+	return evicted
+}
+
+// 添加或更新元素，拒绝添加时返回错误
+// 返回被淘汰的元素，如果淘汰了多个元素，返回最后一个
+// 元素权重超过容量返回 cache.ErrTooLarge，剩余的元素都被固定放不下返回 cache.ErrAllPinned
+// 拒绝添加时不会淘汰任何元素，已经存在的旧值保持不变
+func (c *Cache[K, V]) TryPut(key K, value V) (*cache.Entry[K, V], error) {
+	return c.tryPut(key, value, cache.Expiration(c.ttl))
+}
+
+// 添加或更新元素，拒绝添加时返回错误
+func (c *Cache[K, V]) tryPut(key K, value V, expiration time.Time) (*cache.Entry[K, V], error) {
+	weight := c.weigh(key, value)
+	if weight > int64(c.capacity) {
+		return nil, cache.ErrTooLarge
+	}
+
 	// 如果 key 已经存在，直接把它移到最前面，然后设置新值
 	if elem, ok := c.entries[key]; ok {
 		c.evictList.MoveToFront(elem)
+		c.cost += weight - c.weigh(key, elem.Value.Value)
 		elem.Value.Value = value
-		return nil
+		elem.Value.Expiration = expiration
+		c.schedule(key, expiration)
+		c.stats.RecordUpdate()
+		// 权重变大可能需要淘汰元素，先固定它自己，避免淘汰正在更新的元素
+		// 剩余的元素都被固定时允许暂时超过容量
+		c.pins[key]++
+		evicted := c.evictToFit(0)
+		if c.pins[key]--; c.pins[key] == 0 {
+			delete(c.pins, key)
+		}
+		return evicted, nil
 	}
 
-	// 如果已经到达最大尺寸，先剔除一个元素
-	var evicted *cache.Entry[K, V]
-	if c.Full() {
-		evicted = c.Evict()
+	// 剩余的元素都被固定放不下，直接拒绝，避免白白淘汰元素
+	if !c.canFit(weight) {
+		return nil, cache.ErrAllPinned
 	}
+	// 如果放不下，先剔除元素
+	evicted := c.evictToFit(weight)
 
 	// 添加元素
 	elem := c.evictList.PushFront(&cache.Entry[K, V]{
-		Key:   key,
-		Value: value,
+		Key:        key,
+		Value:      value,
+		Expiration: expiration,
 	})
 	c.entries[key] = elem
-	return evicted
+	c.cost += weight
+	c.schedule(key, expiration)
+	c.stats.RecordPut()
+	return evicted, nil
 }
 
 // 获取元素
 func (c *Cache[K, V]) Get(key K) (V, bool) {
 	// 如果存在移动到头部，然后返回
 	if elem, ok := c.entries[key]; ok {
+		// 过期了直接删除
+		if elem.Value.Expired() {
+			c.expireElement(elem)
+			c.stats.RecordMiss()
+			var value V
+			return value, false
+		}
 		c.evictList.MoveToFront(elem)
+		c.stats.RecordHit()
 		return elem.Value.Value, true
 	}
 
 	// 不存在返回空值和false
+	c.stats.RecordMiss()
 	var value V
 	return value, false
 }
@@ -72,8 +230,8 @@ func (c *Cache[K, V]) Get(key K) (V, bool) {
 // 获取元素，不更新状态
 func (c *Cache[K, V]) Peek(key K) (V, bool) {
 	// 如果存在
-	if elem, ok := c.entries[key]; ok {
-		return elem.Value.Value, true
+	if entry, ok := c.PeekEntry(key); ok {
+		return entry.Value, true
 	}
 
 	// 不存在返回空值和false
@@ -81,13 +239,23 @@ func (c *Cache[K, V]) Peek(key K) (V, bool) {
 	return value, false
 }
 
+// 获取缓存项，不更新状态
+func (c *Cache[K, V]) PeekEntry(key K) (*cache.Entry[K, V], bool) {
+	// 如果存在并且没有过期
+	if elem, ok := c.entries[key]; ok && !elem.Value.Expired() {
+		return elem.Value, true
+	}
+	return nil, false
+}
+
 // 是否包含元素，不更新状态
 func (c *Cache[K, V]) Contains(key K) bool {
-	_, ok := c.entries[key]
+	_, ok := c.PeekEntry(key)
 	return ok
 }
 
 // 获取缓存的Keys
+// 可能包含已经过期但还没被删除的元素
 func (c *Cache[K, V]) Keys() []K {
 	keys := make([]K, c.Len())
 	for elem, i := c.evictList.Back(), 0; elem != nil; elem, i = elem.Prev(), i+1 {
@@ -114,6 +282,19 @@ func (c *Cache[K, V]) Entries() []*cache.Entry[K, V] {
 	return entries
 }
 
+// 按淘汰顺序遍历元素，f返回false时停止，不更新状态，不分配内存
+// 可能包含已经过期但还没被删除的元素
+// 遍历期间可以删除当前元素，其他修改的结果是未定义的
+func (c *Cache[K, V]) Range(f func(key K, value V) bool) {
+	for elem := c.evictList.Back(); elem != nil; {
+		prev := elem.Prev()
+		if !f(elem.Value.Key, elem.Value.Value) {
+			return
+		}
+		elem = prev
+	}
+}
+
 // 移除元素
 func (c *Cache[K, V]) Remove(key K) bool {
 	if elem, ok := c.entries[key]; ok {
@@ -123,23 +304,35 @@ func (c *Cache[K, V]) Remove(key K) bool {
 	return false
 }
 
-// 淘汰元素
+// 移除已经过期的元素
+func (c *Cache[K, V]) RemoveExpired(key K) bool {
+	if elem, ok := c.entries[key]; ok && elem.Value.Expired() {
+		c.expireElement(elem)
+		return true
+	}
+	return false
+}
+
+// 淘汰元素，跳过被固定和不能淘汰的元素
+// 没有可以淘汰的元素返回nil
 func (c *Cache[K, V]) Evict() *cache.Entry[K, V] {
-	elem := c.evictList.Back()
+	elem := c.victim()
 	if elem == nil {
 		return nil
 	}
 	c.removeElement(elem)
 	// 回调
-	if c.onEvict != nil {
-		c.onEvict(elem.Value)
+	reason := cache.EvictReasonCapacity
+	if elem.Value.Expired() {
+		reason = cache.EvictReasonExpired
 	}
+	c.doOnEvict(elem.Value, reason)
 	return elem.Value
 }
 
 // 获取可能被淘汰的元素
 func (c *Cache[K, V]) Victim() *cache.Entry[K, V] {
-	elem := c.evictList.Back()
+	elem := c.victim()
 	if elem == nil {
 		return nil
 	}
@@ -149,27 +342,23 @@ func (c *Cache[K, V]) Victim() *cache.Entry[K, V] {
 // 清空缓存
 func (c *Cache[K, V]) Clear(needOnEvict bool) {
 	// 触发回调
-	if needOnEvict && c.onEvict != nil {
+	if needOnEvict {
 		for elem, i := c.evictList.Back(), 0; elem != nil; elem, i = elem.Prev(), i+1 {
-			c.onEvict(elem.Value)
+			c.doOnEvict(elem.Value, cache.EvictReasonClear)
 		}
 	}
 
 	// 清空
 	c.entries = make(map[K]*list.Element[*cache.Entry[K, V]])
 	c.evictList.Clear()
+	c.cost = 0
+	c.pins = make(map[K]int)
 }
 
 // 改变容量
 func (c *Cache[K, V]) Resize(capacity int, needOnEvict bool) {
-	diff := c.Len() - capacity
-	if diff < 0 {
-		diff = 0
-	}
-	for i := 0; i < diff; i++ {
-		c.Evict()
-	}
 	c.capacity = capacity
+	c.evictToFit(0)
 }
 
 // 元素个数
@@ -177,14 +366,79 @@ func (c *Cache[K, V]) Len() int {
 	return len(c.entries)
 }
 
-// 容量
+// 当前总权重，没有设置权重计算函数时等于元素个数
+func (c *Cache[K, V]) Cost() int64 {
+	return c.cost
+}
+
+// 容量，设置了权重计算函数时表示总权重
 func (c *Cache[K, V]) Cap() int {
 	return c.capacity
 }
 
 // 缓存满了
 func (c *Cache[K, V]) Full() bool {
-	return c.Len() == c.Cap()
+	return c.Cost() >= int64(c.Cap())
+}
+
+// 设置保存快照时使用的编解码器，为空使用GobCodec
+func (c *Cache[K, V]) SetCodec(keyCodec cache.Codec[K], valueCodec cache.Codec[V]) {
+	c.keyCodec = keyCodec
+	c.valueCodec = valueCodec
+}
+
+// 保存快照到w，包括元素的访问顺序
+func (c *Cache[K, V]) Save(w io.Writer) error {
+	return c.Snapshot().Encode(w, c.keyCodec, c.valueCodec)
+}
+
+// 从r加载快照，会先清空缓存，不触发回调
+// 快照格式不正确时缓存保持不变
+func (c *Cache[K, V]) Load(r io.Reader) error {
+	snapshot, err := cache.DecodeSnapshot(r, snapshotPolicy, c.keyCodec, c.valueCodec)
+	if err != nil {
+		return err
+	}
+	return c.Restore(snapshot)
+}
+
+// 获取快照，按淘汰顺序排列
+func (c *Cache[K, V]) Snapshot() *cache.Snapshot[K, V] {
+	snapshot := &cache.Snapshot[K, V]{
+		Policy:  snapshotPolicy,
+		Records: make([]*cache.SnapshotRecord[K, V], 0, c.Len()),
+	}
+	for elem := c.evictList.Back(); elem != nil; elem = elem.Prev() {
+		entry := *elem.Value
+		snapshot.Records = append(snapshot.Records, &cache.SnapshotRecord[K, V]{
+			Entry: &entry,
+		})
+	}
+	return snapshot
+}
+
+// 从快照恢复，会先清空缓存，不触发回调
+// 跳过已经过期的元素，放不下的元素按淘汰顺序淘汰
+func (c *Cache[K, V]) Restore(snapshot *cache.Snapshot[K, V]) error {
+	if snapshot.Policy != snapshotPolicy {
+		return cache.ErrSnapshotPolicyMismatch
+	}
+	c.Clear(false)
+	for _, record := range snapshot.Records {
+		entry := *record.Entry
+		if entry.Expired() {
+			continue
+		}
+		if elem, ok := c.entries[entry.Key]; ok {
+			c.removeElement(elem)
+		}
+		// 快照最先被淘汰的在前面，所以依次放到最前面
+		c.entries[entry.Key] = c.evictList.PushFront(&entry)
+		c.cost += c.weigh(entry.Key, entry.Value)
+		c.schedule(entry.Key, entry.Expiration)
+	}
+	c.evictToFit(0)
+	return nil
 }
 
 // 移除给定节点
@@ -192,4 +446,77 @@ func (c *Cache[K, V]) removeElement(elem *list.Element[*cache.Entry[K, V]]) {
 	c.evictList.Remove(elem)
 	entry := elem.Value
 	delete(c.entries, entry.Key)
+	delete(c.pins, entry.Key)
+	c.cost -= c.weigh(entry.Key, entry.Value)
+}
+
+// 获取最先被淘汰的可以淘汰的节点，都不能淘汰返回nil
+// 需要跳过被固定的元素，被固定的元素很多时会变慢
+func (c *Cache[K, V]) victim() *list.Element[*cache.Entry[K, V]] {
+	for elem := c.evictList.Back(); elem != nil; elem = elem.Prev() {
+		if c.evictable(elem.Value) {
+			return elem
+		}
+	}
+	return nil
+}
+
+// 元素能否被淘汰
+func (c *Cache[K, V]) evictable(entry *cache.Entry[K, V]) bool {
+	return c.pins[entry.Key] == 0 && (c.canEvict == nil || c.canEvict(entry))
+}
+
+// 淘汰可以淘汰的元素后能否放下给定权重的元素
+func (c *Cache[K, V]) canFit(weight int64) bool {
+	need := c.cost + weight - int64(c.capacity)
+	for elem := c.evictList.Back(); elem != nil && need > 0; elem = elem.Prev() {
+		if c.evictable(elem.Value) {
+			need -= c.weigh(elem.Value.Key, elem.Value.Value)
+		}
+	}
+	return need <= 0
+}
+
+// 淘汰元素直到能放下给定权重的元素
+// 返回最后一个被淘汰的元素
+func (c *Cache[K, V]) evictToFit(weight int64) *cache.Entry[K, V] {
+	var evicted *cache.Entry[K, V]
+	for c.Len() > 0 && c.cost+weight > int64(c.capacity) {
+		entry := c.Evict()
+		// 剩下的元素都不能淘汰
+		if entry == nil {
+			break
+		}
+		evicted = entry
+	}
+	return evicted
+}
+
+// 计算元素权重
+func (c *Cache[K, V]) weigh(key K, value V) int64 {
+	if c.weigher == nil {
+		return 1
+	}
+	return c.weigher(key, value)
+}
+
+// 删除过期节点
+func (c *Cache[K, V]) expireElement(elem *list.Element[*cache.Entry[K, V]]) {
+	c.removeElement(elem)
+	c.doOnEvict(elem.Value, cache.EvictReasonExpired)
+}
+
+// 添加到过期清理器
+func (c *Cache[K, V]) schedule(key K, expiration time.Time) {
+	if c.janitor != nil {
+		c.janitor.Push(key, expiration)
+	}
+}
+
+// 触发淘汰回调
+func (c *Cache[K, V]) doOnEvict(entry *cache.Entry[K, V], reason cache.EvictReason) {
+	c.stats.RecordEviction(reason)
+	if c.onEvict != nil {
+		c.onEvict(entry, reason)
+	}
 }
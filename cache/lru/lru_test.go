@@ -1,11 +1,19 @@
 package lru
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
 	"os"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/cache/cachetest"
 )
 
 func TestCache_Put(t *testing.T) {
@@ -125,6 +133,336 @@ func TestCache_Entries(t *testing.T) {
 	}
 }
 
+func TestCache_Range(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Get("11")
+	c.Put("44", 8)
+
+	// 和 Keys() 顺序一致，不更新状态
+	keys := c.Keys()
+	var ranged []string
+	c.Range(func(key string, value int) bool {
+		ranged = append(ranged, key)
+		return true
+	})
+	if !reflect.DeepEqual(ranged, keys) || !reflect.DeepEqual(c.Keys(), keys) {
+		t.Errorf("Range() = %v, want %v", ranged, keys)
+	}
+
+	// 提前停止
+	n := 0
+	c.Range(func(key string, value int) bool {
+		n++
+		return n < 2
+	})
+	if n != 2 {
+		t.Errorf("n = %v, want %v", n, 2)
+	}
+
+	// 遍历期间删除当前元素
+	c.Range(func(key string, value int) bool {
+		c.Remove(key)
+		return true
+	})
+	if c.Len() != 0 {
+		t.Errorf("Len() = %v, want %v", c.Len(), 0)
+	}
+}
+
+func TestCache_TTL(t *testing.T) {
+	cachetest.RunTTL(t, func(capacity int) cachetest.TTLPolicy {
+		return New[string, int](capacity)
+	})
+}
+
+func TestCache_StartJanitor(t *testing.T) {
+	c := New[string, int](3)
+	var mutex sync.Mutex
+	expired := make(chan string, 1)
+	c.SetOnEvictWithReason(func(entry *cache.Entry[string, int], reason cache.EvictReason) {
+		if reason == cache.EvictReasonExpired {
+			expired <- entry.Key
+		}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.StartJanitor(ctx, &mutex)
+
+	mutex.Lock()
+	c.PutWithTTL("11", 5, time.Millisecond*10)
+	mutex.Unlock()
+
+	select {
+	case key := <-expired:
+		if key != "11" {
+			t.Errorf("OnEvict() = %v, want %v", key, "11")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("OnEvict() not called")
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if c.Len() != 0 {
+		t.Errorf("Len() = %v, want %v", c.Len(), 0)
+	}
+}
+
+func TestCache_SetWeigher(t *testing.T) {
+	c := New[string, []byte](10)
+	c.SetWeigher(func(key string, value []byte) int64 {
+		return int64(len(value))
+	})
+	c.Put("11", make([]byte, 4))
+	c.Put("22", make([]byte, 4))
+	if c.Len() != 2 || c.Cost() != 8 {
+		t.Errorf("Len() = %v, Cost() = %v, want %v, %v", c.Len(), c.Cost(), 2, 8)
+	}
+
+	// 放不下需要淘汰
+	c.Put("33", make([]byte, 6))
+	if c.Cost() > int64(c.Cap()) {
+		t.Errorf("Cost() = %v, want <= %v", c.Cost(), c.Cap())
+	}
+
+	// 超过容量直接拒绝
+	rejected := c.Put("44", make([]byte, 11))
+	if rejected == nil || rejected.Key != "44" || c.Contains("44") {
+		t.Errorf("Put() = %v, want %v", rejected, "44")
+	}
+	if c.Cost() > int64(c.Cap()) {
+		t.Errorf("Cost() = %v, want <= %v", c.Cost(), c.Cap())
+	}
+}
+
+func TestCache_Stats(t *testing.T) {
+	c := New[string, int](2)
+	c.SetStatsCounter(cache.NewStatsCounter())
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("11", 7)
+	c.Get("11")
+	c.Get("33")
+	before := c.Stats()
+	c.Put("33", 8)
+	c.Clear(true)
+
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.HitRate() != 0.5 {
+		t.Errorf("Hits = %v, Misses = %v, want %v, %v", stats.Hits, stats.Misses, 1, 1)
+	}
+	if stats.Puts != 3 || stats.Updates != 1 {
+		t.Errorf("Puts = %v, Updates = %v, want %v, %v", stats.Puts, stats.Updates, 3, 1)
+	}
+	if stats.EvictionCountOf(cache.EvictReasonCapacity) != 1 || stats.EvictionCountOf(cache.EvictReasonClear) != 2 {
+		t.Errorf("Evictions = %v, want %v", stats.Evictions, [3]uint64{1, 0, 2})
+	}
+
+	// 两次快照相减得到区间统计
+	diff := stats.Sub(before)
+	if diff.Puts != 1 || diff.Requests() != 0 || diff.EvictionCount() != 3 {
+		t.Errorf("Sub() = %+v, want Puts %v, Requests %v, Evictions %v", diff, 1, 0, 3)
+	}
+}
+
+func TestCache_Save(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.PutWithTTL("22", 6, time.Hour)
+	c.Put("33", 7)
+	c.Get("11")
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// 恢复后淘汰顺序不变
+	c2 := New[string, int](3)
+	c2.Put("44", 8)
+	if err := c2.Load(&buf); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(c2.Keys(), c.Keys()) {
+		t.Errorf("Keys() = %v, want %v", c2.Keys(), c.Keys())
+	}
+	entry, ok := c2.PeekEntry("22")
+	if !ok || entry.Value != 6 || entry.Expiration.IsZero() {
+		t.Errorf("PeekEntry() = %v, want %v", entry, 6)
+	}
+	c2.Put("55", 9)
+	if c2.Contains("22") {
+		t.Errorf("Contains() = %v, want %v", true, false)
+	}
+}
+
+func TestCache_Load(t *testing.T) {
+	c := New[string, int](3)
+	c.SetCodec(cache.JSONCodec[string]{}, cache.JSONCodec[int]{})
+	c.Put("11", 5)
+	c.Put("22", 6)
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	data := buf.Bytes()
+
+	// 快照不完整时缓存保持不变
+	c2 := New[string, int](1)
+	c2.SetCodec(cache.JSONCodec[string]{}, cache.JSONCodec[int]{})
+	c2.Put("33", 7)
+	if err := c2.Load(bytes.NewReader(data[:len(data)-1])); !errors.Is(err, cache.ErrInvalidSnapshot) {
+		t.Errorf("Load() error = %v, want %v", err, cache.ErrInvalidSnapshot)
+	}
+	if !c2.Contains("33") {
+		t.Errorf("Contains() = %v, want %v", false, true)
+	}
+
+	// 容量变小时按淘汰顺序淘汰
+	if err := c2.Load(bytes.NewReader(data)); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if keys := c2.Keys(); len(keys) != 1 || keys[0] != "22" {
+		t.Errorf("Keys() = %v, want %v", keys, []string{"22"})
+	}
+
+	// 快照在更大的流中时，不会读取快照后面的数据
+	r := io.MultiReader(bytes.NewReader(data), strings.NewReader("tail"))
+	if err := c2.Load(r); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if tail, _ := io.ReadAll(r); string(tail) != "tail" {
+		t.Errorf("ReadAll() = %v, want %v", string(tail), "tail")
+	}
+}
+
+func TestCache_Pin(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	if !c.Pin("11") || !c.Pin("11") || c.Pin("44") {
+		t.Errorf("Pin() = %v, want %v", false, true)
+	}
+
+	// 跳过被固定的元素
+	if evicted := c.Put("44", 8); evicted == nil || evicted.Key != "22" {
+		t.Errorf("Put() = %v, want %v", evicted, "22")
+	}
+
+	// 所有元素都被固定时不会添加，直接返回该元素
+	c.Pin("33")
+	c.Pin("44")
+	if evicted := c.Put("55", 9); evicted == nil || evicted.Key != "55" || c.Contains("55") {
+		t.Errorf("Put() = %v, want %v", evicted, "55")
+	}
+
+	// 解除相同次数的固定后才能淘汰
+	c.Unpin("11")
+	if !c.Pinned("11") {
+		t.Errorf("Pinned() = %v, want %v", false, true)
+	}
+	c.Unpin("11")
+	if evicted := c.Put("55", 9); evicted == nil || evicted.Key != "11" {
+		t.Errorf("Put() = %v, want %v", evicted, "11")
+	}
+	if c.Unpin("11") {
+		t.Errorf("Unpin() = %v, want %v", true, false)
+	}
+
+	// 删除元素会清除固定
+	c.Remove("33")
+	if c.Pinned("33") {
+		t.Errorf("Pinned() = %v, want %v", true, false)
+	}
+}
+
+func TestCache_TryPut(t *testing.T) {
+	c := New[string, []byte](10)
+	c.SetWeigher(func(key string, value []byte) int64 {
+		return int64(len(value))
+	})
+	c.Put("11", make([]byte, 4))
+	c.Put("22", make([]byte, 4))
+
+	// 超过容量返回错误，旧值保持不变
+	if _, err := c.TryPut("11", make([]byte, 11)); !errors.Is(err, cache.ErrTooLarge) {
+		t.Errorf("TryPut() error = %v, want %v", err, cache.ErrTooLarge)
+	}
+	if value, ok := c.Peek("11"); !ok || len(value) != 4 {
+		t.Errorf("Peek() = %v, want %v", len(value), 4)
+	}
+
+	// 淘汰所有没有固定的元素也放不下时返回错误，不会淘汰任何元素
+	c.Pin("11")
+	if _, err := c.TryPut("33", make([]byte, 7)); !errors.Is(err, cache.ErrAllPinned) {
+		t.Errorf("TryPut() error = %v, want %v", err, cache.ErrAllPinned)
+	}
+	if !c.Contains("22") || c.Contains("33") {
+		t.Errorf("Keys() = %v, want %v", c.Keys(), []string{"11", "22"})
+	}
+
+	evicted, err := c.TryPut("33", make([]byte, 6))
+	if err != nil || evicted == nil || evicted.Key != "22" {
+		t.Errorf("TryPut() = %v, %v, want %v, %v", evicted, err, "22", nil)
+	}
+}
+
+func TestCache_TryPutUpdatePinned(t *testing.T) {
+	c := New[string, []byte](10)
+	c.SetWeigher(func(key string, value []byte) int64 {
+		return int64(len(value))
+	})
+	c.Put("11", make([]byte, 2))
+	c.Put("22", make([]byte, 4))
+	c.Put("33", make([]byte, 4))
+	c.Pin("22")
+	c.Pin("33")
+
+	// 其他元素都被固定时，更新变重的元素不会淘汰它自己，允许暂时超过容量
+	evicted, err := c.TryPut("11", make([]byte, 6))
+	if err != nil || evicted != nil {
+		t.Errorf("TryPut() = %v, %v, want %v, %v", evicted, err, nil, nil)
+	}
+	if value, ok := c.Peek("11"); !ok || len(value) != 6 {
+		t.Errorf("Peek() = %v, want %v", len(value), 6)
+	}
+	if c.Len() != 3 || c.Pinned("11") {
+		t.Errorf("Len() = %v, want %v", c.Len(), 3)
+	}
+
+	// 解除固定后淘汰到容量以内
+	c.Unpin("33")
+	if c.Contains("33") || !c.Contains("11") {
+		t.Errorf("Keys() = %v, want %v", c.Keys(), []string{"22", "11"})
+	}
+}
+
+func TestCache_SetCanEvict(t *testing.T) {
+	c := New[string, int](2)
+	c.SetCanEvict(func(entry *cache.Entry[string, int]) bool {
+		return entry.Value%2 == 0
+	})
+	c.Put("11", 5)
+	c.Put("22", 6)
+	if evicted := c.Put("33", 8); evicted == nil || evicted.Key != "22" {
+		t.Errorf("Put() = %v, want %v", evicted, "22")
+	}
+	if evicted := c.Put("44", 7); evicted == nil || evicted.Key != "33" {
+		t.Errorf("Put() = %v, want %v", evicted, "33")
+	}
+	if c.Evict() != nil {
+		t.Errorf("Evict() = %v, want %v", c.Evict(), nil)
+	}
+}
+
+func TestCache_Conformance(t *testing.T) {
+	cachetest.Run(t, func(capacity int) cache.Policy[string, int] {
+		return New[string, int](capacity)
+	}, cachetest.Options{EvictionOrder: true})
+}
+
 // lru_test.go:168: cachePercentage=0.1%, count=206048, hitCount=26717, hitRate=12.97%
 // lru_test.go:168: cachePercentage=0.3%, count=206048, hitCount=58169, hitRate=28.23%
 // lru_test.go:168: cachePercentage=0.5%, count=206048, hitCount=87446, hitRate=42.44%
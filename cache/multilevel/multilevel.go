@@ -0,0 +1,220 @@
+package multilevel
+
+import (
+	"time"
+
+	"github.com/jiaxwu/gommon/cache"
+)
+
+// 每一级缓存的统计信息
+type Stats struct {
+	L1 cache.Stats // 添加新元素次数是 Put() 的次数
+	L2 cache.Stats // 只有L1未命中才会访问L2，添加新元素次数是从L1降级的次数
+}
+
+// 总命中率
+func (s Stats) HitRate() float64 {
+	requests := s.L1.Requests()
+	if requests == 0 {
+		return 0
+	}
+	return float64(s.L1.Hits+s.L2.Hits) / float64(requests)
+}
+
+// 两级缓存
+// L1是小的进程内缓存，直接保存值；L2是更大的缓存，保存用codec编码后的字节
+// L2命中时提升到L1，L1淘汰的元素通过 OnEvict 降级到L2
+// L2保留提升后的元素，L1淘汰时会用新值覆盖，L1的元素过期时会删除L2的元素，所以两级不会不一致
+// L1拒绝添加的元素直接写入L2，避免读到L2的旧值
+// 从L2淘汰的元素会通过 OnEvict 通知
+// 编码失败的元素直接丢弃，解码失败当作未命中
+// 非线程安全，请根据业务加锁
+type Cache[K comparable, V any] struct {
+	l1      cache.Policy[K, V]
+	l2      cache.Cache[K, []byte]
+	codec   cache.Codec[V]
+	onEvict cache.OnEvict[K, V]
+	l2Evict *cache.Entry[K, V]  // 降级时L2淘汰的元素
+	demoted *cache.Entry[K, V]  // 最近一次降级的元素
+	l1Stats *cache.StatsCounter // L1的统计，为空表示不统计
+	l2Stats *cache.StatsCounter // L2的统计，为空表示不统计
+}
+
+// l1：会覆盖它的 OnEvict，只能通过该结构访问
+// l2：可以是任意缓存，实现了 Remove(key K) bool 时 Remove() 和L1的元素过期时会同时删除L2的元素
+func New[K comparable, V any](l1 cache.Policy[K, V], l2 cache.Cache[K, []byte], codec cache.Codec[V]) *Cache[K, V] {
+	c := &Cache[K, V]{
+		l1:    l1,
+		l2:    l2,
+		codec: codec,
+	}
+	l1.SetOnEvict(c.demote)
+	return c
+}
+
+// 设置 OnEvict，元素从L2淘汰时调用，L1淘汰的元素会降级到L2，不会调用
+func (c *Cache[K, V]) SetOnEvict(onEvict cache.OnEvict[K, V]) {
+	c.onEvict = onEvict
+}
+
+// 设置每一级的统计计数器，为空表示不统计
+func (c *Cache[K, V]) SetStatsCounters(l1Stats, l2Stats *cache.StatsCounter) {
+	c.l1Stats = l1Stats
+	c.l2Stats = l2Stats
+}
+
+// 获取每一级的统计信息
+func (c *Cache[K, V]) Stats() Stats {
+	return Stats{
+		L1: c.l1Stats.Snapshot(),
+		L2: c.l2Stats.Snapshot(),
+	}
+}
+
+// 添加或更新元素，只写入L1，L1拒绝添加时写入L2
+// 返回从L2淘汰的元素，解码失败返回nil
+func (c *Cache[K, V]) Put(key K, value V) *cache.Entry[K, V] {
+	if c.l1.Contains(key) {
+		c.l1Stats.RecordUpdate()
+	} else {
+		c.l1Stats.RecordPut()
+	}
+	c.l2Evict = nil
+	c.demoted = nil
+	c.demoteRejected(key, value, c.l1.Put(key, value))
+	evicted := c.l2Evict
+	c.l2Evict = nil
+	return evicted
+}
+
+// 获取元素，L1不存在则查找L2，L2命中时提升到L1
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	if value, ok := c.l1.Get(key); ok {
+		c.l1Stats.RecordHit()
+		return value, true
+	}
+	c.l1Stats.RecordMiss()
+
+	var value V
+	data, ok := c.l2.Get(key)
+	if !ok {
+		c.l2Stats.RecordMiss()
+		return value, false
+	}
+	value, err := c.codec.Unmarshal(data)
+	if err != nil {
+		c.l2Stats.RecordMiss()
+		return value, false
+	}
+	c.l2Stats.RecordHit()
+	c.promote(key, value)
+	// 提升导致的L2淘汰已经通过 OnEvict 通知
+	c.l2Evict = nil
+	return value, true
+}
+
+// 移除元素
+func (c *Cache[K, V]) Remove(key K) bool {
+	removed := c.l1.Remove(key)
+	if c.removeL2(key) {
+		removed = true
+	}
+	return removed
+}
+
+// L1的元素个数
+func (c *Cache[K, V]) Len() int {
+	return c.l1.Len()
+}
+
+// L2命中的元素提升到L1
+// L2支持 PeekEntry 并且L1支持过期时间时保留原来的过期时间
+func (c *Cache[K, V]) promote(key K, value V) {
+	c.demoted = nil
+	l1, ok := c.l1.(interface {
+		PutWithExpiration(key K, value V, expiration time.Time) *cache.Entry[K, V]
+	})
+	if !ok {
+		c.demoteRejected(key, value, c.l1.Put(key, value))
+		return
+	}
+	var expiration time.Time
+	if l2, ok := c.l2.(interface {
+		PeekEntry(key K) (*cache.Entry[K, []byte], bool)
+	}); ok {
+		if entry, ok := l2.PeekEntry(key); ok {
+			expiration = entry.Expiration
+		}
+	}
+	c.demoteRejected(key, value, l1.PutWithExpiration(key, value, expiration))
+}
+
+// L1拒绝添加的元素（比如tinylfu没有准入的候选者）不会触发 OnEvict，需要写入L2
+// 否则它会丢失，或者L2还保留着旧值
+// evicted是L1添加元素时返回的元素
+func (c *Cache[K, V]) demoteRejected(key K, value V, evicted *cache.Entry[K, V]) {
+	if evicted == nil {
+		if !c.l1.Contains(key) {
+			c.demote(&cache.Entry[K, V]{Key: key, Value: value})
+		}
+		return
+	}
+	// 拒绝添加时L1可能还保留着旧值
+	if evicted.Key == key {
+		c.l1.Remove(key)
+		c.demote(evicted)
+		return
+	}
+	// 已经通过 OnEvict 降级过了
+	if c.l1.Contains(evicted.Key) || (c.demoted != nil && c.demoted.Key == evicted.Key) {
+		return
+	}
+	c.demote(evicted)
+}
+
+// L1被淘汰的元素降级到L2
+func (c *Cache[K, V]) demote(entry *cache.Entry[K, V]) {
+	c.demoted = entry
+	// 过期的元素不需要降级，L2可能还保留着更早的值，一起删除
+	if entry.Expired() {
+		c.removeL2(entry.Key)
+		return
+	}
+	data, err := c.codec.Marshal(entry.Value)
+	if err != nil {
+		return
+	}
+	c.l2Stats.RecordPut()
+	var evicted *cache.Entry[K, []byte]
+	// L2支持过期时间则保留原来的过期时间
+	if l2, ok := c.l2.(interface {
+		PutWithExpiration(key K, value []byte, expiration time.Time) *cache.Entry[K, []byte]
+	}); ok {
+		evicted = l2.PutWithExpiration(entry.Key, data, entry.Expiration)
+	} else {
+		evicted = c.l2.Put(entry.Key, data)
+	}
+	if evicted == nil {
+		return
+	}
+	c.l2Stats.RecordEviction(cache.EvictReasonCapacity)
+	value, err := c.codec.Unmarshal(evicted.Value)
+	if err != nil {
+		c.l2Evict = nil
+		return
+	}
+	c.l2Evict = &cache.Entry[K, V]{
+		Key:        evicted.Key,
+		Value:      value,
+		Expiration: evicted.Expiration,
+	}
+	if c.onEvict != nil {
+		c.onEvict(c.l2Evict)
+	}
+}
+
+// 删除L2的元素，L2不支持删除返回false
+func (c *Cache[K, V]) removeL2(key K) bool {
+	l2, ok := c.l2.(interface{ Remove(key K) bool })
+	return ok && l2.Remove(key)
+}
@@ -0,0 +1,237 @@
+package multilevel
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/cache/cachetest"
+	"github.com/jiaxwu/gommon/cache/lru"
+	"github.com/jiaxwu/gommon/cache/tinylfu"
+)
+
+func newCache(l1Cap, l2Cap int) *Cache[string, int] {
+	return New[string, int](lru.New[string, int](l1Cap), lru.New[string, []byte](l2Cap), cache.GobCodec[int]{})
+}
+
+func TestCache_Get(t *testing.T) {
+	c := newCache(2, 10)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+
+	// 11被L1淘汰后降级到L2
+	if c.l1.Contains("11") || c.Len() != 2 {
+		t.Errorf("Contains() = %v, Len() = %v, want %v, %v", true, c.Len(), false, 2)
+	}
+	// L2命中后提升到L1，22降级到L2
+	value, ok := c.Get("11")
+	if value != 5 || !ok {
+		t.Errorf("Get() = %v, want %v", value, 5)
+	}
+	if !c.l1.Contains("11") || c.l1.Contains("22") {
+		t.Errorf("Keys() = %v, want %v", c.l1.Keys(), []string{"33", "11"})
+	}
+	value, ok = c.Get("22")
+	if value != 6 || !ok {
+		t.Errorf("Get() = %v, want %v", value, 6)
+	}
+	if _, ok := c.Get("44"); ok {
+		t.Errorf("Get() = %v, want %v", ok, false)
+	}
+}
+
+func TestCache_Update(t *testing.T) {
+	c := newCache(1, 10)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Get("11")
+	// L2中11的旧值在L1淘汰时被覆盖
+	c.Put("11", 7)
+	c.Put("22", 8)
+	value, ok := c.Get("11")
+	if value != 7 || !ok {
+		t.Errorf("Get() = %v, want %v", value, 7)
+	}
+}
+
+func TestCache_Put(t *testing.T) {
+	c := newCache(1, 1)
+	c.Put("11", 5)
+	if evicted := c.Put("22", 6); evicted != nil {
+		t.Errorf("Put() = %v, want %v", evicted, nil)
+	}
+	// 11从L2淘汰
+	evicted := c.Put("33", 7)
+	if evicted == nil || evicted.Key != "11" || evicted.Value != 5 {
+		t.Errorf("Put() = %v, want %v", evicted, "11")
+	}
+}
+
+func TestCache_Remove(t *testing.T) {
+	c := newCache(1, 10)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	if !c.Remove("11") || !c.Remove("22") || c.Remove("33") {
+		t.Errorf("Remove() = %v, want %v", false, true)
+	}
+	if _, ok := c.Get("11"); ok {
+		t.Errorf("Get() = %v, want %v", ok, false)
+	}
+}
+
+func TestCache_Expired(t *testing.T) {
+	l1 := lru.New[string, int](1)
+	l2 := lru.New[string, []byte](10)
+	c := New[string, int](l1, l2, cache.GobCodec[int]{})
+	l1.PutWithTTL("11", 5, time.Millisecond*10)
+	c.Put("22", 6)
+	// 降级时保留过期时间
+	if entry, ok := l2.PeekEntry("11"); !ok || entry.Expiration.IsZero() {
+		t.Errorf("PeekEntry() = %v, want %v", entry, "11")
+	}
+
+	// 过期的元素不会降级
+	l1.PutWithTTL("33", 7, time.Millisecond*10)
+	time.Sleep(time.Millisecond * 20)
+	c.Put("44", 8)
+	if l2.Contains("33") {
+		t.Errorf("Contains() = %v, want %v", true, false)
+	}
+}
+
+func TestCache_ExpiredUpdate(t *testing.T) {
+	clock := cachetest.UseFakeClock(t)
+	l1 := lru.New[string, int](1)
+	l1.SetDefaultTTL(time.Millisecond * 10)
+	l2 := lru.New[string, []byte](10)
+	c := New[string, int](l1, l2, cache.GobCodec[int]{})
+	l1.PutWithTTL("11", 5, time.Hour)
+	c.Put("22", 6)
+	// 提升后L2保留旧值，更新只写入L1，新值比旧值先过期
+	c.Get("11")
+	c.Put("11", 7)
+
+	// L1的新值过期时删除L2的旧值，不会读到旧值
+	clock.Advance(time.Millisecond * 20)
+	if value, ok := c.Get("11"); ok {
+		t.Errorf("Get() = %v, want %v", value, false)
+	}
+	if l2.Contains("11") {
+		t.Errorf("Contains() = %v, want %v", true, false)
+	}
+}
+
+func TestCache_Promote(t *testing.T) {
+	l1 := lru.New[string, int](1)
+	l2 := lru.New[string, []byte](10)
+	c := New[string, int](l1, l2, cache.GobCodec[int]{})
+	data, _ := cache.GobCodec[int]{}.Marshal(5)
+	l2.PutWithTTL("11", data, time.Hour)
+
+	// 提升时保留L2的过期时间
+	if value, ok := c.Get("11"); value != 5 || !ok {
+		t.Errorf("Get() = %v, want %v", value, 5)
+	}
+	l1Entry, ok := l1.PeekEntry("11")
+	l2Entry, _ := l2.PeekEntry("11")
+	if !ok || !l1Entry.Expiration.Equal(l2Entry.Expiration) {
+		t.Errorf("PeekEntry() = %v, want %v", l1Entry, l2Entry.Expiration)
+	}
+}
+
+func TestCache_OnEvict(t *testing.T) {
+	c := newCache(1, 1)
+	var keys []string
+	c.SetOnEvict(func(entry *cache.Entry[string, int]) {
+		keys = append(keys, entry.Key)
+	})
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	// 提升22时33降级到L2，22从L2淘汰
+	c.Get("22")
+	if !reflect.DeepEqual(keys, []string{"11", "22"}) {
+		t.Errorf("OnEvict() = %v, want %v", keys, []string{"11", "22"})
+	}
+}
+
+func TestCache_Rejected(t *testing.T) {
+	l1 := lru.New[string, int](10)
+	l1.SetWeigher(func(key string, value int) int64 {
+		return int64(value)
+	})
+	c := New[string, int](l1, lru.New[string, []byte](10), cache.GobCodec[int]{})
+	c.Put("11", 1)
+	c.Put("22", 10)
+	c.Put("33", 1)
+
+	// L1放不下时写入L2，覆盖L2的旧值
+	c.Put("11", 20)
+	if value, ok := c.Get("11"); value != 20 || !ok {
+		t.Errorf("Get() = %v, want %v", value, 20)
+	}
+
+	// L1拒绝更新时删除L1的旧值
+	c.Put("33", 20)
+	if value, ok := c.Get("33"); value != 20 || !ok {
+		t.Errorf("Get() = %v, want %v", value, 20)
+	}
+}
+
+func TestCache_Stats(t *testing.T) {
+	c := newCache(2, 10)
+	c.SetStatsCounters(cache.NewStatsCounter(), cache.NewStatsCounter())
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Get("33")
+	c.Get("11")
+	c.Get("44")
+
+	stats := c.Stats()
+	if stats.L1.Hits != 1 || stats.L1.Misses != 2 || stats.L1.Puts != 3 {
+		t.Errorf("L1 = %+v, want Hits = %v, Misses = %v, Puts = %v", stats.L1, 1, 2, 3)
+	}
+	if stats.L2.Hits != 1 || stats.L2.Misses != 1 || stats.L2.Puts != 2 {
+		t.Errorf("L2 = %+v, want Hits = %v, Misses = %v, Puts = %v", stats.L2, 1, 1, 2)
+	}
+	if stats.HitRate() != 2.0/3 {
+		t.Errorf("HitRate() = %v, want %v", stats.HitRate(), 2.0/3)
+	}
+}
+
+// multilevel_test.go:155: cachePercentage=0.1%, count=206048, l1HitRate=6.05%, l2HitRate=8.31%, hitRate=13.85%
+// multilevel_test.go:155: cachePercentage=1.0%, count=206048, l1HitRate=15.10%, l2HitRate=70.79%, hitRate=75.20%
+// multilevel_test.go:155: cachePercentage=10.0%, count=206048, l1HitRate=82.95%, l2HitRate=62.41%, hitRate=93.59%
+func TestHitRate(t *testing.T) {
+	dataset, err := os.ReadFile("../dataset")
+	if err != nil {
+		t.Errorf("read dataset error %v", err)
+	}
+	reqs := strings.Split(string(dataset), ",")
+	testHitRate(t, reqs, 0.001)
+	testHitRate(t, reqs, 0.01)
+	testHitRate(t, reqs, 0.1)
+}
+
+// L1是TinyLFU，容量是L2的十分之一
+func testHitRate(t *testing.T, reqs []string, cachePercentage float64) {
+	count := len(reqs)
+	n := int(float64(count) * cachePercentage)
+	l1 := tinylfu.New[string, int](func(key string) []byte {
+		return []byte(key)
+	}, n/10)
+	c := New[string, int](l1, lru.New[string, []byte](n), cache.GobCodec[int]{})
+	c.SetStatsCounters(cache.NewStatsCounter(), cache.NewStatsCounter())
+	for _, req := range reqs {
+		if _, exists := c.Get(req); !exists {
+			c.Put(req, 0)
+		}
+	}
+	stats := c.Stats()
+	t.Logf("cachePercentage=%.1f%%, count=%v, l1HitRate=%.2f%%, l2HitRate=%.2f%%, hitRate=%.2f%%", cachePercentage*100, count, stats.L1.HitRate()*100, stats.L2.HitRate()*100, stats.HitRate()*100)
+}
@@ -1,11 +1,16 @@
 package nearlylru
 
 import (
+	"io"
 	"time"
 
 	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/slices"
 )
 
+// 快照中的缓存策略名称
+const snapshotPolicy = "nearlylru"
+
 // 最小采样个数
 const MinSamples = 5
 
@@ -19,10 +24,13 @@ type lastAccessEntry[K comparable, V any] struct {
 // 优点：不需要额外链表
 // 非线程安全，请根据业务加锁
 type Cache[K comparable, V any] struct {
-	entries  map[K]*lastAccessEntry[K, V]
-	capacity int                 // 容量
-	samples  int                 // 淘汰时采样数量
-	onEvict  cache.OnEvict[K, V] // 淘汰时的回调函数
+	entries    map[K]*lastAccessEntry[K, V]
+	capacity   int                 // 容量
+	samples    int                 // 淘汰时采样数量
+	onEvict    cache.OnEvict[K, V] // 淘汰时的回调函数
+	stats      *cache.StatsCounter // 统计，为空表示不统计
+	keyCodec   cache.Codec[K]      // 保存快照时Key的编解码器
+	valueCodec cache.Codec[V]      // 保存快照时Value的编解码器
 }
 
 func New[K comparable, V any](capacity int) *Cache[K, V] {
@@ -41,6 +49,16 @@ func (c *Cache[K, V]) SetOnEvict(onEvict cache.OnEvict[K, V]) {
 	c.onEvict = onEvict
 }
 
+// 设置统计计数器，为空表示不统计
+func (c *Cache[K, V]) SetStatsCounter(stats *cache.StatsCounter) {
+	c.stats = stats
+}
+
+// 获取统计信息
+func (c *Cache[K, V]) Stats() cache.Stats {
+	return c.stats.Snapshot()
+}
+
 // 设置采样个数
 func (c *Cache[K, V]) SetSamples(samples int) {
 	// 采样数量不能太小，否则和随机没区别
@@ -61,6 +79,7 @@ func (c *Cache[K, V]) Put(key K, value V) *cache.Entry[K, V] {
 	if entry, ok := c.entries[key]; ok {
 		entry.entry.Value = value
 		entry.lastAccess = time.Now()
+		c.stats.RecordUpdate()
 		return nil
 	}
 
@@ -78,6 +97,7 @@ func (c *Cache[K, V]) Put(key K, value V) *cache.Entry[K, V] {
 		},
 		lastAccess: time.Now(),
 	}
+	c.stats.RecordPut()
 	return evicted
 }
 
@@ -86,10 +106,12 @@ func (c *Cache[K, V]) Get(key K) (V, bool) {
 	// 如果存在更新时间，然后返回
 	if entry, ok := c.entries[key]; ok {
 		entry.lastAccess = time.Now()
+		c.stats.RecordHit()
 		return entry.entry.Value, true
 	}
 
 	// 不存在返回空值和false
+	c.stats.RecordMiss()
 	var value V
 	return value, false
 }
@@ -174,6 +196,7 @@ func (c *Cache[K, V]) Evict() *cache.Entry[K, V] {
 	}
 	// 淘汰
 	delete(c.entries, evictEntry.entry.Key)
+	c.stats.RecordEviction(cache.EvictReasonCapacity)
 	// 回调
 	if c.onEvict != nil {
 		c.onEvict(evictEntry.entry)
@@ -184,9 +207,12 @@ func (c *Cache[K, V]) Evict() *cache.Entry[K, V] {
 // 清空缓存
 func (c *Cache[K, V]) Clear(needOnEvict bool) {
 	// 触发回调
-	if needOnEvict && c.onEvict != nil {
+	if needOnEvict {
 		for _, entry := range c.entries {
-			c.onEvict(entry.entry)
+			c.stats.RecordEviction(cache.EvictReasonClear)
+			if c.onEvict != nil {
+				c.onEvict(entry.entry)
+			}
 		}
 	}
 
@@ -194,6 +220,71 @@ func (c *Cache[K, V]) Clear(needOnEvict bool) {
 	c.entries = make(map[K]*lastAccessEntry[K, V])
 }
 
+// 设置保存快照时使用的编解码器，为空使用GobCodec
+func (c *Cache[K, V]) SetCodec(keyCodec cache.Codec[K], valueCodec cache.Codec[V]) {
+	c.keyCodec = keyCodec
+	c.valueCodec = valueCodec
+}
+
+// 保存快照到w，包括元素的使用顺序
+func (c *Cache[K, V]) Save(w io.Writer) error {
+	return c.Snapshot().Encode(w, c.keyCodec, c.valueCodec)
+}
+
+// 从r加载快照，会先清空缓存，不触发回调
+// 快照格式不正确时缓存保持不变
+func (c *Cache[K, V]) Load(r io.Reader) error {
+	snapshot, err := cache.DecodeSnapshot(r, snapshotPolicy, c.keyCodec, c.valueCodec)
+	if err != nil {
+		return err
+	}
+	return c.Restore(snapshot)
+}
+
+// 获取快照，按最后一次使用时间排列，最早使用的在前面
+func (c *Cache[K, V]) Snapshot() *cache.Snapshot[K, V] {
+	entries := make([]*lastAccessEntry[K, V], 0, c.Len())
+	for _, entry := range c.entries {
+		entries = append(entries, entry)
+	}
+	slices.Sort(entries, func(entry1, entry2 *lastAccessEntry[K, V]) bool {
+		return entry1.lastAccess.Before(entry2.lastAccess)
+	})
+	snapshot := &cache.Snapshot[K, V]{
+		Policy:  snapshotPolicy,
+		Records: make([]*cache.SnapshotRecord[K, V], len(entries)),
+	}
+	for i, entry := range entries {
+		e := *entry.entry
+		snapshot.Records[i] = &cache.SnapshotRecord[K, V]{
+			Entry: &e,
+		}
+	}
+	return snapshot
+}
+
+// 从快照恢复，会先清空缓存，不触发回调
+// 只保留元素的使用顺序，最后一次使用时间从现在开始递增
+// 放不下的元素按采样淘汰
+func (c *Cache[K, V]) Restore(snapshot *cache.Snapshot[K, V]) error {
+	if snapshot.Policy != snapshotPolicy {
+		return cache.ErrSnapshotPolicyMismatch
+	}
+	c.Clear(false)
+	now := time.Now()
+	for i, record := range snapshot.Records {
+		entry := *record.Entry
+		c.entries[entry.Key] = &lastAccessEntry[K, V]{
+			entry:      &entry,
+			lastAccess: now.Add(time.Duration(i)),
+		}
+	}
+	for c.Len() > c.capacity {
+		c.Evict()
+	}
+	return nil
+}
+
 // 改变容量
 func (c *Cache[K, V]) Resize(capacity int, needOnEvict bool) {
 	diff := c.Len() - capacity
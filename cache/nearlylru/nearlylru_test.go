@@ -1,11 +1,46 @@
 package nearlylru
 
 import (
+	"bytes"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/cache/cachetest"
 )
 
+func TestCache_Save(t *testing.T) {
+	c := New[string, int](5)
+	for i := 0; i < 5; i++ {
+		c.Put(strconv.Itoa(i), i)
+		time.Sleep(time.Millisecond)
+	}
+	c.Get("0")
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// 恢复后使用顺序不变，采样全部元素时淘汰最早使用的
+	c2 := New[string, int](5)
+	if err := c2.Load(&buf); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	c2.Put("5", 5)
+	if c2.Len() != 5 || c2.Contains("1") || !c2.Contains("0") {
+		t.Errorf("Contains() = %v, want %v", c2.Contains("1"), false)
+	}
+}
+
+func TestCache_Conformance(t *testing.T) {
+	cachetest.Run(t, func(capacity int) cache.Policy[string, int] {
+		return New[string, int](capacity)
+	}, cachetest.Options{Unordered: true})
+}
+
 // nearlylru_test.go:58: samples=5, cachePercentage=0.1%, count=206048, hitCount=26545, hitRate=12.88%
 // nearlylru_test.go:58: samples=5, cachePercentage=0.3%, count=206048, hitCount=56550, hitRate=27.45%
 // nearlylru_test.go:58: samples=5, cachePercentage=0.5%, count=206048, hitCount=84843, hitRate=41.18%
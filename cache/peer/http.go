@@ -0,0 +1,144 @@
+package peer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/jiaxwu/gommon/consistenthash"
+)
+
+const (
+	// 默认的路径前缀
+	defaultBasePath = "/_peer/"
+	// 默认每个节点的虚拟节点数量
+	defaultReplicas = 50
+)
+
+// 基于HTTP的节点池
+// 通过一致性哈希选择Key所在的节点，同时作为 http.Handler 响应其他节点的请求
+// 请求路径是 basePath/group/key
+// 线程安全
+type HTTPPool struct {
+	self     string // 本节点的地址，比如 http://localhost:8000
+	basePath string
+	client   *http.Client
+	mutex    sync.RWMutex // 保护ring、fetchers和groups
+	ring     *consistenthash.HashRing
+	fetchers map[string]*httpFetcher
+	groups   map[string]*Group
+}
+
+// self：本节点的地址，需要和 Set() 中的地址一致
+func NewHTTPPool(self string) *HTTPPool {
+	return &HTTPPool{
+		self:     self,
+		basePath: defaultBasePath,
+		client:   http.DefaultClient,
+		ring:     consistenthash.New(defaultReplicas, nil),
+		fetchers: make(map[string]*httpFetcher),
+		groups:   make(map[string]*Group),
+	}
+}
+
+// 设置HTTP客户端，比如需要设置超时
+// 需要在 Set() 之前设置
+func (p *HTTPPool) SetClient(client *http.Client) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.client = client
+}
+
+// 设置所有节点的地址，包括本节点，会替换原来的节点
+func (p *HTTPPool) Set(peers ...string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.ring.Reset(peers...)
+	p.fetchers = make(map[string]*httpFetcher, len(peers))
+	for _, peer := range peers {
+		p.fetchers[peer] = &httpFetcher{
+			baseURL: strings.TrimSuffix(peer, "/") + p.basePath,
+			client:  p.client,
+		}
+	}
+}
+
+// 注册Group，Group通过该节点池访问远程节点，其他节点也可以通过该节点池访问Group
+func (p *HTTPPool) Register(group *Group) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.groups[group.Name()] = group
+	group.SetPicker(p)
+}
+
+// 选择Key所在的节点
+func (p *HTTPPool) Pick(key string) (Fetcher, bool) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	peer := p.ring.Get(key)
+	if peer == "" || peer == p.self {
+		return nil, false
+	}
+	return p.fetchers[peer], true
+}
+
+// 响应其他节点的请求
+func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, p.basePath) {
+		http.NotFound(w, r)
+		return
+	}
+	parts := strings.SplitN(r.URL.Path[len(p.basePath):], "/", 2)
+	if len(parts) != 2 {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	name, key := parts[0], parts[1]
+
+	p.mutex.RLock()
+	group, ok := p.groups[name]
+	p.mutex.RUnlock()
+	if !ok {
+		http.Error(w, "no such group: "+name, http.StatusNotFound)
+		return
+	}
+	value, err := group.getLocally(r.Context(), key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(value)
+}
+
+// 基于HTTP的远程节点
+type httpFetcher struct {
+	baseURL string
+	client  *http.Client
+}
+
+// 从远程节点获取元素
+func (f *httpFetcher) Fetch(ctx context.Context, group, key string) ([]byte, error) {
+	u := f.baseURL + url.PathEscape(group) + "/" + url.PathEscape(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("peer returned %v: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
@@ -0,0 +1,115 @@
+package peer
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// 在本机启动多个节点
+func startPeers(t *testing.T, n int, getter Getter) ([]*Group, []*HTTPPool) {
+	var addrs []string
+	var listeners []net.Listener
+	for i := 0; i < n; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Listen() error = %v", err)
+		}
+		listeners = append(listeners, l)
+		addrs = append(addrs, "http://"+l.Addr().String())
+	}
+
+	var groups []*Group
+	var pools []*HTTPPool
+	for i := 0; i < n; i++ {
+		pool := NewHTTPPool(addrs[i])
+		pool.Set(addrs...)
+		group := NewGroup("test", 100, 10, getter)
+		pool.Register(group)
+		server := &httptest.Server{
+			Listener: listeners[i],
+			Config:   &http.Server{Handler: pool},
+		}
+		server.Start()
+		t.Cleanup(server.Close)
+		groups = append(groups, group)
+		pools = append(pools, pool)
+	}
+	return groups, pools
+}
+
+func TestHTTPPool(t *testing.T) {
+	var mutex sync.Mutex
+	loads := make(map[string]int)
+	getter := func(ctx context.Context, key string) ([]byte, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		loads[key]++
+		return []byte("value:" + key), nil
+	}
+	groups, _ := startPeers(t, 3, getter)
+
+	// 每个Key只会被负责的节点加载一次
+	for _, group := range groups {
+		for i := 0; i < 50; i++ {
+			key := "key/" + strconv.Itoa(i)
+			value, err := group.Get(context.Background(), key)
+			if err != nil || string(value) != "value:"+key {
+				t.Errorf("Get() = %s, %v, want %v, %v", value, err, "value:"+key, nil)
+			}
+		}
+	}
+	for key, n := range loads {
+		if n != 1 {
+			t.Errorf("loads[%v] = %v, want %v", key, n, 1)
+		}
+	}
+	if len(loads) != 50 {
+		t.Errorf("len(loads) = %v, want %v", len(loads), 50)
+	}
+}
+
+func TestHTTPPool_Pick(t *testing.T) {
+	p := NewHTTPPool("http://a")
+	if _, ok := p.Pick("11"); ok {
+		t.Errorf("Pick() = %v, want %v", ok, false)
+	}
+	p.Set("http://a", "http://b")
+	remote := 0
+	for i := 0; i < 100; i++ {
+		if _, ok := p.Pick(strconv.Itoa(i)); ok {
+			remote++
+		}
+	}
+	if remote == 0 || remote == 100 {
+		t.Errorf("remote = %v, want between %v and %v", remote, 0, 100)
+	}
+}
+
+func TestHTTPPool_ServeHTTP(t *testing.T) {
+	p := NewHTTPPool("http://a")
+	p.Register(NewGroup("test", 10, 2, func(ctx context.Context, key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/_peer/test/11", http.StatusOK, "11"},
+		{"/_peer/other/11", http.StatusNotFound, ""},
+		{"/_peer/test", http.StatusBadRequest, ""},
+		{"/other/test/11", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code || (tt.body != "" && w.Body.String() != tt.body) {
+			t.Errorf("ServeHTTP(%v) = %v, %v, want %v, %v", tt.path, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+	}
+}
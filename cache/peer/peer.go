@@ -0,0 +1,86 @@
+package peer
+
+import (
+	"context"
+
+	"github.com/jiaxwu/gommon/cache/loader"
+	"github.com/jiaxwu/gommon/cache/lru"
+)
+
+// 本节点负责的Key不存在时调用，从数据源加载
+type Getter func(ctx context.Context, key string) ([]byte, error)
+
+// 远程节点
+type Fetcher interface {
+	// 从远程节点获取group中的元素
+	Fetch(ctx context.Context, group, key string) ([]byte, error)
+}
+
+// 选择Key所在的节点
+type Picker interface {
+	// 返回负责该Key的远程节点，由本节点负责则返回false
+	Pick(key string) (Fetcher, bool)
+}
+
+// 一组分布在多个节点上的缓存，参考 groupcache
+// 每个Key通过一致性哈希由一个节点负责加载，其他节点向它获取
+// 本节点负责的元素放在主缓存，从远程节点获取的元素放在热点缓存，避免热点Key总是访问远程节点
+// 和groupcache一样，元素一旦加载不会更新，返回的值不能修改
+// 同一个Key的并发加载和远程获取只会执行一次
+// 线程安全
+type Group struct {
+	name   string
+	getter Getter
+	picker Picker
+	main   *loader.Cache[string, []byte] // 本节点负责的元素
+	hot    *loader.Cache[string, []byte] // 从远程节点获取的元素
+}
+
+// mainCapacity：主缓存容量
+// hotCapacity：热点缓存容量，一般比主缓存小
+func NewGroup(name string, mainCapacity, hotCapacity int, getter Getter) *Group {
+	return &Group{
+		name:   name,
+		getter: getter,
+		main:   loader.New[string, []byte](lru.New[string, []byte](mainCapacity)),
+		hot:    loader.New[string, []byte](lru.New[string, []byte](hotCapacity)),
+	}
+}
+
+// 名字，节点之间通过名字找到对应的Group
+func (g *Group) Name() string {
+	return g.name
+}
+
+// 设置节点选择器，为空表示只有本节点
+// 需要在 Get() 之前设置
+func (g *Group) SetPicker(picker Picker) {
+	g.picker = picker
+}
+
+// 获取元素
+// 由远程节点负责的Key向远程节点获取，远程节点失败则在本节点加载
+func (g *Group) Get(ctx context.Context, key string) ([]byte, error) {
+	if value, ok := g.main.Get(key); ok {
+		return value, nil
+	}
+	if value, ok := g.hot.Get(key); ok {
+		return value, nil
+	}
+	if g.picker != nil {
+		if fetcher, ok := g.picker.Pick(key); ok {
+			value, err := g.hot.GetOrLoad(ctx, key, func(ctx context.Context, key string) ([]byte, error) {
+				return fetcher.Fetch(ctx, g.name, key)
+			})
+			if err == nil {
+				return value, nil
+			}
+		}
+	}
+	return g.getLocally(ctx, key)
+}
+
+// 在本节点获取元素，不访问远程节点，避免节点之间的路由不一致时互相转发
+func (g *Group) getLocally(ctx context.Context, key string) ([]byte, error) {
+	return g.main.GetOrLoad(ctx, key, loader.LoadFunc[string, []byte](g.getter))
+}
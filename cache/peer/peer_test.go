@@ -0,0 +1,88 @@
+package peer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// 固定返回某个节点的选择器
+type stubPicker struct {
+	fetcher Fetcher
+}
+
+func (p *stubPicker) Pick(key string) (Fetcher, bool) {
+	return p.fetcher, p.fetcher != nil
+}
+
+// 记录调用次数的远程节点
+type stubFetcher struct {
+	mutex   sync.Mutex
+	fetches int
+	err     error
+}
+
+func (f *stubFetcher) Fetch(ctx context.Context, group, key string) ([]byte, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.fetches++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []byte("remote:" + key), nil
+}
+
+// 记录调用次数的加载函数
+func countingGetter(loads *int, mutex *sync.Mutex) Getter {
+	return func(ctx context.Context, key string) ([]byte, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		*loads++
+		return []byte("local:" + key), nil
+	}
+}
+
+func TestGroup_GetLocally(t *testing.T) {
+	var loads int
+	var mutex sync.Mutex
+	g := NewGroup("test", 10, 2, countingGetter(&loads, &mutex))
+	for i := 0; i < 3; i++ {
+		value, err := g.Get(context.Background(), "11")
+		if err != nil || string(value) != "local:11" {
+			t.Errorf("Get() = %s, %v, want %v, %v", value, err, "local:11", nil)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("loads = %v, want %v", loads, 1)
+	}
+}
+
+func TestGroup_GetRemote(t *testing.T) {
+	var loads int
+	var mutex sync.Mutex
+	g := NewGroup("test", 10, 2, countingGetter(&loads, &mutex))
+	fetcher := &stubFetcher{}
+	g.SetPicker(&stubPicker{fetcher: fetcher})
+
+	// 远程节点的元素放在热点缓存
+	for i := 0; i < 3; i++ {
+		value, err := g.Get(context.Background(), "11")
+		if err != nil || string(value) != "remote:11" {
+			t.Errorf("Get() = %s, %v, want %v, %v", value, err, "remote:11", nil)
+		}
+	}
+	if fetcher.fetches != 1 || loads != 0 {
+		t.Errorf("fetches = %v, loads = %v, want %v, %v", fetcher.fetches, loads, 1, 0)
+	}
+
+	// 远程节点失败则在本节点加载
+	fetcher.err = errors.New("peer down")
+	value, err := g.Get(context.Background(), "22")
+	if err != nil || string(value) != "local:22" {
+		t.Errorf("Get() = %s, %v, want %v, %v", value, err, "local:22", nil)
+	}
+	if fetcher.fetches != 2 || loads != 1 {
+		t.Errorf("fetches = %v, loads = %v, want %v, %v", fetcher.fetches, loads, 2, 1)
+	}
+}
@@ -1,14 +1,24 @@
 package random
 
-import "github.com/jiaxwu/gommon/cache"
+import (
+	"io"
+
+	"github.com/jiaxwu/gommon/cache"
+)
+
+// 快照中的缓存策略名称
+const snapshotPolicy = "random"
 
 // 随机
 // 优点：实现简单
 // 非线程安全，请根据业务加锁
 type Cache[K comparable, V any] struct {
-	entries  map[K]V
-	capacity int
-	onEvict  cache.OnEvict[K, V]
+	entries    map[K]V
+	capacity   int
+	onEvict    cache.OnEvict[K, V]
+	stats      *cache.StatsCounter // 统计，为空表示不统计
+	keyCodec   cache.Codec[K]      // 保存快照时Key的编解码器
+	valueCodec cache.Codec[V]      // 保存快照时Value的编解码器
 }
 
 func New[K comparable, V any](capacity int) *Cache[K, V] {
@@ -26,12 +36,23 @@ func (c *Cache[K, V]) SetOnEvict(onEvict cache.OnEvict[K, V]) {
 	c.onEvict = onEvict
 }
 
+// 设置统计计数器，为空表示不统计
+func (c *Cache[K, V]) SetStatsCounter(stats *cache.StatsCounter) {
+	c.stats = stats
+}
+
+// 获取统计信息
+func (c *Cache[K, V]) Stats() cache.Stats {
+	return c.stats.Snapshot()
+}
+
 // 添加或更新元素
 // 返回被淘汰的元素
 func (c *Cache[K, V]) Put(key K, value V) *cache.Entry[K, V] {
 	// 如果 key 已经存在，直接设置新值
 	if _, ok := c.entries[key]; ok {
 		c.entries[key] = value
+		c.stats.RecordUpdate()
 		return nil
 	}
 
@@ -43,12 +64,19 @@ func (c *Cache[K, V]) Put(key K, value V) *cache.Entry[K, V] {
 
 	// 添加元素
 	c.entries[key] = value
+	c.stats.RecordPut()
 	return evicted
 }
 
 // 获取元素
 func (c *Cache[K, V]) Get(key K) (V, bool) {
-	return c.Peek(key)
+	value, ok := c.Peek(key)
+	if ok {
+		c.stats.RecordHit()
+	} else {
+		c.stats.RecordMiss()
+	}
+	return value, ok
 }
 
 // 获取元素
@@ -112,6 +140,7 @@ func (c *Cache[K, V]) Remove(key K) bool {
 func (c *Cache[K, V]) Evict() *cache.Entry[K, V] {
 	for key, value := range c.entries {
 		delete(c.entries, key)
+		c.stats.RecordEviction(cache.EvictReasonCapacity)
 		// 回调
 		if c.onEvict != nil {
 			c.onEvict(&cache.Entry[K, V]{
@@ -130,12 +159,15 @@ func (c *Cache[K, V]) Evict() *cache.Entry[K, V] {
 // 清空缓存
 func (c *Cache[K, V]) Clear(needOnEvict bool) {
 	// 触发回调
-	if needOnEvict && c.onEvict != nil {
+	if needOnEvict {
 		for key, value := range c.entries {
-			c.onEvict(&cache.Entry[K, V]{
-				Key:   key,
-				Value: value,
-			})
+			c.stats.RecordEviction(cache.EvictReasonClear)
+			if c.onEvict != nil {
+				c.onEvict(&cache.Entry[K, V]{
+					Key:   key,
+					Value: value,
+				})
+			}
 		}
 	}
 
@@ -143,6 +175,60 @@ func (c *Cache[K, V]) Clear(needOnEvict bool) {
 	c.entries = make(map[K]V)
 }
 
+// 设置保存快照时使用的编解码器，为空使用GobCodec
+func (c *Cache[K, V]) SetCodec(keyCodec cache.Codec[K], valueCodec cache.Codec[V]) {
+	c.keyCodec = keyCodec
+	c.valueCodec = valueCodec
+}
+
+// 保存快照到w
+func (c *Cache[K, V]) Save(w io.Writer) error {
+	return c.Snapshot().Encode(w, c.keyCodec, c.valueCodec)
+}
+
+// 从r加载快照，会先清空缓存，不触发回调
+// 快照格式不正确时缓存保持不变
+func (c *Cache[K, V]) Load(r io.Reader) error {
+	snapshot, err := cache.DecodeSnapshot(r, snapshotPolicy, c.keyCodec, c.valueCodec)
+	if err != nil {
+		return err
+	}
+	return c.Restore(snapshot)
+}
+
+// 获取快照
+func (c *Cache[K, V]) Snapshot() *cache.Snapshot[K, V] {
+	snapshot := &cache.Snapshot[K, V]{
+		Policy:  snapshotPolicy,
+		Records: make([]*cache.SnapshotRecord[K, V], 0, c.Len()),
+	}
+	for key, value := range c.entries {
+		snapshot.Records = append(snapshot.Records, &cache.SnapshotRecord[K, V]{
+			Entry: &cache.Entry[K, V]{
+				Key:   key,
+				Value: value,
+			},
+		})
+	}
+	return snapshot
+}
+
+// 从快照恢复，会先清空缓存，不触发回调
+// 放不下的元素随机淘汰
+func (c *Cache[K, V]) Restore(snapshot *cache.Snapshot[K, V]) error {
+	if snapshot.Policy != snapshotPolicy {
+		return cache.ErrSnapshotPolicyMismatch
+	}
+	c.Clear(false)
+	for _, record := range snapshot.Records {
+		c.entries[record.Entry.Key] = record.Entry.Value
+	}
+	for c.Len() > c.capacity {
+		c.Evict()
+	}
+	return nil
+}
+
 // 改变容量
 func (c *Cache[K, V]) Resize(capacity int, needOnEvict bool) {
 	diff := c.Len() - capacity
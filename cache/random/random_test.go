@@ -1,11 +1,39 @@
 package random
 
 import (
+	"bytes"
 	"os"
 	"strings"
 	"testing"
+
+	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/cache/cachetest"
 )
 
+func TestCache_Save(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	c2 := New[string, int](3)
+	if err := c2.Load(&buf); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if value, ok := c2.Get("22"); c2.Len() != 2 || value != 6 || !ok {
+		t.Errorf("Get() = %v, want %v", value, 6)
+	}
+}
+
+func TestCache_Conformance(t *testing.T) {
+	cachetest.Run(t, func(capacity int) cache.Policy[string, int] {
+		return New[string, int](capacity)
+	}, cachetest.Options{Unordered: true})
+}
+
 // random_test.go:49: cachePercentage=0.1%, count=206048, hitCount=26140, hitRate=12.69%
 // random_test.go:49: cachePercentage=0.3%, count=206048, hitCount=55157, hitRate=26.77%
 // random_test.go:49: cachePercentage=0.5%, count=206048, hitCount=80166, hitRate=38.91%
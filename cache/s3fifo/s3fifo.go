@@ -0,0 +1,600 @@
+package s3fifo
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/container/list"
+	"github.com/jiaxwu/gommon/math"
+)
+
+const (
+	// 小队列比例
+	smallPercentage = 0.1
+	// 访问频率上限
+	maxFrequency = 3
+	// 快照中的缓存策略名称
+	snapshotPolicy = "s3fifo"
+)
+
+// 快照中元素所在的队列
+const (
+	segmentSmall uint8 = iota // 小队列
+	segmentMain               // 主队列
+	segmentGhost              // 幽灵队列，只有Key
+)
+
+type frequencyEntry[K comparable, V any] struct {
+	entry     *cache.Entry[K, V]
+	frequency uint8 // 访问频率，最大为maxFrequency
+	small     bool  // 是否在小队列
+}
+
+// S3-FIFO
+// 由小队列、主队列和幽灵队列三个FIFO队列组成，访问时只增加频率，不移动元素
+// 新元素先进入小队列，只访问过一次的元素很快被淘汰，Key进入幽灵队列
+// 小队列中访问过的元素和幽灵队列中的元素会进入主队列
+// 主队列淘汰时访问过的元素会重新插入，同时频率减一
+// 优点：命中时不需要调整队列，能快速淘汰只访问一次的元素
+// 非线程安全，请根据业务加锁
+// https://dl.acm.org/doi/10.1145/3600006.3613147
+type Cache[K comparable, V any] struct {
+	entries    map[K]*list.Element[*frequencyEntry[K, V]]
+	small      *list.List[*frequencyEntry[K, V]] // 小队列
+	main       *list.List[*frequencyEntry[K, V]] // 主队列
+	ghosts     map[K]*list.Element[K]            // 幽灵队列的Key
+	ghostList  *list.List[K]                     // 幽灵队列
+	capacity   int
+	smallCap   int                 // 小队列容量
+	smallCost  int64               // 小队列的总权重
+	cost       int64               // 当前总权重
+	weigher    cache.Weigher[K, V] // 计算元素权重，为空则每个元素权重为1
+	ttl        time.Duration       // 默认过期时间
+	onEvict    cache.OnEvictWithReason[K, V]
+	janitor    *cache.Janitor[K]
+	stats      *cache.StatsCounter // 统计，为空表示不统计
+	keyCodec   cache.Codec[K]      // 保存快照时Key的编解码器
+	valueCodec cache.Codec[V]      // 保存快照时Value的编解码器
+}
+
+func New[K comparable, V any](capacity int) *Cache[K, V] {
+	if capacity < 1 {
+		panic("too small capacity")
+	}
+	return &Cache[K, V]{
+		entries:   make(map[K]*list.Element[*frequencyEntry[K, V]]),
+		small:     list.New[*frequencyEntry[K, V]](),
+		main:      list.New[*frequencyEntry[K, V]](),
+		ghosts:    make(map[K]*list.Element[K]),
+		ghostList: list.New[K](),
+		capacity:  capacity,
+		smallCap:  smallCap(capacity),
+	}
+}
+
+// 设置 OnEvict
+func (c *Cache[K, V]) SetOnEvict(onEvict cache.OnEvict[K, V]) {
+	c.onEvict = onEvict.WithReason()
+}
+
+// 设置 OnEvict，带上淘汰原因
+func (c *Cache[K, V]) SetOnEvictWithReason(onEvict cache.OnEvictWithReason[K, V]) {
+	c.onEvict = onEvict
+}
+
+// 设置权重计算函数，设置后容量表示总权重，而不是元素个数
+func (c *Cache[K, V]) SetWeigher(weigher cache.Weigher[K, V]) {
+	c.weigher = weigher
+	// 重新计算总权重
+	c.cost, c.smallCost = 0, 0
+	for _, elem := range c.entries {
+		weight := c.weigh(elem.Value.entry.Key, elem.Value.entry.Value)
+		c.cost += weight
+		if elem.Value.small {
+			c.smallCost += weight
+		}
+	}
+	c.evictToFit(0)
+}
+
+// 设置统计计数器，为空表示不统计
+func (c *Cache[K, V]) SetStatsCounter(stats *cache.StatsCounter) {
+	c.stats = stats
+}
+
+// 获取统计信息
+func (c *Cache[K, V]) Stats() cache.Stats {
+	return c.stats.Snapshot()
+}
+
+// 设置默认过期时间，Put()会使用该过期时间，小于等于0表示永不过期
+func (c *Cache[K, V]) SetDefaultTTL(ttl time.Duration) {
+	c.ttl = ttl
+}
+
+// 启动过期清理器，到期主动删除元素，直到ctx被关闭
+// locker是业务访问缓存时使用的锁
+// 只会清理启动之后添加的元素，之前添加的元素依然在访问时删除
+func (c *Cache[K, V]) StartJanitor(ctx context.Context, locker sync.Locker) {
+	c.janitor = cache.NewJanitor(locker, c.RemoveExpired)
+	go c.janitor.Run(ctx)
+}
+
+// 添加或更新元素
+// 返回被淘汰的元素
+func (c *Cache[K, V]) Put(key K, value V) *cache.Entry[K, V] {
+	return c.PutWithTTL(key, value, c.ttl)
+}
+
+// 添加或更新元素，并设置过期时间，小于等于0表示永不过期
+// 返回被淘汰的元素
+func (c *Cache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) *cache.Entry[K, V] {
+	return c.PutWithExpiration(key, value, cache.Expiration(ttl))
+}
+
+// 添加或更新元素，并设置过期时刻，零值表示永不过期
+// 返回被淘汰的元素，如果淘汰了多个元素，返回最后一个
+// 如果元素权重超过容量，则不会添加，直接返回该元素
+func (c *Cache[K, V]) PutWithExpiration(key K, value V, expiration time.Time) *cache.Entry[K, V] {
+	weight := c.weigh(key, value)
+	if weight > int64(c.capacity) {
+		c.Remove(key)
+		return &cache.Entry[K, V]{
+			Key:        key,
+			Value:      value,
+			Expiration: expiration,
+		}
+	}
+
+	// 如果 key 已经存在，增加访问频率，然后设置新值
+	if elem, ok := c.entries[key]; ok {
+		c.inc(elem.Value)
+		oldWeight := c.weigh(key, elem.Value.entry.Value)
+		c.cost += weight - oldWeight
+		if elem.Value.small {
+			c.smallCost += weight - oldWeight
+		}
+		elem.Value.entry.Value = value
+		elem.Value.entry.Expiration = expiration
+		c.schedule(key, expiration)
+		c.stats.RecordUpdate()
+		// 权重变大可能需要淘汰元素
+		return c.evictToFit(0)
+	}
+
+	// 如果放不下，先剔除元素
+	evicted := c.evictToFit(weight)
+
+	// 在幽灵队列说明最近被淘汰过，直接进入主队列，否则进入小队列
+	entry := &frequencyEntry[K, V]{
+		entry: &cache.Entry[K, V]{
+			Key:        key,
+			Value:      value,
+			Expiration: expiration,
+		},
+	}
+	if ghost, ok := c.ghosts[key]; ok {
+		c.removeGhost(ghost)
+		c.entries[key] = c.main.PushFront(entry)
+	} else {
+		entry.small = true
+		c.entries[key] = c.small.PushFront(entry)
+		c.smallCost += weight
+	}
+	c.cost += weight
+	c.schedule(key, expiration)
+	c.stats.RecordPut()
+	return evicted
+}
+
+// 获取元素
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	// 如果存在增加访问频率，然后返回
+	if elem, ok := c.entries[key]; ok {
+		// 过期了直接删除
+		if elem.Value.entry.Expired() {
+			c.expireElement(elem)
+			c.stats.RecordMiss()
+			var value V
+			return value, false
+		}
+		c.inc(elem.Value)
+		c.stats.RecordHit()
+		return elem.Value.entry.Value, true
+	}
+
+	// 不存在返回空值和false
+	c.stats.RecordMiss()
+	var value V
+	return value, false
+}
+
+// 获取元素，不更新状态
+func (c *Cache[K, V]) Peek(key K) (V, bool) {
+	// 如果存在
+	if entry, ok := c.PeekEntry(key); ok {
+		return entry.Value, true
+	}
+
+	// 不存在返回空值和false
+	var value V
+	return value, false
+}
+
+// 获取缓存项，不更新状态
+func (c *Cache[K, V]) PeekEntry(key K) (*cache.Entry[K, V], bool) {
+	// 如果存在并且没有过期
+	if elem, ok := c.entries[key]; ok && !elem.Value.entry.Expired() {
+		return elem.Value.entry, true
+	}
+	return nil, false
+}
+
+// 是否包含元素，不更新状态
+func (c *Cache[K, V]) Contains(key K) bool {
+	_, ok := c.PeekEntry(key)
+	return ok
+}
+
+// 获取缓存的Keys，先是小队列，然后是主队列，队列内按插入顺序排列
+// 可能包含已经过期但还没被删除的元素
+func (c *Cache[K, V]) Keys() []K {
+	keys := make([]K, 0, c.Len())
+	c.each(func(entry *frequencyEntry[K, V]) {
+		keys = append(keys, entry.entry.Key)
+	})
+	return keys
+}
+
+// 获取缓存的Values
+func (c *Cache[K, V]) Values() []V {
+	values := make([]V, 0, c.Len())
+	c.each(func(entry *frequencyEntry[K, V]) {
+		values = append(values, entry.entry.Value)
+	})
+	return values
+}
+
+// 获取缓存的Entries
+func (c *Cache[K, V]) Entries() []*cache.Entry[K, V] {
+	entries := make([]*cache.Entry[K, V], 0, c.Len())
+	c.each(func(entry *frequencyEntry[K, V]) {
+		entries = append(entries, entry.entry)
+	})
+	return entries
+}
+
+// 移除元素
+func (c *Cache[K, V]) Remove(key K) bool {
+	if elem, ok := c.entries[key]; ok {
+		c.removeElement(elem)
+		return true
+	}
+	return false
+}
+
+// 移除已经过期的元素
+func (c *Cache[K, V]) RemoveExpired(key K) bool {
+	if elem, ok := c.entries[key]; ok && elem.Value.entry.Expired() {
+		c.expireElement(elem)
+		return true
+	}
+	return false
+}
+
+// 淘汰元素
+// 小队列超过容量时淘汰小队列，否则淘汰主队列
+func (c *Cache[K, V]) Evict() *cache.Entry[K, V] {
+	for c.Len() > 0 {
+		var elem *list.Element[*frequencyEntry[K, V]]
+		if c.smallCost >= int64(c.smallCap) || c.main.Len() == 0 {
+			elem = c.evictSmall()
+		} else {
+			elem = c.evictMain()
+		}
+		if elem == nil {
+			continue
+		}
+		entry := elem.Value.entry
+		// 回调
+		reason := cache.EvictReasonCapacity
+		if entry.Expired() {
+			reason = cache.EvictReasonExpired
+		}
+		c.doOnEvict(entry, reason)
+		return entry
+	}
+	return nil
+}
+
+// 获取可能被淘汰的元素
+func (c *Cache[K, V]) Victim() *cache.Entry[K, V] {
+	if c.Len() == 0 {
+		return nil
+	}
+	if c.smallCost >= int64(c.smallCap) || c.main.Len() == 0 {
+		// 访问过多次的元素会进入主队列
+		for elem := c.small.Back(); elem != nil; elem = elem.Prev() {
+			if elem.Value.frequency <= 1 || elem.Value.entry.Expired() {
+				return elem.Value.entry
+			}
+		}
+	}
+	// 主队列频率最低的元素会最先被淘汰，频率相同时越靠近尾部越先被淘汰
+	var victim *frequencyEntry[K, V]
+	for elem := c.main.Back(); elem != nil; elem = elem.Prev() {
+		if elem.Value.entry.Expired() {
+			return elem.Value.entry
+		}
+		if victim == nil || elem.Value.frequency < victim.frequency {
+			victim = elem.Value
+		}
+	}
+	if victim == nil {
+		return c.small.Back().Value.entry
+	}
+	return victim.entry
+}
+
+// 清空缓存
+func (c *Cache[K, V]) Clear(needOnEvict bool) {
+	// 触发回调
+	if needOnEvict {
+		c.each(func(entry *frequencyEntry[K, V]) {
+			c.doOnEvict(entry.entry, cache.EvictReasonClear)
+		})
+	}
+
+	// 清空
+	c.entries = make(map[K]*list.Element[*frequencyEntry[K, V]])
+	c.small.Clear()
+	c.main.Clear()
+	c.ghosts = make(map[K]*list.Element[K])
+	c.ghostList.Clear()
+	c.smallCost = 0
+	c.cost = 0
+}
+
+// 改变容量
+func (c *Cache[K, V]) Resize(capacity int, needOnEvict bool) {
+	c.capacity = capacity
+	c.smallCap = smallCap(capacity)
+	c.evictToFit(0)
+	for c.ghostList.Len() > c.capacity {
+		c.removeGhost(c.ghostList.Back())
+	}
+}
+
+// 元素个数
+func (c *Cache[K, V]) Len() int {
+	return len(c.entries)
+}
+
+// 当前总权重，没有设置权重计算函数时等于元素个数
+func (c *Cache[K, V]) Cost() int64 {
+	return c.cost
+}
+
+// 容量，设置了权重计算函数时表示总权重
+func (c *Cache[K, V]) Cap() int {
+	return c.capacity
+}
+
+// 缓存满了
+func (c *Cache[K, V]) Full() bool {
+	return c.Cost() >= int64(c.Cap())
+}
+
+// 设置保存快照时使用的编解码器，为空使用GobCodec
+func (c *Cache[K, V]) SetCodec(keyCodec cache.Codec[K], valueCodec cache.Codec[V]) {
+	c.keyCodec = keyCodec
+	c.valueCodec = valueCodec
+}
+
+// 保存快照到w，包括元素所在的队列、队列内的顺序、访问频率和幽灵队列
+func (c *Cache[K, V]) Save(w io.Writer) error {
+	return c.Snapshot().Encode(w, c.keyCodec, c.valueCodec)
+}
+
+// 从r加载快照，会先清空缓存，不触发回调
+// 快照格式不正确时缓存保持不变
+func (c *Cache[K, V]) Load(r io.Reader) error {
+	snapshot, err := cache.DecodeSnapshot(r, snapshotPolicy, c.keyCodec, c.valueCodec)
+	if err != nil {
+		return err
+	}
+	return c.Restore(snapshot)
+}
+
+// 获取快照，依次是幽灵队列、小队列、主队列，队列内按插入顺序排列
+// 幽灵队列只有Key，Value是零值
+func (c *Cache[K, V]) Snapshot() *cache.Snapshot[K, V] {
+	snapshot := &cache.Snapshot[K, V]{
+		Policy:  snapshotPolicy,
+		Records: make([]*cache.SnapshotRecord[K, V], 0, c.ghostList.Len()+c.Len()),
+	}
+	for elem := c.ghostList.Back(); elem != nil; elem = elem.Prev() {
+		snapshot.Records = append(snapshot.Records, &cache.SnapshotRecord[K, V]{
+			Entry:   &cache.Entry[K, V]{Key: elem.Value},
+			Segment: segmentGhost,
+		})
+	}
+	c.each(func(entry *frequencyEntry[K, V]) {
+		e := *entry.entry
+		record := &cache.SnapshotRecord[K, V]{
+			Entry:     &e,
+			Segment:   segmentMain,
+			Frequency: uint64(entry.frequency),
+		}
+		if entry.small {
+			record.Segment = segmentSmall
+		}
+		snapshot.Records = append(snapshot.Records, record)
+	})
+	return snapshot
+}
+
+// 从快照恢复，会先清空缓存，不触发回调
+// 跳过已经过期的元素，放不下的元素按淘汰顺序淘汰
+func (c *Cache[K, V]) Restore(snapshot *cache.Snapshot[K, V]) error {
+	if snapshot.Policy != snapshotPolicy {
+		return cache.ErrSnapshotPolicyMismatch
+	}
+	c.Clear(false)
+	for _, record := range snapshot.Records {
+		entry := *record.Entry
+		if elem, ok := c.entries[entry.Key]; ok {
+			c.removeElement(elem)
+		}
+		if ghost, ok := c.ghosts[entry.Key]; ok {
+			c.removeGhost(ghost)
+		}
+		if record.Segment == segmentGhost {
+			c.addGhost(entry.Key)
+			continue
+		}
+		if entry.Expired() {
+			continue
+		}
+		// 快照最先插入的在前面，所以依次放到最前面
+		fe := &frequencyEntry[K, V]{
+			entry:     &entry,
+			frequency: uint8(math.Min(record.Frequency, maxFrequency)),
+		}
+		weight := c.weigh(entry.Key, entry.Value)
+		if record.Segment == segmentSmall {
+			fe.small = true
+			c.entries[entry.Key] = c.small.PushFront(fe)
+			c.smallCost += weight
+		} else {
+			c.entries[entry.Key] = c.main.PushFront(fe)
+		}
+		c.cost += weight
+		c.schedule(entry.Key, entry.Expiration)
+	}
+	c.evictToFit(0)
+	return nil
+}
+
+// 淘汰小队列的尾部元素
+// 访问过多次的元素移动到主队列，返回空；否则淘汰它并把Key放入幽灵队列
+func (c *Cache[K, V]) evictSmall() *list.Element[*frequencyEntry[K, V]] {
+	elem := c.small.Back()
+	entry := elem.Value
+	if entry.frequency > 1 && !entry.entry.Expired() {
+		c.small.Remove(elem)
+		c.smallCost -= c.weigh(entry.entry.Key, entry.entry.Value)
+		entry.small = false
+		entry.frequency = 0
+		c.entries[entry.entry.Key] = c.main.PushFront(entry)
+		return nil
+	}
+	c.removeElement(elem)
+	c.addGhost(entry.entry.Key)
+	return elem
+}
+
+// 淘汰主队列的尾部元素
+// 访问过的元素重新插入主队列并且频率减一，返回空；否则淘汰它
+func (c *Cache[K, V]) evictMain() *list.Element[*frequencyEntry[K, V]] {
+	elem := c.main.Back()
+	if elem.Value.frequency > 0 && !elem.Value.entry.Expired() {
+		elem.Value.frequency--
+		c.main.MoveToFront(elem)
+		return nil
+	}
+	c.removeElement(elem)
+	return elem
+}
+
+// 按小队列、主队列的顺序遍历元素，队列内从尾部到头部
+func (c *Cache[K, V]) each(f func(entry *frequencyEntry[K, V])) {
+	for elem := c.small.Back(); elem != nil; elem = elem.Prev() {
+		f(elem.Value)
+	}
+	for elem := c.main.Back(); elem != nil; elem = elem.Prev() {
+		f(elem.Value)
+	}
+}
+
+// 增加访问频率
+func (c *Cache[K, V]) inc(entry *frequencyEntry[K, V]) {
+	if entry.frequency < maxFrequency {
+		entry.frequency++
+	}
+}
+
+// 添加到幽灵队列，幽灵队列的Key个数不超过容量
+func (c *Cache[K, V]) addGhost(key K) {
+	c.ghosts[key] = c.ghostList.PushFront(key)
+	for c.ghostList.Len() > c.capacity {
+		c.removeGhost(c.ghostList.Back())
+	}
+}
+
+// 从幽灵队列移除
+func (c *Cache[K, V]) removeGhost(elem *list.Element[K]) {
+	c.ghostList.Remove(elem)
+	delete(c.ghosts, elem.Value)
+}
+
+// 移除给定节点
+func (c *Cache[K, V]) removeElement(elem *list.Element[*frequencyEntry[K, V]]) {
+	entry := elem.Value
+	weight := c.weigh(entry.entry.Key, entry.entry.Value)
+	if entry.small {
+		c.small.Remove(elem)
+		c.smallCost -= weight
+	} else {
+		c.main.Remove(elem)
+	}
+	delete(c.entries, entry.entry.Key)
+	c.cost -= weight
+}
+
+// 淘汰元素直到能放下给定权重的元素
+// 返回最后一个被淘汰的元素
+func (c *Cache[K, V]) evictToFit(weight int64) *cache.Entry[K, V] {
+	var evicted *cache.Entry[K, V]
+	for c.Len() > 0 && c.cost+weight > int64(c.capacity) {
+		evicted = c.Evict()
+	}
+	return evicted
+}
+
+// 计算元素权重
+func (c *Cache[K, V]) weigh(key K, value V) int64 {
+	if c.weigher == nil {
+		return 1
+	}
+	return c.weigher(key, value)
+}
+
+// 删除过期节点
+func (c *Cache[K, V]) expireElement(elem *list.Element[*frequencyEntry[K, V]]) {
+	c.removeElement(elem)
+	c.doOnEvict(elem.Value.entry, cache.EvictReasonExpired)
+}
+
+// 添加到过期清理器
+func (c *Cache[K, V]) schedule(key K, expiration time.Time) {
+	if c.janitor != nil {
+		c.janitor.Push(key, expiration)
+	}
+}
+
+// 触发淘汰回调
+func (c *Cache[K, V]) doOnEvict(entry *cache.Entry[K, V], reason cache.EvictReason) {
+	c.stats.RecordEviction(reason)
+	if c.onEvict != nil {
+		c.onEvict(entry, reason)
+	}
+}
+
+// 计算小队列容量
+func smallCap(capacity int) int {
+	return math.Max(int(smallPercentage*float64(capacity)), 1)
+}
@@ -0,0 +1,272 @@
+package s3fifo
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/cache/cachetest"
+)
+
+func TestCache_Put(t *testing.T) {
+	c := New[string, int](10)
+	for i := 0; i < 10; i++ {
+		c.Put(strconv.Itoa(i), i)
+	}
+	c.Get("0")
+	c.Get("0")
+	c.Get("1")
+
+	// 小队列只容纳1个元素，0访问过多次进入主队列，1只访问过一次被淘汰
+	evicted := c.Put("10", 10)
+	if evicted == nil || evicted.Key != "1" {
+		t.Errorf("Put() = %v, want %v", evicted, "1")
+	}
+	value, ok := c.Get("0")
+	if value != 0 || !ok {
+		t.Errorf("Get() = %v, want %v", ok, true)
+	}
+}
+
+func TestCache_Ghost(t *testing.T) {
+	c := New[string, int](10)
+	for i := 0; i < 11; i++ {
+		c.Put(strconv.Itoa(i), i)
+	}
+	if c.Contains("0") {
+		t.Errorf("Contains() = %v, want %v", true, false)
+	}
+
+	// 最近被淘汰过的元素直接进入主队列
+	c.Put("0", 0)
+	if !reflect.DeepEqual(c.Keys()[c.Len()-1], "0") {
+		t.Errorf("Keys() = %v, want %v at the end", c.Keys(), "0")
+	}
+	for i := 11; i < 20; i++ {
+		c.Put(strconv.Itoa(i), i)
+	}
+	if !c.Contains("0") {
+		t.Errorf("Contains() = %v, want %v", false, true)
+	}
+}
+
+func TestCache_OnEvict(t *testing.T) {
+	c := New[string, int](3)
+	c.SetOnEvict(func(entry *cache.Entry[string, int]) {
+		if entry.Key != "11" || entry.Value != 5 {
+			t.Errorf("OnEvict() = %v, want %v", entry.Key, "11")
+		}
+	})
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Put("44", 8)
+
+	value, ok := c.Get("11")
+	if value != 0 || ok {
+		t.Errorf("Get() = %v, want %v", ok, false)
+	}
+}
+
+func TestCache_Evict(t *testing.T) {
+	c := New[string, int](10)
+	for i := 0; i < 10; i++ {
+		c.Put(strconv.Itoa(i), i)
+	}
+	for i := 0; i < 10; i++ {
+		c.Get(strconv.Itoa(i))
+		c.Get(strconv.Itoa(i))
+	}
+	// 全部进入主队列，然后淘汰0，之后新元素都在小队列被淘汰
+	for i := 0; i < 10; i++ {
+		c.Evict()
+		c.Put(strconv.Itoa(i+10), i+10)
+	}
+
+	// 小队列超过容量，淘汰小队列中没有访问过的元素
+	if victim := c.Victim(); victim.Key != "19" {
+		t.Errorf("Victim() = %v, want %v", victim.Key, "19")
+	}
+	if evicted := c.Evict(); evicted.Key != "19" {
+		t.Errorf("Evict() = %v, want %v", evicted.Key, "19")
+	}
+
+	// 小队列为空，淘汰主队列，1访问过重新插入，淘汰2
+	c.Get("1")
+	if victim := c.Victim(); victim.Key != "2" {
+		t.Errorf("Victim() = %v, want %v", victim.Key, "2")
+	}
+	if evicted := c.Evict(); evicted.Key != "2" {
+		t.Errorf("Evict() = %v, want %v", evicted.Key, "2")
+	}
+	if keys := c.Keys(); keys[len(keys)-1] != "1" {
+		t.Errorf("Keys() = %v, want %v at the end", keys, "1")
+	}
+}
+
+func TestCache_Peek(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Peek("11")
+	c.Peek("11")
+	c.Put("44", 8)
+
+	value, ok := c.Get("11")
+	if value != 0 || ok {
+		t.Errorf("Get() = %v, want %v", ok, false)
+	}
+}
+
+func TestCache_Remove(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Remove("22")
+	c.Put("44", 8)
+
+	value, ok := c.Get("22")
+	if value != 0 || ok {
+		t.Errorf("Get() = %v, want %v", value, 0)
+	}
+	if c.Len() != 3 {
+		t.Errorf("Len() = %v, want %v", c.Len(), 3)
+	}
+}
+
+func TestCache_Resize(t *testing.T) {
+	c := New[string, int](10)
+	for i := 0; i < 10; i++ {
+		c.Put(strconv.Itoa(i), i)
+	}
+	c.Get("9")
+	c.Get("9")
+	c.Resize(1, true)
+
+	if !reflect.DeepEqual(c.Keys(), []string{"9"}) {
+		t.Errorf("Keys() = %v, want %v", c.Keys(), []string{"9"})
+	}
+}
+
+func TestCache_PutWithTTL(t *testing.T) {
+	c := New[string, int](3)
+	evicted := map[string]cache.EvictReason{}
+	c.SetOnEvictWithReason(func(entry *cache.Entry[string, int], reason cache.EvictReason) {
+		evicted[entry.Key] = reason
+	})
+	c.PutWithTTL("11", 5, time.Millisecond*10)
+	c.Put("22", 6)
+	c.Get("11")
+	c.Get("11")
+	time.Sleep(time.Millisecond * 20)
+
+	// 过期的元素即使访问过也会被淘汰
+	c.Put("33", 7)
+	c.Put("44", 8)
+	if reason, ok := evicted["11"]; !ok || reason != cache.EvictReasonExpired {
+		t.Errorf("OnEvict() = %v, want %v", reason, cache.EvictReasonExpired)
+	}
+	value, ok := c.Get("22")
+	if value != 6 || !ok {
+		t.Errorf("Get() = %v, want %v", ok, true)
+	}
+}
+
+func TestCache_SetWeigher(t *testing.T) {
+	c := New[string, []byte](10)
+	c.SetWeigher(func(key string, value []byte) int64 {
+		return int64(len(value))
+	})
+	c.Put("11", make([]byte, 4))
+	c.Put("22", make([]byte, 4))
+	c.Put("33", make([]byte, 6))
+	if c.Cost() > int64(c.Cap()) {
+		t.Errorf("Cost() = %v, want <= %v", c.Cost(), c.Cap())
+	}
+
+	// 超过容量直接拒绝
+	rejected := c.Put("44", make([]byte, 11))
+	if rejected == nil || rejected.Key != "44" || c.Contains("44") {
+		t.Errorf("Put() = %v, want %v", rejected, "44")
+	}
+}
+
+func TestCache_Save(t *testing.T) {
+	c := New[string, int](10)
+	for i := 0; i < 15; i++ {
+		c.Put(strconv.Itoa(i), i)
+		c.Get(strconv.Itoa(i % 3))
+	}
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// 恢复后队列、访问频率和幽灵队列不变
+	c2 := New[string, int](10)
+	if err := c2.Load(&buf); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(c2.Keys(), c.Keys()) {
+		t.Errorf("Keys() = %v, want %v", c2.Keys(), c.Keys())
+	}
+	if !reflect.DeepEqual(c2.Snapshot(), c.Snapshot()) {
+		t.Errorf("Snapshot() = %v, want %v", c2.Snapshot(), c.Snapshot())
+	}
+}
+
+func TestCache_Conformance(t *testing.T) {
+	cachetest.Run(t, func(capacity int) cache.Policy[string, int] {
+		return New[string, int](capacity)
+	}, cachetest.Options{EvictionOrder: true})
+}
+
+// s3fifo_test.go:255: cachePercentage=0.1%, count=206048, hitCount=30393, hitRate=14.75%
+// s3fifo_test.go:255: cachePercentage=0.3%, count=206048, hitCount=67544, hitRate=32.78%
+// s3fifo_test.go:255: cachePercentage=0.5%, count=206048, hitCount=100287, hitRate=48.67%
+// s3fifo_test.go:255: cachePercentage=0.7%, count=206048, hitCount=130371, hitRate=63.27%
+// s3fifo_test.go:255: cachePercentage=1.0%, count=206048, hitCount=165172, hitRate=80.16%
+// s3fifo_test.go:255: cachePercentage=2.0%, count=206048, hitCount=189100, hitRate=91.77%
+// s3fifo_test.go:255: cachePercentage=3.0%, count=206048, hitCount=190871, hitRate=92.63%
+// s3fifo_test.go:255: cachePercentage=5.0%, count=206048, hitCount=192527, hitRate=93.44%
+// s3fifo_test.go:255: cachePercentage=10.0%, count=206048, hitCount=192842, hitRate=93.59%
+func TestHitRate(t *testing.T) {
+	dataset, err := os.ReadFile("../dataset")
+	if err != nil {
+		t.Errorf("read dataset error %v", err)
+	}
+	reqs := strings.Split(string(dataset), ",")
+	testHitRate(t, reqs, 0.001)
+	testHitRate(t, reqs, 0.003)
+	testHitRate(t, reqs, 0.005)
+	testHitRate(t, reqs, 0.007)
+	testHitRate(t, reqs, 0.01)
+	testHitRate(t, reqs, 0.02)
+	testHitRate(t, reqs, 0.03)
+	testHitRate(t, reqs, 0.05)
+	testHitRate(t, reqs, 0.1)
+}
+
+func testHitRate(t *testing.T, reqs []string, cachePercentage float64) {
+	count := len(reqs)
+	n := int(float64(count) * cachePercentage)
+	c := New[string, int](n)
+	hitCount := 0
+	for _, req := range reqs {
+		_, exists := c.Get(req)
+		if exists {
+			hitCount++
+		} else {
+			c.Put(req, 0)
+		}
+	}
+	hitRate := float64(hitCount) / float64(count)
+	t.Logf("cachePercentage=%.1f%%, count=%v, hitCount=%v, hitRate=%.2f%%", cachePercentage*100, count, hitCount, hitRate*100)
+}
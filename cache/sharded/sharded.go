@@ -0,0 +1,200 @@
+package sharded
+
+import (
+	"sync"
+
+	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/math"
+)
+
+// 创建分片的缓存策略，capacity是分片的容量
+type NewFunc[K comparable, V any] func(capacity int) cache.Policy[K, V]
+
+// 计算Key的哈希值，用于选择分片
+type HashFunc[K comparable] func(key K) uint64
+
+// 分片
+type shard[K comparable, V any] struct {
+	policy cache.Policy[K, V]
+	mutex  sync.Mutex
+}
+
+// 分片缓存
+// 把Key哈希到多个分片，每个分片有独立的锁，减少锁竞争
+// 线程安全
+type Cache[K comparable, V any] struct {
+	shards     []*shard[K, V]
+	mask       uint64
+	hashFunc   HashFunc[K]
+	onEvict    cache.OnEvict[K, V]
+	stats      *cache.StatsCounter
+	statsMutex sync.RWMutex // 保护stats
+}
+
+// shards：分片数量，会向上取整到2的幂
+// capacity：总容量，会平均分到每个分片，除不尽的部分分给前面的分片
+func New[K comparable, V any](newFunc NewFunc[K, V], hashFunc HashFunc[K], capacity, shards int) *Cache[K, V] {
+	if shards < 1 {
+		panic("too small shards")
+	}
+	shardCnt := math.RoundUpPowOf2(uint(shards))
+	if capacity < int(shardCnt) {
+		panic("too small capacity")
+	}
+	shardCap, remainder := capacity/int(shardCnt), capacity%int(shardCnt)
+	c := &Cache[K, V]{
+		shards:   make([]*shard[K, V], shardCnt),
+		mask:     uint64(shardCnt - 1),
+		hashFunc: hashFunc,
+	}
+	for i := range c.shards {
+		capacity := shardCap
+		if i < remainder {
+			capacity++
+		}
+		s := &shard[K, V]{
+			policy: newFunc(capacity),
+		}
+		// 回调在分片锁内执行，可以从任意分片触发
+		s.policy.SetOnEvict(c.doOnEvict)
+		c.shards[i] = s
+	}
+	return c
+}
+
+// 设置 OnEvict
+// 回调会在对应分片的锁内执行，不能在回调里面访问同一个缓存
+func (c *Cache[K, V]) SetOnEvict(onEvict cache.OnEvict[K, V]) {
+	// 先锁住所有分片，避免和正在淘汰的分片并发读写
+	for _, s := range c.shards {
+		s.mutex.Lock()
+	}
+	c.onEvict = onEvict
+	for _, s := range c.shards {
+		s.mutex.Unlock()
+	}
+}
+
+// 设置统计计数器，所有分片共享同一个计数器，为空表示不统计
+// 缓存策略需要实现 SetStatsCounter(stats *cache.StatsCounter)
+func (c *Cache[K, V]) SetStatsCounter(stats *cache.StatsCounter) {
+	for _, s := range c.shards {
+		s.mutex.Lock()
+		if p, ok := s.policy.(interface {
+			SetStatsCounter(stats *cache.StatsCounter)
+		}); ok {
+			p.SetStatsCounter(stats)
+		}
+		s.mutex.Unlock()
+	}
+	c.statsMutex.Lock()
+	c.stats = stats
+	c.statsMutex.Unlock()
+}
+
+// 获取统计信息
+func (c *Cache[K, V]) Stats() cache.Stats {
+	c.statsMutex.RLock()
+	stats := c.stats
+	c.statsMutex.RUnlock()
+	return stats.Snapshot()
+}
+
+// 添加或更新元素
+// 返回被淘汰的元素
+func (c *Cache[K, V]) Put(key K, value V) *cache.Entry[K, V] {
+	s := c.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.policy.Put(key, value)
+}
+
+// 获取元素
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	s := c.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.policy.Get(key)
+}
+
+// 获取元素，不更新状态
+func (c *Cache[K, V]) Peek(key K) (V, bool) {
+	s := c.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.policy.Peek(key)
+}
+
+// 是否包含元素，不更新状态
+func (c *Cache[K, V]) Contains(key K) bool {
+	_, ok := c.Peek(key)
+	return ok
+}
+
+// 移除元素
+func (c *Cache[K, V]) Remove(key K) bool {
+	s := c.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.policy.Remove(key)
+}
+
+// 获取缓存的Keys
+// 按分片顺序拼接，分片内的顺序由缓存策略决定
+func (c *Cache[K, V]) Keys() []K {
+	var keys []K
+	for _, s := range c.shards {
+		s.mutex.Lock()
+		keys = append(keys, s.policy.Keys()...)
+		s.mutex.Unlock()
+	}
+	return keys
+}
+
+// 元素个数
+func (c *Cache[K, V]) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		s.mutex.Lock()
+		n += s.policy.Len()
+		s.mutex.Unlock()
+	}
+	return n
+}
+
+// 容量，所有分片的容量之和
+func (c *Cache[K, V]) Cap() int {
+	n := 0
+	for _, s := range c.shards {
+		s.mutex.Lock()
+		n += s.policy.Cap()
+		s.mutex.Unlock()
+	}
+	return n
+}
+
+// 清空缓存
+func (c *Cache[K, V]) Clear(needOnEvict bool) {
+	for _, s := range c.shards {
+		s.mutex.Lock()
+		s.policy.Clear(needOnEvict)
+		s.mutex.Unlock()
+	}
+}
+
+// 分片数量
+func (c *Cache[K, V]) Shards() int {
+	return len(c.shards)
+}
+
+// 获取Key所在的分片
+func (c *Cache[K, V]) shard(key K) *shard[K, V] {
+	return c.shards[c.hashFunc(key)&c.mask]
+}
+
+// 触发淘汰回调
+func (c *Cache[K, V]) doOnEvict(entry *cache.Entry[K, V]) {
+	if c.onEvict != nil {
+		c.onEvict(entry)
+	}
+}
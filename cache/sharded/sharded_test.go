@@ -0,0 +1,155 @@
+package sharded
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/cache/cachetest"
+	"github.com/jiaxwu/gommon/cache/lru"
+	"github.com/jiaxwu/gommon/cache/tinylfu"
+	"github.com/jiaxwu/gommon/hash"
+)
+
+func newLRU(capacity int) cache.Policy[string, int] {
+	return lru.New[string, int](capacity)
+}
+
+func newTinyLFU(capacity int) cache.Policy[string, int] {
+	return tinylfu.New[string, int](func(key string) []byte {
+		return []byte(key)
+	}, capacity)
+}
+
+func hashFunc() HashFunc[string] {
+	h := hash.New()
+	var mutex sync.Mutex
+	return func(key string) uint64 {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return h.Sum64String(key)
+	}
+}
+
+func TestCache_Put(t *testing.T) {
+	c := New(newLRU, hashFunc(), 8, 3)
+	if c.Shards() != 4 {
+		t.Errorf("Shards() = %v, want %v", c.Shards(), 4)
+	}
+	c.Put("11", 5)
+	c.Put("22", 6)
+	value, ok := c.Get("11")
+	if value != 5 || !ok {
+		t.Errorf("Get() = %v, want %v", value, 5)
+	}
+	value, ok = c.Peek("22")
+	if value != 6 || !ok {
+		t.Errorf("Peek() = %v, want %v", value, 6)
+	}
+	if !c.Remove("22") || c.Contains("22") {
+		t.Errorf("Remove() = %v, want %v", false, true)
+	}
+	if c.Len() != 1 {
+		t.Errorf("Len() = %v, want %v", c.Len(), 1)
+	}
+}
+
+func TestCache_Cap(t *testing.T) {
+	// 除不尽的部分分给前面的分片，总容量不变
+	c := New(newLRU, hashFunc(), 10, 4)
+	if c.Cap() != 10 {
+		t.Errorf("Cap() = %v, want %v", c.Cap(), 10)
+	}
+	for i, want := range []int{3, 3, 2, 2} {
+		if got := c.shards[i].policy.Cap(); got != want {
+			t.Errorf("shards[%v].Cap() = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestCache_OnEvict(t *testing.T) {
+	c := New(newLRU, hashFunc(), 4, 4)
+	evicted := map[string]bool{}
+	c.SetOnEvict(func(entry *cache.Entry[string, int]) {
+		evicted[entry.Key] = true
+	})
+	for i := 0; i < 100; i++ {
+		c.Put(strconv.Itoa(i), i)
+	}
+	if c.Len()+len(evicted) != 100 {
+		t.Errorf("Len() + evicted = %v, want %v", c.Len()+len(evicted), 100)
+	}
+	for _, key := range c.Keys() {
+		if evicted[key] {
+			t.Errorf("Keys() contains evicted key %v", key)
+		}
+	}
+}
+
+func TestCache_Concurrent(t *testing.T) {
+	for _, newFunc := range []NewFunc[string, int]{newLRU, newTinyLFU} {
+		c := New(newFunc, hashFunc(), 1024, 16)
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 10000; j++ {
+					key := strconv.Itoa((i*j + j) % 2048)
+					if _, ok := c.Get(key); !ok {
+						c.Put(key, j)
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+		if c.Len() > 1024 {
+			t.Errorf("Len() = %v, want <= %v", c.Len(), 1024)
+		}
+	}
+}
+
+func TestCache_SetStatsCounter(t *testing.T) {
+	c := New(newLRU, hashFunc(), 4, 4)
+	c.SetStatsCounter(cache.NewStatsCounter())
+	for i := 0; i < 100; i++ {
+		c.Put(strconv.Itoa(i), i)
+	}
+	for i := 0; i < 100; i++ {
+		c.Get(strconv.Itoa(i))
+	}
+
+	// 所有分片共享同一个计数器
+	stats := c.Stats()
+	if stats.Puts != 100 || stats.Requests() != 100 {
+		t.Errorf("Puts = %v, Requests() = %v, want %v, %v", stats.Puts, stats.Requests(), 100, 100)
+	}
+	if stats.Hits != uint64(c.Len()) || stats.EvictionCount() != uint64(100-c.Len()) {
+		t.Errorf("Hits = %v, Evictions = %v, want %v, %v", stats.Hits, stats.EvictionCount(), c.Len(), 100-c.Len())
+	}
+}
+
+func TestCache_SetStatsCounterConcurrent(t *testing.T) {
+	c := New(newLRU, hashFunc(), 4, 4)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			c.SetStatsCounter(cache.NewStatsCounter())
+		}
+	}()
+	// 运行时切换计数器不会和读取统计信息竞争
+	for i := 0; i < 1000; i++ {
+		c.Put(strconv.Itoa(i), i)
+		c.Stats()
+	}
+	wg.Wait()
+}
+
+func TestCache_Conformance(t *testing.T) {
+	cachetest.Run(t, func(capacity int) cache.Policy[string, int] {
+		return New(newLRU, hashFunc(), capacity, 2)
+	}, cachetest.Options{})
+}
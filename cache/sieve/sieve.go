@@ -0,0 +1,473 @@
+package sieve
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/container/list"
+)
+
+// 快照中的缓存策略名称
+const snapshotPolicy = "sieve"
+
+type visitedEntry[K comparable, V any] struct {
+	entry   *cache.Entry[K, V]
+	visited bool // 上次指针经过之后是否被访问过
+}
+
+// SIEVE
+// 新元素放到队列头部，访问时只标记visited，不移动元素
+// 淘汰时指针从尾部往头部移动，跳过并清除visited的元素，淘汰第一个没有visited的元素
+// 优点：命中时不需要调整队列，实现简单，命中率比LRU高
+// 非线程安全，请根据业务加锁
+// https://junchengyang.com/publication/nsdi24-SIEVE.pdf
+type Cache[K comparable, V any] struct {
+	entries    map[K]*list.Element[*visitedEntry[K, V]]
+	evictList  *list.List[*visitedEntry[K, V]]
+	hand       *list.Element[*visitedEntry[K, V]] // 淘汰指针，为空表示从尾部开始
+	capacity   int
+	cost       int64               // 当前总权重
+	weigher    cache.Weigher[K, V] // 计算元素权重，为空则每个元素权重为1
+	ttl        time.Duration       // 默认过期时间
+	onEvict    cache.OnEvictWithReason[K, V]
+	janitor    *cache.Janitor[K]
+	stats      *cache.StatsCounter // 统计，为空表示不统计
+	keyCodec   cache.Codec[K]      // 保存快照时Key的编解码器
+	valueCodec cache.Codec[V]      // 保存快照时Value的编解码器
+}
+
+func New[K comparable, V any](capacity int) *Cache[K, V] {
+	if capacity < 1 {
+		panic("too small capacity")
+	}
+	return &Cache[K, V]{
+		entries:   make(map[K]*list.Element[*visitedEntry[K, V]]),
+		evictList: list.New[*visitedEntry[K, V]](),
+		capacity:  capacity,
+	}
+}
+
+// 设置 OnEvict
+func (c *Cache[K, V]) SetOnEvict(onEvict cache.OnEvict[K, V]) {
+	c.onEvict = onEvict.WithReason()
+}
+
+// 设置 OnEvict，带上淘汰原因
+func (c *Cache[K, V]) SetOnEvictWithReason(onEvict cache.OnEvictWithReason[K, V]) {
+	c.onEvict = onEvict
+}
+
+// 设置权重计算函数，设置后容量表示总权重，而不是元素个数
+func (c *Cache[K, V]) SetWeigher(weigher cache.Weigher[K, V]) {
+	c.weigher = weigher
+	// 重新计算总权重
+	c.cost = 0
+	for elem := c.evictList.Back(); elem != nil; elem = elem.Prev() {
+		c.cost += c.weigh(elem.Value.entry.Key, elem.Value.entry.Value)
+	}
+	c.evictToFit(0)
+}
+
+// 设置统计计数器，为空表示不统计
+func (c *Cache[K, V]) SetStatsCounter(stats *cache.StatsCounter) {
+	c.stats = stats
+}
+
+// 获取统计信息
+func (c *Cache[K, V]) Stats() cache.Stats {
+	return c.stats.Snapshot()
+}
+
+// 设置默认过期时间，Put()会使用该过期时间，小于等于0表示永不过期
+func (c *Cache[K, V]) SetDefaultTTL(ttl time.Duration) {
+	c.ttl = ttl
+}
+
+// 启动过期清理器，到期主动删除元素，直到ctx被关闭
+// locker是业务访问缓存时使用的锁
+// 只会清理启动之后添加的元素，之前添加的元素依然在访问时删除
+func (c *Cache[K, V]) StartJanitor(ctx context.Context, locker sync.Locker) {
+	c.janitor = cache.NewJanitor(locker, c.RemoveExpired)
+	go c.janitor.Run(ctx)
+}
+
+// 添加或更新元素
+// 返回被淘汰的元素
+func (c *Cache[K, V]) Put(key K, value V) *cache.Entry[K, V] {
+	return c.PutWithTTL(key, value, c.ttl)
+}
+
+// 添加或更新元素，并设置过期时间，小于等于0表示永不过期
+// 返回被淘汰的元素
+func (c *Cache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) *cache.Entry[K, V] {
+	return c.PutWithExpiration(key, value, cache.Expiration(ttl))
+}
+
+// 添加或更新元素，并设置过期时刻，零值表示永不过期
+// 返回被淘汰的元素，如果淘汰了多个元素，返回最后一个
+// 如果元素权重超过容量，则不会添加，直接返回该元素
+func (c *Cache[K, V]) PutWithExpiration(key K, value V, expiration time.Time) *cache.Entry[K, V] {
+	weight := c.weigh(key, value)
+	if weight > int64(c.capacity) {
+		c.Remove(key)
+		return &cache.Entry[K, V]{
+			Key:        key,
+			Value:      value,
+			Expiration: expiration,
+		}
+	}
+
+	// 如果 key 已经存在，标记为访问过，然后设置新值
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.visited = true
+		c.cost += weight - c.weigh(key, elem.Value.entry.Value)
+		elem.Value.entry.Value = value
+		elem.Value.entry.Expiration = expiration
+		c.schedule(key, expiration)
+		c.stats.RecordUpdate()
+		// 权重变大可能需要淘汰元素，它自己被标记为访问过，不会第一个被淘汰
+		return c.evictToFit(0)
+	}
+
+	// 如果放不下，先剔除元素
+	evicted := c.evictToFit(weight)
+
+	// 添加元素到头部
+	elem := c.evictList.PushFront(&visitedEntry[K, V]{
+		entry: &cache.Entry[K, V]{
+			Key:        key,
+			Value:      value,
+			Expiration: expiration,
+		},
+	})
+	c.entries[key] = elem
+	c.cost += weight
+	c.schedule(key, expiration)
+	c.stats.RecordPut()
+	return evicted
+}
+
+// 获取元素
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	// 如果存在标记为访问过，然后返回
+	if elem, ok := c.entries[key]; ok {
+		// 过期了直接删除
+		if elem.Value.entry.Expired() {
+			c.expireElement(elem)
+			c.stats.RecordMiss()
+			var value V
+			return value, false
+		}
+		elem.Value.visited = true
+		c.stats.RecordHit()
+		return elem.Value.entry.Value, true
+	}
+
+	// 不存在返回空值和false
+	c.stats.RecordMiss()
+	var value V
+	return value, false
+}
+
+// 获取元素，不更新状态
+func (c *Cache[K, V]) Peek(key K) (V, bool) {
+	// 如果存在
+	if entry, ok := c.PeekEntry(key); ok {
+		return entry.Value, true
+	}
+
+	// 不存在返回空值和false
+	var value V
+	return value, false
+}
+
+// 获取缓存项，不更新状态
+func (c *Cache[K, V]) PeekEntry(key K) (*cache.Entry[K, V], bool) {
+	// 如果存在并且没有过期
+	if elem, ok := c.entries[key]; ok && !elem.Value.entry.Expired() {
+		return elem.Value.entry, true
+	}
+	return nil, false
+}
+
+// 是否包含元素，不更新状态
+func (c *Cache[K, V]) Contains(key K) bool {
+	_, ok := c.PeekEntry(key)
+	return ok
+}
+
+// 获取缓存的Keys，按插入顺序排列
+// 可能包含已经过期但还没被删除的元素
+func (c *Cache[K, V]) Keys() []K {
+	keys := make([]K, c.Len())
+	for elem, i := c.evictList.Back(), 0; elem != nil; elem, i = elem.Prev(), i+1 {
+		keys[i] = elem.Value.entry.Key
+	}
+	return keys
+}
+
+// 获取缓存的Values
+func (c *Cache[K, V]) Values() []V {
+	values := make([]V, c.Len())
+	for elem, i := c.evictList.Back(), 0; elem != nil; elem, i = elem.Prev(), i+1 {
+		values[i] = elem.Value.entry.Value
+	}
+	return values
+}
+
+// 获取缓存的Entries
+func (c *Cache[K, V]) Entries() []*cache.Entry[K, V] {
+	entries := make([]*cache.Entry[K, V], c.Len())
+	for elem, i := c.evictList.Back(), 0; elem != nil; elem, i = elem.Prev(), i+1 {
+		entries[i] = elem.Value.entry
+	}
+	return entries
+}
+
+// 移除元素
+func (c *Cache[K, V]) Remove(key K) bool {
+	if elem, ok := c.entries[key]; ok {
+		c.removeElement(elem)
+		return true
+	}
+	return false
+}
+
+// 移除已经过期的元素
+func (c *Cache[K, V]) RemoveExpired(key K) bool {
+	if elem, ok := c.entries[key]; ok && elem.Value.entry.Expired() {
+		c.expireElement(elem)
+		return true
+	}
+	return false
+}
+
+// 淘汰元素
+// 指针跳过访问过的元素并清除标记，淘汰第一个没有访问过的元素
+func (c *Cache[K, V]) Evict() *cache.Entry[K, V] {
+	if c.Len() == 0 {
+		return nil
+	}
+	elem := c.hand
+	if elem == nil {
+		elem = c.evictList.Back()
+	}
+	for elem.Value.visited && !elem.Value.entry.Expired() {
+		elem.Value.visited = false
+		elem = c.prev(elem)
+	}
+	c.hand = elem
+	c.removeElement(elem)
+	entry := elem.Value.entry
+	// 回调
+	reason := cache.EvictReasonCapacity
+	if entry.Expired() {
+		reason = cache.EvictReasonExpired
+	}
+	c.doOnEvict(entry, reason)
+	return entry
+}
+
+// 获取可能被淘汰的元素
+func (c *Cache[K, V]) Victim() *cache.Entry[K, V] {
+	if c.Len() == 0 {
+		return nil
+	}
+	start := c.hand
+	if start == nil {
+		start = c.evictList.Back()
+	}
+	// 如果所有元素都访问过，指针转一圈之后会回到起点
+	elem := start
+	for elem.Value.visited && !elem.Value.entry.Expired() {
+		if elem = c.prev(elem); elem == start {
+			break
+		}
+	}
+	return elem.Value.entry
+}
+
+// 清空缓存
+func (c *Cache[K, V]) Clear(needOnEvict bool) {
+	// 触发回调
+	if needOnEvict {
+		for elem, i := c.evictList.Back(), 0; elem != nil; elem, i = elem.Prev(), i+1 {
+			c.doOnEvict(elem.Value.entry, cache.EvictReasonClear)
+		}
+	}
+
+	// 清空
+	c.entries = make(map[K]*list.Element[*visitedEntry[K, V]])
+	c.evictList.Clear()
+	c.hand = nil
+	c.cost = 0
+}
+
+// 改变容量
+func (c *Cache[K, V]) Resize(capacity int, needOnEvict bool) {
+	c.capacity = capacity
+	c.evictToFit(0)
+}
+
+// 元素个数
+func (c *Cache[K, V]) Len() int {
+	return len(c.entries)
+}
+
+// 当前总权重，没有设置权重计算函数时等于元素个数
+func (c *Cache[K, V]) Cost() int64 {
+	return c.cost
+}
+
+// 容量，设置了权重计算函数时表示总权重
+func (c *Cache[K, V]) Cap() int {
+	return c.capacity
+}
+
+// 缓存满了
+func (c *Cache[K, V]) Full() bool {
+	return c.Cost() >= int64(c.Cap())
+}
+
+// 设置保存快照时使用的编解码器，为空使用GobCodec
+func (c *Cache[K, V]) SetCodec(keyCodec cache.Codec[K], valueCodec cache.Codec[V]) {
+	c.keyCodec = keyCodec
+	c.valueCodec = valueCodec
+}
+
+// 保存快照到w，包括元素的插入顺序、访问标记和淘汰指针的位置
+func (c *Cache[K, V]) Save(w io.Writer) error {
+	return c.Snapshot().Encode(w, c.keyCodec, c.valueCodec)
+}
+
+// 从r加载快照，会先清空缓存，不触发回调
+// 快照格式不正确时缓存保持不变
+func (c *Cache[K, V]) Load(r io.Reader) error {
+	snapshot, err := cache.DecodeSnapshot(r, snapshotPolicy, c.keyCodec, c.valueCodec)
+	if err != nil {
+		return err
+	}
+	return c.Restore(snapshot)
+}
+
+// 获取快照，按插入顺序排列，Frequency为1表示访问过
+// Meta[0]是淘汰指针在Records中的下标，-1表示从尾部开始
+func (c *Cache[K, V]) Snapshot() *cache.Snapshot[K, V] {
+	snapshot := &cache.Snapshot[K, V]{
+		Policy:  snapshotPolicy,
+		Meta:    []int64{-1},
+		Records: make([]*cache.SnapshotRecord[K, V], 0, c.Len()),
+	}
+	for elem := c.evictList.Back(); elem != nil; elem = elem.Prev() {
+		if elem == c.hand {
+			snapshot.Meta[0] = int64(len(snapshot.Records))
+		}
+		entry := *elem.Value.entry
+		record := &cache.SnapshotRecord[K, V]{
+			Entry: &entry,
+		}
+		if elem.Value.visited {
+			record.Frequency = 1
+		}
+		snapshot.Records = append(snapshot.Records, record)
+	}
+	return snapshot
+}
+
+// 从快照恢复，会先清空缓存，不触发回调
+// 跳过已经过期的元素，放不下的元素按淘汰顺序淘汰
+func (c *Cache[K, V]) Restore(snapshot *cache.Snapshot[K, V]) error {
+	if snapshot.Policy != snapshotPolicy {
+		return cache.ErrSnapshotPolicyMismatch
+	}
+	c.Clear(false)
+	hand := int64(-1)
+	if len(snapshot.Meta) > 0 {
+		hand = snapshot.Meta[0]
+	}
+	for i, record := range snapshot.Records {
+		entry := *record.Entry
+		if entry.Expired() {
+			continue
+		}
+		if elem, ok := c.entries[entry.Key]; ok {
+			c.removeElement(elem)
+		}
+		// 快照最先插入的在前面，所以依次放到最前面
+		elem := c.evictList.PushFront(&visitedEntry[K, V]{
+			entry:   &entry,
+			visited: record.Frequency > 0,
+		})
+		c.entries[entry.Key] = elem
+		c.cost += c.weigh(entry.Key, entry.Value)
+		c.schedule(entry.Key, entry.Expiration)
+		// 指针指向的元素过期了，就指向它后面的元素
+		if c.hand == nil && int64(i) >= hand && hand >= 0 {
+			c.hand = elem
+		}
+	}
+	c.evictToFit(0)
+	return nil
+}
+
+// 指针的下一个位置，到头部之后回到尾部
+func (c *Cache[K, V]) prev(elem *list.Element[*visitedEntry[K, V]]) *list.Element[*visitedEntry[K, V]] {
+	if prev := elem.Prev(); prev != nil {
+		return prev
+	}
+	return c.evictList.Back()
+}
+
+// 移除给定节点
+func (c *Cache[K, V]) removeElement(elem *list.Element[*visitedEntry[K, V]]) {
+	// 指针指向被移除的元素，则移动到下一个位置
+	if c.hand == elem {
+		c.hand = elem.Prev()
+	}
+	c.evictList.Remove(elem)
+	entry := elem.Value.entry
+	delete(c.entries, entry.Key)
+	c.cost -= c.weigh(entry.Key, entry.Value)
+}
+
+// 淘汰元素直到能放下给定权重的元素
+// 返回最后一个被淘汰的元素
+func (c *Cache[K, V]) evictToFit(weight int64) *cache.Entry[K, V] {
+	var evicted *cache.Entry[K, V]
+	for c.Len() > 0 && c.cost+weight > int64(c.capacity) {
+		evicted = c.Evict()
+	}
+	return evicted
+}
+
+// 计算元素权重
+func (c *Cache[K, V]) weigh(key K, value V) int64 {
+	if c.weigher == nil {
+		return 1
+	}
+	return c.weigher(key, value)
+}
+
+// 删除过期节点
+func (c *Cache[K, V]) expireElement(elem *list.Element[*visitedEntry[K, V]]) {
+	c.removeElement(elem)
+	c.doOnEvict(elem.Value.entry, cache.EvictReasonExpired)
+}
+
+// 添加到过期清理器
+func (c *Cache[K, V]) schedule(key K, expiration time.Time) {
+	if c.janitor != nil {
+		c.janitor.Push(key, expiration)
+	}
+}
+
+// 触发淘汰回调
+func (c *Cache[K, V]) doOnEvict(entry *cache.Entry[K, V], reason cache.EvictReason) {
+	c.stats.RecordEviction(reason)
+	if c.onEvict != nil {
+		c.onEvict(entry, reason)
+	}
+}
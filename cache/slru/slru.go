@@ -1,6 +1,11 @@
 package slru
 
 import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
 	"github.com/jiaxwu/gommon/cache"
 	"github.com/jiaxwu/gommon/cache/lru"
 )
@@ -8,6 +13,19 @@ import (
 // 保护段比例
 const ProtectedPercentage = 0.8
 
+const (
+	// 快照中的缓存策略名称
+	snapshotPolicy = "slru"
+	// 淘汰段和保护段使用的lru的快照策略名称
+	lruSnapshotPolicy = "lru"
+)
+
+// 快照中元素所在的段
+const (
+	segmentProbation uint8 = iota // 淘汰段
+	segmentProtected              // 保护段
+)
+
 // 分段最近最少使用
 // 第一次access是淘汰段，第二次access才进入保护段
 // 避免某些很少读取的值把一直读取的值给淘汰了
@@ -18,63 +36,213 @@ type Cache[K comparable, V any] struct {
 	protected    *lru.Cache[K, V] // 保护段
 	probationCap int
 	protectedCap int
+	weigher      cache.Weigher[K, V] // 计算元素权重，为空则每个元素权重为1
+	ttl          time.Duration       // 默认过期时间
+	onEvict      cache.OnEvictWithReason[K, V]
+	pins         map[K]int            // 被固定的元素的固定次数，在两个段之间移动时保持不变
+	canEvict     cache.CanEvict[K, V] // 判断元素能否被淘汰，为空表示都可以
+	janitor      *cache.Janitor[K]
+	stats        *cache.StatsCounter // 统计，为空表示不统计
+	keyCodec     cache.Codec[K]      // 保存快照时Key的编解码器
+	valueCodec   cache.Codec[V]      // 保存快照时Value的编解码器
 }
 
 func New[K comparable, V any](capacity int) *Cache[K, V] {
 	probationCap, protectedCap := splitCap(capacity)
-	return &Cache[K, V]{
+	c := &Cache[K, V]{
 		probation:    lru.New[K, V](capacity),
 		protected:    lru.New[K, V](protectedCap),
 		probationCap: probationCap,
 		protectedCap: protectedCap,
+		pins:         make(map[K]int),
 	}
+	// 被固定的元素不会被淘汰，也不会从保护段降级
+	c.probation.SetCanEvict(c.evictable)
+	c.protected.SetCanEvict(c.evictable)
+	// 只有淘汰段的元素才会真正被淘汰，保护段的元素会先被淘汰到淘汰段
+	c.probation.SetOnEvictWithReason(c.doOnEvict)
+	// 保护段只有过期和清空才算真正被淘汰
+	c.protected.SetOnEvictWithReason(func(entry *cache.Entry[K, V], reason cache.EvictReason) {
+		if reason != cache.EvictReasonCapacity {
+			c.doOnEvict(entry, reason)
+		}
+	})
+	return c
 }
 
 // 设置 OnEvict
 func (c *Cache[K, V]) SetOnEvict(onEvict cache.OnEvict[K, V]) {
-	// 只有淘汰段的元素才会真正被淘汰，保护段的元素会先被淘汰到淘汰段
-	c.probation.SetOnEvict(onEvict)
+	c.onEvict = onEvict.WithReason()
+}
+
+// 设置 OnEvict，带上淘汰原因
+func (c *Cache[K, V]) SetOnEvictWithReason(onEvict cache.OnEvictWithReason[K, V]) {
+	c.onEvict = onEvict
+}
+
+// 设置 CanEvict，返回false的元素淘汰时会被跳过
+func (c *Cache[K, V]) SetCanEvict(canEvict cache.CanEvict[K, V]) {
+	c.canEvict = canEvict
+}
+
+// 固定元素，固定的元素不会被淘汰，可以固定多次，需要相同次数的 Unpin()
+// 不存在返回false
+func (c *Cache[K, V]) Pin(key K) bool {
+	if !c.Contains(key) {
+		return false
+	}
+	c.pins[key]++
+	return true
+}
+
+// 解除一次固定，完全解除后如果超过容量则淘汰元素
+// 没有固定返回false
+func (c *Cache[K, V]) Unpin(key K) bool {
+	n, ok := c.pins[key]
+	if !ok {
+		return false
+	}
+	if n > 1 {
+		c.pins[key] = n - 1
+		return true
+	}
+	delete(c.pins, key)
+	c.evictToFit(0)
+	return true
+}
+
+// 元素是否被固定
+func (c *Cache[K, V]) Pinned(key K) bool {
+	return c.pins[key] > 0
+}
+
+// 设置权重计算函数，设置后容量表示总权重，而不是元素个数
+func (c *Cache[K, V]) SetWeigher(weigher cache.Weigher[K, V]) {
+	c.weigher = weigher
+	c.probation.SetWeigher(weigher)
+	c.protected.SetWeigher(weigher)
+	c.evictToFit(0)
+}
+
+// 设置统计计数器，为空表示不统计
+func (c *Cache[K, V]) SetStatsCounter(stats *cache.StatsCounter) {
+	c.stats = stats
+}
+
+// 获取统计信息
+func (c *Cache[K, V]) Stats() cache.Stats {
+	return c.stats.Snapshot()
+}
+
+// 设置默认过期时间，Put()会使用该过期时间，小于等于0表示永不过期
+func (c *Cache[K, V]) SetDefaultTTL(ttl time.Duration) {
+	c.ttl = ttl
+}
+
+// 启动过期清理器，到期主动删除元素，直到ctx被关闭
+// locker是业务访问缓存时使用的锁
+// 只会清理启动之后添加的元素，之前添加的元素依然在访问时删除
+func (c *Cache[K, V]) StartJanitor(ctx context.Context, locker sync.Locker) {
+	c.janitor = cache.NewJanitor(locker, c.RemoveExpired)
+	go c.janitor.Run(ctx)
 }
 
 // 添加或更新元素
 // 返回被淘汰的元素
 func (c *Cache[K, V]) Put(key K, value V) *cache.Entry[K, V] {
-	// 先看是否已经在保护段，如果是则更新即可
-	if c.protected.Contains(key) {
-		return c.protected.Put(key, value)
+	return c.PutWithTTL(key, value, c.ttl)
+}
+
+// 添加或更新元素，并设置过期时间，小于等于0表示永不过期
+// 返回被淘汰的元素
+func (c *Cache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) *cache.Entry[K, V] {
+	return c.PutWithExpiration(key, value, cache.Expiration(ttl))
+}
+
+// 添加或更新元素，并设置过期时刻，零值表示永不过期
+// 返回被淘汰的元素，如果淘汰了多个元素，返回最后一个
+// 如果元素权重超过容量，或者剩余的元素都被固定放不下，则不会添加，直接返回该元素，可以通过 TryPut() 区分
+// 更新已经存在的元素时，如果剩余的元素都被固定，允许暂时超过容量
+func (c *Cache[K, V]) PutWithExpiration(key K, value V, expiration time.Time) *cache.Entry[K, V] {
+	evicted, err := c.tryPut(key, value, expiration)
+	if err != nil {
+		return &cache.Entry[K, V]{
+			Key:        key,
+			Value:      value,
+			Expiration: expiration,
+		}
 	}
+	return evicted
+}
 
-	// 如果在淘汰段，则移动到保护段
-	if c.probation.Contains(key) {
-		c.moveToProtected(key, value)
-		return nil
+// 添加或更新元素，拒绝添加时返回错误
+// 返回被淘汰的元素，如果淘汰了多个元素，返回最后一个
+// 元素权重超过容量返回 cache.ErrTooLarge，剩余的元素都被固定放不下返回 cache.ErrAllPinned
+// 拒绝添加时不会淘汰任何元素，已经存在的旧值保持不变
+func (c *Cache[K, V]) TryPut(key K, value V) (*cache.Entry[K, V], error) {
+	return c.tryPut(key, value, cache.Expiration(c.ttl))
+}
+
+// 添加或更新元素，拒绝添加时返回错误
+func (c *Cache[K, V]) tryPut(key K, value V, expiration time.Time) (*cache.Entry[K, V], error) {
+	weight := c.weigh(key, value)
+	if weight > int64(c.Cap()) {
+		return nil, cache.ErrTooLarge
 	}
 
-	// 如果已经到达最大尺寸，先剔除淘汰段的一个元素
-	var evicted *cache.Entry[K, V]
-	if c.Full() {
-		evicted = c.probation.Evict()
+	// 先删除过期的旧元素，避免同一个Key同时出现在两个段
+	c.RemoveExpired(key)
+	if c.janitor != nil {
+		c.janitor.Push(key, expiration)
 	}
 
+	// 如果已经在保护段或淘汰段，则移动到保护段，移动时会重新计算权重
+	if c.protected.Remove(key) || c.probation.Contains(key) {
+		// 先固定它自己，避免移动和淘汰时淘汰正在更新的元素
+		// 剩余的元素都被固定时允许暂时超过容量
+		c.pins[key]++
+		c.moveToProtected(key, value, expiration)
+		c.stats.RecordUpdate()
+		// 权重变大可能需要淘汰元素
+		evicted := c.evictToFit(0)
+		if c.pins[key]--; c.pins[key] == 0 {
+			delete(c.pins, key)
+		}
+		return evicted, nil
+	}
+
+	// 剩余的元素都被固定放不下，直接拒绝，避免白白淘汰元素
+	if !c.canFit(weight) {
+		return nil, cache.ErrAllPinned
+	}
+	// 如果放不下，先剔除元素
+	evicted := c.evictToFit(weight)
+
 	// 添加元素到淘汰段
-	c.probation.Put(key, value)
-	return evicted
+	c.probation.PutWithExpiration(key, value, expiration)
+	c.stats.RecordPut()
+	return evicted, nil
 }
 
 // 获取元素
 func (c *Cache[K, V]) Get(key K) (V, bool) {
 	// 先看是否已经在保护段，如果是则更新即可
 	if value, ok := c.protected.Get(key); ok {
+		c.stats.RecordHit()
 		return value, true
 	}
 
 	// 如果在淘汰段，则移动到保护段
-	if value, ok := c.probation.Get(key); ok {
-		c.moveToProtected(key, value)
-		return value, true
+	if entry, ok := c.probation.PeekEntry(key); ok {
+		c.moveToProtected(key, entry.Value, entry.Expiration)
+		c.stats.RecordHit()
+		return entry.Value, true
 	}
+	// 过期了直接删除
+	c.probation.RemoveExpired(key)
 
 	// 不存在返回空值和false
+	c.stats.RecordMiss()
 	var value V
 	return value, false
 }
@@ -100,6 +268,7 @@ func (c *Cache[K, V]) Contains(key K) bool {
 }
 
 // 获取缓存的Keys
+// 可能包含已经过期但还没被删除的元素
 func (c *Cache[K, V]) Keys() []K {
 	return append(c.probation.Keys(), c.protected.Keys()...)
 }
@@ -114,8 +283,23 @@ func (c *Cache[K, V]) Entries() []*cache.Entry[K, V] {
 	return append(c.probation.Entries(), c.protected.Entries()...)
 }
 
+// 按淘汰顺序，先淘汰段再保护段遍历元素，f返回false时停止，不更新状态，不分配内存
+// 可能包含已经过期但还没被删除的元素
+// 遍历期间可以删除当前元素，其他修改的结果是未定义的
+func (c *Cache[K, V]) Range(f func(key K, value V) bool) {
+	stop := false
+	c.probation.Range(func(key K, value V) bool {
+		stop = !f(key, value)
+		return !stop
+	})
+	if !stop {
+		c.protected.Range(f)
+	}
+}
+
 // 移除元素
 func (c *Cache[K, V]) Remove(key K) bool {
+	delete(c.pins, key)
 	if c.protected.Remove(key) {
 		return true
 	}
@@ -125,6 +309,11 @@ func (c *Cache[K, V]) Remove(key K) bool {
 	return false
 }
 
+// 移除已经过期的元素
+func (c *Cache[K, V]) RemoveExpired(key K) bool {
+	return c.protected.RemoveExpired(key) || c.probation.RemoveExpired(key)
+}
+
 // 淘汰元素
 func (c *Cache[K, V]) Evict() *cache.Entry[K, V] {
 	return c.probation.Evict()
@@ -136,21 +325,32 @@ func (c *Cache[K, V]) Victim() *cache.Entry[K, V] {
 	if !c.Full() {
 		return nil
 	}
-	// 获取淘汰段的最后一个
-	return c.probation.Victim()
+	// 获取淘汰段的最后一个，淘汰段的元素都不能淘汰时才淘汰保护段
+	if victim := c.probation.Victim(); victim != nil {
+		return victim
+	}
+	return c.protected.Victim()
 }
 
 // 清空缓存
 func (c *Cache[K, V]) Clear(needOnEvict bool) {
 	c.probation.Clear(needOnEvict)
 	c.protected.Clear(needOnEvict)
+	c.pins = make(map[K]int)
 }
 
 // 改变容量
 func (c *Cache[K, V]) Resize(capacity int, needOnEvict bool) {
-	_, protectedCap := splitCap(capacity)
+	c.probationCap, c.protectedCap = splitCap(capacity)
+	// 保护段放不下的元素降级到淘汰段
+	for c.protected.Cost() > int64(c.protectedCap) {
+		if !c.demote() {
+			break
+		}
+	}
+	c.protected.Resize(c.protectedCap, needOnEvict)
 	c.probation.Resize(capacity, needOnEvict)
-	c.protected.Resize(protectedCap, needOnEvict)
+	c.evictToFit(0)
 }
 
 // 元素个数
@@ -163,26 +363,193 @@ func (c *Cache[K, V]) Cap() int {
 	return c.probationCap + c.protectedCap
 }
 
+// 当前总权重，没有设置权重计算函数时等于元素个数
+func (c *Cache[K, V]) Cost() int64 {
+	return c.probation.Cost() + c.protected.Cost()
+}
+
 // 缓存满了
 func (c *Cache[K, V]) Full() bool {
-	return c.Len() == c.Cap()
+	return c.Cost() >= int64(c.Cap())
+}
+
+// 设置保存快照时使用的编解码器，为空使用GobCodec
+func (c *Cache[K, V]) SetCodec(keyCodec cache.Codec[K], valueCodec cache.Codec[V]) {
+	c.keyCodec = keyCodec
+	c.valueCodec = valueCodec
+}
+
+// 保存快照到w，包括元素所在的段和段内的访问顺序
+func (c *Cache[K, V]) Save(w io.Writer) error {
+	return c.Snapshot().Encode(w, c.keyCodec, c.valueCodec)
+}
+
+// 从r加载快照，会先清空缓存，不触发回调
+// 快照格式不正确时缓存保持不变
+func (c *Cache[K, V]) Load(r io.Reader) error {
+	snapshot, err := cache.DecodeSnapshot(r, snapshotPolicy, c.keyCodec, c.valueCodec)
+	if err != nil {
+		return err
+	}
+	return c.Restore(snapshot)
+}
+
+// 获取快照，先是淘汰段，然后是保护段，段内按淘汰顺序排列
+func (c *Cache[K, V]) Snapshot() *cache.Snapshot[K, V] {
+	snapshot := &cache.Snapshot[K, V]{
+		Policy: snapshotPolicy,
+	}
+	for _, record := range c.probation.Snapshot().Records {
+		record.Segment = segmentProbation
+		snapshot.Records = append(snapshot.Records, record)
+	}
+	for _, record := range c.protected.Snapshot().Records {
+		record.Segment = segmentProtected
+		snapshot.Records = append(snapshot.Records, record)
+	}
+	return snapshot
+}
+
+// 从快照恢复，会先清空缓存，不触发回调
+// 跳过已经过期的元素，保护段放不下的元素降级到淘汰段，放不下的元素按淘汰顺序淘汰
+func (c *Cache[K, V]) Restore(snapshot *cache.Snapshot[K, V]) error {
+	if snapshot.Policy != snapshotPolicy {
+		return cache.ErrSnapshotPolicyMismatch
+	}
+	probation := &cache.Snapshot[K, V]{Policy: lruSnapshotPolicy}
+	protected := &cache.Snapshot[K, V]{Policy: lruSnapshotPolicy}
+	for _, record := range snapshot.Records {
+		if record.Segment == segmentProtected {
+			protected.Records = append(protected.Records, record)
+		} else {
+			probation.Records = append(probation.Records, record)
+		}
+	}
+
+	// 保护段最旧的元素放不下，和demote()一样降级到淘汰段的最前面
+	i, cost := len(protected.Records), int64(0)
+	for ; i > 0; i-- {
+		entry := protected.Records[i-1].Entry
+		weight := c.weigh(entry.Key, entry.Value)
+		if cost+weight > int64(c.protectedCap) {
+			break
+		}
+		cost += weight
+	}
+	probation.Records = append(probation.Records, protected.Records[:i]...)
+	protected.Records = protected.Records[i:]
+
+	c.probation.Restore(probation)
+	c.protected.Restore(protected)
+	c.evictToFit(0)
+	return nil
 }
 
 // 移动到保护段
-func (c *Cache[K, V]) moveToProtected(key K, value V) {
-	// 从淘汰段移除
+func (c *Cache[K, V]) moveToProtected(key K, value V, expiration time.Time) {
+	// 保护段放不下，只能留在淘汰段
+	weight := c.weigh(key, value)
+	if weight > int64(c.protectedCap) {
+		c.probation.PutWithExpiration(key, value, expiration)
+		return
+	}
+
+	// 如果保护段满了，则把保护段的元素移动到淘汰段
+	for c.protected.Len() > 0 && c.protected.Cost()+weight > int64(c.protectedCap) {
+		if !c.demote() {
+			break
+		}
+	}
+	// 保护段的元素都被固定，只能留在淘汰段
+	if c.protected.Cost()+weight > int64(c.protectedCap) {
+		c.probation.PutWithExpiration(key, value, expiration)
+		return
+	}
+
+	// 从淘汰段移动到保护段
 	c.probation.Remove(key)
+	c.protected.PutWithExpiration(key, value, expiration)
+}
+
+// 把保护段的一个元素降级到淘汰段
+// 保护段的元素都不能淘汰返回false
+func (c *Cache[K, V]) demote() bool {
+	// 从保护段淘汰一个元素
+	entry := c.protected.Evict()
+	if entry == nil {
+		return false
+	}
+	// 添加到淘汰段，已经过期的元素在淘汰时已经触发回调，直接丢弃
+	if !entry.Expired() {
+		c.probation.PutWithExpiration(entry.Key, entry.Value, entry.Expiration)
+	}
+	return true
+}
 
-	// 如果保护段满了，则把保护段的一个元素移动到淘汰段
-	if c.protected.Len() == c.protectedCap {
-		// 从保护段淘汰一个元素
+// 淘汰可以淘汰的元素后能否放下给定权重的元素
+func (c *Cache[K, V]) canFit(weight int64) bool {
+	need := c.Cost() + weight - int64(c.Cap())
+	f := func(segment *lru.Cache[K, V]) func(key K, value V) bool {
+		return func(key K, value V) bool {
+			if need <= 0 {
+				return false
+			}
+			// 过期的元素也可以淘汰
+			if entry, ok := segment.PeekEntry(key); !ok || c.evictable(entry) {
+				need -= c.weigh(key, value)
+			}
+			return true
+		}
+	}
+	c.probation.Range(f(c.probation))
+	c.protected.Range(f(c.protected))
+	return need <= 0
+}
+
+// 淘汰元素直到能放下给定权重的元素
+// 优先淘汰淘汰段，淘汰段为空或者都不能淘汰才淘汰保护段
+// 返回最后一个被淘汰的元素
+func (c *Cache[K, V]) evictToFit(weight int64) *cache.Entry[K, V] {
+	var evicted *cache.Entry[K, V]
+	for c.Len() > 0 && c.Cost()+weight > int64(c.Cap()) {
+		if entry := c.probation.Evict(); entry != nil {
+			evicted = entry
+			continue
+		}
 		entry := c.protected.Evict()
-		// 添加到淘汰段
-		c.probation.Put(entry.Key, entry.Value)
+		// 剩下的元素都不能淘汰
+		if entry == nil {
+			break
+		}
+		evicted = entry
+		// 过期的元素在淘汰时已经触发回调
+		if !evicted.Expired() {
+			c.doOnEvict(evicted, cache.EvictReasonCapacity)
+		}
+	}
+	return evicted
+}
+
+// 计算元素权重
+func (c *Cache[K, V]) weigh(key K, value V) int64 {
+	if c.weigher == nil {
+		return 1
 	}
+	return c.weigher(key, value)
+}
 
-	// 添加到保护段
-	c.protected.Put(key, value)
+// 元素能否被淘汰
+func (c *Cache[K, V]) evictable(entry *cache.Entry[K, V]) bool {
+	return c.pins[entry.Key] == 0 && (c.canEvict == nil || c.canEvict(entry))
+}
+
+// 触发淘汰回调
+func (c *Cache[K, V]) doOnEvict(entry *cache.Entry[K, V], reason cache.EvictReason) {
+	delete(c.pins, entry.Key)
+	c.stats.RecordEviction(reason)
+	if c.onEvict != nil {
+		c.onEvict(entry, reason)
+	}
 }
 
 // 分割容量
@@ -1,11 +1,15 @@
 package slru
 
 import (
+	"bytes"
+	"errors"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/cache/cachetest"
 )
 
 func TestCache_Put(t *testing.T) {
@@ -125,6 +129,228 @@ func TestCache_Entries(t *testing.T) {
 	}
 }
 
+func TestCache_Range(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Get("11")
+	c.Put("44", 8)
+
+	// 和 Keys() 顺序一致，不更新状态
+	keys := c.Keys()
+	var ranged []string
+	c.Range(func(key string, value int) bool {
+		ranged = append(ranged, key)
+		return true
+	})
+	if !reflect.DeepEqual(ranged, keys) || !reflect.DeepEqual(c.Keys(), keys) {
+		t.Errorf("Range() = %v, want %v", ranged, keys)
+	}
+
+	// 提前停止
+	n := 0
+	c.Range(func(key string, value int) bool {
+		n++
+		return n < 2
+	})
+	if n != 2 {
+		t.Errorf("n = %v, want %v", n, 2)
+	}
+
+	// 遍历期间删除当前元素
+	c.Range(func(key string, value int) bool {
+		c.Remove(key)
+		return true
+	})
+	if c.Len() != 0 {
+		t.Errorf("Len() = %v, want %v", c.Len(), 0)
+	}
+}
+
+func TestCache_TTL(t *testing.T) {
+	cachetest.RunTTL(t, func(capacity int) cachetest.TTLPolicy {
+		return New[string, int](capacity)
+	})
+}
+
+func TestCache_SetWeigher(t *testing.T) {
+	c := New[string, []byte](10)
+	c.SetWeigher(func(key string, value []byte) int64 {
+		return int64(len(value))
+	})
+	c.Put("11", make([]byte, 4))
+	c.Put("22", make([]byte, 4))
+	if c.Len() != 2 || c.Cost() != 8 {
+		t.Errorf("Len() = %v, Cost() = %v, want %v, %v", c.Len(), c.Cost(), 2, 8)
+	}
+
+	// 放不下需要淘汰
+	c.Put("33", make([]byte, 6))
+	if c.Cost() > int64(c.Cap()) {
+		t.Errorf("Cost() = %v, want <= %v", c.Cost(), c.Cap())
+	}
+
+	// 超过容量直接拒绝
+	rejected := c.Put("44", make([]byte, 11))
+	if rejected == nil || rejected.Key != "44" || c.Contains("44") {
+		t.Errorf("Put() = %v, want %v", rejected, "44")
+	}
+	if c.Cost() > int64(c.Cap()) {
+		t.Errorf("Cost() = %v, want <= %v", c.Cost(), c.Cap())
+	}
+}
+
+func TestCache_Save(t *testing.T) {
+	c := New[string, int](5)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Get("11")
+	c.Get("22")
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// 恢复后每个元素所在的段不变
+	c2 := New[string, int](5)
+	if err := c2.Load(&buf); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(c2.Keys(), c.Keys()) {
+		t.Errorf("Keys() = %v, want %v", c2.Keys(), c.Keys())
+	}
+	if !reflect.DeepEqual(c2.protected.Keys(), []string{"11", "22"}) {
+		t.Errorf("protected.Keys() = %v, want %v", c2.protected.Keys(), []string{"11", "22"})
+	}
+
+	// 保护段放不下的元素降级到淘汰段
+	buf.Reset()
+	c.Save(&buf)
+	c3 := New[string, int](2)
+	if err := c3.Load(&buf); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(c3.Keys(), []string{"11", "22"}) || c3.protected.Len() != 1 {
+		t.Errorf("Keys() = %v, want %v", c3.Keys(), []string{"11", "22"})
+	}
+}
+
+func TestCache_Pin(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	if !c.Pin("11") || !c.Pin("11") || c.Pin("44") {
+		t.Errorf("Pin() = %v, want %v", false, true)
+	}
+
+	// 跳过被固定的元素
+	if evicted := c.Put("44", 8); evicted == nil || evicted.Key != "22" {
+		t.Errorf("Put() = %v, want %v", evicted, "22")
+	}
+
+	// 所有元素都被固定时不会添加，直接返回该元素
+	c.Pin("33")
+	c.Pin("44")
+	if evicted := c.Put("55", 9); evicted == nil || evicted.Key != "55" || c.Contains("55") {
+		t.Errorf("Put() = %v, want %v", evicted, "55")
+	}
+
+	// 解除相同次数的固定后才能淘汰
+	c.Unpin("11")
+	if !c.Pinned("11") {
+		t.Errorf("Pinned() = %v, want %v", false, true)
+	}
+	c.Unpin("11")
+	if evicted := c.Put("55", 9); evicted == nil || evicted.Key != "11" {
+		t.Errorf("Put() = %v, want %v", evicted, "11")
+	}
+	if c.Unpin("11") {
+		t.Errorf("Unpin() = %v, want %v", true, false)
+	}
+
+	// 删除元素会清除固定
+	c.Remove("33")
+	if c.Pinned("33") {
+		t.Errorf("Pinned() = %v, want %v", true, false)
+	}
+}
+
+func TestCache_TryPut(t *testing.T) {
+	c := New[string, []byte](10)
+	c.SetWeigher(func(key string, value []byte) int64 {
+		return int64(len(value))
+	})
+	c.Put("11", make([]byte, 4))
+	c.Put("22", make([]byte, 4))
+
+	// 超过容量返回错误，旧值保持不变
+	if _, err := c.TryPut("11", make([]byte, 11)); !errors.Is(err, cache.ErrTooLarge) {
+		t.Errorf("TryPut() error = %v, want %v", err, cache.ErrTooLarge)
+	}
+	if value, ok := c.Peek("11"); !ok || len(value) != 4 {
+		t.Errorf("Peek() = %v, want %v", len(value), 4)
+	}
+
+	// 淘汰所有没有固定的元素也放不下时返回错误，不会淘汰任何元素
+	c.Pin("11")
+	if _, err := c.TryPut("33", make([]byte, 7)); !errors.Is(err, cache.ErrAllPinned) {
+		t.Errorf("TryPut() error = %v, want %v", err, cache.ErrAllPinned)
+	}
+	if !c.Contains("22") || c.Contains("33") {
+		t.Errorf("Keys() = %v, want %v", c.Keys(), []string{"11", "22"})
+	}
+
+	evicted, err := c.TryPut("33", make([]byte, 6))
+	if err != nil || evicted == nil || evicted.Key != "22" {
+		t.Errorf("TryPut() = %v, %v, want %v, %v", evicted, err, "22", nil)
+	}
+}
+
+func TestCache_TryPutUpdatePinned(t *testing.T) {
+	c := New[string, []byte](10)
+	c.SetWeigher(func(key string, value []byte) int64 {
+		return int64(len(value))
+	})
+	var evicted []string
+	c.SetOnEvict(func(entry *cache.Entry[string, []byte]) {
+		evicted = append(evicted, entry.Key)
+	})
+	c.Put("11", make([]byte, 2))
+	c.Put("22", make([]byte, 4))
+	c.Put("33", make([]byte, 4))
+	c.Pin("22")
+	c.Pin("33")
+
+	// 其他元素都被固定时，更新变重的元素不会淘汰它自己，允许暂时超过容量
+	for _, n := range []int{6, 5} {
+		entry, err := c.TryPut("11", make([]byte, n))
+		if err != nil || entry != nil {
+			t.Errorf("TryPut() = %v, %v, want %v, %v", entry, err, nil, nil)
+		}
+		if value, ok := c.Peek("11"); !ok || len(value) != n {
+			t.Errorf("Peek() = %v, want %v", len(value), n)
+		}
+	}
+	if c.Len() != 3 || c.Pinned("11") || len(evicted) != 0 {
+		t.Errorf("Len() = %v, evicted = %v, want %v, %v", c.Len(), evicted, 3, []string{})
+	}
+
+	// 解除固定后淘汰到容量以内
+	c.Unpin("33")
+	if !c.Contains("11") || len(evicted) != 1 || evicted[0] != "33" {
+		t.Errorf("evicted = %v, want %v", evicted, []string{"33"})
+	}
+}
+
+func TestCache_Conformance(t *testing.T) {
+	cachetest.Run(t, func(capacity int) cache.Policy[string, int] {
+		return New[string, int](capacity)
+	}, cachetest.Options{EvictionOrder: true})
+}
+
 // slru_test.go:159: cachePercentage=0.1%, count=206048, hitCount=30093, hitRate=14.60%
 // slru_test.go:159: cachePercentage=0.3%, count=206048, hitCount=67481, hitRate=32.75%
 // slru_test.go:159: cachePercentage=0.5%, count=206048, hitCount=101590, hitRate=49.30%
@@ -0,0 +1,240 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// 快照格式版本
+const snapshotVersion = 1
+
+// 快照文件头
+var snapshotMagic = []byte("GCS")
+
+var (
+	// 快照格式不正确
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+	// 快照不是由同一种缓存策略保存的
+	ErrSnapshotPolicyMismatch = errors.New("snapshot policy mismatch")
+)
+
+// 快照中的元素
+type SnapshotRecord[K comparable, V any] struct {
+	Entry     *Entry[K, V]
+	Segment   uint8  // 元素所在的段，比如slru的淘汰段和保护段
+	Frequency uint64 // 访问频率，lfu使用
+}
+
+// 缓存快照
+// 用于重启后恢复缓存，避免缓存预热期间大量请求落到数据库
+type Snapshot[K comparable, V any] struct {
+	Policy  string                  // 缓存策略名称，恢复时校验
+	Meta    []int64                 // 缓存策略的内部状态，比如arc偏向LRU的程度
+	Records []*SnapshotRecord[K, V] // 按淘汰顺序排列，最先被淘汰的在前面
+}
+
+// 编码快照并写入w
+// keyCodec和valueCodec为空时使用GobCodec
+func (s *Snapshot[K, V]) Encode(w io.Writer, keyCodec Codec[K], valueCodec Codec[V]) error {
+	if keyCodec == nil {
+		keyCodec = GobCodec[K]{}
+	}
+	if valueCodec == nil {
+		valueCodec = GobCodec[V]{}
+	}
+	sw := &snapshotWriter{w: bufio.NewWriter(w)}
+	sw.writeBytes(snapshotMagic)
+	sw.writeUvarint(snapshotVersion)
+	sw.writeBytes([]byte(s.Policy))
+	sw.writeUvarint(uint64(len(s.Meta)))
+	for _, meta := range s.Meta {
+		sw.writeVarint(meta)
+	}
+	sw.writeUvarint(uint64(len(s.Records)))
+	for _, record := range s.Records {
+		key, err := keyCodec.Marshal(record.Entry.Key)
+		if err != nil {
+			return fmt.Errorf("marshal key: %w", err)
+		}
+		value, err := valueCodec.Marshal(record.Entry.Value)
+		if err != nil {
+			return fmt.Errorf("marshal value: %w", err)
+		}
+		var expiration int64
+		if !record.Entry.Expiration.IsZero() {
+			expiration = record.Entry.Expiration.UnixNano()
+		}
+		sw.writeUvarint(uint64(record.Segment))
+		sw.writeUvarint(record.Frequency)
+		sw.writeVarint(expiration)
+		sw.writeBytes(key)
+		sw.writeBytes(value)
+	}
+	if sw.err != nil {
+		return sw.err
+	}
+	return sw.w.Flush()
+}
+
+// 从r读取并解码快照，只读取快照本身，不会多读后面的数据，所以快照可以保存在更大的流中
+// r实现了 io.ByteReader 时直接使用，否则逐字节读取长度，建议传入 bufio.Reader 等带缓冲的Reader
+// policy是期望的缓存策略名称，不一致返回ErrSnapshotPolicyMismatch
+// keyCodec和valueCodec为空时使用GobCodec
+func DecodeSnapshot[K comparable, V any](r io.Reader, policy string, keyCodec Codec[K],
+	valueCodec Codec[V]) (*Snapshot[K, V], error) {
+	if keyCodec == nil {
+		keyCodec = GobCodec[K]{}
+	}
+	if valueCodec == nil {
+		valueCodec = GobCodec[V]{}
+	}
+	br, ok := r.(byteReader)
+	if !ok {
+		br = &singleByteReader{Reader: r}
+	}
+	sr := &snapshotReader{r: br}
+	if magic := sr.readBytes(); sr.err == nil && string(magic) != string(snapshotMagic) {
+		return nil, ErrInvalidSnapshot
+	}
+	if version := sr.readUvarint(); sr.err == nil && version != snapshotVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidSnapshot, version)
+	}
+	s := &Snapshot[K, V]{
+		Policy: string(sr.readBytes()),
+	}
+	if sr.err == nil && s.Policy != policy {
+		return nil, fmt.Errorf("%w: got %s, want %s", ErrSnapshotPolicyMismatch, s.Policy, policy)
+	}
+	for n := sr.readUvarint(); sr.err == nil && n > 0; n-- {
+		s.Meta = append(s.Meta, sr.readVarint())
+	}
+	for n := sr.readUvarint(); sr.err == nil && n > 0; n-- {
+		record := &SnapshotRecord[K, V]{
+			Entry: &Entry[K, V]{},
+		}
+		segment := sr.readUvarint()
+		record.Frequency = sr.readUvarint()
+		if expiration := sr.readVarint(); expiration != 0 {
+			record.Entry.Expiration = time.Unix(0, expiration)
+		}
+		key, value := sr.readBytes(), sr.readBytes()
+		if sr.err != nil {
+			break
+		}
+		if segment > math.MaxUint8 {
+			return nil, ErrInvalidSnapshot
+		}
+		record.Segment = uint8(segment)
+		var err error
+		if record.Entry.Key, err = keyCodec.Unmarshal(key); err != nil {
+			return nil, fmt.Errorf("unmarshal key: %w", err)
+		}
+		if record.Entry.Value, err = valueCodec.Unmarshal(value); err != nil {
+			return nil, fmt.Errorf("unmarshal value: %w", err)
+		}
+		s.Records = append(s.Records, record)
+	}
+	if sr.err != nil {
+		return nil, sr.err
+	}
+	return s, nil
+}
+
+// 写快照，出错后忽略后续写入
+type snapshotWriter struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (w *snapshotWriter) writeUvarint(x uint64) {
+	n := binary.PutUvarint(w.buf[:], x)
+	w.write(w.buf[:n])
+}
+
+func (w *snapshotWriter) writeVarint(x int64) {
+	n := binary.PutVarint(w.buf[:], x)
+	w.write(w.buf[:n])
+}
+
+// 写入长度和数据
+func (w *snapshotWriter) writeBytes(b []byte) {
+	w.writeUvarint(uint64(len(b)))
+	w.write(b)
+}
+
+func (w *snapshotWriter) write(b []byte) {
+	if w.err == nil {
+		_, w.err = w.w.Write(b)
+	}
+}
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// 每次只读取一个字节，避免缓冲多读调用者的数据
+type singleByteReader struct {
+	io.Reader
+	buf [1]byte
+}
+
+func (r *singleByteReader) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(r.Reader, r.buf[:]); err != nil {
+		return 0, err
+	}
+	return r.buf[0], nil
+}
+
+// 读快照，出错后忽略后续读取
+type snapshotReader struct {
+	r   byteReader
+	err error
+}
+
+func (r *snapshotReader) readUvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	x, err := binary.ReadUvarint(r.r)
+	r.setErr(err)
+	return x
+}
+
+func (r *snapshotReader) readVarint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	x, err := binary.ReadVarint(r.r)
+	r.setErr(err)
+	return x
+}
+
+// 读取长度和数据
+func (r *snapshotReader) readBytes() []byte {
+	n := r.readUvarint()
+	if r.err != nil {
+		return nil
+	}
+	// 不根据长度预先分配内存，避免错误的长度导致分配大量内存
+	b, err := io.ReadAll(io.LimitReader(r.r, int64(n)))
+	if err == nil && uint64(len(b)) != n {
+		err = io.ErrUnexpectedEOF
+	}
+	r.setErr(err)
+	return b
+}
+
+// 快照不完整也是格式错误
+func (r *snapshotReader) setErr(err error) {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = fmt.Errorf("%w: %v", ErrInvalidSnapshot, io.ErrUnexpectedEOF)
+	}
+	r.err = err
+}
@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// 缓存统计信息快照
+// 可以用两次快照相减计算一段时间内的统计信息
+type Stats struct {
+	Hits          uint64                   // 命中次数
+	Misses        uint64                   // 未命中次数
+	Puts          uint64                   // 添加新元素次数
+	Updates       uint64                   // 更新已存在元素次数
+	Evictions     [evictReasonCount]uint64 // 淘汰次数，按淘汰原因统计
+	LoadSuccesses uint64                   // 加载成功次数
+	LoadFailures  uint64                   // 加载失败次数
+	TotalLoadTime time.Duration            // 加载总耗时，包括成功和失败
+}
+
+// 请求次数
+func (s Stats) Requests() uint64 {
+	return s.Hits + s.Misses
+}
+
+// 命中率
+func (s Stats) HitRate() float64 {
+	requests := s.Requests()
+	if requests == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(requests)
+}
+
+// 淘汰总次数
+func (s Stats) EvictionCount() uint64 {
+	var count uint64
+	for _, n := range s.Evictions {
+		count += n
+	}
+	return count
+}
+
+// 某个原因的淘汰次数
+func (s Stats) EvictionCountOf(reason EvictReason) uint64 {
+	return s.Evictions[reason]
+}
+
+// 加载次数
+func (s Stats) Loads() uint64 {
+	return s.LoadSuccesses + s.LoadFailures
+}
+
+// 平均加载耗时
+func (s Stats) AverageLoadTime() time.Duration {
+	loads := s.Loads()
+	if loads == 0 {
+		return 0
+	}
+	return s.TotalLoadTime / time.Duration(loads)
+}
+
+// 减去另一个快照，通常是更早的快照
+func (s Stats) Sub(other Stats) Stats {
+	s.Hits -= other.Hits
+	s.Misses -= other.Misses
+	s.Puts -= other.Puts
+	s.Updates -= other.Updates
+	for i := range s.Evictions {
+		s.Evictions[i] -= other.Evictions[i]
+	}
+	s.LoadSuccesses -= other.LoadSuccesses
+	s.LoadFailures -= other.LoadFailures
+	s.TotalLoadTime -= other.TotalLoadTime
+	return s
+}
+
+// 加上另一个快照，比如合并多个缓存的统计信息
+func (s Stats) Add(other Stats) Stats {
+	s.Hits += other.Hits
+	s.Misses += other.Misses
+	s.Puts += other.Puts
+	s.Updates += other.Updates
+	for i := range s.Evictions {
+		s.Evictions[i] += other.Evictions[i]
+	}
+	s.LoadSuccesses += other.LoadSuccesses
+	s.LoadFailures += other.LoadFailures
+	s.TotalLoadTime += other.TotalLoadTime
+	return s
+}
+
+// 缓存统计计数器
+// 线程安全，可以被多个缓存共享，比如分片缓存的所有分片
+// 所有方法都可以在nil上调用，表示不统计
+type StatsCounter struct {
+	hits          uint64
+	misses        uint64
+	puts          uint64
+	updates       uint64
+	evictions     [evictReasonCount]uint64
+	loadSuccesses uint64
+	loadFailures  uint64
+	totalLoadTime int64
+}
+
+func NewStatsCounter() *StatsCounter {
+	return &StatsCounter{}
+}
+
+// 记录命中
+func (c *StatsCounter) RecordHit() {
+	if c != nil {
+		atomic.AddUint64(&c.hits, 1)
+	}
+}
+
+// 记录未命中
+func (c *StatsCounter) RecordMiss() {
+	if c != nil {
+		atomic.AddUint64(&c.misses, 1)
+	}
+}
+
+// 记录添加新元素
+func (c *StatsCounter) RecordPut() {
+	if c != nil {
+		atomic.AddUint64(&c.puts, 1)
+	}
+}
+
+// 记录更新已存在元素
+func (c *StatsCounter) RecordUpdate() {
+	if c != nil {
+		atomic.AddUint64(&c.updates, 1)
+	}
+}
+
+// 记录淘汰
+func (c *StatsCounter) RecordEviction(reason EvictReason) {
+	if c != nil {
+		atomic.AddUint64(&c.evictions[reason], 1)
+	}
+}
+
+// 记录加载成功
+func (c *StatsCounter) RecordLoadSuccess(loadTime time.Duration) {
+	if c != nil {
+		atomic.AddUint64(&c.loadSuccesses, 1)
+		atomic.AddInt64(&c.totalLoadTime, int64(loadTime))
+	}
+}
+
+// 记录加载失败
+func (c *StatsCounter) RecordLoadFailure(loadTime time.Duration) {
+	if c != nil {
+		atomic.AddUint64(&c.loadFailures, 1)
+		atomic.AddInt64(&c.totalLoadTime, int64(loadTime))
+	}
+}
+
+// 获取统计信息快照
+func (c *StatsCounter) Snapshot() Stats {
+	var s Stats
+	if c == nil {
+		return s
+	}
+	s.Hits = atomic.LoadUint64(&c.hits)
+	s.Misses = atomic.LoadUint64(&c.misses)
+	s.Puts = atomic.LoadUint64(&c.puts)
+	s.Updates = atomic.LoadUint64(&c.updates)
+	for i := range s.Evictions {
+		s.Evictions[i] = atomic.LoadUint64(&c.evictions[i])
+	}
+	s.LoadSuccesses = atomic.LoadUint64(&c.loadSuccesses)
+	s.LoadFailures = atomic.LoadUint64(&c.loadFailures)
+	s.TotalLoadTime = time.Duration(atomic.LoadInt64(&c.totalLoadTime))
+	return s
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"sync"
+)
+
+// 内存存储，主要用于测试
+// 线程安全
+type MemoryStore[K comparable, V any] struct {
+	data  map[K]V
+	mutex sync.RWMutex
+}
+
+func NewMemoryStore[K comparable, V any]() *MemoryStore[K, V] {
+	return &MemoryStore[K, V]{
+		data: make(map[K]V),
+	}
+}
+
+// 获取元素，不存在返回ErrNotFound
+func (s *MemoryStore[K, V]) Get(ctx context.Context, key K) (V, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	value, ok := s.data[key]
+	if !ok {
+		return value, ErrNotFound
+	}
+	return value, nil
+}
+
+// 添加或更新元素
+func (s *MemoryStore[K, V]) Put(ctx context.Context, key K, value V) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.data[key] = value
+	return nil
+}
+
+// 删除元素，不存在也返回成功
+func (s *MemoryStore[K, V]) Delete(ctx context.Context, key K) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.data, key)
+	return nil
+}
+
+// 元素个数
+func (s *MemoryStore[K, V]) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.data)
+}
@@ -0,0 +1,22 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore[string, int]()
+	if _, err := s.Get(ctx, "11"); err != ErrNotFound {
+		t.Errorf("Get() error = %v, want %v", err, ErrNotFound)
+	}
+	s.Put(ctx, "11", 5)
+	if value, err := s.Get(ctx, "11"); err != nil || value != 5 {
+		t.Errorf("Get() = %v, %v, want %v, %v", value, err, 5, nil)
+	}
+	s.Delete(ctx, "11")
+	if err := s.Delete(ctx, "11"); err != nil || s.Len() != 0 {
+		t.Errorf("Delete() error = %v, Len() = %v, want %v, %v", err, s.Len(), nil, 0)
+	}
+}
@@ -0,0 +1,368 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jiaxwu/gommon/cache"
+)
+
+// 后端存储中不存在该元素
+var ErrNotFound = errors.New("not found")
+
+// 后端存储，比如数据库
+type Store[K comparable, V any] interface {
+	// 获取元素，不存在返回ErrNotFound
+	Get(ctx context.Context, key K) (V, error)
+	// 添加或更新元素
+	Put(ctx context.Context, key K, value V) error
+	// 删除元素，不存在也返回成功
+	Delete(ctx context.Context, key K) error
+}
+
+// 写模式
+type Mode int
+
+const (
+	WriteThrough Mode = iota // 写穿，同步更新后端存储和缓存
+	WriteBehind              // 写回，先更新缓存，脏数据在定时刷新、被淘汰或者Flush()时写入后端存储
+)
+
+const (
+	// 默认写失败重试次数
+	defaultRetries = 2
+	// 默认重试间隔
+	defaultRetryInterval = time.Millisecond * 100
+)
+
+// 写回模式下写后端存储失败时调用，已经重试过
+type OnError[K comparable] func(key K, err error)
+
+// 还没写入后端存储的修改
+type dirtyEntry[V any] struct {
+	value   V
+	deleted bool // 是否是删除
+}
+
+// 单个Key的写锁，没有人使用时删除
+type keyMutex struct {
+	mutex sync.Mutex
+	refs  int // 正在使用的次数
+}
+
+// 带后端存储的缓存
+// 读不命中时从后端存储加载，写根据模式同步或者异步写入后端存储
+// 同一个Key按顺序写入后端存储，不同Key可以并发写入
+// 写回模式下被淘汰的脏数据在后台写入，不阻塞 Put() 和 Get()
+// 会覆盖缓存策略的 OnEvict，缓存策略只能通过该结构访问
+// 线程安全
+type Cache[K comparable, V any] struct {
+	policy        cache.Policy[K, V]
+	store         Store[K, V]
+	mode          Mode
+	mutex         sync.Mutex           // 保护除了keyMutex以外的所有字段
+	keyMutexes    map[K]*keyMutex      // 每个Key的写锁，保证同一个Key的写入顺序
+	dirty         map[K]*dirtyEntry[V] // 写回模式下还没写入后端存储的修改
+	evicted       []K                  // 被淘汰的脏数据，需要尽快写入后端存储
+	flushing      bool                 // 是否正在后台写入被淘汰的脏数据
+	flushers      sync.WaitGroup       // 后台写入被淘汰的脏数据的goroutine
+	version       uint64               // 修改次数，加载期间有修改则不放入缓存，避免覆盖新值
+	retries       int                  // 写失败重试次数
+	retryInterval time.Duration        // 重试间隔
+	onError       OnError[K]
+}
+
+func New[K comparable, V any](policy cache.Policy[K, V], store Store[K, V], mode Mode) *Cache[K, V] {
+	c := &Cache[K, V]{
+		policy:        policy,
+		store:         store,
+		mode:          mode,
+		keyMutexes:    make(map[K]*keyMutex),
+		dirty:         make(map[K]*dirtyEntry[V]),
+		retries:       defaultRetries,
+		retryInterval: defaultRetryInterval,
+	}
+	// 回调在c.mutex内执行，被淘汰的脏数据在操作结束后由后台写入后端存储
+	policy.SetOnEvict(func(entry *cache.Entry[K, V]) {
+		if _, ok := c.dirty[entry.Key]; ok {
+			c.evicted = append(c.evicted, entry.Key)
+		}
+	})
+	return c
+}
+
+// 设置写回模式下写失败的重试次数和重试间隔
+func (c *Cache[K, V]) SetRetry(retries int, interval time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.retries = retries
+	c.retryInterval = interval
+}
+
+// 设置写回模式下写失败的回调
+func (c *Cache[K, V]) SetOnError(onError OnError[K]) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onError = onError
+}
+
+// 获取元素，缓存不存在则从后端存储加载
+// 不存在返回ErrNotFound
+func (c *Cache[K, V]) Get(ctx context.Context, key K) (V, error) {
+	c.mutex.Lock()
+	if value, ok := c.policy.Get(key); ok {
+		c.mutex.Unlock()
+		return value, nil
+	}
+	// 被淘汰但还没写入后端存储的脏数据
+	if entry, ok := c.dirty[key]; ok {
+		c.mutex.Unlock()
+		if entry.deleted {
+			var value V
+			return value, ErrNotFound
+		}
+		return entry.value, nil
+	}
+	version := c.version
+	c.mutex.Unlock()
+
+	value, err := c.store.Get(ctx, key)
+	if err != nil {
+		return value, err
+	}
+	c.mutex.Lock()
+	if c.version == version {
+		c.putPolicy(key, value)
+	}
+	c.startFlushEvicted()
+	c.mutex.Unlock()
+	return value, nil
+}
+
+// 添加或更新元素
+// 写穿模式下先写后端存储，失败返回错误，并且删除缓存中的旧值
+// 写回模式下只更新缓存，总是返回nil
+func (c *Cache[K, V]) Put(ctx context.Context, key K, value V) error {
+	if c.mode == WriteThrough {
+		c.lockKey(key)
+		defer c.unlockKey(key)
+		err := c.store.Put(ctx, key, value)
+		c.mutex.Lock()
+		c.version++
+		if err != nil {
+			c.policy.Remove(key)
+		} else {
+			c.putPolicy(key, value)
+		}
+		c.mutex.Unlock()
+		return err
+	}
+
+	c.mutex.Lock()
+	c.version++
+	c.dirty[key] = &dirtyEntry[V]{value: value}
+	c.putPolicy(key, value)
+	c.startFlushEvicted()
+	c.mutex.Unlock()
+	return nil
+}
+
+// 删除元素
+// 写穿模式下先删除后端存储，失败返回错误
+// 写回模式下只删除缓存，总是返回nil
+func (c *Cache[K, V]) Delete(ctx context.Context, key K) error {
+	if c.mode == WriteThrough {
+		c.lockKey(key)
+		defer c.unlockKey(key)
+		if err := c.store.Delete(ctx, key); err != nil {
+			return err
+		}
+		c.mutex.Lock()
+		c.version++
+		c.policy.Remove(key)
+		c.mutex.Unlock()
+		return nil
+	}
+
+	c.mutex.Lock()
+	c.version++
+	c.dirty[key] = &dirtyEntry[V]{deleted: true}
+	c.policy.Remove(key)
+	c.mutex.Unlock()
+	return nil
+}
+
+// 把所有脏数据写入后端存储
+// 写失败的元素会调用 OnError，并且保留到下一次刷新，返回第一个错误
+func (c *Cache[K, V]) Flush(ctx context.Context) error {
+	c.mutex.Lock()
+	keys := make([]K, 0, len(c.dirty))
+	for key := range c.dirty {
+		keys = append(keys, key)
+	}
+	c.evicted = nil
+	c.mutex.Unlock()
+	return c.flush(ctx, keys)
+}
+
+// 在后台定时把脏数据写入后端存储，直到ctx被关闭
+// 关闭后剩余的脏数据需要调用 Flush() 写入
+func (c *Cache[K, V]) StartFlusher(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.Flush(ctx)
+			}
+		}
+	}()
+}
+
+// 缓存中的元素个数
+func (c *Cache[K, V]) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.policy.Len()
+}
+
+// 还没写入后端存储的脏数据个数
+func (c *Cache[K, V]) Dirty() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.dirty)
+}
+
+// 有被淘汰的脏数据时启动后台写入，已经在写入则由它继续写入
+// 调用时需要持有c.mutex
+func (c *Cache[K, V]) startFlushEvicted() {
+	if len(c.evicted) == 0 || c.flushing {
+		return
+	}
+	c.flushing = true
+	c.flushers.Add(1)
+	go c.flushEvicted()
+}
+
+// 在后台把被淘汰的脏数据写入后端存储，直到没有被淘汰的脏数据
+// 调用方的ctx可能已经被关闭，所以使用新的ctx
+func (c *Cache[K, V]) flushEvicted() {
+	defer c.flushers.Done()
+	for {
+		c.mutex.Lock()
+		keys := c.evicted
+		c.evicted = nil
+		if len(keys) == 0 {
+			c.flushing = false
+			c.mutex.Unlock()
+			return
+		}
+		c.mutex.Unlock()
+		c.flush(context.Background(), keys)
+	}
+}
+
+// 把给定Key的脏数据写入后端存储
+// 写入期间元素又被修改则保留新的脏数据
+func (c *Cache[K, V]) flush(ctx context.Context, keys []K) error {
+	var firstErr error
+	for _, key := range keys {
+		err := c.flushKey(ctx, key)
+		if err == nil {
+			continue
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return firstErr
+}
+
+// 把一个Key的脏数据写入后端存储，持有Key的写锁，保证同一个Key的写入顺序
+func (c *Cache[K, V]) flushKey(ctx context.Context, key K) error {
+	c.lockKey(key)
+	defer c.unlockKey(key)
+	c.mutex.Lock()
+	entry, ok := c.dirty[key]
+	retries, retryInterval, onError := c.retries, c.retryInterval, c.onError
+	c.mutex.Unlock()
+	if !ok {
+		return nil
+	}
+
+	err := c.write(ctx, key, entry, retries, retryInterval)
+	if err != nil {
+		if onError != nil {
+			onError(key, err)
+		}
+		return err
+	}
+	c.mutex.Lock()
+	if c.dirty[key] == entry {
+		delete(c.dirty, key)
+	}
+	c.mutex.Unlock()
+	return nil
+}
+
+// 获取Key的写锁
+func (c *Cache[K, V]) lockKey(key K) {
+	c.mutex.Lock()
+	m, ok := c.keyMutexes[key]
+	if !ok {
+		m = &keyMutex{}
+		c.keyMutexes[key] = m
+	}
+	m.refs++
+	c.mutex.Unlock()
+	m.mutex.Lock()
+}
+
+// 释放Key的写锁，没有人使用时删除
+func (c *Cache[K, V]) unlockKey(key K) {
+	c.mutex.Lock()
+	m := c.keyMutexes[key]
+	m.refs--
+	if m.refs == 0 {
+		delete(c.keyMutexes, key)
+	}
+	c.mutex.Unlock()
+	m.mutex.Unlock()
+}
+
+// 写入后端存储，失败会重试
+func (c *Cache[K, V]) write(ctx context.Context, key K, entry *dirtyEntry[V], retries int, retryInterval time.Duration) error {
+	for i := 0; ; i++ {
+		var err error
+		if entry.deleted {
+			err = c.store.Delete(ctx, key)
+		} else {
+			err = c.store.Put(ctx, key, entry.value)
+		}
+		if err == nil || i >= retries {
+			return err
+		}
+		timer := time.NewTimer(retryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// 添加到缓存，缓存拒绝添加时删除旧值，避免读到旧值
+func (c *Cache[K, V]) putPolicy(key K, value V) {
+	if evicted := c.policy.Put(key, value); evicted != nil && evicted.Key == key {
+		c.policy.Remove(key)
+	}
+}
@@ -0,0 +1,308 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jiaxwu/gommon/cache/lru"
+)
+
+var errStore = errors.New("store error")
+
+// 可以模拟失败的存储，记录写入次数
+type flakyStore struct {
+	*MemoryStore[string, int]
+	mutex    sync.Mutex
+	failures int // 接下来失败的次数，小于0表示一直失败
+	writes   int
+}
+
+func newFlakyStore() *flakyStore {
+	return &flakyStore{MemoryStore: NewMemoryStore[string, int]()}
+}
+
+func (s *flakyStore) setFailures(failures int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failures = failures
+}
+
+func (s *flakyStore) fail() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.writes++
+	if s.failures == 0 {
+		return false
+	}
+	if s.failures > 0 {
+		s.failures--
+	}
+	return true
+}
+
+func (s *flakyStore) Put(ctx context.Context, key string, value int) error {
+	if s.fail() {
+		return errStore
+	}
+	return s.MemoryStore.Put(ctx, key, value)
+}
+
+func (s *flakyStore) Delete(ctx context.Context, key string) error {
+	if s.fail() {
+		return errStore
+	}
+	return s.MemoryStore.Delete(ctx, key)
+}
+
+func TestCache_WriteThrough(t *testing.T) {
+	ctx := context.Background()
+	s := newFlakyStore()
+	c := New[string, int](lru.New[string, int](2), s, WriteThrough)
+	if err := c.Put(ctx, "11", 5); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if value, err := s.Get(ctx, "11"); err != nil || value != 5 {
+		t.Errorf("store.Get() = %v, %v, want %v, %v", value, err, 5, nil)
+	}
+
+	// 缓存被淘汰后从后端存储加载
+	c.Put(ctx, "22", 6)
+	c.Put(ctx, "33", 7)
+	if value, err := c.Get(ctx, "11"); err != nil || value != 5 {
+		t.Errorf("Get() = %v, %v, want %v, %v", value, err, 5, nil)
+	}
+	if _, err := c.Get(ctx, "44"); err != ErrNotFound {
+		t.Errorf("Get() error = %v, want %v", err, ErrNotFound)
+	}
+
+	// 写失败时返回错误，并且删除缓存中的旧值
+	s.setFailures(1)
+	if err := c.Put(ctx, "11", 8); err != errStore {
+		t.Errorf("Put() error = %v, want %v", err, errStore)
+	}
+	if c.policy.Contains("11") {
+		t.Errorf("Contains() = %v, want %v", true, false)
+	}
+	if value, err := c.Get(ctx, "11"); err != nil || value != 5 {
+		t.Errorf("Get() = %v, %v, want %v, %v", value, err, 5, nil)
+	}
+
+	if err := c.Delete(ctx, "11"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := c.Get(ctx, "11"); err != ErrNotFound {
+		t.Errorf("Get() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestCache_WriteBehind(t *testing.T) {
+	ctx := context.Background()
+	s := newFlakyStore()
+	c := New[string, int](lru.New[string, int](10), s, WriteBehind)
+	c.Put(ctx, "11", 5)
+	c.Put(ctx, "11", 6)
+	c.Put(ctx, "22", 7)
+	s.MemoryStore.Put(ctx, "33", 8)
+	c.Delete(ctx, "33")
+
+	// 刷新前不写后端存储，删除的元素也读不到
+	if s.Len() != 1 || c.Dirty() != 3 {
+		t.Errorf("store.Len() = %v, Dirty() = %v, want %v, %v", s.Len(), c.Dirty(), 1, 3)
+	}
+	if _, err := c.Get(ctx, "33"); err != ErrNotFound {
+		t.Errorf("Get() error = %v, want %v", err, ErrNotFound)
+	}
+
+	// 同一个Key的多次修改只写一次
+	if err := c.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if s.writes != 3 || c.Dirty() != 0 {
+		t.Errorf("writes = %v, Dirty() = %v, want %v, %v", s.writes, c.Dirty(), 3, 0)
+	}
+	if value, err := s.Get(ctx, "11"); err != nil || value != 6 {
+		t.Errorf("store.Get() = %v, %v, want %v, %v", value, err, 6, nil)
+	}
+	if _, err := s.Get(ctx, "33"); err != ErrNotFound {
+		t.Errorf("store.Get() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestCache_WriteBehindOnEvict(t *testing.T) {
+	ctx := context.Background()
+	s := newFlakyStore()
+	c := New[string, int](lru.New[string, int](2), s, WriteBehind)
+	c.Put(ctx, "11", 5)
+	c.Put(ctx, "22", 6)
+	c.Put(ctx, "33", 7)
+
+	// 被淘汰的脏数据马上在后台写入后端存储
+	c.flushers.Wait()
+	if value, err := s.Get(ctx, "11"); err != nil || value != 5 {
+		t.Errorf("store.Get() = %v, %v, want %v, %v", value, err, 5, nil)
+	}
+	if s.Len() != 1 || c.Dirty() != 2 {
+		t.Errorf("store.Len() = %v, Dirty() = %v, want %v, %v", s.Len(), c.Dirty(), 1, 2)
+	}
+
+	// 写入失败的脏数据保留，仍然可以读到
+	c.SetRetry(0, 0)
+	s.setFailures(-1)
+	c.Put(ctx, "44", 8)
+	c.flushers.Wait()
+	if value, err := c.Get(ctx, "22"); err != nil || value != 6 {
+		t.Errorf("Get() = %v, %v, want %v, %v", value, err, 6, nil)
+	}
+	if c.Dirty() != 3 {
+		t.Errorf("Dirty() = %v, want %v", c.Dirty(), 3)
+	}
+}
+
+// 写入给定Key时阻塞，直到unblock被关闭
+type blockingStore struct {
+	*MemoryStore[string, int]
+	key     string
+	blocked chan struct{}
+	unblock chan struct{}
+}
+
+func newBlockingStore(key string) *blockingStore {
+	return &blockingStore{
+		MemoryStore: NewMemoryStore[string, int](),
+		key:         key,
+		blocked:     make(chan struct{}),
+		unblock:     make(chan struct{}),
+	}
+}
+
+func (s *blockingStore) Put(ctx context.Context, key string, value int) error {
+	if key == s.key {
+		close(s.blocked)
+		<-s.unblock
+	}
+	return s.MemoryStore.Put(ctx, key, value)
+}
+
+func TestCache_WriteNotBlocked(t *testing.T) {
+	ctx := context.Background()
+
+	// 写穿模式下一个Key写入阻塞时不影响其他Key
+	s := newBlockingStore("11")
+	c := New[string, int](lru.New[string, int](10), s, WriteThrough)
+	done := make(chan struct{})
+	go func() {
+		c.Put(ctx, "11", 5)
+		close(done)
+	}()
+	<-s.blocked
+	if err := c.Put(ctx, "22", 6); err != nil {
+		t.Errorf("Put() error = %v, want %v", err, nil)
+	}
+	close(s.unblock)
+	<-done
+	if value, err := c.Get(ctx, "11"); err != nil || value != 5 {
+		t.Errorf("Get() = %v, %v, want %v, %v", value, err, 5, nil)
+	}
+
+	// 写回模式下被淘汰的脏数据在后台写入，不阻塞 Put()
+	s = newBlockingStore("11")
+	c = New[string, int](lru.New[string, int](1), s, WriteBehind)
+	c.Put(ctx, "11", 5)
+	c.Put(ctx, "22", 6)
+	<-s.blocked
+	c.Put(ctx, "33", 7)
+	if value, err := c.Get(ctx, "11"); err != nil || value != 5 {
+		t.Errorf("Get() = %v, %v, want %v, %v", value, err, 5, nil)
+	}
+	close(s.unblock)
+	c.flushers.Wait()
+	if s.Len() != 2 || c.Dirty() != 1 {
+		t.Errorf("store.Len() = %v, Dirty() = %v, want %v, %v", s.Len(), c.Dirty(), 2, 1)
+	}
+}
+
+func TestCache_Retry(t *testing.T) {
+	ctx := context.Background()
+	s := newFlakyStore()
+	c := New[string, int](lru.New[string, int](10), s, WriteBehind)
+	var failed []string
+	c.SetOnError(func(key string, err error) {
+		failed = append(failed, key)
+	})
+	c.SetRetry(2, time.Millisecond)
+
+	// 重试后成功
+	s.setFailures(2)
+	c.Put(ctx, "11", 5)
+	if err := c.Flush(ctx); err != nil || len(failed) != 0 {
+		t.Errorf("Flush() error = %v, failed = %v, want %v, %v", err, failed, nil, 0)
+	}
+
+	// 重试后还是失败，调用 OnError 并且保留到下一次刷新
+	s.setFailures(3)
+	c.Put(ctx, "22", 6)
+	if err := c.Flush(ctx); err != errStore || len(failed) != 1 || failed[0] != "22" {
+		t.Errorf("Flush() error = %v, failed = %v, want %v, %v", err, failed, errStore, []string{"22"})
+	}
+	if c.Dirty() != 1 {
+		t.Errorf("Dirty() = %v, want %v", c.Dirty(), 1)
+	}
+	if err := c.Flush(ctx); err != nil || c.Dirty() != 0 {
+		t.Errorf("Flush() error = %v, Dirty() = %v, want %v, %v", err, c.Dirty(), nil, 0)
+	}
+
+	// ctx被关闭时停止重试
+	s.setFailures(-1)
+	c.SetRetry(100, time.Hour)
+	c.Put(ctx, "33", 7)
+	ctx, cancel := context.WithTimeout(ctx, time.Millisecond*10)
+	defer cancel()
+	if err := c.Flush(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Flush() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestCache_StartFlusher(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := newFlakyStore()
+	c := New[string, int](lru.New[string, int](10), s, WriteBehind)
+	c.StartFlusher(ctx, time.Millisecond*10)
+	c.Put(ctx, "11", 5)
+	time.Sleep(time.Millisecond * 50)
+
+	if value, err := s.Get(ctx, "11"); err != nil || value != 5 || c.Dirty() != 0 {
+		t.Errorf("store.Get() = %v, %v, Dirty() = %v, want %v, %v, %v", value, err, c.Dirty(), 5, nil, 0)
+	}
+}
+
+func TestCache_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore[int, int]()
+	c := New[int, int](lru.New[int, int](10), s, WriteBehind)
+	c.StartFlusher(ctx, time.Millisecond)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				c.Put(ctx, j%100, j)
+				c.Get(ctx, (i+j)%100)
+			}
+		}(i)
+	}
+	wg.Wait()
+	c.Flush(ctx)
+
+	// 最后写入的值和后端存储一致
+	for i := 0; i < 100; i++ {
+		value, err := c.Get(ctx, i)
+		if stored, _ := s.Get(ctx, i); err != nil || value != stored {
+			t.Errorf("Get(%v) = %v, %v, want %v", i, value, err, stored)
+		}
+	}
+}
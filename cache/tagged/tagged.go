@@ -0,0 +1,186 @@
+package tagged
+
+import (
+	"github.com/jiaxwu/gommon/cache"
+)
+
+// 元素的标签和命名空间
+type meta struct {
+	tags       []string
+	namespace  string
+	generation uint64 // 添加时命名空间的版本，和当前版本不同说明已经失效
+}
+
+// 支持按标签和命名空间批量失效的缓存
+// 按标签失效会立即删除所有带该标签的元素
+// 按命名空间失效只增加命名空间的版本，是O(1)的，旧版本的元素在访问时才删除，或者等待被淘汰
+// 失效删除的元素和 Remove() 一样不触发 OnEvict
+// 非线程安全，请根据业务加锁
+type Cache[K comparable, V any] struct {
+	policy      cache.Policy[K, V]
+	metas       map[K]*meta           // 只保存有标签或者命名空间的元素
+	tags        map[string]map[K]bool // 标签到元素的索引
+	generations map[string]uint64     // 命名空间的当前版本
+	onEvict     cache.OnEvict[K, V]
+}
+
+// policy：会覆盖它的 OnEvict，只能通过该结构访问
+func New[K comparable, V any](policy cache.Policy[K, V]) *Cache[K, V] {
+	c := &Cache[K, V]{
+		policy:      policy,
+		metas:       make(map[K]*meta),
+		tags:        make(map[string]map[K]bool),
+		generations: make(map[string]uint64),
+	}
+	// 被淘汰时清理标签索引
+	policy.SetOnEvict(func(entry *cache.Entry[K, V]) {
+		c.untrack(entry.Key)
+		if c.onEvict != nil {
+			c.onEvict(entry)
+		}
+	})
+	return c
+}
+
+// 设置 OnEvict
+func (c *Cache[K, V]) SetOnEvict(onEvict cache.OnEvict[K, V]) {
+	c.onEvict = onEvict
+}
+
+// 添加或更新元素，会清除原来的标签和命名空间
+// 返回被淘汰的元素
+func (c *Cache[K, V]) Put(key K, value V) *cache.Entry[K, V] {
+	return c.put("", key, value, nil)
+}
+
+// 添加或更新元素，并设置标签，会替换原来的标签
+// 返回被淘汰的元素
+func (c *Cache[K, V]) PutWithTags(key K, value V, tags ...string) *cache.Entry[K, V] {
+	return c.put("", key, value, tags)
+}
+
+// 添加或更新元素到命名空间，可以同时设置标签
+// 返回被淘汰的元素
+func (c *Cache[K, V]) PutWithNamespace(namespace string, key K, value V, tags ...string) *cache.Entry[K, V] {
+	return c.put(namespace, key, value, tags)
+}
+
+// 获取元素，命名空间已经失效的元素会被删除
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	if c.stale(key) {
+		c.Remove(key)
+		var value V
+		return value, false
+	}
+	return c.policy.Get(key)
+}
+
+// 获取元素，不更新状态
+func (c *Cache[K, V]) Peek(key K) (V, bool) {
+	if c.stale(key) {
+		var value V
+		return value, false
+	}
+	return c.policy.Peek(key)
+}
+
+// 是否包含元素，不更新状态
+func (c *Cache[K, V]) Contains(key K) bool {
+	return !c.stale(key) && c.policy.Contains(key)
+}
+
+// 移除元素
+func (c *Cache[K, V]) Remove(key K) bool {
+	c.untrack(key)
+	return c.policy.Remove(key)
+}
+
+// 删除所有带该标签的元素，返回删除的元素个数
+func (c *Cache[K, V]) InvalidateTag(tag string) int {
+	n := 0
+	for key := range c.tags[tag] {
+		if c.Remove(key) {
+			n++
+		}
+	}
+	delete(c.tags, tag)
+	return n
+}
+
+// 使命名空间的所有元素失效，O(1)
+func (c *Cache[K, V]) InvalidateNamespace(namespace string) {
+	c.generations[namespace]++
+}
+
+// 获取元素的标签
+func (c *Cache[K, V]) Tags(key K) []string {
+	if m, ok := c.metas[key]; ok && !c.stale(key) {
+		return append([]string(nil), m.tags...)
+	}
+	return nil
+}
+
+// 元素个数，包括命名空间已经失效但还没删除的元素
+func (c *Cache[K, V]) Len() int {
+	return c.policy.Len()
+}
+
+// 清空缓存
+func (c *Cache[K, V]) Clear(needOnEvict bool) {
+	c.policy.Clear(needOnEvict)
+	c.metas = make(map[K]*meta)
+	c.tags = make(map[string]map[K]bool)
+}
+
+// 添加或更新元素，并记录标签和命名空间
+func (c *Cache[K, V]) put(namespace string, key K, value V, tags []string) *cache.Entry[K, V] {
+	c.untrack(key)
+	evicted := c.policy.Put(key, value)
+	// 拒绝添加时可能还保留着旧值，它的标签已经清除，一起删除
+	if evicted != nil && evicted.Key == key {
+		c.policy.Remove(key)
+		return evicted
+	}
+	// 可能直接被拒绝，比如超过容量
+	if (namespace == "" && len(tags) == 0) || !c.policy.Contains(key) {
+		return evicted
+	}
+
+	m := &meta{
+		tags:       append([]string(nil), tags...),
+		namespace:  namespace,
+		generation: c.generations[namespace],
+	}
+	c.metas[key] = m
+	for _, tag := range m.tags {
+		keys, ok := c.tags[tag]
+		if !ok {
+			keys = make(map[K]bool)
+			c.tags[tag] = keys
+		}
+		keys[key] = true
+	}
+	return evicted
+}
+
+// 命名空间是否已经失效
+func (c *Cache[K, V]) stale(key K) bool {
+	m, ok := c.metas[key]
+	return ok && m.namespace != "" && m.generation != c.generations[m.namespace]
+}
+
+// 清理元素的标签索引
+func (c *Cache[K, V]) untrack(key K) {
+	m, ok := c.metas[key]
+	if !ok {
+		return
+	}
+	delete(c.metas, key)
+	for _, tag := range m.tags {
+		keys := c.tags[tag]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+}
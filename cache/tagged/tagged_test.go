@@ -0,0 +1,133 @@
+package tagged
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/cache/lru"
+	"github.com/jiaxwu/gommon/cache/tinylfu"
+)
+
+func TestCache_InvalidateTag(t *testing.T) {
+	c := New[string, int](lru.New[string, int](10))
+	c.PutWithTags("a", 1, "user:1", "org:1")
+	c.PutWithTags("b", 2, "user:1")
+	c.PutWithTags("c", 3, "org:1")
+	c.Put("d", 4)
+
+	if n := c.InvalidateTag("user:1"); n != 2 {
+		t.Errorf("InvalidateTag() = %v, want %v", n, 2)
+	}
+	if c.Contains("a") || c.Contains("b") || !c.Contains("c") || !c.Contains("d") {
+		t.Errorf("Contains() wrong after InvalidateTag()")
+	}
+	if n := c.InvalidateTag("user:1"); n != 0 {
+		t.Errorf("InvalidateTag() = %v, want %v", n, 0)
+	}
+	if n := c.InvalidateTag("org:1"); n != 1 || c.Len() != 1 {
+		t.Errorf("InvalidateTag() = %v, Len() = %v, want %v, %v", n, c.Len(), 1, 1)
+	}
+}
+
+func TestCache_ReplaceTags(t *testing.T) {
+	c := New[string, int](lru.New[string, int](10))
+	c.PutWithTags("a", 1, "x")
+	c.PutWithTags("a", 2, "y")
+	if n := c.InvalidateTag("x"); n != 0 {
+		t.Errorf("InvalidateTag() = %v, want %v", n, 0)
+	}
+	if tags := c.Tags("a"); len(tags) != 1 || tags[0] != "y" {
+		t.Errorf("Tags() = %v, want %v", tags, []string{"y"})
+	}
+	c.Put("a", 3)
+	if tags := c.Tags("a"); tags != nil || len(c.tags) != 0 {
+		t.Errorf("Tags() = %v, want %v", tags, nil)
+	}
+}
+
+func TestCache_InvalidateNamespace(t *testing.T) {
+	c := New[string, int](lru.New[string, int](10))
+	c.PutWithNamespace("user:1", "a", 1)
+	c.PutWithNamespace("user:1", "b", 2, "x")
+	c.PutWithNamespace("user:2", "c", 3)
+
+	c.InvalidateNamespace("user:1")
+	// 延迟删除，访问前还在缓存中
+	if c.Len() != 3 {
+		t.Errorf("Len() = %v, want %v", c.Len(), 3)
+	}
+	if _, ok := c.Peek("a"); ok || c.Contains("b") || c.Tags("b") != nil {
+		t.Errorf("Peek() = %v, want %v", ok, false)
+	}
+	if _, ok := c.Get("a"); ok || c.Len() != 2 {
+		t.Errorf("Get() = %v, Len() = %v, want %v, %v", ok, c.Len(), false, 2)
+	}
+	if value, ok := c.Get("c"); !ok || value != 3 {
+		t.Errorf("Get() = %v, %v, want %v, %v", value, ok, 3, true)
+	}
+
+	// 失效后添加的元素属于新版本
+	c.PutWithNamespace("user:1", "a", 4)
+	if value, ok := c.Get("a"); !ok || value != 4 {
+		t.Errorf("Get() = %v, %v, want %v, %v", value, ok, 4, true)
+	}
+}
+
+func TestCache_OnEvict(t *testing.T) {
+	c := New[string, int](lru.New[string, int](2))
+	var evicted []string
+	c.SetOnEvict(func(entry *cache.Entry[string, int]) {
+		evicted = append(evicted, entry.Key)
+	})
+	c.PutWithTags("a", 1, "x")
+	c.PutWithTags("b", 2, "x")
+	c.PutWithTags("c", 3, "y")
+
+	// 被淘汰时清理标签索引
+	if len(evicted) != 1 || evicted[0] != "a" {
+		t.Errorf("evicted = %v, want %v", evicted, []string{"a"})
+	}
+	if _, ok := c.metas["a"]; ok || len(c.tags["x"]) != 1 {
+		t.Errorf("len(tags[x]) = %v, want %v", len(c.tags["x"]), 1)
+	}
+
+	// 失效删除不触发 OnEvict
+	c.InvalidateTag("x")
+	if len(evicted) != 1 {
+		t.Errorf("len(evicted) = %v, want %v", len(evicted), 1)
+	}
+
+	c.Clear(true)
+	if len(evicted) != 2 || len(c.metas) != 0 || len(c.tags) != 0 {
+		t.Errorf("len(evicted) = %v, want %v", len(evicted), 2)
+	}
+}
+
+func TestCache_Rejected(t *testing.T) {
+	c := New[int, int](tinylfu.New[int, int](func(key int) []byte {
+		return []byte(strconv.Itoa(key))
+	}, 100))
+	for i := 0; i < 1000; i++ {
+		c.PutWithTags(i, i, "x")
+		c.Get(i % 50)
+	}
+	// 被拒绝或者淘汰的元素不会留在标签索引中
+	if len(c.tags["x"]) != c.Len() || len(c.metas) != c.Len() {
+		t.Errorf("len(tags[x]) = %v, Len() = %v", len(c.tags["x"]), c.Len())
+	}
+}
+
+func TestCache_RejectedUpdate(t *testing.T) {
+	policy := lru.New[string, int](10)
+	policy.SetWeigher(func(key string, value int) int64 {
+		return int64(value)
+	})
+	c := New[string, int](policy)
+	c.PutWithTags("a", 1, "x")
+	// 更新被拒绝时旧值和它的标签一起删除
+	c.PutWithTags("a", 20, "y")
+	if c.Contains("a") || len(c.tags["x"]) != 0 || len(c.tags["y"]) != 0 {
+		t.Errorf("Contains() = %v, want %v", c.Contains("a"), false)
+	}
+}
@@ -2,6 +2,7 @@ package tinylfu
 
 import (
 	"hash/fnv"
+	"io"
 
 	"github.com/jiaxwu/gommon/math"
 
@@ -13,8 +14,10 @@ import (
 )
 
 const (
-	// 窗口缓存比例
+	// 窗口缓存的初始比例
 	windowPercentage = 0.01
+	// 窗口缓存的最大比例
+	maxWindowPercentage = 0.8
 	// 过滤器错误率
 	filterFalsePositiveRate = 0.01
 	// 计数器错误范围
@@ -23,46 +26,135 @@ const (
 	counterErrorRate = 0.01
 	// 采样因子
 	samplesFactor = 8
+	// 计数器最大值
+	maxFrequency = 15
+)
+
+const (
+	// 爬山法的采样因子，每容量的多少倍次访问调整一次窗口缓存大小
+	climberSamplesFactor = 10
+	// 每次调整的步长比例
+	climberStepPercentage = 0.0625
+	// 命中率变化超过该阈值时重新使用初始步长
+	climberRestartThreshold = 0.05
+	// 步长衰减率，命中率稳定时调整幅度逐渐变小
+	climberStepDecayRate = 0.98
+)
+
+const (
+	// 快照中的缓存策略名称
+	snapshotPolicy = "tinylfu"
+	// 窗口缓存和主缓存的快照策略名称
+	lruSnapshotPolicy  = "lru"
+	slruSnapshotPolicy = "slru"
+)
+
+// 快照中元素所在的段，主缓存的段从segmentMain开始
+const (
+	segmentWindow uint8 = iota // 窗口缓存
+	segmentMain                // 主缓存
 )
 
 // 转换成[]byte
 type BytesFunc[T comparable] func(key T) []byte
 
 // W-TinyLFU
-// 没有充分测试，只做学习参考
+// 窗口缓存默认是总容量的1%，可以通过 SetAdaptiveWindow() 开启爬山法，根据命中率自动调整窗口缓存和主缓存的大小
+// 偏向最近访问的负载窗口缓存会变大，偏向访问频率的负载窗口缓存会变小
 // 非线程安全，请根据业务加锁
 // https://arxiv.org/pdf/1512.00727v2.pdf
 type Cache[K comparable, V any] struct {
-	filter           *bloom.Filter     // 过滤器
-	counter          *cm.Counter4      // 计数器
-	window           *lru.Cache[K, V]  // 窗口缓存
-	main             *slru.Cache[K, V] // 主缓存
-	samplesThreshold uint64            // 采样阈值，到达阈值计数会减半
-	samples          uint64            // 当前采样数量
-	bytesFunc        BytesFunc[K]      // 把Key转换成Bytes的函数
+	filter           *bloom.Filter        // 过滤器
+	counter          *cm.Counter4         // 计数器
+	window           *lru.Cache[K, V]     // 窗口缓存
+	main             *slru.Cache[K, V]    // 主缓存
+	samplesThreshold uint64               // 采样阈值，到达阈值计数会减半
+	samples          uint64               // 当前采样数量
+	bytesFunc        BytesFunc[K]         // 把Key转换成Bytes的函数
+	weigher          cache.Weigher[K, V]  // 计算元素权重，为空则每个元素权重为1
+	candidates       []*cache.Entry[K, V] // 被窗口缓存淘汰，等待进入主缓存的元素
+	transferring     bool                 // 是否正在把主缓存的元素移动到窗口缓存
+	adaptive         bool                 // 是否使用爬山法调整窗口缓存大小
+	sampleHits       uint64               // 当前采样周期的命中次数
+	sampleMisses     uint64               // 当前采样周期的未命中次数
+	prevHitRate      float64              // 上一个采样周期的命中率
+	stepSize         float64              // 下一次调整窗口缓存的大小，负数表示缩小
+	onEvict          cache.OnEvictWithReason[K, V]
+	stats            *cache.StatsCounter // 统计，为空表示不统计
+	keyCodec         cache.Codec[K]      // 保存快照时Key的编解码器
+	valueCodec       cache.Codec[V]      // 保存快照时Value的编解码器
 }
 
 func New[K comparable, V any](bytesFunc BytesFunc[K], capacity int) *Cache[K, V] {
-	windowCap := math.Max(int(windowPercentage*float64(capacity)), 1)
+	windowCap := clampWindowCap(int(windowPercentage*float64(capacity)), capacity)
 	mainCap := capacity - windowCap
 
-	return &Cache[K, V]{
+	c := &Cache[K, V]{
 		filter:           bloom.New(uint64(capacity), filterFalsePositiveRate),
 		counter:          cm.New4(uint64(capacity), counterErrorRange, counterErrorRate),
 		window:           lru.New[K, V](windowCap),
 		main:             slru.New[K, V](mainCap),
 		samplesThreshold: uint64(capacity) * samplesFactor,
 		bytesFunc:        bytesFunc,
+		stepSize:         -climberStepPercentage * float64(capacity),
 	}
+	// 窗口缓存因为容量淘汰的元素作为候选者，和主缓存的元素PK
+	c.window.SetOnEvictWithReason(func(entry *cache.Entry[K, V], reason cache.EvictReason) {
+		if reason == cache.EvictReasonCapacity {
+			c.candidates = append(c.candidates, entry)
+		} else {
+			c.doOnEvict(entry, reason)
+		}
+	})
+	c.main.SetOnEvictWithReason(func(entry *cache.Entry[K, V], reason cache.EvictReason) {
+		// 增大窗口缓存时，主缓存放不下的元素移动到窗口缓存
+		if c.transferring && reason == cache.EvictReasonCapacity {
+			c.window.PutWithExpiration(entry.Key, entry.Value, entry.Expiration)
+			return
+		}
+		c.doOnEvict(entry, reason)
+	})
+	return c
 }
 
-// // 设置 OnEvict
+// 设置 OnEvict
 func (c *Cache[K, V]) SetOnEvict(onEvict cache.OnEvict[K, V]) {
-	c.main.SetOnEvict(onEvict)
+	c.onEvict = onEvict.WithReason()
+}
+
+// 设置 OnEvict，带上淘汰原因
+func (c *Cache[K, V]) SetOnEvictWithReason(onEvict cache.OnEvictWithReason[K, V]) {
+	c.onEvict = onEvict
+}
+
+// 设置是否使用爬山法根据命中率自动调整窗口缓存大小，默认关闭
+// 负载在最近访问和访问频率之间变化时适合开启，负载稳定时固定的窗口缓存命中率通常更高
+// 关闭时保持当前的窗口缓存大小
+func (c *Cache[K, V]) SetAdaptiveWindow(adaptive bool) {
+	c.adaptive = adaptive
+	c.resetClimber()
+}
+
+// 设置统计计数器，为空表示不统计
+func (c *Cache[K, V]) SetStatsCounter(stats *cache.StatsCounter) {
+	c.stats = stats
+}
+
+// 获取统计信息
+func (c *Cache[K, V]) Stats() cache.Stats {
+	return c.stats.Snapshot()
+}
+
+// 设置权重计算函数，设置后容量表示总权重，而不是元素个数
+func (c *Cache[K, V]) SetWeigher(weigher cache.Weigher[K, V]) {
+	c.weigher = weigher
+	c.main.SetWeigher(weigher)
+	c.window.SetWeigher(weigher)
+	c.admitCandidates()
 }
 
 // 添加或更新元素
-// 返回被淘汰的元素
+// 返回被淘汰的元素，如果淘汰了多个元素，返回最后一个
 func (c *Cache[K, V]) Put(key K, value V) *cache.Entry[K, V] {
 	// 计算元素哈希值
 	hash := c.hash(key)
@@ -70,28 +162,29 @@ func (c *Cache[K, V]) Put(key K, value V) *cache.Entry[K, V] {
 	// 增加元素计数
 	c.inc(hash)
 
-	// 先添加到window
-	candidate := c.window.Put(key, value)
-	if candidate == nil {
-		return nil
+	// 如果已经在main，直接更新
+	if c.main.Contains(key) {
+		c.stats.RecordUpdate()
+		return c.main.Put(key, value)
 	}
-
-	// 获取main里面的最可能被淘汰的元素
-	victim := c.main.Victim()
-	if victim == nil {
-		return c.main.Put(candidate.Key, candidate.Value)
+	if c.window.Contains(key) {
+		c.stats.RecordUpdate()
+	} else {
+		c.stats.RecordPut()
 	}
 
-	// candidate和victim进行PK
-	candidateFreq := c.estimate(c.hash(candidate.Key))
-	victimFreq := c.estimate(c.hash(victim.Key))
-	// 如果candidate胜利则加入主缓存
-	if candidateFreq > victimFreq {
-		return c.main.Put(candidate.Key, candidate.Value)
+	// 先添加到window，window放不下则删除旧值，直接和主缓存的元素PK
+	// 没能进入主缓存时只返回，不触发回调
+	if rejected := c.window.Put(key, value); rejected != nil && rejected.Key == key {
+		c.window.Remove(key)
+		evicted, ok := c.admit(rejected)
+		if !ok {
+			c.stats.RecordEviction(cache.EvictReasonCapacity)
+			return rejected
+		}
+		return evicted
 	}
-
-	// 否则就被淘汰了
-	return candidate
+	return c.admitCandidates()
 }
 
 // 获取元素
@@ -103,18 +196,20 @@ func (c *Cache[K, V]) Get(key K) (V, bool) {
 	c.inc(hash)
 
 	// 判断元素是否存在window
-	if value, ok := c.window.Get(key); ok {
-		return value, true
+	value, ok := c.window.Get(key)
+	if !ok {
+		// 判断元素是否存在main
+		value, ok = c.main.Get(key)
 	}
-
-	// 判断元素是否存在main
-	if value, ok := c.main.Get(key); ok {
-		return value, true
+	if ok {
+		c.stats.RecordHit()
+		c.sampleHits++
+	} else {
+		c.stats.RecordMiss()
+		c.sampleMisses++
 	}
-
-	// 不存在返回空值和false
-	var value V
-	return value, false
+	c.climb()
+	return value, ok
 }
 
 // 获取元素，不更新状态
@@ -167,6 +262,27 @@ func (c *Cache[K, V]) Clear(needOnEvict bool) {
 	c.filter.Clear()
 	c.counter.Attenuation(0)
 	c.samples = 0
+	c.resetClimber()
+}
+
+// 改变容量，窗口缓存和主缓存按当前比例一起调整
+// 窗口缓存淘汰的候选者没能进入主缓存时也会触发回调
+func (c *Cache[K, V]) Resize(capacity int, needOnEvict bool) {
+	windowCap := int(float64(c.window.Cap()) / float64(c.Cap()) * float64(capacity))
+	windowCap = clampWindowCap(windowCap, capacity)
+	c.resize(windowCap, capacity-windowCap)
+	c.samplesThreshold = uint64(capacity) * samplesFactor
+	c.resetClimber()
+}
+
+// 窗口缓存的容量
+func (c *Cache[K, V]) WindowCap() int {
+	return c.window.Cap()
+}
+
+// 主缓存的容量
+func (c *Cache[K, V]) MainCap() int {
+	return c.main.Cap()
 }
 
 // 元素个数
@@ -181,7 +297,214 @@ func (c *Cache[K, V]) Cap() int {
 
 // 缓存满了
 func (c *Cache[K, V]) Full() bool {
-	return c.Len() == c.Cap()
+	return c.Cost() >= int64(c.Cap())
+}
+
+// 当前总权重，没有设置权重计算函数时等于元素个数
+func (c *Cache[K, V]) Cost() int64 {
+	return c.window.Cost() + c.main.Cost()
+}
+
+// 设置保存快照时使用的编解码器，为空使用GobCodec
+func (c *Cache[K, V]) SetCodec(keyCodec cache.Codec[K], valueCodec cache.Codec[V]) {
+	c.keyCodec = keyCodec
+	c.valueCodec = valueCodec
+}
+
+// 保存快照到w，包括元素所在的段、段内的访问顺序和估算的访问频率
+func (c *Cache[K, V]) Save(w io.Writer) error {
+	return c.Snapshot().Encode(w, c.keyCodec, c.valueCodec)
+}
+
+// 从r加载快照，会先清空缓存，不触发回调
+// 快照格式不正确时缓存保持不变
+func (c *Cache[K, V]) Load(r io.Reader) error {
+	snapshot, err := cache.DecodeSnapshot(r, snapshotPolicy, c.keyCodec, c.valueCodec)
+	if err != nil {
+		return err
+	}
+	return c.Restore(snapshot)
+}
+
+// 获取快照，先是窗口缓存，然后是主缓存的淘汰段和保护段
+// 计数器不保存，只保存每个元素估算的访问频率
+// Meta[0]是窗口缓存的容量
+func (c *Cache[K, V]) Snapshot() *cache.Snapshot[K, V] {
+	snapshot := &cache.Snapshot[K, V]{
+		Policy: snapshotPolicy,
+		Meta:   []int64{int64(c.window.Cap())},
+	}
+	for _, record := range c.window.Snapshot().Records {
+		record.Segment = segmentWindow
+		snapshot.Records = append(snapshot.Records, record)
+	}
+	for _, record := range c.main.Snapshot().Records {
+		record.Segment += segmentMain
+		snapshot.Records = append(snapshot.Records, record)
+	}
+	for _, record := range snapshot.Records {
+		record.Frequency = uint64(c.estimate(c.hash(record.Entry.Key)))
+	}
+	return snapshot
+}
+
+// 从快照恢复，会先清空缓存，不触发回调
+// 跳过已经过期的元素，放不下的元素按淘汰顺序淘汰
+func (c *Cache[K, V]) Restore(snapshot *cache.Snapshot[K, V]) error {
+	if snapshot.Policy != snapshotPolicy {
+		return cache.ErrSnapshotPolicyMismatch
+	}
+	c.Clear(false)
+	// 恢复窗口缓存和主缓存的比例
+	if len(snapshot.Meta) > 0 {
+		windowCap := clampWindowCap(int(snapshot.Meta[0]), c.Cap())
+		c.resize(windowCap, c.Cap()-windowCap)
+	}
+	window := &cache.Snapshot[K, V]{Policy: lruSnapshotPolicy}
+	main := &cache.Snapshot[K, V]{Policy: slruSnapshotPolicy}
+	for _, record := range snapshot.Records {
+		// 恢复访问频率
+		hash := c.hash(record.Entry.Key)
+		for i := uint64(0); i < record.Frequency && i <= maxFrequency; i++ {
+			c.inc(hash)
+		}
+		if record.Segment == segmentWindow {
+			window.Records = append(window.Records, record)
+			continue
+		}
+		mainRecord := *record
+		mainRecord.Segment -= segmentMain
+		main.Records = append(main.Records, &mainRecord)
+	}
+	c.main.Restore(main)
+	// 窗口缓存放不下的元素作为候选者进入主缓存
+	c.window.Restore(window)
+	c.admitCandidates()
+	return nil
+}
+
+// 候选者和主缓存的元素PK，胜利的进入主缓存，失败的触发回调
+// 返回最后一个被淘汰的元素
+func (c *Cache[K, V]) admitCandidates() *cache.Entry[K, V] {
+	var evicted *cache.Entry[K, V]
+	for len(c.candidates) > 0 {
+		candidate := c.candidates[0]
+		c.candidates = c.candidates[1:]
+		e, ok := c.admit(candidate)
+		// 候选者是从窗口缓存淘汰的，没能进入主缓存也算被淘汰
+		if !ok {
+			c.doOnEvict(candidate, cache.EvictReasonCapacity)
+			e = candidate
+		}
+		if e != nil {
+			evicted = e
+		}
+	}
+	c.candidates = nil
+	return evicted
+}
+
+// 候选者和主缓存的元素PK，胜利则加入主缓存
+// 返回被淘汰的元素和候选者是否进入主缓存
+func (c *Cache[K, V]) admit(candidate *cache.Entry[K, V]) (*cache.Entry[K, V], bool) {
+	weight := c.weigh(candidate.Key, candidate.Value)
+	if weight > int64(c.main.Cap()) {
+		return nil, false
+	}
+
+	candidateFreq := c.estimate(c.hash(candidate.Key))
+	var evicted *cache.Entry[K, V]
+	for c.main.Cost()+weight > int64(c.main.Cap()) {
+		// 获取main里面的最可能被淘汰的元素
+		victim := c.main.Victim()
+		if victim == nil {
+			break
+		}
+		// candidate和victim进行PK，如果candidate失败就被淘汰了
+		victimFreq := c.estimate(c.hash(victim.Key))
+		if candidateFreq <= victimFreq {
+			return evicted, false
+		}
+		evicted = c.main.Evict()
+	}
+
+	// 如果candidate胜利则加入主缓存
+	if e := c.main.Put(candidate.Key, candidate.Value); e != nil {
+		evicted = e
+	}
+	return evicted, true
+}
+
+// 爬山法调整窗口缓存大小
+// 每个采样周期结束时，如果命中率比上个周期高，继续按上次的方向调整，否则反方向调整
+func (c *Cache[K, V]) climb() {
+	if !c.adaptive {
+		return
+	}
+	requests := c.sampleHits + c.sampleMisses
+	if requests < uint64(c.Cap())*climberSamplesFactor {
+		return
+	}
+	hitRate := float64(c.sampleHits) / float64(requests)
+	hitRateChange := hitRate - c.prevHitRate
+	amount := c.stepSize
+	if hitRateChange < 0 {
+		amount = -amount
+	}
+	// 命中率变化比较大说明负载变了，重新使用初始步长，否则步长逐渐衰减
+	if math.Abs(hitRateChange) >= climberRestartThreshold {
+		c.stepSize = climberStepPercentage * float64(c.Cap())
+		if amount < 0 {
+			c.stepSize = -c.stepSize
+		}
+	} else {
+		c.stepSize = climberStepDecayRate * amount
+	}
+	c.prevHitRate = hitRate
+	c.sampleHits, c.sampleMisses = 0, 0
+
+	windowCap := clampWindowCap(c.window.Cap()+int(amount), c.Cap())
+	if windowCap != c.window.Cap() {
+		c.resize(windowCap, c.Cap()-windowCap)
+	}
+}
+
+// 重置爬山法的状态
+func (c *Cache[K, V]) resetClimber() {
+	c.sampleHits, c.sampleMisses = 0, 0
+	c.prevHitRate = 0
+	c.stepSize = -climberStepPercentage * float64(c.Cap())
+}
+
+// 调整窗口缓存和主缓存的容量
+// 主缓存放不下的元素移动到窗口缓存，窗口缓存放不下的元素作为候选者和主缓存的元素PK
+func (c *Cache[K, V]) resize(windowCap, mainCap int) {
+	if windowCap > c.window.Cap() {
+		c.window.Resize(windowCap, false)
+		c.transferring = true
+		c.main.Resize(mainCap, false)
+		c.transferring = false
+	} else {
+		c.main.Resize(mainCap, false)
+		c.window.Resize(windowCap, false)
+	}
+	c.admitCandidates()
+}
+
+// 触发淘汰回调
+func (c *Cache[K, V]) doOnEvict(entry *cache.Entry[K, V], reason cache.EvictReason) {
+	c.stats.RecordEviction(reason)
+	if c.onEvict != nil {
+		c.onEvict(entry, reason)
+	}
+}
+
+// 计算元素权重
+func (c *Cache[K, V]) weigh(key K, value V) int64 {
+	if c.weigher == nil {
+		return 1
+	}
+	return c.weigher(key, value)
 }
 
 // 增加元素计数
@@ -208,6 +531,12 @@ func (c *Cache[K, V]) estimate(hash uint64) uint8 {
 	return freq
 }
 
+// 限制窗口缓存的容量，至少为1，并且不超过总容量的maxWindowPercentage
+func clampWindowCap(windowCap, capacity int) int {
+	maxWindowCap := math.Max(int(maxWindowPercentage*float64(capacity)), 1)
+	return math.Max(math.Min(windowCap, maxWindowCap), 1)
+}
+
 // 计算哈希值
 func (c *Cache[K, V]) hash(key K) uint64 {
 	keyBytes := c.bytesFunc(key)
@@ -1,11 +1,16 @@
 package tinylfu
 
 import (
+	"bytes"
+	"math/rand"
 	"os"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 
 	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/cache/cachetest"
 )
 
 func TestCache_Put(t *testing.T) {
@@ -28,9 +33,11 @@ func TestCache_OnEvict(t *testing.T) {
 	c := New[string, int](func(key string) []byte {
 		return []byte(key)
 	}, 3)
+	evicted := 0
 	c.SetOnEvict(func(entry *cache.Entry[string, int]) {
-		if entry.Key != "22" || entry.Value != 6 {
-			t.Errorf("OnEvict() = %v, want %v", entry.Key, "22")
+		evicted++
+		if entry.Key != "33" || entry.Value != 7 {
+			t.Errorf("OnEvict() = %v, want %v", entry.Key, "33")
 		}
 	})
 	c.Put("11", 5)
@@ -39,9 +46,10 @@ func TestCache_OnEvict(t *testing.T) {
 	c.Get("11")
 	c.Put("44", 8)
 
+	// 没能进入主缓存的候选者也会触发回调
 	value, ok := c.Get("33")
-	if value != 0 || ok {
-		t.Errorf("Get() = %v, want %v", ok, false)
+	if value != 0 || ok || evicted != 1 {
+		t.Errorf("Get() = %v, OnEvict count = %v, want %v, %v", ok, evicted, false, 1)
 	}
 }
 
@@ -123,15 +131,201 @@ func TestCache_Entries(t *testing.T) {
 	}
 }
 
-// tinylfu_test.go:159: cachePercentage=0.1%, count=206048, hitCount=30850, hitRate=14.97%
-// tinylfu_test.go:159: cachePercentage=0.3%, count=206048, hitCount=70378, hitRate=34.16%
-// tinylfu_test.go:159: cachePercentage=0.5%, count=206048, hitCount=106413, hitRate=51.64%
-// tinylfu_test.go:159: cachePercentage=0.7%, count=206048, hitCount=138563, hitRate=67.25%
-// tinylfu_test.go:159: cachePercentage=1.0%, count=206048, hitCount=170996, hitRate=82.99%
-// tinylfu_test.go:159: cachePercentage=2.0%, count=206048, hitCount=188875, hitRate=91.67%
-// tinylfu_test.go:159: cachePercentage=3.0%, count=206048, hitCount=191104, hitRate=92.75%
-// tinylfu_test.go:159: cachePercentage=5.0%, count=206048, hitCount=192629, hitRate=93.49%
-// tinylfu_test.go:159: cachePercentage=10.0%, count=206048, hitCount=192842, hitRate=93.59%
+func TestCache_SetWeigher(t *testing.T) {
+	c := New[string, []byte](func(key string) []byte {
+		return []byte(key)
+	}, 10)
+	c.SetWeigher(func(key string, value []byte) int64 {
+		return int64(len(value))
+	})
+	c.Put("11", make([]byte, 4))
+	c.Put("22", make([]byte, 4))
+	if c.Len() != 2 || c.Cost() != 8 {
+		t.Errorf("Len() = %v, Cost() = %v, want %v, %v", c.Len(), c.Cost(), 2, 8)
+	}
+
+	// 放不下需要淘汰
+	c.Put("33", make([]byte, 6))
+	if c.Cost() > int64(c.Cap()) {
+		t.Errorf("Cost() = %v, want <= %v", c.Cost(), c.Cap())
+	}
+
+	// 超过容量直接拒绝
+	rejected := c.Put("44", make([]byte, 11))
+	if rejected == nil || rejected.Key != "44" || c.Contains("44") {
+		t.Errorf("Put() = %v, want %v", rejected, "44")
+	}
+	if c.Cost() > int64(c.Cap()) {
+		t.Errorf("Cost() = %v, want <= %v", c.Cost(), c.Cap())
+	}
+}
+
+func TestCache_Stats(t *testing.T) {
+	c := New[string, int](func(key string) []byte {
+		return []byte(key)
+	}, 10)
+	c.SetStatsCounter(cache.NewStatsCounter())
+	for i := 0; i < 100; i++ {
+		c.Put(strconv.Itoa(i), i)
+	}
+	c.Get("99")
+	c.Get("0")
+
+	// 被主缓存淘汰和没能进入主缓存的元素都算淘汰
+	stats := c.Stats()
+	if stats.Puts != 100 || uint64(c.Len())+stats.EvictionCount() != 100 {
+		t.Errorf("Puts = %v, Len() + Evictions = %v, want %v, %v", stats.Puts, uint64(c.Len())+stats.EvictionCount(), 100, 100)
+	}
+	if stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Hits = %v, Misses = %v, want %v, %v", stats.Hits, stats.Misses, 1, 1)
+	}
+}
+
+func TestCache_Save(t *testing.T) {
+	c := New[string, int](func(key string) []byte {
+		return []byte(key)
+	}, 100)
+	for i := 0; i < 100; i++ {
+		c.Put(strconv.Itoa(i), i)
+		if i%2 == 0 {
+			c.Get(strconv.Itoa(i))
+		}
+	}
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	c2 := New[string, int](func(key string) []byte {
+		return []byte(key)
+	}, 100)
+	if err := c2.Load(&buf); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(c2.Keys(), c.Keys()) {
+		t.Errorf("Keys() = %v, want %v", c2.Keys(), c.Keys())
+	}
+	// 窗口缓存和主缓存的比例也恢复了
+	if c2.WindowCap() != c.WindowCap() {
+		t.Errorf("WindowCap() = %v, want %v", c2.WindowCap(), c.WindowCap())
+	}
+	// 访问频率也恢复了
+	if c2.estimate(c2.hash("0")) != c.estimate(c.hash("0")) {
+		t.Errorf("estimate() = %v, want %v", c2.estimate(c2.hash("0")), c.estimate(c.hash("0")))
+	}
+}
+
+func TestCache_Resize(t *testing.T) {
+	c := New[string, int](func(key string) []byte {
+		return []byte(key)
+	}, 1000)
+	for i := 0; i < 1000; i++ {
+		c.Put(strconv.Itoa(i), i)
+	}
+
+	// 窗口缓存和主缓存按比例一起调整
+	c.Resize(200, true)
+	if c.WindowCap() != 2 || c.MainCap() != 198 || c.Len() > 200 {
+		t.Errorf("WindowCap() = %v, MainCap() = %v, Len() = %v, want %v, %v, <= %v", c.WindowCap(), c.MainCap(), c.Len(), 2, 198, 200)
+	}
+	c.Resize(1, true)
+	if c.WindowCap() != 1 || c.Len() > 1 {
+		t.Errorf("WindowCap() = %v, Len() = %v, want %v, <= %v", c.WindowCap(), c.Len(), 1, 1)
+	}
+}
+
+func TestCache_ResizeOnEvict(t *testing.T) {
+	c := New[string, int](func(key string) []byte {
+		return []byte(key)
+	}, 25)
+	evicted := map[string]int{}
+	c.SetOnEvict(func(entry *cache.Entry[string, int]) {
+		evicted[entry.Key]++
+	})
+	// 窗口缓存变大后缩小，窗口缓存才会淘汰候选者
+	c.resize(12, 13)
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(r.Intn(50))
+		if _, ok := c.Get(key); !ok {
+			c.Put(key, i)
+		}
+	}
+
+	// 缩小时窗口缓存淘汰的候选者没能进入主缓存也会触发回调
+	for _, capacity := range []int{10, 3, 1} {
+		keys := c.Keys()
+		for key := range evicted {
+			delete(evicted, key)
+		}
+		c.Resize(capacity, true)
+		for _, key := range keys {
+			if c.Contains(key) == (evicted[key] == 1) {
+				t.Errorf("Contains(%v) = %v, OnEvict count = %v", key, c.Contains(key), evicted[key])
+			}
+		}
+		if len(evicted)+c.Len() != len(keys) {
+			t.Errorf("evicted + Len() = %v, want %v", len(evicted)+c.Len(), len(keys))
+		}
+	}
+}
+
+func TestCache_RecencyTrace(t *testing.T) {
+	c := New[int, int](func(key int) []byte {
+		return []byte(strconv.Itoa(key))
+	}, 1000)
+	c.SetAdaptiveWindow(true)
+	r := rand.New(rand.NewSource(1))
+	// 大部分访问都是最近加入的元素，窗口缓存越大命中率越高
+	for i := 0; i < 500000; i++ {
+		key := i - int(r.ExpFloat64()*300)
+		if _, ok := c.Get(key); !ok {
+			c.Put(key, key)
+		}
+	}
+	if c.WindowCap() < 500 || c.WindowCap()+c.MainCap() != 1000 {
+		t.Errorf("WindowCap() = %v, MainCap() = %v, want >= %v", c.WindowCap(), c.MainCap(), 500)
+	}
+}
+
+func TestCache_FrequencyTrace(t *testing.T) {
+	c := New[int, int](func(key int) []byte {
+		return []byte(strconv.Itoa(key))
+	}, 1000)
+	c.SetAdaptiveWindow(true)
+	r := rand.New(rand.NewSource(1))
+	// 热点元素和只访问一次的元素交替出现，窗口缓存越小命中率越高
+	for i := 0; i < 500000; i++ {
+		key := r.Intn(950)
+		if i%2 == 0 {
+			key = 1000000 + i
+		}
+		if _, ok := c.Get(key); !ok {
+			c.Put(key, key)
+		}
+	}
+	if c.WindowCap() >= 100 || c.WindowCap()+c.MainCap() != 1000 {
+		t.Errorf("WindowCap() = %v, MainCap() = %v, want < %v", c.WindowCap(), c.MainCap(), 100)
+	}
+}
+
+func TestCache_Conformance(t *testing.T) {
+	cachetest.Run(t, func(capacity int) cache.Policy[string, int] {
+		return New[string, int](func(key string) []byte {
+			return []byte(key)
+		}, capacity)
+	}, cachetest.Options{})
+}
+
+// tinylfu_test.go:312: cachePercentage=0.1%, count=206048, hitCount=31080, hitRate=15.08%
+// tinylfu_test.go:312: cachePercentage=0.3%, count=206048, hitCount=70887, hitRate=34.40%
+// tinylfu_test.go:312: cachePercentage=0.5%, count=206048, hitCount=106835, hitRate=51.85%
+// tinylfu_test.go:312: cachePercentage=0.7%, count=206048, hitCount=138660, hitRate=67.29%
+// tinylfu_test.go:312: cachePercentage=1.0%, count=206048, hitCount=170703, hitRate=82.85%
+// tinylfu_test.go:312: cachePercentage=2.0%, count=206048, hitCount=188912, hitRate=91.68%
+// tinylfu_test.go:312: cachePercentage=3.0%, count=206048, hitCount=191122, hitRate=92.76%
+// tinylfu_test.go:312: cachePercentage=5.0%, count=206048, hitCount=192629, hitRate=93.49%
+// tinylfu_test.go:312: cachePercentage=10.0%, count=206048, hitCount=192842, hitRate=93.59%
 func TestHitRate(t *testing.T) {
 	dataset, err := os.ReadFile("../dataset")
 	if err != nil {
@@ -0,0 +1,565 @@
+package twoq
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/container/list"
+	"github.com/jiaxwu/gommon/math"
+)
+
+const (
+	// A1in队列比例
+	inPercentage = 0.25
+	// A1out队列比例
+	outPercentage = 0.5
+	// 快照中的缓存策略名称
+	snapshotPolicy = "twoq"
+)
+
+// 快照中元素所在的队列
+const (
+	segmentIn   uint8 = iota // A1in队列
+	segmentMain              // Am队列
+	segmentOut               // A1out队列，只有Key
+)
+
+type queueEntry[K comparable, V any] struct {
+	entry *cache.Entry[K, V]
+	in    bool // 是否在A1in队列
+}
+
+// 2Q
+// 由A1in、A1out两个FIFO队列和Am一个LRU队列组成
+// 新元素先进入A1in，A1in超过容量时淘汰到A1out，A1out只保存Key
+// A1in中再次被访问的元素和A1out中的元素会进入Am，Am按LRU淘汰
+// 优点：只访问一次的元素不会进入Am，一次性的顺序扫描不会冲掉热点元素
+// 非线程安全，请根据业务加锁
+// https://www.vldb.org/conf/1994/P439.PDF
+type Cache[K comparable, V any] struct {
+	entries    map[K]*list.Element[*queueEntry[K, V]]
+	in         *list.List[*queueEntry[K, V]] // A1in队列
+	main       *list.List[*queueEntry[K, V]] // Am队列
+	outs       map[K]*list.Element[K]        // A1out队列的Key
+	outList    *list.List[K]                 // A1out队列
+	capacity   int
+	inCap      int                 // A1in队列容量
+	outCap     int                 // A1out队列的Key个数上限
+	inCost     int64               // A1in队列的总权重
+	cost       int64               // 当前总权重
+	weigher    cache.Weigher[K, V] // 计算元素权重，为空则每个元素权重为1
+	ttl        time.Duration       // 默认过期时间
+	onEvict    cache.OnEvictWithReason[K, V]
+	janitor    *cache.Janitor[K]
+	stats      *cache.StatsCounter // 统计，为空表示不统计
+	keyCodec   cache.Codec[K]      // 保存快照时Key的编解码器
+	valueCodec cache.Codec[V]      // 保存快照时Value的编解码器
+}
+
+func New[K comparable, V any](capacity int) *Cache[K, V] {
+	if capacity < 1 {
+		panic("too small capacity")
+	}
+	return &Cache[K, V]{
+		entries:  make(map[K]*list.Element[*queueEntry[K, V]]),
+		in:       list.New[*queueEntry[K, V]](),
+		main:     list.New[*queueEntry[K, V]](),
+		outs:     make(map[K]*list.Element[K]),
+		outList:  list.New[K](),
+		capacity: capacity,
+		inCap:    inCap(capacity),
+		outCap:   outCap(capacity),
+	}
+}
+
+// 设置 OnEvict
+func (c *Cache[K, V]) SetOnEvict(onEvict cache.OnEvict[K, V]) {
+	c.onEvict = onEvict.WithReason()
+}
+
+// 设置 OnEvict，带上淘汰原因
+func (c *Cache[K, V]) SetOnEvictWithReason(onEvict cache.OnEvictWithReason[K, V]) {
+	c.onEvict = onEvict
+}
+
+// 设置权重计算函数，设置后容量表示总权重，而不是元素个数
+func (c *Cache[K, V]) SetWeigher(weigher cache.Weigher[K, V]) {
+	c.weigher = weigher
+	// 重新计算总权重
+	c.cost, c.inCost = 0, 0
+	for _, elem := range c.entries {
+		weight := c.weigh(elem.Value.entry.Key, elem.Value.entry.Value)
+		c.cost += weight
+		if elem.Value.in {
+			c.inCost += weight
+		}
+	}
+	c.evictToFit(0)
+}
+
+// 设置统计计数器，为空表示不统计
+func (c *Cache[K, V]) SetStatsCounter(stats *cache.StatsCounter) {
+	c.stats = stats
+}
+
+// 获取统计信息
+func (c *Cache[K, V]) Stats() cache.Stats {
+	return c.stats.Snapshot()
+}
+
+// 设置默认过期时间，Put()会使用该过期时间，小于等于0表示永不过期
+func (c *Cache[K, V]) SetDefaultTTL(ttl time.Duration) {
+	c.ttl = ttl
+}
+
+// 启动过期清理器，到期主动删除元素，直到ctx被关闭
+// locker是业务访问缓存时使用的锁
+// 只会清理启动之后添加的元素，之前添加的元素依然在访问时删除
+func (c *Cache[K, V]) StartJanitor(ctx context.Context, locker sync.Locker) {
+	c.janitor = cache.NewJanitor(locker, c.RemoveExpired)
+	go c.janitor.Run(ctx)
+}
+
+// 添加或更新元素
+// 返回被淘汰的元素
+func (c *Cache[K, V]) Put(key K, value V) *cache.Entry[K, V] {
+	return c.PutWithTTL(key, value, c.ttl)
+}
+
+// 添加或更新元素，并设置过期时间，小于等于0表示永不过期
+// 返回被淘汰的元素
+func (c *Cache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) *cache.Entry[K, V] {
+	return c.PutWithExpiration(key, value, cache.Expiration(ttl))
+}
+
+// 添加或更新元素，并设置过期时刻，零值表示永不过期
+// 返回被淘汰的元素，如果淘汰了多个元素，返回最后一个
+// 如果元素权重超过容量，则不会添加，直接返回该元素
+func (c *Cache[K, V]) PutWithExpiration(key K, value V, expiration time.Time) *cache.Entry[K, V] {
+	weight := c.weigh(key, value)
+	if weight > int64(c.capacity) {
+		c.Remove(key)
+		return &cache.Entry[K, V]{
+			Key:        key,
+			Value:      value,
+			Expiration: expiration,
+		}
+	}
+
+	// 如果 key 已经存在，当作一次访问，然后设置新值
+	if elem, ok := c.entries[key]; ok {
+		oldWeight := c.weigh(key, elem.Value.entry.Value)
+		c.cost += weight - oldWeight
+		if elem.Value.in {
+			c.inCost += weight - oldWeight
+		}
+		elem.Value.entry.Value = value
+		elem.Value.entry.Expiration = expiration
+		c.access(elem)
+		c.schedule(key, expiration)
+		c.stats.RecordUpdate()
+		// 权重变大可能需要淘汰元素
+		return c.evictToFit(0)
+	}
+
+	// 如果放不下，先剔除元素
+	evicted := c.evictToFit(weight)
+
+	// 在A1out队列说明最近被淘汰过，直接进入Am，否则进入A1in
+	entry := &queueEntry[K, V]{
+		entry: &cache.Entry[K, V]{
+			Key:        key,
+			Value:      value,
+			Expiration: expiration,
+		},
+	}
+	if out, ok := c.outs[key]; ok {
+		c.removeOut(out)
+		c.entries[key] = c.main.PushFront(entry)
+	} else {
+		entry.in = true
+		c.entries[key] = c.in.PushFront(entry)
+		c.inCost += weight
+	}
+	c.cost += weight
+	c.schedule(key, expiration)
+	c.stats.RecordPut()
+	return evicted
+}
+
+// 获取元素
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	// 如果存在调整位置，然后返回
+	if elem, ok := c.entries[key]; ok {
+		// 过期了直接删除
+		if elem.Value.entry.Expired() {
+			c.expireElement(elem)
+			c.stats.RecordMiss()
+			var value V
+			return value, false
+		}
+		c.access(elem)
+		c.stats.RecordHit()
+		return elem.Value.entry.Value, true
+	}
+
+	// 不存在返回空值和false
+	c.stats.RecordMiss()
+	var value V
+	return value, false
+}
+
+// 获取元素，不更新状态
+func (c *Cache[K, V]) Peek(key K) (V, bool) {
+	// 如果存在
+	if entry, ok := c.PeekEntry(key); ok {
+		return entry.Value, true
+	}
+
+	// 不存在返回空值和false
+	var value V
+	return value, false
+}
+
+// 获取缓存项，不更新状态
+func (c *Cache[K, V]) PeekEntry(key K) (*cache.Entry[K, V], bool) {
+	// 如果存在并且没有过期
+	if elem, ok := c.entries[key]; ok && !elem.Value.entry.Expired() {
+		return elem.Value.entry, true
+	}
+	return nil, false
+}
+
+// 是否包含元素，不更新状态
+func (c *Cache[K, V]) Contains(key K) bool {
+	_, ok := c.PeekEntry(key)
+	return ok
+}
+
+// 获取缓存的Keys，先是A1in队列，然后是Am队列，按淘汰顺序排列
+// 可能包含已经过期但还没被删除的元素
+func (c *Cache[K, V]) Keys() []K {
+	keys := make([]K, 0, c.Len())
+	c.each(func(entry *queueEntry[K, V]) {
+		keys = append(keys, entry.entry.Key)
+	})
+	return keys
+}
+
+// 获取缓存的Values
+func (c *Cache[K, V]) Values() []V {
+	values := make([]V, 0, c.Len())
+	c.each(func(entry *queueEntry[K, V]) {
+		values = append(values, entry.entry.Value)
+	})
+	return values
+}
+
+// 获取缓存的Entries
+func (c *Cache[K, V]) Entries() []*cache.Entry[K, V] {
+	entries := make([]*cache.Entry[K, V], 0, c.Len())
+	c.each(func(entry *queueEntry[K, V]) {
+		entries = append(entries, entry.entry)
+	})
+	return entries
+}
+
+// 移除元素
+func (c *Cache[K, V]) Remove(key K) bool {
+	if elem, ok := c.entries[key]; ok {
+		c.removeElement(elem)
+		return true
+	}
+	return false
+}
+
+// 移除已经过期的元素
+func (c *Cache[K, V]) RemoveExpired(key K) bool {
+	if elem, ok := c.entries[key]; ok && elem.Value.entry.Expired() {
+		c.expireElement(elem)
+		return true
+	}
+	return false
+}
+
+// 淘汰元素
+// A1in超过容量时淘汰A1in的尾部元素，并把Key放入A1out，否则淘汰Am的尾部元素
+func (c *Cache[K, V]) Evict() *cache.Entry[K, V] {
+	if c.Len() == 0 {
+		return nil
+	}
+	elem := c.victim()
+	c.removeElement(elem)
+	entry := elem.Value.entry
+	if elem.Value.in {
+		c.addOut(entry.Key)
+	}
+	// 回调
+	reason := cache.EvictReasonCapacity
+	if entry.Expired() {
+		reason = cache.EvictReasonExpired
+	}
+	c.doOnEvict(entry, reason)
+	return entry
+}
+
+// 获取可能被淘汰的元素
+func (c *Cache[K, V]) Victim() *cache.Entry[K, V] {
+	if c.Len() == 0 {
+		return nil
+	}
+	return c.victim().Value.entry
+}
+
+// 清空缓存
+func (c *Cache[K, V]) Clear(needOnEvict bool) {
+	// 触发回调
+	if needOnEvict {
+		c.each(func(entry *queueEntry[K, V]) {
+			c.doOnEvict(entry.entry, cache.EvictReasonClear)
+		})
+	}
+
+	// 清空
+	c.entries = make(map[K]*list.Element[*queueEntry[K, V]])
+	c.in.Clear()
+	c.main.Clear()
+	c.outs = make(map[K]*list.Element[K])
+	c.outList.Clear()
+	c.inCost = 0
+	c.cost = 0
+}
+
+// 改变容量
+func (c *Cache[K, V]) Resize(capacity int, needOnEvict bool) {
+	c.capacity = capacity
+	c.inCap = inCap(capacity)
+	c.outCap = outCap(capacity)
+	c.evictToFit(0)
+	for c.outList.Len() > c.outCap {
+		c.removeOut(c.outList.Back())
+	}
+}
+
+// 元素个数
+func (c *Cache[K, V]) Len() int {
+	return len(c.entries)
+}
+
+// 当前总权重，没有设置权重计算函数时等于元素个数
+func (c *Cache[K, V]) Cost() int64 {
+	return c.cost
+}
+
+// 容量，设置了权重计算函数时表示总权重
+func (c *Cache[K, V]) Cap() int {
+	return c.capacity
+}
+
+// 缓存满了
+func (c *Cache[K, V]) Full() bool {
+	return c.Cost() >= int64(c.Cap())
+}
+
+// 设置保存快照时使用的编解码器，为空使用GobCodec
+func (c *Cache[K, V]) SetCodec(keyCodec cache.Codec[K], valueCodec cache.Codec[V]) {
+	c.keyCodec = keyCodec
+	c.valueCodec = valueCodec
+}
+
+// 保存快照到w，包括元素所在的队列、队列内的顺序和A1out队列
+func (c *Cache[K, V]) Save(w io.Writer) error {
+	return c.Snapshot().Encode(w, c.keyCodec, c.valueCodec)
+}
+
+// 从r加载快照，会先清空缓存，不触发回调
+// 快照格式不正确时缓存保持不变
+func (c *Cache[K, V]) Load(r io.Reader) error {
+	snapshot, err := cache.DecodeSnapshot(r, snapshotPolicy, c.keyCodec, c.valueCodec)
+	if err != nil {
+		return err
+	}
+	return c.Restore(snapshot)
+}
+
+// 获取快照，依次是A1out队列、A1in队列、Am队列，队列内按淘汰顺序排列
+// A1out队列只有Key，Value是零值
+func (c *Cache[K, V]) Snapshot() *cache.Snapshot[K, V] {
+	snapshot := &cache.Snapshot[K, V]{
+		Policy:  snapshotPolicy,
+		Records: make([]*cache.SnapshotRecord[K, V], 0, c.outList.Len()+c.Len()),
+	}
+	for elem := c.outList.Back(); elem != nil; elem = elem.Prev() {
+		snapshot.Records = append(snapshot.Records, &cache.SnapshotRecord[K, V]{
+			Entry:   &cache.Entry[K, V]{Key: elem.Value},
+			Segment: segmentOut,
+		})
+	}
+	c.each(func(entry *queueEntry[K, V]) {
+		e := *entry.entry
+		record := &cache.SnapshotRecord[K, V]{
+			Entry:   &e,
+			Segment: segmentMain,
+		}
+		if entry.in {
+			record.Segment = segmentIn
+		}
+		snapshot.Records = append(snapshot.Records, record)
+	})
+	return snapshot
+}
+
+// 从快照恢复，会先清空缓存，不触发回调
+// 跳过已经过期的元素，放不下的元素按淘汰顺序淘汰
+func (c *Cache[K, V]) Restore(snapshot *cache.Snapshot[K, V]) error {
+	if snapshot.Policy != snapshotPolicy {
+		return cache.ErrSnapshotPolicyMismatch
+	}
+	c.Clear(false)
+	for _, record := range snapshot.Records {
+		entry := *record.Entry
+		if elem, ok := c.entries[entry.Key]; ok {
+			c.removeElement(elem)
+		}
+		if out, ok := c.outs[entry.Key]; ok {
+			c.removeOut(out)
+		}
+		if record.Segment == segmentOut {
+			c.addOut(entry.Key)
+			continue
+		}
+		if entry.Expired() {
+			continue
+		}
+		// 快照最先淘汰的在前面，所以依次放到最前面
+		qe := &queueEntry[K, V]{
+			entry: &entry,
+		}
+		weight := c.weigh(entry.Key, entry.Value)
+		if record.Segment == segmentIn {
+			qe.in = true
+			c.entries[entry.Key] = c.in.PushFront(qe)
+			c.inCost += weight
+		} else {
+			c.entries[entry.Key] = c.main.PushFront(qe)
+		}
+		c.cost += weight
+		c.schedule(entry.Key, entry.Expiration)
+	}
+	c.evictToFit(0)
+	return nil
+}
+
+// 访问元素
+// A1in中的元素移动到Am头部，Am中的元素移动到头部
+func (c *Cache[K, V]) access(elem *list.Element[*queueEntry[K, V]]) {
+	entry := elem.Value
+	if !entry.in {
+		c.main.MoveToFront(elem)
+		return
+	}
+	c.in.Remove(elem)
+	c.inCost -= c.weigh(entry.entry.Key, entry.entry.Value)
+	entry.in = false
+	c.entries[entry.entry.Key] = c.main.PushFront(entry)
+}
+
+// 下一个被淘汰的元素
+func (c *Cache[K, V]) victim() *list.Element[*queueEntry[K, V]] {
+	if c.inCost >= int64(c.inCap) || c.main.Len() == 0 {
+		if elem := c.in.Back(); elem != nil {
+			return elem
+		}
+	}
+	return c.main.Back()
+}
+
+// 按A1in队列、Am队列的顺序遍历元素，队列内从尾部到头部
+func (c *Cache[K, V]) each(f func(entry *queueEntry[K, V])) {
+	for elem := c.in.Back(); elem != nil; elem = elem.Prev() {
+		f(elem.Value)
+	}
+	for elem := c.main.Back(); elem != nil; elem = elem.Prev() {
+		f(elem.Value)
+	}
+}
+
+// 添加到A1out队列，超过上限时移除最早的Key
+func (c *Cache[K, V]) addOut(key K) {
+	c.outs[key] = c.outList.PushFront(key)
+	for c.outList.Len() > c.outCap {
+		c.removeOut(c.outList.Back())
+	}
+}
+
+// 从A1out队列移除
+func (c *Cache[K, V]) removeOut(elem *list.Element[K]) {
+	c.outList.Remove(elem)
+	delete(c.outs, elem.Value)
+}
+
+// 移除给定节点
+func (c *Cache[K, V]) removeElement(elem *list.Element[*queueEntry[K, V]]) {
+	entry := elem.Value
+	weight := c.weigh(entry.entry.Key, entry.entry.Value)
+	if entry.in {
+		c.in.Remove(elem)
+		c.inCost -= weight
+	} else {
+		c.main.Remove(elem)
+	}
+	delete(c.entries, entry.entry.Key)
+	c.cost -= weight
+}
+
+// 淘汰元素直到能放下给定权重的元素
+// 返回最后一个被淘汰的元素
+func (c *Cache[K, V]) evictToFit(weight int64) *cache.Entry[K, V] {
+	var evicted *cache.Entry[K, V]
+	for c.Len() > 0 && c.cost+weight > int64(c.capacity) {
+		evicted = c.Evict()
+	}
+	return evicted
+}
+
+// 计算元素权重
+func (c *Cache[K, V]) weigh(key K, value V) int64 {
+	if c.weigher == nil {
+		return 1
+	}
+	return c.weigher(key, value)
+}
+
+// 删除过期节点
+func (c *Cache[K, V]) expireElement(elem *list.Element[*queueEntry[K, V]]) {
+	c.removeElement(elem)
+	c.doOnEvict(elem.Value.entry, cache.EvictReasonExpired)
+}
+
+// 添加到过期清理器
+func (c *Cache[K, V]) schedule(key K, expiration time.Time) {
+	if c.janitor != nil {
+		c.janitor.Push(key, expiration)
+	}
+}
+
+// 触发淘汰回调
+func (c *Cache[K, V]) doOnEvict(entry *cache.Entry[K, V], reason cache.EvictReason) {
+	c.stats.RecordEviction(reason)
+	if c.onEvict != nil {
+		c.onEvict(entry, reason)
+	}
+}
+
+// 计算A1in队列容量
+func inCap(capacity int) int {
+	return math.Max(int(inPercentage*float64(capacity)), 1)
+}
+
+// 计算A1out队列的Key个数上限
+func outCap(capacity int) int {
+	return math.Max(int(outPercentage*float64(capacity)), 1)
+}
@@ -0,0 +1,252 @@
+package twoq
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/cache/cachetest"
+	"github.com/jiaxwu/gommon/cache/lru"
+)
+
+func TestCache_Put(t *testing.T) {
+	c := New[string, int](4)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Put("44", 8)
+	// 淘汰A1in中最早的11，11进入A1out
+	c.Put("55", 9)
+	// 11在A1out中，直接进入Am，淘汰22
+	c.Put("11", 5)
+
+	if !reflect.DeepEqual(c.Keys(), []string{"33", "44", "55", "11"}) {
+		t.Errorf("Keys() = %v, want %v", c.Keys(), []string{"33", "44", "55", "11"})
+	}
+	// A1in中的元素再次被访问进入Am
+	c.Get("33")
+	if !reflect.DeepEqual(c.Keys(), []string{"44", "55", "11", "33"}) {
+		t.Errorf("Keys() = %v, want %v", c.Keys(), []string{"44", "55", "11", "33"})
+	}
+}
+
+func TestCache_OnEvict(t *testing.T) {
+	c := New[string, int](3)
+	c.SetOnEvict(func(entry *cache.Entry[string, int]) {
+		if entry.Key != "22" || entry.Value != 6 {
+			t.Errorf("OnEvict() = %v, want %v", entry.Key, "22")
+		}
+	})
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Get("11")
+	evicted := c.Put("44", 8)
+	if evicted == nil || evicted.Key != "22" {
+		t.Errorf("Put() = %v, want %v", evicted, "22")
+	}
+}
+
+func TestCache_Evict(t *testing.T) {
+	c := New[string, int](4)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Get("11")
+	c.Get("22")
+	c.Get("33")
+
+	// A1in没有超过容量，淘汰Am
+	if victim := c.Victim(); victim.Key != "11" {
+		t.Errorf("Victim() = %v, want %v", victim.Key, "11")
+	}
+	c.Put("44", 8)
+	if evicted := c.Evict(); evicted.Key != "44" {
+		t.Errorf("Evict() = %v, want %v", evicted.Key, "44")
+	}
+	if evicted := c.Evict(); evicted.Key != "11" {
+		t.Errorf("Evict() = %v, want %v", evicted.Key, "11")
+	}
+
+	// A1in淘汰的元素会进入A1out，Am淘汰的元素不会
+	c.Put("11", 5)
+	c.Put("44", 8)
+	if !reflect.DeepEqual(c.Keys(), []string{"11", "22", "33", "44"}) {
+		t.Errorf("Keys() = %v, want %v", c.Keys(), []string{"11", "22", "33", "44"})
+	}
+}
+
+func TestCache_Peek(t *testing.T) {
+	c := New[string, int](3)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Peek("11")
+	c.Put("44", 8)
+
+	value, ok := c.Get("11")
+	if value != 0 || ok {
+		t.Errorf("Get() = %v, want %v", ok, false)
+	}
+}
+
+func TestCache_Resize(t *testing.T) {
+	c := New[string, int](4)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Get("33")
+	c.Resize(1, true)
+
+	if !reflect.DeepEqual(c.Keys(), []string{"33"}) {
+		t.Errorf("Keys() = %v, want %v", c.Keys(), []string{"33"})
+	}
+	if c.outList.Len() > 1 {
+		t.Errorf("outList.Len() = %v, want %v", c.outList.Len(), 1)
+	}
+}
+
+func TestCache_PutWithTTL(t *testing.T) {
+	c := New[string, int](3)
+	c.PutWithTTL("11", 5, time.Millisecond*10)
+	c.Put("22", 6)
+	time.Sleep(time.Millisecond * 20)
+
+	if _, ok := c.Get("11"); ok || c.Len() != 1 {
+		t.Errorf("Get() = %v, Len() = %v, want %v, %v", ok, c.Len(), false, 1)
+	}
+	value, ok := c.Get("22")
+	if value != 6 || !ok {
+		t.Errorf("Get() = %v, want %v", ok, true)
+	}
+}
+
+func TestCache_SetWeigher(t *testing.T) {
+	c := New[string, []byte](10)
+	c.SetWeigher(func(key string, value []byte) int64 {
+		return int64(len(value))
+	})
+	c.Put("11", make([]byte, 4))
+	c.Put("22", make([]byte, 4))
+	c.Get("11")
+	c.Put("33", make([]byte, 6))
+	if c.Contains("22") || c.Cost() != 10 {
+		t.Errorf("Keys() = %v, Cost() = %v, want %v, %v", c.Keys(), c.Cost(), []string{"33", "11"}, 10)
+	}
+
+	// 超过容量直接拒绝
+	rejected := c.Put("44", make([]byte, 11))
+	if rejected == nil || rejected.Key != "44" || c.Contains("44") {
+		t.Errorf("Put() = %v, want %v", rejected, "44")
+	}
+}
+
+func TestCache_Save(t *testing.T) {
+	c := New[string, int](4)
+	c.Put("11", 5)
+	c.Put("22", 6)
+	c.Put("33", 7)
+	c.Put("44", 8)
+	c.Put("55", 9)
+	c.Get("33")
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// 恢复后队列和A1out不变
+	c2 := New[string, int](4)
+	if err := c2.Load(&buf); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(c2.Keys(), c.Keys()) {
+		t.Errorf("Keys() = %v, want %v", c2.Keys(), c.Keys())
+	}
+	if _, ok := c2.outs["11"]; !ok {
+		t.Errorf("outs[11] = %v, want %v", ok, true)
+	}
+}
+
+func TestCache_Scan(t *testing.T) {
+	c := New[int, int](100)
+	l := lru.New[int, int](100)
+	access := func(key int) {
+		if _, ok := c.Get(key); !ok {
+			c.Put(key, key)
+		}
+		if _, ok := l.Get(key); !ok {
+			l.Put(key, key)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 50; j++ {
+			access(j)
+		}
+	}
+
+	// 一次性的顺序扫描会冲掉LRU中的热点元素，但不会冲掉Am中的元素
+	for i := 1000; i < 2000; i++ {
+		access(i)
+	}
+	for i := 0; i < 50; i++ {
+		if !c.Contains(i) {
+			t.Errorf("Contains(%v) = %v, want %v", i, false, true)
+		}
+		if l.Contains(i) {
+			t.Errorf("lru.Contains(%v) = %v, want %v", i, true, false)
+		}
+	}
+}
+
+func TestCache_Conformance(t *testing.T) {
+	cachetest.Run(t, func(capacity int) cache.Policy[string, int] {
+		return New[string, int](capacity)
+	}, cachetest.Options{EvictionOrder: true})
+}
+
+// twoq_test.go:244: cachePercentage=0.1%, count=206048, hitCount=29459, hitRate=14.30%
+// twoq_test.go:244: cachePercentage=0.3%, count=206048, hitCount=64549, hitRate=31.33%
+// twoq_test.go:244: cachePercentage=0.5%, count=206048, hitCount=95088, hitRate=46.15%
+// twoq_test.go:244: cachePercentage=0.7%, count=206048, hitCount=121093, hitRate=58.77%
+// twoq_test.go:244: cachePercentage=1.0%, count=206048, hitCount=152506, hitRate=74.01%
+// twoq_test.go:244: cachePercentage=2.0%, count=206048, hitCount=188347, hitRate=91.41%
+// twoq_test.go:244: cachePercentage=3.0%, count=206048, hitCount=191157, hitRate=92.77%
+// twoq_test.go:244: cachePercentage=5.0%, count=206048, hitCount=192620, hitRate=93.48%
+// twoq_test.go:244: cachePercentage=10.0%, count=206048, hitCount=192842, hitRate=93.59%
+func TestHitRate(t *testing.T) {
+	dataset, err := os.ReadFile("../dataset")
+	if err != nil {
+		t.Errorf("read dataset error %v", err)
+	}
+	reqs := strings.Split(string(dataset), ",")
+	testHitRate(t, reqs, 0.001)
+	testHitRate(t, reqs, 0.003)
+	testHitRate(t, reqs, 0.005)
+	testHitRate(t, reqs, 0.007)
+	testHitRate(t, reqs, 0.01)
+	testHitRate(t, reqs, 0.02)
+	testHitRate(t, reqs, 0.03)
+	testHitRate(t, reqs, 0.05)
+	testHitRate(t, reqs, 0.1)
+}
+
+func testHitRate(t *testing.T, reqs []string, cachePercentage float64) {
+	count := len(reqs)
+	n := int(float64(count) * cachePercentage)
+	c := New[string, int](n)
+	hitCount := 0
+	for _, req := range reqs {
+		_, exists := c.Get(req)
+		if exists {
+			hitCount++
+		} else {
+			c.Put(req, 0)
+		}
+	}
+	hitRate := float64(hitCount) / float64(count)
+	t.Logf("cachePercentage=%.1f%%, count=%v, hitCount=%v, hitRate=%.2f%%", cachePercentage*100, count, hitCount, hitRate*100)
+}
@@ -0,0 +1,112 @@
+// cachesim 回放访问记录，比较各个缓存策略在不同容量下的命中率
+//
+// 用法：
+//
+//	cachesim -format arc -capacities 1000,10000,100000 -output csv trace.txt
+//
+// 支持的访问记录格式：
+//   - plain：每行一个Key
+//   - arc：ARC论文使用的格式，每行是“起始块号 块数 忽略 请求编号”
+//   - lirs：LIRS论文使用的格式，每行一个块号
+//   - wikipedia：Wikipedia CDN的格式，每行是“相对时间 哈希后的路径 类型 响应大小 首字节时间”
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "cachesim:", err)
+		os.Exit(2)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("cachesim", flag.ContinueOnError)
+	format := fs.String("format", "plain", "访问记录格式：plain、arc、lirs、wikipedia")
+	capacities := fs.String("capacities", "1000,10000,100000", "缓存容量，逗号分隔")
+	policyNames := fs.String("policies", "", "缓存策略，逗号分隔，为空表示所有策略")
+	output := fs.String("output", "text", "输出格式：text、csv")
+	parallel := fs.Int("parallel", runtime.NumCPU(), "同时运行的模拟数量")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: cachesim [flags] trace")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("need exactly one trace file")
+	}
+
+	if _, ok := parsers[*format]; !ok {
+		return fmt.Errorf("unknown trace format %q", *format)
+	}
+	caps, err := parseCapacities(*capacities)
+	if err != nil {
+		return err
+	}
+	ps, err := parsePolicies(*policyNames)
+	if err != nil {
+		return err
+	}
+	if *parallel < 1 {
+		return fmt.Errorf("invalid parallel %d", *parallel)
+	}
+	var write func(w io.Writer, results []*result, capacities []int) error
+	switch *output {
+	case "text":
+		write = writeText
+	case "csv":
+		write = writeCSV
+	default:
+		return fmt.Errorf("unknown output format %q", *output)
+	}
+
+	results, err := simulateAll(fs.Arg(0), *format, ps, caps, *parallel)
+	if err != nil {
+		return err
+	}
+	return write(stdout, results, caps)
+}
+
+// 解析逗号分隔的容量列表
+func parseCapacities(s string) ([]int, error) {
+	var capacities []int
+	for _, field := range strings.Split(s, ",") {
+		capacity, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("invalid capacity %q", field)
+		}
+		if capacity < minCapacity {
+			return nil, fmt.Errorf("capacity %d is less than %d", capacity, minCapacity)
+		}
+		capacities = append(capacities, capacity)
+	}
+	return capacities, nil
+}
+
+// 解析逗号分隔的缓存策略列表，为空表示所有策略
+func parsePolicies(s string) ([]policy, error) {
+	if s == "" {
+		return policies, nil
+	}
+	var ps []policy
+	for _, name := range strings.Split(s, ",") {
+		p, ok := findPolicy(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("unknown policy %q", name)
+		}
+		ps = append(ps, p)
+	}
+	return ps, nil
+}
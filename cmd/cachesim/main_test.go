@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace")
+	// 20个Key循环访问5次，容量足够时只有第一轮未命中
+	var trace strings.Builder
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 20; j++ {
+			trace.WriteString(strings.Repeat("k", j+1) + "\n")
+		}
+	}
+	if err := os.WriteFile(path, []byte(trace.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := run([]string{"-output", "csv", "-policies", "lru,fifo", "-capacities", "10,20", path}, &out); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	// 容量小于循环长度时LRU和FIFO一直不命中
+	want := "policy,10,20\nlru,0.0000,0.8000\nfifo,0.0000,0.8000\n"
+	if out.String() != want {
+		t.Errorf("run() = %q, want %q", out.String(), want)
+	}
+
+	// 所有缓存策略都能运行
+	out.Reset()
+	if err := run([]string{"-capacities", "10,20", path}, &out); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != len(policies)+2 {
+		t.Errorf("run() lines = %v, want %v", len(lines), len(policies)+2)
+	}
+}
+
+func TestRun_InvalidArgs(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"-format", "unknown", "trace"},
+		{"-capacities", "1", "trace"},
+		{"-policies", "unknown", "trace"},
+		{"-output", "unknown", "trace"},
+		{"trace-not-exist"},
+	}
+	for _, args := range tests {
+		var out bytes.Buffer
+		if err := run(args, &out); err == nil {
+			t.Errorf("run(%v) error = %v, want not nil", args, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// 把结果整理成表格，每行一个缓存策略，每列一个容量
+func table(results []*result, capacities []int) (header []string, rows [][]*result) {
+	header = append(header, "policy")
+	for _, capacity := range capacities {
+		header = append(header, strconv.Itoa(capacity))
+	}
+	for i := 0; i < len(results); i += len(capacities) {
+		rows = append(rows, results[i:i+len(capacities)])
+	}
+	return header, rows
+}
+
+// 输出对齐的文本表格，命中率使用百分比
+func writeText(w io.Writer, results []*result, capacities []int) error {
+	header, rows := table(results, capacities)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for _, h := range header {
+		fmt.Fprintf(tw, "%s\t", h)
+	}
+	fmt.Fprintln(tw)
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%s\t", row[0].policy)
+		for _, r := range row {
+			fmt.Fprintf(tw, "%.2f%%\t", r.hitRatio()*100)
+		}
+		fmt.Fprintln(tw)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(results) > 0 {
+		_, err := fmt.Fprintf(w, "requests: %d\n", results[0].requests)
+		return err
+	}
+	return nil
+}
+
+// 输出CSV表格，命中率使用小数
+func writeCSV(w io.Writer, results []*result, capacities []int) error {
+	header, rows := table(results, capacities)
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := []string{row[0].policy}
+		for _, r := range row {
+			record = append(record, strconv.FormatFloat(r.hitRatio(), 'f', 4, 64))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"os"
+	"sync"
+
+	"github.com/jiaxwu/gommon/cache"
+	"github.com/jiaxwu/gommon/cache/arc"
+	"github.com/jiaxwu/gommon/cache/clock"
+	"github.com/jiaxwu/gommon/cache/clockpro"
+	"github.com/jiaxwu/gommon/cache/fifo"
+	"github.com/jiaxwu/gommon/cache/lfu"
+	"github.com/jiaxwu/gommon/cache/lirs"
+	"github.com/jiaxwu/gommon/cache/lru"
+	"github.com/jiaxwu/gommon/cache/nearlylru"
+	"github.com/jiaxwu/gommon/cache/random"
+	"github.com/jiaxwu/gommon/cache/s3fifo"
+	"github.com/jiaxwu/gommon/cache/sieve"
+	"github.com/jiaxwu/gommon/cache/slru"
+	"github.com/jiaxwu/gommon/cache/tinylfu"
+	"github.com/jiaxwu/gommon/cache/twoq"
+)
+
+// 最小容量，太小时部分缓存策略无法创建
+const minCapacity = 10
+
+// 缓存策略
+type policy struct {
+	name string
+	new  func(capacity int) cache.Cache[string, struct{}]
+}
+
+// 所有缓存策略
+var policies = []policy{
+	{"lru", func(capacity int) cache.Cache[string, struct{}] {
+		return lru.New[string, struct{}](capacity)
+	}},
+	{"lfu", func(capacity int) cache.Cache[string, struct{}] {
+		return lfu.New[string, struct{}](capacity)
+	}},
+	{"fifo", func(capacity int) cache.Cache[string, struct{}] {
+		return fifo.New[string, struct{}](capacity)
+	}},
+	{"arc", func(capacity int) cache.Cache[string, struct{}] {
+		return arc.New[string, struct{}](capacity)
+	}},
+	{"slru", func(capacity int) cache.Cache[string, struct{}] {
+		return slru.New[string, struct{}](capacity)
+	}},
+	{"tinylfu", func(capacity int) cache.Cache[string, struct{}] {
+		return tinylfu.New[string, struct{}](func(key string) []byte {
+			return []byte(key)
+		}, capacity)
+	}},
+	{"s3fifo", func(capacity int) cache.Cache[string, struct{}] {
+		return s3fifo.New[string, struct{}](capacity)
+	}},
+	{"sieve", func(capacity int) cache.Cache[string, struct{}] {
+		return sieve.New[string, struct{}](capacity)
+	}},
+	{"clock", func(capacity int) cache.Cache[string, struct{}] {
+		return clock.New[string, struct{}](capacity)
+	}},
+	{"clockpro", func(capacity int) cache.Cache[string, struct{}] {
+		return clockpro.New[string, struct{}](capacity)
+	}},
+	{"lirs", func(capacity int) cache.Cache[string, struct{}] {
+		return lirs.New[string, struct{}](capacity)
+	}},
+	{"twoq", func(capacity int) cache.Cache[string, struct{}] {
+		return twoq.New[string, struct{}](capacity)
+	}},
+	{"nearlylru", func(capacity int) cache.Cache[string, struct{}] {
+		return nearlylru.New[string, struct{}](capacity)
+	}},
+	{"random", func(capacity int) cache.Cache[string, struct{}] {
+		return random.New[string, struct{}](capacity)
+	}},
+}
+
+// 根据名字查找缓存策略
+func findPolicy(name string) (policy, bool) {
+	for _, p := range policies {
+		if p.name == name {
+			return p, true
+		}
+	}
+	return policy{}, false
+}
+
+// 模拟结果
+type result struct {
+	policy   string
+	capacity int
+	requests uint64
+	hits     uint64
+}
+
+// 命中率
+func (r *result) hitRatio() float64 {
+	if r.requests == 0 {
+		return 0
+	}
+	return float64(r.hits) / float64(r.requests)
+}
+
+// 回放访问记录，未命中时添加到缓存
+func simulate(c cache.Cache[string, struct{}], trace func(fn func(key string)) error) (requests, hits uint64, err error) {
+	err = trace(func(key string) {
+		requests++
+		if _, ok := c.Get(key); ok {
+			hits++
+		} else {
+			c.Put(key, struct{}{})
+		}
+	})
+	return requests, hits, err
+}
+
+// 用每个缓存策略在每个容量下回放访问记录，最多同时运行parallel个模拟
+// 每个模拟单独读取一遍文件，避免把整个访问记录加载到内存
+func simulateAll(path, format string, ps []policy, capacities []int, parallel int) ([]*result, error) {
+	trace := func(fn func(key string)) error {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return readTrace(f, format, fn)
+	}
+
+	results := make([]*result, 0, len(ps)*len(capacities))
+	for _, p := range ps {
+		for _, capacity := range capacities {
+			results = append(results, &result{policy: p.name, capacity: capacity})
+		}
+	}
+	var (
+		wg       sync.WaitGroup
+		mutex    sync.Mutex
+		firstErr error
+		sem      = make(chan struct{}, parallel)
+	)
+	for i, r := range results {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(p policy, r *result) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			requests, hits, err := simulate(p.new(r.capacity), trace)
+			if err != nil {
+				mutex.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mutex.Unlock()
+				return
+			}
+			r.requests, r.hits = requests, hits
+		}(ps[i/len(capacities)], r)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return results, nil
+}
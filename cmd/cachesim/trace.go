@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 解析一行访问记录，每访问一个Key调用一次fn
+type lineParser func(line string, fn func(key string)) error
+
+// 支持的访问记录格式
+var parsers = map[string]lineParser{
+	"plain":     parsePlain,
+	"arc":       parseARC,
+	"lirs":      parseLIRS,
+	"wikipedia": parseWikipedia,
+}
+
+// 读取访问记录，每访问一个Key调用一次fn
+func readTrace(r io.Reader, format string, fn func(key string)) error {
+	parse, ok := parsers[format]
+	if !ok {
+		return fmt.Errorf("unknown trace format %q", format)
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if err := parse(line, fn); err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// 每行一个Key
+func parsePlain(line string, fn func(key string)) error {
+	fn(line)
+	return nil
+}
+
+// ARC论文使用的格式，每行是“起始块号 块数 忽略 请求编号”，表示访问连续的多个块
+func parseARC(line string, fn func(key string)) error {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return fmt.Errorf("invalid arc record %q", line)
+	}
+	start, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid arc start block %q", fields[0])
+	}
+	count, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid arc block count %q", fields[1])
+	}
+	for i := uint64(0); i < count; i++ {
+		fn(strconv.FormatUint(start+i, 10))
+	}
+	return nil
+}
+
+// LIRS论文使用的格式，每行一个块号，“*”开头的行是分隔符
+func parseLIRS(line string, fn func(key string)) error {
+	if strings.HasPrefix(line, "*") {
+		return nil
+	}
+	block, err := strconv.ParseUint(line, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid lirs block %q", line)
+	}
+	fn(strconv.FormatUint(block, 10))
+	return nil
+}
+
+// Wikipedia CDN的格式，每行是“相对时间 哈希后的路径 类型 响应大小 首字节时间”，使用哈希后的路径作为Key
+// 第一个字段不是数字的行是表头
+func parseWikipedia(line string, fn func(key string)) error {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return fmt.Errorf("invalid wikipedia record %q", line)
+	}
+	if _, err := strconv.ParseFloat(fields[0], 64); err != nil {
+		return nil
+	}
+	fn(fields[1])
+	return nil
+}
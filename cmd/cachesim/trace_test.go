@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadTrace(t *testing.T) {
+	tests := []struct {
+		format string
+		trace  string
+		want   []string
+	}{
+		{"plain", "a\nb\n\n a \n", []string{"a", "b", "a"}},
+		{"arc", "10 3 0 1\n5 1 0 2\n", []string{"10", "11", "12", "5"}},
+		{"lirs", "007\n8\n*\n7\n", []string{"7", "8", "7"}},
+		{"wikipedia", "relative_unix\thashed_host_path_query\timage_type\tresponse_size\ttime_firstbyte\n" +
+			"0\t833\tjpeg\t15475\t0.000015\n1\t14\tpng\t2114\t0.000017\n", []string{"833", "14"}},
+	}
+	for _, tt := range tests {
+		var keys []string
+		err := readTrace(strings.NewReader(tt.trace), tt.format, func(key string) {
+			keys = append(keys, key)
+		})
+		if err != nil {
+			t.Fatalf("readTrace(%v) error = %v", tt.format, err)
+		}
+		if !reflect.DeepEqual(keys, tt.want) {
+			t.Errorf("readTrace(%v) = %v, want %v", tt.format, keys, tt.want)
+		}
+	}
+}
+
+func TestReadTrace_Invalid(t *testing.T) {
+	fn := func(key string) {}
+	if err := readTrace(strings.NewReader("1 2\n"), "unknown", fn); err == nil {
+		t.Errorf("readTrace() error = %v, want %v", err, "unknown trace format")
+	}
+	if err := readTrace(strings.NewReader("1 2\n3\n"), "arc", fn); err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Errorf("readTrace() error = %v, want %v", err, "line 2: invalid arc record")
+	}
+	if err := readTrace(strings.NewReader("x\n"), "lirs", fn); err == nil {
+		t.Errorf("readTrace() error = %v, want %v", err, "invalid lirs block")
+	}
+}
@@ -19,6 +19,16 @@ func New() *Hash {
 	return h
 }
 
+// 使用指定的种子，种子相同的哈希函数计算结果相同
+// 可以给每个协程创建一个哈希函数，避免加锁
+func NewWithSeed(seed maphash.Seed) *Hash {
+	h := &Hash{
+		h: &maphash.Hash{},
+	}
+	h.h.SetSeed(seed)
+	return h
+}
+
 // 计算哈希值
 func (h *Hash) Sum64(b []byte) uint64 {
 	h.h.Reset()
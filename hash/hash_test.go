@@ -1,6 +1,7 @@
 package hash
 
 import (
+	"hash/maphash"
 	"strconv"
 	"testing"
 
@@ -36,6 +37,15 @@ func TestString(t *testing.T) {
 	}
 }
 
+func TestNewWithSeed(t *testing.T) {
+	seed := maphash.MakeSeed()
+	a := NewWithSeed(seed).Sum64String("bz")
+	b := NewWithSeed(seed).Sum64String("bz")
+	if a != b {
+		t.Errorf("want %v, but %v", a, b)
+	}
+}
+
 func Benchmark64(b *testing.B) {
 	buf := make([]byte, 8192)
 	for length := 1; length <= cap(buf); length *= 2 {
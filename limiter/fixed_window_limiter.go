@@ -1,6 +1,7 @@
 package limiter
 
 import (
+	"context"
 	"sync"
 	"time"
 )
@@ -23,6 +24,33 @@ func NewFixedWindowLimiter(limit int, window time.Duration) *FixedWindowLimiter
 }
 
 func (l *FixedWindowLimiter) TryAcquire() bool {
+	return l.Allow()
+}
+
+func (l *FixedWindowLimiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+func (l *FixedWindowLimiter) AllowN(n int) bool {
+	_, ok := l.tryAcquireN(n)
+	return ok
+}
+
+func (l *FixedWindowLimiter) Wait(ctx context.Context) error {
+	return l.WaitN(ctx, 1)
+}
+
+func (l *FixedWindowLimiter) WaitN(ctx context.Context, n int) error {
+	if n > l.limit {
+		return ErrExceedsLimit
+	}
+	return wait(ctx, func() (time.Duration, bool) {
+		return l.tryAcquireN(n)
+	})
+}
+
+// 尝试获取n个请求，失败返回到下一个窗口的时间
+func (l *FixedWindowLimiter) tryAcquireN(n int) (time.Duration, bool) {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 	// 获取当前时间
@@ -33,10 +61,10 @@ func (l *FixedWindowLimiter) TryAcquire() bool {
 		l.lastTime = now
 	}
 	// 若到达窗口请求上限，请求失败
-	if l.counter >= l.limit {
-		return false
+	if l.counter+n > l.limit {
+		return l.window - now.Sub(l.lastTime) + time.Nanosecond, false
 	}
-	// 若没到窗口请求上限，计数器+1，请求成功
-	l.counter++
-	return true
+	// 若没到窗口请求上限，计数器+n，请求成功
+	l.counter += n
+	return 0, true
 }
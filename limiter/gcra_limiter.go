@@ -0,0 +1,172 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// GCRA 的一次请求结果
+type GCRAResult struct {
+	Allowed    bool          // 是否允许
+	Remaining  int           // 剩余可以立即通过的请求数
+	RetryAfter time.Duration // 被拒绝时需要等待多久重试，n超过burst时为InfDuration
+	ResetAfter time.Duration // 需要等待多久恢复到满额
+}
+
+// GCRA 的参数，GCRALimiter 和 KeyedGCRA 共用
+type gcra struct {
+	burst    int           // 突发上限
+	period   time.Duration // 从空恢复到满额需要的时间
+	emission time.Duration // 每个请求的间隔
+}
+
+// rate：每秒恢复的请求数
+// burst：一次最多通过的请求数
+func newGCRA(rate Rate, burst int) (gcra, error) {
+	if burst < 1 {
+		return gcra{}, errors.New("burst must be greater than 0")
+	}
+	if rate <= 0 {
+		return gcra{}, errors.New("rate must be greater than 0")
+	}
+	seconds := 1 / float64(rate)
+	if seconds*float64(burst) >= InfDuration.Seconds() {
+		return gcra{}, errors.New("rate is too small for burst")
+	}
+	emission := time.Duration(seconds * float64(time.Second))
+	if emission <= 0 {
+		return gcra{}, errors.New("rate is too large")
+	}
+	return gcra{
+		burst:    burst,
+		period:   emission * time.Duration(burst),
+		emission: emission,
+	}, nil
+}
+
+// 尝试获取n个请求，返回结果和新的理论到达时间，拒绝时理论到达时间不变
+// oldTat和now是纳秒时间戳，oldTat早于now表示满额
+func (g gcra) take(oldTat, now int64, n int) (GCRAResult, int64) {
+	tat := oldTat
+	if tat < now {
+		tat = now
+	}
+	// 超过突发上限，永远不可能成功
+	if n > g.burst {
+		return GCRAResult{
+			Remaining:  g.remaining(tat, now),
+			RetryAfter: InfDuration,
+			ResetAfter: time.Duration(tat - now),
+		}, oldTat
+	}
+
+	// 通过后的理论到达时间不能超过当前时间+period
+	newTat := tat + int64(g.emission)*int64(n)
+	if allowAt := newTat - int64(g.period); allowAt > now {
+		return GCRAResult{
+			Remaining:  g.remaining(tat, now),
+			RetryAfter: time.Duration(allowAt - now),
+			ResetAfter: time.Duration(tat - now),
+		}, oldTat
+	}
+	return GCRAResult{
+		Allowed:    true,
+		Remaining:  g.remaining(newTat, now),
+		ResetAfter: time.Duration(newTat - now),
+	}, newTat
+}
+
+// 理论到达时间为tat时剩余可以立即通过的请求数
+func (g gcra) remaining(tat, now int64) int {
+	return int((g.period - time.Duration(tat-now)) / g.emission)
+}
+
+// GCRALimiter 通用信元速率算法（Generic Cell Rate Algorithm）限流器
+// 只保存一个理论到达时间（TAT），每次请求都是O(1)的，适合按Key限流和持久化
+// 每隔 1/rate 秒恢复一个请求，最多可以一次通过burst个请求
+type GCRALimiter struct {
+	gcra
+	tat   int64            // 理论到达时间，纳秒时间戳，早于当前时间表示满额
+	now   func() time.Time // 获取当前时间，测试时可以替换
+	mutex sync.Mutex       // 避免并发问题
+}
+
+// rate：每秒恢复的请求数，比如 Every(time.Millisecond) 或者 Per(5, time.Minute)
+// burst：一次最多通过的请求数
+func NewGCRALimiter(rate Rate, burst int) (*GCRALimiter, error) {
+	g, err := newGCRA(rate, burst)
+	if err != nil {
+		return nil, err
+	}
+	return &GCRALimiter{
+		gcra: g,
+		now:  time.Now,
+	}, nil
+}
+
+// 理论到达时间，可以用于持久化
+func (l *GCRALimiter) TAT() time.Time {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return tatTime(l.tat)
+}
+
+// 设置理论到达时间，可以用于从持久化的状态恢复
+func (l *GCRALimiter) SetTAT(tat time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.tat = tatNanos(tat)
+}
+
+func (l *GCRALimiter) TryAcquire() bool {
+	return l.Allow()
+}
+
+func (l *GCRALimiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+func (l *GCRALimiter) AllowN(n int) bool {
+	return l.Take(n).Allowed
+}
+
+func (l *GCRALimiter) Wait(ctx context.Context) error {
+	return l.WaitN(ctx, 1)
+}
+
+func (l *GCRALimiter) WaitN(ctx context.Context, n int) error {
+	if n > l.burst {
+		return ErrExceedsLimit
+	}
+	return wait(ctx, func() (time.Duration, bool) {
+		r := l.Take(n)
+		return r.RetryAfter, r.Allowed
+	})
+}
+
+// 尝试获取n个请求，返回是否允许、剩余请求数和需要等待的时间
+func (l *GCRALimiter) Take(n int) GCRAResult {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	r, tat := l.take(l.tat, l.now().UnixNano(), n)
+	l.tat = tat
+	return r
+}
+
+// 纳秒时间戳转换成理论到达时间，0表示没有请求过
+func tatTime(tat int64) time.Time {
+	if tat == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, tat)
+}
+
+// 理论到达时间转换成纳秒时间戳，零值表示没有请求过
+func tatNanos(tat time.Time) int64 {
+	if tat.IsZero() {
+		return 0
+	}
+	return tat.UnixNano()
+}
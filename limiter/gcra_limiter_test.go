@@ -0,0 +1,81 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewGCRALimiter(t *testing.T) {
+	if _, err := NewGCRALimiter(10, 0); err == nil {
+		t.Errorf("NewGCRALimiter() error = %v, want not nil", err)
+	}
+	if _, err := NewGCRALimiter(0, 10); err == nil {
+		t.Errorf("NewGCRALimiter() error = %v, want not nil", err)
+	}
+	if _, err := NewGCRALimiter(Every(0), 10); err == nil {
+		t.Errorf("NewGCRALimiter() error = %v, want not nil", err)
+	}
+
+	// 速率和突发上限相互独立
+	l, err := NewGCRALimiter(Per(1, time.Second), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.emission != time.Second || l.period != time.Second*10 {
+		t.Errorf("emission = %v, period = %v, want %v, %v", l.emission, l.period, time.Second, time.Second*10)
+	}
+}
+
+func TestGCRALimiter_Take(t *testing.T) {
+	l, err := NewGCRALimiter(10, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	l.now = func() time.Time {
+		return now
+	}
+	// 一开始是满额
+	r := l.Take(4)
+	if !r.Allowed || r.Remaining != 6 || r.RetryAfter != 0 || r.ResetAfter != time.Millisecond*400 {
+		t.Errorf("Take() = %+v, want allowed with %v remaining", r, 6)
+	}
+
+	// 不够时返回需要等待的时间，不消耗额度
+	r = l.Take(8)
+	if r.Allowed || r.Remaining != 6 || r.RetryAfter != time.Millisecond*200 {
+		t.Errorf("Take() = %+v, want not allowed with %v remaining", r, 6)
+	}
+	if r = l.Take(11); r.Allowed || r.RetryAfter != InfDuration {
+		t.Errorf("Take() = %+v, want RetryAfter %v", r, InfDuration)
+	}
+
+	// 等待后恢复
+	now = now.Add(time.Millisecond * 200)
+	if r = l.Take(8); !r.Allowed || r.Remaining != 0 {
+		t.Errorf("Take() = %+v, want allowed with %v remaining", r, 0)
+	}
+	if l.Allow() {
+		t.Errorf("Allow() = %v, want %v", true, false)
+	}
+}
+
+func TestGCRALimiter_SetTAT(t *testing.T) {
+	l, err := NewGCRALimiter(2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.TAT().IsZero() || !l.AllowN(2) {
+		t.Errorf("AllowN() = %v, want %v", false, true)
+	}
+
+	// 从保存的状态恢复
+	restored, err := NewGCRALimiter(2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored.SetTAT(l.TAT())
+	if restored.Allow() {
+		t.Errorf("Allow() = %v, want %v", true, false)
+	}
+}
@@ -0,0 +1,117 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jiaxwu/gommon/cache/lru"
+	"github.com/jiaxwu/gommon/math"
+)
+
+// 创建Key对应的限流器
+type NewLimiterFunc[K comparable] func(key K) Limiter
+
+// 计算Key的哈希值，用于选择分片
+type HashFunc[K comparable] func(key K) uint64
+
+// 分片
+type keyedShard[K comparable] struct {
+	limiters *lru.Cache[K, Limiter]
+	mutex    sync.Mutex
+}
+
+// 按Key限流，比如每个用户或者每个IP一个限流器
+// 第一次访问Key时创建限流器，最多保存capacity个Key，超过时淘汰最久没有访问的Key
+// 被淘汰的Key再次访问时会创建新的限流器，之前的限流状态会丢失
+// 把Key哈希到多个分片，每个分片有独立的锁，不同分片的Key可以并行检查
+// 线程安全
+type Keyed[K comparable] struct {
+	shards     []*keyedShard[K]
+	mask       uint64
+	hashFunc   HashFunc[K]
+	newLimiter NewLimiterFunc[K]
+	ttl        time.Duration // 空闲多久淘汰，0表示只按容量淘汰
+}
+
+// shards：分片数量，会向上取整到2的幂
+// capacity：最多保存的Key数量，会平均分到每个分片
+func NewKeyed[K comparable](newLimiter NewLimiterFunc[K], hashFunc HashFunc[K], capacity, shards int) *Keyed[K] {
+	if shards < 1 {
+		panic("too small shards")
+	}
+	shardCnt := math.RoundUpPowOf2(uint(shards))
+	if capacity < int(shardCnt) {
+		panic("too small capacity")
+	}
+	shardCap, _ := math.Split(uint(capacity), shardCnt)
+	k := &Keyed[K]{
+		shards:     make([]*keyedShard[K], shardCnt),
+		mask:       uint64(shardCnt - 1),
+		hashFunc:   hashFunc,
+		newLimiter: newLimiter,
+	}
+	for i := range k.shards {
+		k.shards[i] = &keyedShard[K]{
+			limiters: lru.New[K, Limiter](int(shardCap)),
+		}
+	}
+	return k
+}
+
+// 设置空闲时间，超过该时间没有访问的Key会被淘汰，小于等于0表示只按容量淘汰
+// 需要在使用前设置
+func (k *Keyed[K]) SetIdleTTL(ttl time.Duration) {
+	k.ttl = ttl
+}
+
+// 获取Key对应的限流器，不存在则创建
+func (k *Keyed[K]) Limiter(key K) Limiter {
+	s := k.shards[k.hashFunc(key)&k.mask]
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	l, ok := s.limiters.Get(key)
+	if !ok {
+		l = k.newLimiter(key)
+	} else if k.ttl <= 0 {
+		return l
+	}
+	// 每次访问都刷新过期时间
+	s.limiters.PutWithTTL(key, l, k.ttl)
+	return l
+}
+
+func (k *Keyed[K]) Allow(key K) bool {
+	return k.Limiter(key).Allow()
+}
+
+func (k *Keyed[K]) AllowN(key K, n int) bool {
+	return k.Limiter(key).AllowN(n)
+}
+
+func (k *Keyed[K]) Wait(ctx context.Context, key K) error {
+	return k.Limiter(key).Wait(ctx)
+}
+
+func (k *Keyed[K]) WaitN(ctx context.Context, key K, n int) error {
+	return k.Limiter(key).WaitN(ctx, n)
+}
+
+// 删除Key对应的限流器
+func (k *Keyed[K]) Remove(key K) bool {
+	s := k.shards[k.hashFunc(key)&k.mask]
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.limiters.Remove(key)
+}
+
+// 保存的Key数量，可能包含已经过期但还没被删除的Key
+func (k *Keyed[K]) Len() int {
+	n := 0
+	for _, s := range k.shards {
+		s.mutex.Lock()
+		n += s.limiters.Len()
+		s.mutex.Unlock()
+	}
+	return n
+}
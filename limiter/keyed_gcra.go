@@ -0,0 +1,161 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jiaxwu/gommon/math"
+)
+
+// 分片至少达到这个大小才清理
+const minGCRASweepSize = 64
+
+// 分片
+type gcraShard[K comparable] struct {
+	tats      map[K]int64 // 每个Key的理论到达时间，纳秒时间戳
+	sweepSize int         // Key数量达到这个大小时清理已经满额的Key
+	mutex     sync.Mutex
+}
+
+// 按Key的GCRA限流，所有Key共用同样的rate和burst
+// 每个Key只保存一个理论到达时间，比每个Key一个 Limiter 省内存
+// 理论到达时间早于当前时间的Key已经恢复满额，和没有记录一样，Key数量翻倍时会被清理
+// 把Key哈希到多个分片，每个分片有独立的锁，不同分片的Key可以并行检查
+// 线程安全
+type KeyedGCRA[K comparable] struct {
+	gcra
+	shards   []*gcraShard[K]
+	mask     uint64
+	hashFunc HashFunc[K]
+	now      func() time.Time // 获取当前时间，测试时可以替换
+}
+
+// rate：每个Key每秒恢复的请求数
+// burst：每个Key一次最多通过的请求数
+// shards：分片数量，会向上取整到2的幂
+func NewKeyedGCRA[K comparable](rate Rate, burst int, hashFunc HashFunc[K], shards int) (*KeyedGCRA[K], error) {
+	if shards < 1 {
+		return nil, errors.New("shards must be greater than 0")
+	}
+	g, err := newGCRA(rate, burst)
+	if err != nil {
+		return nil, err
+	}
+	shardCnt := math.RoundUpPowOf2(uint(shards))
+	k := &KeyedGCRA[K]{
+		gcra:     g,
+		shards:   make([]*gcraShard[K], shardCnt),
+		mask:     uint64(shardCnt - 1),
+		hashFunc: hashFunc,
+		now:      time.Now,
+	}
+	for i := range k.shards {
+		k.shards[i] = &gcraShard[K]{
+			tats:      make(map[K]int64),
+			sweepSize: minGCRASweepSize,
+		}
+	}
+	return k, nil
+}
+
+func (k *KeyedGCRA[K]) Allow(key K) bool {
+	return k.AllowN(key, 1)
+}
+
+func (k *KeyedGCRA[K]) AllowN(key K, n int) bool {
+	return k.Take(key, n).Allowed
+}
+
+func (k *KeyedGCRA[K]) Wait(ctx context.Context, key K) error {
+	return k.WaitN(ctx, key, 1)
+}
+
+func (k *KeyedGCRA[K]) WaitN(ctx context.Context, key K, n int) error {
+	if n > k.burst {
+		return ErrExceedsLimit
+	}
+	return wait(ctx, func() (time.Duration, bool) {
+		r := k.Take(key, n)
+		return r.RetryAfter, r.Allowed
+	})
+}
+
+// 尝试为Key获取n个请求，返回是否允许、剩余请求数和需要等待的时间
+func (k *KeyedGCRA[K]) Take(key K, n int) GCRAResult {
+	s := k.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := k.now().UnixNano()
+	r, tat := k.take(s.tats[key], now, n)
+	// 满额的Key不需要记录
+	if tat > now {
+		s.tats[key] = tat
+		k.sweep(s, now)
+	}
+	return r
+}
+
+// Key的理论到达时间，可以用于持久化，没有记录返回零值
+func (k *KeyedGCRA[K]) TAT(key K) time.Time {
+	s := k.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return tatTime(s.tats[key])
+}
+
+// 设置Key的理论到达时间，可以用于从持久化的状态恢复
+func (k *KeyedGCRA[K]) SetTAT(key K, tat time.Time) {
+	s := k.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if tat.IsZero() {
+		delete(s.tats, key)
+		return
+	}
+	s.tats[key] = tatNanos(tat)
+	k.sweep(s, k.now().UnixNano())
+}
+
+// 删除Key的记录，相当于恢复满额
+func (k *KeyedGCRA[K]) Remove(key K) bool {
+	s := k.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, ok := s.tats[key]
+	delete(s.tats, key)
+	return ok
+}
+
+// 保存的Key数量，可能包含已经满额但还没被清理的Key
+func (k *KeyedGCRA[K]) Len() int {
+	n := 0
+	for _, s := range k.shards {
+		s.mutex.Lock()
+		n += len(s.tats)
+		s.mutex.Unlock()
+	}
+	return n
+}
+
+// 获取Key所在的分片
+func (k *KeyedGCRA[K]) shard(key K) *gcraShard[K] {
+	return k.shards[k.hashFunc(key)&k.mask]
+}
+
+// Key数量达到清理大小时删除已经满额的Key，下次清理大小是剩余数量的两倍，均摊O(1)
+func (k *KeyedGCRA[K]) sweep(s *gcraShard[K], now int64) {
+	if len(s.tats) < s.sweepSize {
+		return
+	}
+	for key, tat := range s.tats {
+		if tat <= now {
+			delete(s.tats, key)
+		}
+	}
+	s.sweepSize = len(s.tats) * 2
+	if s.sweepSize < minGCRASweepSize {
+		s.sweepSize = minGCRASweepSize
+	}
+}
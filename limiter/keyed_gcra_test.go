@@ -0,0 +1,118 @@
+package limiter
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jiaxwu/gommon/hash"
+)
+
+func newKeyedGCRA(t *testing.T, shards int) *KeyedGCRA[string] {
+	h := hash.New()
+	var mutex sync.Mutex
+	hashFunc := func(key string) uint64 {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return h.Sum64String(key)
+	}
+	k, err := NewKeyedGCRA[string](Per(2, time.Second), 2, hashFunc, shards)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestNewKeyedGCRA(t *testing.T) {
+	hashFunc := func(key string) uint64 { return 0 }
+	if _, err := NewKeyedGCRA[string](10, 10, hashFunc, 0); err == nil {
+		t.Errorf("NewKeyedGCRA() error = %v, want not nil", err)
+	}
+	if _, err := NewKeyedGCRA[string](10, 0, hashFunc, 1); err == nil {
+		t.Errorf("NewKeyedGCRA() error = %v, want not nil", err)
+	}
+}
+
+func TestKeyedGCRA_Allow(t *testing.T) {
+	k := newKeyedGCRA(t, 4)
+	now := time.Now()
+	k.now = func() time.Time {
+		return now
+	}
+	// 不同Key相互独立
+	for _, key := range []string{"a", "b"} {
+		if !k.Allow(key) || !k.Allow(key) {
+			t.Errorf("Allow(%v) = %v, want %v", key, false, true)
+		}
+		if r := k.Take(key, 1); r.Allowed || r.RetryAfter != time.Millisecond*500 {
+			t.Errorf("Take(%v) = %+v, want RetryAfter %v", key, r, time.Millisecond*500)
+		}
+	}
+	if k.Len() != 2 {
+		t.Errorf("Len() = %v, want %v", k.Len(), 2)
+	}
+	if err := k.WaitN(context.Background(), "c", 3); err != ErrExceedsLimit {
+		t.Errorf("WaitN() error = %v, want %v", err, ErrExceedsLimit)
+	}
+
+	// 删除后恢复满额
+	if !k.Remove("a") || k.Remove("a") {
+		t.Errorf("Remove() = %v, want %v", false, true)
+	}
+	if !k.AllowN("a", 2) {
+		t.Errorf("AllowN() = %v, want %v", false, true)
+	}
+
+	// 从保存的状态恢复
+	k.SetTAT("c", k.TAT("b"))
+	if k.Allow("c") {
+		t.Errorf("Allow() = %v, want %v", true, false)
+	}
+	now = now.Add(time.Millisecond * 500)
+	if !k.Allow("c") {
+		t.Errorf("Allow() = %v, want %v", false, true)
+	}
+}
+
+func TestKeyedGCRA_Sweep(t *testing.T) {
+	k := newKeyedGCRA(t, 1)
+	now := time.Now()
+	k.now = func() time.Time {
+		return now
+	}
+	for i := 0; i < minGCRASweepSize-1; i++ {
+		k.Allow(strconv.Itoa(i))
+	}
+	// 满额的Key在Key数量达到清理大小时被清理
+	now = now.Add(time.Second)
+	k.Allow("x")
+	if k.Len() != 1 {
+		t.Errorf("Len() = %v, want %v", k.Len(), 1)
+	}
+}
+
+func TestKeyedGCRA_Concurrent(t *testing.T) {
+	k := newKeyedGCRA(t, 16)
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	successCount := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if k.Allow(strconv.Itoa(j)) {
+					mutex.Lock()
+					successCount++
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if successCount != 200 {
+		t.Errorf("successCount = %v, want %v", successCount, 200)
+	}
+}
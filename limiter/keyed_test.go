@@ -0,0 +1,106 @@
+package limiter
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jiaxwu/gommon/hash"
+)
+
+func newKeyed(capacity, shards int) *Keyed[string] {
+	h := hash.New()
+	var mutex sync.Mutex
+	hashFunc := func(key string) uint64 {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return h.Sum64String(key)
+	}
+	newLimiter := func(key string) Limiter {
+		return NewFixedWindowLimiter(2, time.Minute)
+	}
+	return NewKeyed[string](newLimiter, hashFunc, capacity, shards)
+}
+
+func TestKeyed_Allow(t *testing.T) {
+	k := newKeyed(100, 4)
+	// 不同Key的限流器相互独立
+	for _, key := range []string{"a", "b"} {
+		if !k.Allow(key) || !k.Allow(key) {
+			t.Errorf("Allow(%v) = %v, want %v", key, false, true)
+		}
+		if k.Allow(key) {
+			t.Errorf("Allow(%v) = %v, want %v", key, true, false)
+		}
+	}
+	if k.Len() != 2 {
+		t.Errorf("Len() = %v, want %v", k.Len(), 2)
+	}
+	if err := k.WaitN(context.Background(), "c", 3); err != ErrExceedsLimit {
+		t.Errorf("WaitN() error = %v, want %v", err, ErrExceedsLimit)
+	}
+
+	// 删除后重新创建限流器
+	if !k.Remove("a") || k.Remove("a") {
+		t.Errorf("Remove() = %v, want %v", false, true)
+	}
+	if !k.AllowN("a", 2) {
+		t.Errorf("AllowN() = %v, want %v", false, true)
+	}
+}
+
+func TestKeyed_Capacity(t *testing.T) {
+	k := newKeyed(8, 2)
+	for i := 0; i < 100; i++ {
+		k.Allow(strconv.Itoa(i))
+	}
+	if k.Len() > 8 {
+		t.Errorf("Len() = %v, want <= %v", k.Len(), 8)
+	}
+}
+
+func TestKeyed_SetIdleTTL(t *testing.T) {
+	k := newKeyed(100, 1)
+	k.SetIdleTTL(time.Millisecond * 50)
+	if !k.AllowN("a", 2) || k.Allow("a") {
+		t.Errorf("AllowN() = %v, want %v", false, true)
+	}
+	// 一直访问不会过期
+	for i := 0; i < 3; i++ {
+		time.Sleep(time.Millisecond * 30)
+		if k.Allow("a") {
+			t.Errorf("Allow() = %v, want %v", true, false)
+		}
+	}
+	// 空闲过期后重新创建限流器
+	time.Sleep(time.Millisecond * 60)
+	if !k.Allow("a") {
+		t.Errorf("Allow() = %v, want %v", false, true)
+	}
+}
+
+func TestKeyed_Concurrent(t *testing.T) {
+	k := newKeyed(1000, 16)
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	successCount := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if k.Allow(strconv.Itoa(j)) {
+					mutex.Lock()
+					successCount++
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if successCount != 200 {
+		t.Errorf("successCount = %v, want %v", successCount, 200)
+	}
+}
@@ -1,6 +1,7 @@
 package limiter
 
 import (
+	"context"
 	"sync"
 	"time"
 )
@@ -23,26 +24,99 @@ func NewLeakyBucketLimiter(peakLevel, currentVelocity int) *LeakyBucketLimiter {
 }
 
 func (l *LeakyBucketLimiter) TryAcquire() bool {
+	return l.Allow()
+}
+
+func (l *LeakyBucketLimiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+func (l *LeakyBucketLimiter) AllowN(n int) bool {
+	_, ok := l.tryAcquireN(n)
+	return ok
+}
+
+func (l *LeakyBucketLimiter) Wait(ctx context.Context) error {
+	return l.WaitN(ctx, 1)
+}
+
+func (l *LeakyBucketLimiter) WaitN(ctx context.Context, n int) error {
+	if n > l.peakLevel {
+		return ErrExceedsLimit
+	}
+	return wait(ctx, func() (time.Duration, bool) {
+		return l.tryAcquireN(n)
+	})
+}
+
+// 预留n个请求，超过最高水位时先记上，返回的预留在水位降到最高水位以下时才能执行请求
+// n超过最高水位时预留失败
+func (l *LeakyBucketLimiter) Reserve(n int) *Reservation {
+	if n > l.peakLevel {
+		return failedReservation()
+	}
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 
-	// 尝试放水
 	now := time.Now()
+	interval := l.leak(now)
+	l.currentLevel += n
+	r := &Reservation{
+		ok:        true,
+		n:         n,
+		timeToAct: now,
+		now:       time.Now,
+		restore: func(n int) {
+			l.mutex.Lock()
+			defer l.mutex.Unlock()
+			l.currentLevel = maxInt(0, l.currentLevel-n)
+		},
+	}
+	// 超过最高水位，等待放水
+	if l.currentLevel > l.peakLevel {
+		r.timeToAct = now.Add(l.delay(l.currentLevel-l.peakLevel, interval))
+	}
+	return r
+}
+
+// 尝试加入n个请求，失败返回水位降到能放下n个请求的时间
+func (l *LeakyBucketLimiter) tryAcquireN(n int) (time.Duration, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// 尝试放水
+	interval := l.leak(time.Now())
+
+	// 若超过最高水位，请求失败
+	if l.currentLevel+n > l.peakLevel {
+		return l.delay(l.currentLevel+n-l.peakLevel, interval), false
+	}
+	// 若没有超过最高水位，当前水位+n，请求成功
+	l.currentLevel += n
+	return 0, true
+}
+
+// 放水，返回距离上次放水的时间
+func (l *LeakyBucketLimiter) leak(now time.Time) time.Duration {
 	// 距离上次放水的时间
 	interval := now.Sub(l.lastTime)
 	if interval >= time.Second {
 		// 当前水位-距离上次放水的时间(秒)*水流速度
 		l.currentLevel = maxInt(0, l.currentLevel-int(interval/time.Second)*l.currentVelocity)
 		l.lastTime = now
+		interval = 0
 	}
+	return interval
+}
 
-	// 若到达最高水位，请求失败
-	if l.currentLevel >= l.peakLevel {
-		return false
+// 水位需要下降n时需要等待的时间
+func (l *LeakyBucketLimiter) delay(n int, interval time.Duration) time.Duration {
+	// 每秒放一次水，需要放水的次数向上取整
+	seconds := 1
+	if l.currentVelocity > 0 {
+		seconds = (n + l.currentVelocity - 1) / l.currentVelocity
 	}
-	// 若没有到达最高水位，当前水位+1，请求成功
-	l.currentLevel++
-	return true
+	return time.Duration(seconds)*time.Second - interval
 }
 
 func maxInt(a, b int) int {
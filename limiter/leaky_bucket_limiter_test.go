@@ -53,3 +53,26 @@ func TestNewLeakyBucketLimiter(t *testing.T) {
 		})
 	}
 }
+
+func TestLeakyBucketLimiter_Reserve(t *testing.T) {
+	l := NewLeakyBucketLimiter(2, 2)
+	if r := l.Reserve(3); r.OK() || r.Delay() != InfDuration {
+		t.Errorf("Reserve() = %v, %v, want %v, %v", r.OK(), r.Delay(), false, InfDuration)
+	}
+	if r := l.Reserve(2); !r.OK() || r.Delay() != 0 {
+		t.Errorf("Reserve() = %v, %v, want %v, %v", r.OK(), r.Delay(), true, 0)
+	}
+
+	// 超过最高水位时等待放水
+	r := l.Reserve(1)
+	if !r.OK() || r.Delay() <= time.Millisecond*900 || r.Delay() > time.Second {
+		t.Errorf("Delay() = %v, want about %v", r.Delay(), time.Second)
+	}
+	r.Cancel()
+	if l.currentLevel != 2 {
+		t.Errorf("currentLevel = %v, want %v", l.currentLevel, 2)
+	}
+	if l.Allow() {
+		t.Errorf("Allow() = %v, want %v", true, false)
+	}
+}
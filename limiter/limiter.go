@@ -0,0 +1,68 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// 一次请求的数量超过限流器的上限，永远不可能成功
+var ErrExceedsLimit = errors.New("n exceeds limit")
+
+// 限流器，所有限流器都实现了该接口
+type Limiter interface {
+	// 是否允许一个请求，不阻塞
+	Allow() bool
+	// 是否允许n个请求，不阻塞，要么都允许要么都拒绝
+	AllowN(n int) bool
+	// 阻塞直到允许一个请求，或者ctx被关闭
+	Wait(ctx context.Context) error
+	// 阻塞直到允许n个请求，或者ctx被关闭
+	// n超过上限返回ErrExceedsLimit
+	WaitN(ctx context.Context, n int) error
+}
+
+// 阻塞直到获取成功，或者ctx被关闭
+// tryAcquire失败时返回需要等待的时间，等待后重试
+func wait(ctx context.Context, tryAcquire func() (time.Duration, bool)) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		delay, ok := tryAcquire()
+		if ok {
+			return nil
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// 滑动窗口中需要等待多久才能再放下n个请求
+// 从最早的小窗口开始，小窗口滑出窗口后它的请求就不再计数
+func slidingWait(counters map[int64]int, startSmallWindow, smallWindow, smallWindows int64, count, limit, n int, now int64) time.Duration {
+	smallWindowsInUse := make([]int64, 0, len(counters))
+	for w := range counters {
+		if w >= startSmallWindow {
+			smallWindowsInUse = append(smallWindowsInUse, w)
+		}
+	}
+	sort.Slice(smallWindowsInUse, func(i, j int) bool {
+		return smallWindowsInUse[i] < smallWindowsInUse[j]
+	})
+
+	excess := count + n - limit
+	for _, w := range smallWindowsInUse {
+		excess -= counters[w]
+		if excess <= 0 {
+			return time.Duration(w + smallWindow*smallWindows - now)
+		}
+	}
+	return time.Duration(smallWindow)
+}
@@ -0,0 +1,85 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newLimiters(t *testing.T) map[string]Limiter {
+	slidingWindow, err := NewSlidingWindowLimiter(2, time.Millisecond*100, time.Millisecond*10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slidingLog, err := NewSlidingLogLimiter(time.Millisecond*10,
+		NewSlidingLogLimiterStrategy(2, time.Millisecond*100),
+		NewSlidingLogLimiterStrategy(10, time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcra, err := NewGCRALimiter(Per(2, time.Millisecond*100), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenBucket := NewTokenBucketLimiter(2, 2)
+	tokenBucket.tokens = 2
+	return map[string]Limiter{
+		"fixed_window":   NewFixedWindowLimiter(2, time.Millisecond*100),
+		"sliding_window": slidingWindow,
+		"sliding_log":    slidingLog,
+		"token_bucket":   tokenBucket,
+		"leaky_bucket":   NewLeakyBucketLimiter(2, 2),
+		"gcra":           gcra,
+	}
+}
+
+func TestLimiter_AllowN(t *testing.T) {
+	for name, l := range newLimiters(t) {
+		t.Run(name, func(t *testing.T) {
+			if l.AllowN(3) {
+				t.Errorf("AllowN() = %v, want %v", true, false)
+			}
+			if !l.AllowN(2) {
+				t.Errorf("AllowN() = %v, want %v", false, true)
+			}
+			if l.Allow() {
+				t.Errorf("Allow() = %v, want %v", true, false)
+			}
+		})
+	}
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	for name := range newLimiters(t) {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			// 并行的子测试可能很晚才开始，在子测试里面创建，避免窗口在测试中途切换
+			l := newLimiters(t)[name]
+			if err := l.WaitN(context.Background(), 3); err != ErrExceedsLimit {
+				t.Errorf("WaitN() error = %v, want %v", err, ErrExceedsLimit)
+			}
+			l.AllowN(2)
+
+			// ctx被关闭时返回
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+			defer cancel()
+			if err := l.Wait(ctx); err != context.DeadlineExceeded {
+				t.Errorf("Wait() error = %v, want %v", err, context.DeadlineExceeded)
+			}
+
+			// 等待到有空闲
+			start := time.Now()
+			if err := l.WaitN(context.Background(), 2); err != nil {
+				t.Errorf("WaitN() error = %v, want %v", err, nil)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second*2 {
+				t.Errorf("elapsed = %v, want <= %v", elapsed, time.Second*2)
+			}
+			if l.Allow() {
+				t.Errorf("Allow() = %v, want %v", true, false)
+			}
+		})
+	}
+}
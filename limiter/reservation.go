@@ -0,0 +1,60 @@
+package limiter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// 无限长的等待时间，预留失败时 Delay() 返回该值
+const InfDuration = time.Duration(math.MaxInt64)
+
+// 预留，表示预留的请求在 Delay() 之后才能执行
+// 不执行请求时需要调用 Cancel() 归还
+type Reservation struct {
+	ok        bool
+	n         int
+	timeToAct time.Time
+	restore   func(n int)      // 归还n个请求
+	now       func() time.Time // 限流器的时钟
+	mutex     sync.Mutex
+	canceled  bool
+}
+
+// 预留失败
+func failedReservation() *Reservation {
+	return &Reservation{}
+}
+
+// 是否预留成功，请求数量超过上限会失败
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+// 还需要等待多久才能执行请求，预留失败返回InfDuration
+func (r *Reservation) Delay() time.Duration {
+	if !r.ok {
+		return InfDuration
+	}
+	delay := r.timeToAct.Sub(r.now())
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// 取消预留，归还预留的请求，多次调用只会归还一次
+// 已经过了执行时间的预留视为已经使用，不会归还
+// 取消后不能再执行请求
+func (r *Reservation) Cancel() {
+	if !r.ok {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.canceled || r.timeToAct.Before(r.now()) {
+		return
+	}
+	r.canceled = true
+	r.restore(r.n)
+}
@@ -1,6 +1,7 @@
 package limiter
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sort"
@@ -80,11 +81,44 @@ func NewSlidingLogLimiter(smallWindow time.Duration, strategies ...*SlidingLogLi
 }
 
 func (l *SlidingLogLimiter) TryAcquire() error {
+	if _, err := l.tryAcquireN(1); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (l *SlidingLogLimiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+func (l *SlidingLogLimiter) AllowN(n int) bool {
+	_, err := l.tryAcquireN(n)
+	return err == nil
+}
+
+func (l *SlidingLogLimiter) Wait(ctx context.Context) error {
+	return l.WaitN(ctx, 1)
+}
+
+func (l *SlidingLogLimiter) WaitN(ctx context.Context, n int) error {
+	// 窗口时间小的策略上限最小
+	if n > l.strategies[len(l.strategies)-1].limit {
+		return ErrExceedsLimit
+	}
+	return wait(ctx, func() (time.Duration, bool) {
+		delay, err := l.tryAcquireN(n)
+		return delay, err == nil
+	})
+}
+
+// 尝试获取n个请求，失败返回违背的第一个策略，以及所有策略都满足需要等待的时间
+func (l *SlidingLogLimiter) tryAcquireN(n int) (time.Duration, *ViolationStrategyError) {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 
 	// 获取当前小窗口值
-	currentSmallWindow := time.Now().UnixNano() / l.smallWindow * l.smallWindow
+	now := time.Now().UnixNano()
+	currentSmallWindow := now / l.smallWindow * l.smallWindow
 	// 获取每个策略的起始小窗口值
 	startSmallWindows := make([]int64, len(l.strategies))
 	for i, strategy := range l.strategies {
@@ -105,17 +139,30 @@ func (l *SlidingLogLimiter) TryAcquire() error {
 		}
 	}
 
-	// 若到达对应策略窗口请求上限，请求失败，返回违背的策略
+	// 若超过对应策略窗口请求上限，请求失败，返回违背的策略
+	var violation *ViolationStrategyError
+	var delay time.Duration
 	for i, strategy := range l.strategies {
-		if counts[i] >= strategy.limit {
-			return &ViolationStrategyError{
+		if counts[i]+n <= strategy.limit {
+			continue
+		}
+		if violation == nil {
+			violation = &ViolationStrategyError{
 				Limit:  strategy.limit,
 				Window: time.Duration(strategy.window),
 			}
 		}
+		// 需要等待所有违背的策略都满足
+		wait := slidingWait(l.counters, startSmallWindows[i], l.smallWindow, strategy.smallWindows, counts[i], strategy.limit, n, now)
+		if wait > delay {
+			delay = wait
+		}
+	}
+	if violation != nil {
+		return delay, violation
 	}
 
-	// 若没到窗口请求上限，当前小窗口计数器+1，请求成功
-	l.counters[currentSmallWindow]++
-	return nil
+	// 若没超过窗口请求上限，当前小窗口计数器+n，请求成功
+	l.counters[currentSmallWindow] += n
+	return 0, nil
 }
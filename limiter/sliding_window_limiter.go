@@ -1,6 +1,7 @@
 package limiter
 
 import (
+	"context"
 	"errors"
 	"sync"
 	"time"
@@ -32,11 +33,39 @@ func NewSlidingWindowLimiter(limit int, window, smallWindow time.Duration) (*Sli
 }
 
 func (l *SlidingWindowLimiter) TryAcquire() bool {
+	return l.Allow()
+}
+
+func (l *SlidingWindowLimiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+func (l *SlidingWindowLimiter) AllowN(n int) bool {
+	_, ok := l.tryAcquireN(n)
+	return ok
+}
+
+func (l *SlidingWindowLimiter) Wait(ctx context.Context) error {
+	return l.WaitN(ctx, 1)
+}
+
+func (l *SlidingWindowLimiter) WaitN(ctx context.Context, n int) error {
+	if n > l.limit {
+		return ErrExceedsLimit
+	}
+	return wait(ctx, func() (time.Duration, bool) {
+		return l.tryAcquireN(n)
+	})
+}
+
+// 尝试获取n个请求，失败返回足够多的请求滑出窗口的时间
+func (l *SlidingWindowLimiter) tryAcquireN(n int) (time.Duration, bool) {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 
 	// 获取当前小窗口值
-	currentSmallWindow := time.Now().UnixNano() / l.smallWindow * l.smallWindow
+	now := time.Now().UnixNano()
+	currentSmallWindow := now / l.smallWindow * l.smallWindow
 	// 获取起始小窗口值
 	startSmallWindow := currentSmallWindow - l.smallWindow*(l.smallWindows-1)
 
@@ -50,11 +79,11 @@ func (l *SlidingWindowLimiter) TryAcquire() bool {
 		}
 	}
 
-	// 若到达窗口请求上限，请求失败
-	if count >= l.limit {
-		return false
+	// 若超过窗口请求上限，请求失败
+	if count+n > l.limit {
+		return slidingWait(l.counters, startSmallWindow, l.smallWindow, l.smallWindows, count, l.limit, n, now), false
 	}
-	// 若没到窗口请求上限，当前小窗口计数器+1，请求成功
-	l.counters[currentSmallWindow]++
-	return true
+	// 若没超过窗口请求上限，当前小窗口计数器+n，请求成功
+	l.counters[currentSmallWindow] += n
+	return 0, true
 }
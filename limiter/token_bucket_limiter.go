@@ -1,53 +1,185 @@
 package limiter
 
 import (
+	"context"
+	"math"
 	"sync"
 	"time"
 )
 
+// 令牌发放速率，每秒发放的令牌数，可以是小数
+type Rate float64
+
+// 每隔interval发放一个令牌，interval小于等于0表示不限速
+func Every(interval time.Duration) Rate {
+	if interval <= 0 {
+		return Rate(math.Inf(1))
+	}
+	return 1 / Rate(interval.Seconds())
+}
+
+// 每个period发放n个令牌
+func Per(n int, period time.Duration) Rate {
+	if n <= 0 {
+		return 0
+	}
+	return Every(period) * Rate(n)
+}
+
 // TokenBucketLimiter 令牌桶限流器
+// 令牌按时间连续发放，精确到纳秒
 type TokenBucketLimiter struct {
-	capacity      int        // 容量
-	currentTokens int        // 令牌数量
-	rate          int        // 发放令牌速率/秒
-	lastTime      time.Time  // 上次发放令牌时间
-	mutex         sync.Mutex // 避免并发问题
+	capacity int              // 容量
+	tokens   float64          // 令牌数量，可以是小数，有预留时可以是负数
+	rate     Rate             // 发放令牌速率
+	lastTime time.Time        // 上次发放令牌时间
+	now      func() time.Time // 获取当前时间，测试时可以替换
+	mutex    sync.Mutex       // 避免并发问题
 }
 
+// rate：每秒发放的令牌数
 func NewTokenBucketLimiter(capacity, rate int) *TokenBucketLimiter {
+	return NewTokenBucketLimiterWithRate(capacity, Rate(rate))
+}
+
+// rate：令牌发放速率，比如 Every(time.Millisecond) 或者 Per(5, time.Minute)
+func NewTokenBucketLimiterWithRate(capacity int, rate Rate) *TokenBucketLimiter {
 	return &TokenBucketLimiter{
 		capacity: capacity,
 		rate:     rate,
 		lastTime: time.Now(),
+		now:      time.Now,
 	}
 }
 
+// 修改令牌发放速率，修改前已经发放的令牌不会丢失
+func (l *TokenBucketLimiter) SetRate(rate Rate) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.refill(l.now())
+	l.rate = rate
+}
+
+// 修改容量，已经发放的令牌会保留，超过新容量的部分丢弃
+func (l *TokenBucketLimiter) SetBurst(capacity int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.refill(l.now())
+	l.capacity = capacity
+	l.tokens = math.Min(l.tokens, float64(capacity))
+}
+
+// 令牌发放速率
+func (l *TokenBucketLimiter) Rate() Rate {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.rate
+}
+
+// 容量
+func (l *TokenBucketLimiter) Burst() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.capacity
+}
+
 func (l *TokenBucketLimiter) TryAcquire() bool {
+	return l.Allow()
+}
+
+func (l *TokenBucketLimiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+func (l *TokenBucketLimiter) AllowN(n int) bool {
+	_, ok := l.tryAcquireN(n)
+	return ok
+}
+
+func (l *TokenBucketLimiter) Wait(ctx context.Context) error {
+	return l.WaitN(ctx, 1)
+}
+
+func (l *TokenBucketLimiter) WaitN(ctx context.Context, n int) error {
+	if n > l.Burst() {
+		return ErrExceedsLimit
+	}
+	return wait(ctx, func() (time.Duration, bool) {
+		return l.tryAcquireN(n)
+	})
+}
+
+// 预留n个令牌，令牌不够时先欠着，返回的预留在令牌足够时才能执行请求
+// n超过容量，或者令牌不够并且不发放令牌时预留失败
+func (l *TokenBucketLimiter) Reserve(n int) *Reservation {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	l.refill(now)
+	if n > l.capacity || (l.tokens < float64(n) && l.rate <= 0) {
+		return failedReservation()
+	}
+	l.tokens -= float64(n)
+	r := &Reservation{
+		ok:        true,
+		n:         n,
+		timeToAct: now,
+		now:       l.now,
+		restore: func(n int) {
+			l.mutex.Lock()
+			defer l.mutex.Unlock()
+			l.tokens = math.Min(float64(l.capacity), l.tokens+float64(n))
+		},
+	}
+	// 令牌是负数，等待补齐
+	if l.tokens < 0 {
+		r.timeToAct = now.Add(l.delay(-l.tokens))
+	}
+	return r
+}
+
+// 尝试获取n个令牌，失败返回令牌足够的时间
+func (l *TokenBucketLimiter) tryAcquireN(n int) (time.Duration, bool) {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 
 	// 尝试发放令牌
-	now := time.Now()
-	// 距离上次发放令牌的时间
-	interval := now.Sub(l.lastTime)
-	if interval >= time.Second {
-		// 当前令牌数量+距离上次发放令牌的时间(秒)*发放令牌速率
-		l.currentTokens = minInt(l.capacity, l.currentTokens+int(interval/time.Second)*l.rate)
-		l.lastTime = now
+	l.refill(l.now())
+
+	// 如果令牌不够，请求失败
+	if l.tokens < float64(n) {
+		return l.delay(float64(n) - l.tokens), false
 	}
+	// 如果令牌足够，当前令牌-n，请求成功
+	l.tokens -= float64(n)
+	return 0, true
+}
 
-	// 如果没有令牌，请求失败
-	if l.currentTokens == 0 {
-		return false
+// 按距离上次发放令牌的时间发放令牌
+func (l *TokenBucketLimiter) refill(now time.Time) {
+	interval := now.Sub(l.lastTime)
+	if interval <= 0 {
+		return
+	}
+	l.lastTime = now
+	// 不限速则直接装满
+	if math.IsInf(float64(l.rate), 1) {
+		l.tokens = float64(l.capacity)
+		return
 	}
-	// 如果有令牌，当前令牌-1，请求成功
-	l.currentTokens--
-	return true
+	// 当前令牌数量+距离上次发放令牌的时间(秒)*发放令牌速率
+	l.tokens = math.Min(float64(l.capacity), l.tokens+interval.Seconds()*float64(l.rate))
 }
 
-func minInt(a, b int) int {
-	if a < b {
-		return a
+// 还差n个令牌时需要等待的时间，不发放令牌返回InfDuration
+func (l *TokenBucketLimiter) delay(n float64) time.Duration {
+	if l.rate <= 0 {
+		return InfDuration
+	}
+	seconds := n / float64(l.rate)
+	if seconds >= InfDuration.Seconds() {
+		return InfDuration
 	}
-	return b
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
 }
@@ -1,6 +1,8 @@
 package limiter
 
 import (
+	"context"
+	"math"
 	"testing"
 	"time"
 )
@@ -46,10 +48,108 @@ func TestNewTokenBucketLimiter(t *testing.T) {
 				}
 				time.Sleep(time.Second / 10)
 			}
-			if successCount != tt.args.capacity-tt.args.rate {
-				t.Errorf("NewTokenBucketLimiter() got = %v, want %v", successCount, tt.args.capacity-tt.args.rate)
+			// 令牌连续发放，只有第一次没有令牌
+			if successCount != tt.args.capacity-1 {
+				t.Errorf("NewTokenBucketLimiter() got = %v, want %v", successCount, tt.args.capacity-1)
 				return
 			}
 		})
 	}
 }
+
+func TestTokenBucketLimiter_Reserve(t *testing.T) {
+	l := NewTokenBucketLimiter(2, 2)
+	now := l.lastTime
+	l.now = func() time.Time {
+		return now
+	}
+	if r := l.Reserve(3); r.OK() || r.Delay() != InfDuration {
+		t.Errorf("Reserve() = %v, %v, want %v, %v", r.OK(), r.Delay(), false, InfDuration)
+	}
+
+	// 令牌不够时先欠着，等待令牌补齐
+	r1 := l.Reserve(1)
+	if !r1.OK() || r1.Delay() != time.Millisecond*500 {
+		t.Errorf("Delay() = %v, want %v", r1.Delay(), time.Millisecond*500)
+	}
+	r2 := l.Reserve(2)
+	if !r2.OK() || r2.Delay() != time.Millisecond*1500 {
+		t.Errorf("Delay() = %v, want %v", r2.Delay(), time.Millisecond*1500)
+	}
+
+	// 取消归还令牌，只会归还一次
+	r2.Cancel()
+	r2.Cancel()
+	if l.tokens != -1 {
+		t.Errorf("tokens = %v, want %v", l.tokens, -1)
+	}
+	if l.Allow() {
+		t.Errorf("Allow() = %v, want %v", true, false)
+	}
+
+	// 过了执行时间的预留已经使用，取消不会归还
+	now = now.Add(time.Second)
+	if r1.Delay() != 0 {
+		t.Errorf("Delay() = %v, want %v", r1.Delay(), 0)
+	}
+	r1.Cancel()
+	if !l.Allow() || l.Allow() {
+		t.Errorf("Allow() = %v, want %v", true, false)
+	}
+}
+
+func TestTokenBucketLimiter_Every(t *testing.T) {
+	// 每10ms一个令牌，不会等到一秒后才发放
+	l := NewTokenBucketLimiterWithRate(1, Every(time.Millisecond*10))
+	time.Sleep(time.Millisecond * 20)
+	if !l.Allow() || l.Allow() {
+		t.Errorf("Allow() = %v, want %v", false, true)
+	}
+	time.Sleep(time.Millisecond * 20)
+	if !l.Allow() {
+		t.Errorf("Allow() = %v, want %v", false, true)
+	}
+
+	if rate := Per(5, time.Minute); rate != Rate(5.0/60) {
+		t.Errorf("Per() = %v, want %v", rate, 5.0/60)
+	}
+	if rate := Every(0); !math.IsInf(float64(rate), 1) {
+		t.Errorf("Every() = %v, want %v", rate, math.Inf(1))
+	}
+}
+
+func TestTokenBucketLimiter_SetRate(t *testing.T) {
+	l := NewTokenBucketLimiterWithRate(10, Every(time.Millisecond*10))
+	now := l.lastTime
+	l.now = func() time.Time {
+		return now
+	}
+	now = now.Add(time.Millisecond * 55)
+
+	// 修改速率不会丢失已经发放的令牌
+	l.SetRate(0)
+	if !l.AllowN(5) || l.Allow() {
+		t.Errorf("AllowN() = %v, want %v", false, true)
+	}
+	now = now.Add(time.Millisecond * 20)
+	if l.Allow() {
+		t.Errorf("Allow() = %v, want %v", true, false)
+	}
+	if r := l.Reserve(1); r.OK() {
+		t.Errorf("Reserve() = %v, want %v", true, false)
+	}
+}
+
+func TestTokenBucketLimiter_SetBurst(t *testing.T) {
+	l := NewTokenBucketLimiterWithRate(10, Every(time.Millisecond))
+	time.Sleep(time.Millisecond * 20)
+
+	// 超过新容量的令牌被丢弃
+	l.SetBurst(3)
+	if l.Burst() != 3 || !l.AllowN(3) || l.AllowN(3) {
+		t.Errorf("Burst() = %v, want %v", l.Burst(), 3)
+	}
+	if err := l.WaitN(context.Background(), 4); err != ErrExceedsLimit {
+		t.Errorf("WaitN() error = %v, want %v", err, ErrExceedsLimit)
+	}
+}
@@ -93,6 +93,7 @@ func (q *DelayQueue[T]) Take(ctx context.Context) (T, bool) {
 				}
 			case <-ctx.Done(): // 被关闭
 				timer.Stop()
+				q.stopSleeping()
 				var t T
 				return t, false
 			}
@@ -100,6 +101,7 @@ func (q *DelayQueue[T]) Take(ctx context.Context) (T, bool) {
 			select {
 			case <-q.wakeup: // 新的更快到期元素
 			case <-ctx.Done(): // 被关闭
+				q.stopSleeping()
 				var t T
 				return t, false
 			}
@@ -107,6 +109,14 @@ func (q *DelayQueue[T]) Take(ctx context.Context) (T, bool) {
 	}
 }
 
+// 结束等待，避免Take()退出后Push()阻塞在q.wakeup
+func (q *DelayQueue[T]) stopSleeping() {
+	// 设置为0，如果原来也为0表示有Push()正在q.wakeup被阻塞
+	if atomic.SwapInt32(&q.sleeping, 0) == 0 {
+		<-q.wakeup
+	}
+}
+
 // 返回一个通道，输出到期元素
 // size是通道缓存大小
 func (q *DelayQueue[T]) Channel(ctx context.Context, size int) <-chan T {
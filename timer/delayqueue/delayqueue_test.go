@@ -24,6 +24,26 @@ func TestDelayQueue(t *testing.T) {
 	}
 }
 
+func TestDelayQueue_PushAfterTakeCanceled(t *testing.T) {
+	q := New[int]()
+	ctx, cancel := context.WithCancel(context.Background())
+	go cancel()
+	if _, ok := q.Take(ctx); ok {
+		t.Errorf("want %v, but %v", false, ok)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		q.Push(1, time.Millisecond)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("Push() blocked after Take() canceled")
+	}
+}
+
 func BenchmarkPushAndTake(b *testing.B) {
 	q := New[int]()
 	b.ResetTimer()